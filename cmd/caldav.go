@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tcooper-uk/go-todo/internal/caldav"
+)
+
+// serveCalDAV Serve the current backend as a CalDAV task list on addr until
+// interrupted. The names and UIDs that apps give new items are kept in
+// ~/.todo, one file per backend.
+func serveCalDAV(ctx context.Context, current backend, addr, token string) error {
+	statePath := filepath.Join(os.Getenv("HOME"), ".todo", fmt.Sprintf("caldav-%s.json", current.name()))
+	handler, err := caldav.New(current.store, caldav.Options{Token: token, StatePath: statePath})
+	if err != nil {
+		return err
+	}
+	return listenAndServe(ctx, handler, addr, token, "Serving CalDAV on http://%s (tasks at /calendars/todo/)")
+}
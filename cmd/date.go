@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal/dateparse"
+)
+
+// dateCommand Run todo date: show the date an expression stands for, as
+// --due and filters would read it now.
+func dateCommand(args []string) {
+	if len(args) == 0 {
+		fail("Usage: todo date <expression>, e.g. %s", dateparse.Examples)
+	}
+	expr := strings.Join(args, " ")
+
+	now := time.Now()
+	d, err := dateparse.Parse(expr, now)
+	if err != nil {
+		fail("Invalid date %q — use e.g. %s", expr, dateparse.Examples)
+	}
+
+	if d.HasTime {
+		fmt.Printf("%s (%s)\n", d.Time.Format("Mon 02 Jan 2006 15:04 MST"), fromToday(d.Time, now))
+	} else {
+		fmt.Printf("%s (%s)\n", d.Time.Format("Mon 02 Jan 2006"), fromToday(d.Time, now))
+	}
+}
+
+// fromToday How many days t is from now's day, e.g. "in 3 days".
+func fromToday(t, now time.Time) string {
+	y, m, d := t.Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	y, m, d = now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	switch n := int(day.Sub(today).Hours() / 24); {
+	case n == 0:
+		return "today"
+	case n == 1:
+		return "tomorrow"
+	case n == -1:
+		return "yesterday"
+	case n > 0:
+		return fmt.Sprintf("in %d days", n)
+	default:
+		return fmt.Sprintf("%d days ago", -n)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal/dateparse"
+	"github.com/tcooper-uk/go-todo/internal/filter"
+	s "github.com/tcooper-uk/go-todo/internal/storage"
+)
+
+// currentUser Who the changes made by this command are recorded as made by.
+func currentUser() string {
+	for _, name := range []string{"USER", "USERNAME"} {
+		if user := os.Getenv(name); user != "" {
+			return user
+		}
+	}
+	return ""
+}
+
+// printHistory List the changes made to the item with id, oldest first.
+func printHistory(ctx context.Context, store s.TodoStore, id int) error {
+	changes, err := s.ItemHistory(ctx, store, id)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		if err := requireExists(ctx, store, id); err != nil {
+			return err
+		}
+		fmt.Printf("No changes recorded for item %d.\n", id)
+		return nil
+	}
+	printChanges(changes, false)
+	return nil
+}
+
+// requireExists Fail with ErrNotFound unless an item with id is in the
+// store or its trash.
+func requireExists(ctx context.Context, store s.TodoStore, id int) error {
+	trashed, err := store.GetAllItems(ctx, s.ListOptions{
+		Trashed:  true,
+		ShowDone: true,
+		Filter:   filter.Cond{Field: filter.FieldID, Op: filter.OpEqual, Value: id},
+	})
+	if err != nil {
+		return err
+	}
+	if len(trashed.Items) > 0 {
+		return nil
+	}
+	_, err = store.GetItem(ctx, id)
+	return err
+}
+
+// printLog List the changes made to any item at or after since, oldest
+// first.
+func printLog(ctx context.Context, store s.TodoStore, since time.Time) error {
+	changes, err := s.ChangesSince(ctx, store, since)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Printf("No changes since %s.\n", since.Local().Format("Mon 02 Jan 06 15:04"))
+		return nil
+	}
+	printChanges(changes, true)
+	return nil
+}
+
+// printChanges One line per change, with the item it was made to if
+// withID is set.
+func printChanges(changes []s.FieldChange, withID bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := "When\tWho\tField\tChange"
+	if withID {
+		header = "ID\t" + header
+	}
+	fmt.Fprintln(w, header)
+	for _, c := range changes {
+		line := fmt.Sprintf("%s\t%s\t%s\t%s → %s",
+			c.At.Local().Format("Mon 02 Jan 06 15:04"), c.Actor, c.Field, changeValue(c.Old), changeValue(c.New))
+		if withID {
+			line = fmt.Sprintf("[%d]\t%s", c.ItemID, line)
+		}
+		fmt.Fprintln(w, line)
+	}
+	w.Flush()
+}
+
+// changeValue A recorded value as one line, with - for none.
+func changeValue(v string) string {
+	if v == "" {
+		return "-"
+	}
+	if i := strings.IndexByte(v, '\n'); i != -1 {
+		return v[:i] + " …"
+	}
+	return v
+}
+
+// parseSince The time --since names: a length of time before now, as
+// parseAge takes, or a date or time, e.g. 2026-10-01, yesterday or "last
+// mon 9am", from the start of the day if no time is given.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if age, err := parseAge(value); err == nil {
+		return now.Add(-age), nil
+	}
+	d, err := dateparse.Parse(value, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return d.Time, nil
+}
@@ -1,11 +1,22 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
 )
 
 var todoBin string
@@ -43,6 +54,26 @@ func run(t *testing.T, homeDir string, args ...string) (stdout, stderr string, o
 	return outBuf.String(), errBuf.String(), err == nil
 }
 
+// runStatus invokes the todo binary like run and returns its exit status
+// along with stderr.
+func runStatus(t *testing.T, homeDir string, args ...string) (int, string) {
+	t.Helper()
+	cmd := exec.Command(todoBin, args...)
+	cmd.Env = append(os.Environ(), "HOME="+homeDir, "TODO_BACKEND=sqlite")
+
+	var errBuf strings.Builder
+	cmd.Stderr = &errBuf
+	err := cmd.Run()
+	if err == nil {
+		return 0, errBuf.String()
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("%v did not run: %v", args, err)
+	}
+	return exitErr.ExitCode(), errBuf.String()
+}
+
 // tempHome creates a temporary directory to use as HOME for one test.
 func tempHome(t *testing.T) string {
 	t.Helper()
@@ -105,6 +136,70 @@ func TestAdd_WithTag(t *testing.T) {
 	}
 }
 
+func TestAdd_NaturalLanguageDueDate(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "--due", "in 3 days", "Soon task")
+	mustRun(t, home, "add", "--due", "tomorrow", "Tomorrow task")
+
+	want := time.Now().AddDate(0, 0, 3).Format("02 Jan 06")
+	if out := mustRun(t, home, "list"); !strings.Contains(out, want) {
+		t.Errorf("expected due date %s in output, got:\n%s", want, out)
+	}
+	out := mustRun(t, home, "list", `due<"in 2 days"`)
+	if !strings.Contains(out, "Tomorrow task") || strings.Contains(out, "Soon task") {
+		t.Errorf("expected only the item due tomorrow, got:\n%s", out)
+	}
+
+	code, stderr := runStatus(t, home, "add", "--due", "someday", "Vague task")
+	if code != exitError || !strings.Contains(stderr, `Invalid date "someday"`) {
+		t.Errorf("expected an unreadable date to be refused, got exit %d: %s", code, stderr)
+	}
+}
+
+func TestAdd_DeadlineKeepsItsTime(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "--due", "tomorrow 9am", "Call the bank")
+	mustRun(t, home, "add", "--due", "today", "Due today")
+	mustRun(t, home, "add", "--due", "-1h", "Missed call")
+
+	if out := mustRun(t, home, "show", "1"); !strings.Contains(out, "09:00") {
+		t.Errorf("expected the deadline's time in show, got:\n%s", out)
+	}
+	out := mustRun(t, home, "--output", "json", "--fields", "due_timed", "list")
+	if out != "[\n  {\n    \"due_timed\": true\n  },\n  {\n    \"due_timed\": false\n  },\n  {\n    \"due_timed\": true\n  }\n]\n" {
+		t.Errorf("expected only the all-day item not to be timed, got:\n%s", out)
+	}
+
+	out = mustRun(t, home, "list", "--overdue")
+	if !strings.Contains(out, "Missed call") || strings.Contains(out, "Due today") {
+		t.Errorf("expected a passed deadline but not today's item to be overdue, got:\n%s", out)
+	}
+
+	mustRun(t, home, "edit", "1", "--due", "-")
+	if out := mustRun(t, home, "--output", "json", "--fields", "due_date,due_timed,due_zone", "show", "1"); strings.Contains(out, "true") || strings.Contains(out, "/") {
+		t.Errorf("expected clearing the due date to clear its time and zone, got:\n%s", out)
+	}
+}
+
+// --- date ---
+
+func TestDate_ShowsWhatAnExpressionMeans(t *testing.T) {
+	home := tempHome(t)
+
+	want := time.Now().AddDate(0, 0, 14).Format("Mon 02 Jan 2006")
+	if out := mustRun(t, home, "date", "+2w"); out != want+" (in 14 days)\n" {
+		t.Errorf("expected %s (in 14 days), got:\n%s", want, out)
+	}
+	if out := mustRun(t, home, "date", "tomorrow", "3pm"); !strings.Contains(out, "15:00") || !strings.Contains(out, "(tomorrow)") {
+		t.Errorf("expected tomorrow at 15:00, got:\n%s", out)
+	}
+
+	code, stderr := runStatus(t, home, "date", "the", "twelfth", "of", "never")
+	if code != exitError || !strings.Contains(stderr, "Invalid date") {
+		t.Errorf("expected an unreadable date to be refused, got exit %d: %s", code, stderr)
+	}
+}
+
 // --- list ---
 
 func TestList_HidesDoneByDefault(t *testing.T) {
@@ -177,6 +272,91 @@ func TestList_FilterByTag(t *testing.T) {
 	}
 }
 
+func TestList_FilterByEveryTag(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "--tag", "work", "Work task")
+	mustRun(t, home, "add", "--tag", "work", "--tag", "urgent", "Urgent work task")
+
+	out := mustRun(t, home, "list", "--tag", "work", "--tag", "urgent")
+	if !strings.Contains(out, "Urgent work task") || strings.Contains(out, "Work task") {
+		t.Errorf("expected only the item with both tags, got:\n%s", out)
+	}
+}
+
+func TestList_FilterExpression(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "--tag", "work", "--priority", "high", "Report")
+	mustRun(t, home, "add", "--tag", "work", "--tag", "blocked", "--priority", "high", "Blocked task")
+	mustRun(t, home, "add", "--tag", "work", "--due", "2026-10-30", "Email")
+	mustRun(t, home, "add", "--tag", "home", "--priority", "high", "Garden")
+
+	out := mustRun(t, home, "list", "tag:work and (priority:high or due<2026-11-01) and not tag:blocked")
+	for _, want := range []string{"Report", "Email"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in filtered list, got:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"Blocked task", "Garden"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("expected %q to be filtered out, got:\n%s", unwanted, out)
+		}
+	}
+}
+
+func TestList_FilterOnDoneShowsDoneItems(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "Open task")
+	mustRun(t, home, "add", "Finished task")
+	mustRun(t, home, "done", "2")
+
+	out := mustRun(t, home, "list", "done:true")
+	if !strings.Contains(out, "Finished task") || strings.Contains(out, "Open task") {
+		t.Errorf("expected only the done item, got:\n%s", out)
+	}
+}
+
+func TestList_InvalidFilterPointsAtColumn(t *testing.T) {
+	home := tempHome(t)
+	code, stderr := runStatus(t, home, "list", "tag:work and prio:high")
+	if code != 1 {
+		t.Errorf("expected exit status 1, got %d", code)
+	}
+	if !strings.Contains(stderr, "column 14") || !strings.Contains(stderr, "\n  "+strings.Repeat(" ", 13)+"^") {
+		t.Errorf("expected the error to point at column 14, got:\n%s", stderr)
+	}
+}
+
+func TestList_SortAndPage(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "--priority", "low", "--due", "2026-11-03", "Low task")
+	mustRun(t, home, "add", "--priority", "high", "--due", "2026-11-05", "High task")
+	mustRun(t, home, "add", "--priority", "medium", "--due", "2026-11-01", "Medium task")
+
+	stdout, stderr, ok := run(t, home, "list", "--sort", "-priority", "--limit", "2")
+	if !ok {
+		t.Fatalf("list failed: %s", stderr)
+	}
+	high, medium := strings.Index(stdout, "High task"), strings.Index(stdout, "Medium task")
+	if high == -1 || medium == -1 || high > medium || strings.Contains(stdout, "Low task") {
+		t.Errorf("expected High then Medium on the first page, got:\n%s", stdout)
+	}
+	if !strings.Contains(stderr, "--cursor 2") {
+		t.Errorf("expected a cursor for the next page, got:\n%s", stderr)
+	}
+
+	stdout, stderr, _ = run(t, home, "list", "--sort", "-priority", "--limit", "2", "--cursor", "2")
+	if !strings.Contains(stdout, "Low task") || strings.Contains(stdout, "High task") || stderr != "" {
+		t.Errorf("expected only Low on the last page, got:\n%s%s", stdout, stderr)
+	}
+}
+
+func TestList_InvalidSortField(t *testing.T) {
+	home := tempHome(t)
+	if _, _, ok := run(t, home, "list", "--sort", "urgency"); ok {
+		t.Error("expected non-zero exit for an unknown sort field")
+	}
+}
+
 func TestList_Overdue(t *testing.T) {
 	home := tempHome(t)
 	mustRun(t, home, "add", "--due", "2020-01-01", "Overdue task")
@@ -301,6 +481,43 @@ func TestReopen_MarksOpen(t *testing.T) {
 	}
 }
 
+// --- snooze ---
+
+func TestSnooze_HidesUntilThen(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "Renew passport")
+	mustRun(t, home, "add", "--start", "next month", "Book flights")
+	out := mustRun(t, home, "snooze", "1", "3d")
+	want := time.Now().AddDate(0, 0, 3).Format("Mon 02 Jan 06")
+	if !strings.Contains(out, "Snoozed [1] until "+want) {
+		t.Errorf("expected the item to be snoozed until %s, got:\n%s", want, out)
+	}
+
+	if out := mustRun(t, home, "list"); strings.Contains(out, "Renew passport") || strings.Contains(out, "Book flights") {
+		t.Errorf("deferred items should be hidden from the default list, got:\n%s", out)
+	}
+	out = mustRun(t, home, "list", "--include-deferred")
+	if !strings.Contains(out, "Renew passport") || !strings.Contains(out, "⏸") {
+		t.Errorf("expected deferred items with --include-deferred, got:\n%s", out)
+	}
+	if out := mustRun(t, home, "show", "1"); !strings.Contains(out, "Starts:") {
+		t.Errorf("expected the start date in show, got:\n%s", out)
+	}
+
+	mustRun(t, home, "edit", "1", "--start", "-")
+	if out := mustRun(t, home, "list"); !strings.Contains(out, "Renew passport") {
+		t.Errorf("expected the item back once its start date is cleared, got:\n%s", out)
+	}
+
+	code, stderr := runStatus(t, home, "snooze", "1", "someday")
+	if code != exitError || !strings.Contains(stderr, `Invalid start "someday"`) {
+		t.Errorf("expected an unreadable start to be refused, got exit %d: %s", code, stderr)
+	}
+	if code, _ := runStatus(t, home, "snooze", "42", "1d"); code != exitNotFound {
+		t.Errorf("expected snoozing a missing item to exit %d, got %d", exitNotFound, code)
+	}
+}
+
 // --- delete ---
 
 func TestDelete_Single(t *testing.T) {
@@ -330,6 +547,513 @@ func TestDelete_Multiple(t *testing.T) {
 	}
 }
 
+// --- trash ---
+
+func TestTrash_RestoreWithSubtasks(t *testing.T) {
+	for _, backend := range []string{"sqlite", "file"} {
+		t.Run(backend, func(t *testing.T) {
+			home := tempHome(t)
+			mustRun(t, home, "--backend", backend, "add", "Plan the party")
+			mustRun(t, home, "--backend", backend, "add", "--parent", "1", "Book the venue")
+			mustRun(t, home, "--backend", backend, "rm", "--cascade", "1")
+
+			out := mustRun(t, home, "--backend", backend, "trash")
+			if !strings.Contains(out, "Deleted") || !strings.Contains(out, "Plan the party") || !strings.Contains(out, "Book the venue") {
+				t.Errorf("expected both items in the trash, got:\n%s", out)
+			}
+
+			mustRun(t, home, "--backend", backend, "restore", "1")
+			out = mustRun(t, home, "--backend", backend, "list")
+			if !strings.Contains(out, "[1]") || !strings.Contains(out, "[2]") || !strings.Contains(out, "└ Book the venue") {
+				t.Errorf("expected the item and its subtask back with their IDs, got:\n%s", out)
+			}
+			if out := mustRun(t, home, "--backend", backend, "trash"); strings.Contains(out, "Plan the party") {
+				t.Errorf("expected the trash to be empty, got:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestTrash_Empty(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "Old idea")
+	mustRun(t, home, "rm", "1")
+
+	if out := mustRun(t, home, "trash", "empty", "--older-than", "30d"); !strings.Contains(out, "Deleted 0 item(s)") {
+		t.Errorf("expected nothing old enough to purge, got:\n%s", out)
+	}
+	if out := mustRun(t, home, "trash", "empty"); !strings.Contains(out, "Deleted 1 item(s)") {
+		t.Errorf("expected the item purged, got:\n%s", out)
+	}
+	code, _ := runStatus(t, home, "restore", "1")
+	if code != exitNotFound {
+		t.Errorf("expected a purged item to be gone for good, got exit %d", code)
+	}
+
+	code, stderr := runStatus(t, home, "trash", "empty", "--older-than", "soon")
+	if code != exitError || !strings.Contains(stderr, "Invalid --older-than") {
+		t.Errorf("expected an invalid age to be refused, got exit %d: %s", code, stderr)
+	}
+}
+
+func TestClearall_Permanent(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "First")
+	mustRun(t, home, "add", "Second")
+	mustRun(t, home, "rm", "2")
+
+	mustRun(t, home, "clearall", "--yes")
+	if out := mustRun(t, home, "trash"); !strings.Contains(out, "First") {
+		t.Errorf("expected clearall to move items to the trash, got:\n%s", out)
+	}
+
+	mustRun(t, home, "clearall", "--yes", "--permanent")
+	if out := mustRun(t, home, "trash"); strings.Contains(out, "First") || strings.Contains(out, "Second") {
+		t.Errorf("expected clearall --permanent to empty the trash, got:\n%s", out)
+	}
+}
+
+// --- history ---
+
+func TestHistory_FieldChangesOfAnItem(t *testing.T) {
+	for _, backend := range []string{"sqlite", "file"} {
+		t.Run(backend, func(t *testing.T) {
+			home := tempHome(t)
+			mustRun(t, home, "--backend", backend, "add", "--priority", "low", "--due", "2026-11-01", "Plan the party")
+			mustRun(t, home, "--backend", backend, "edit", "1", "--priority", "high", "--due", "2026-11-05")
+			mustRun(t, home, "--backend", backend, "rm", "1")
+
+			out := mustRun(t, home, "--backend", backend, "history", "1")
+			for _, want := range []string{"name", "- → Plan the party", "low → high", "2026-11-01 → 2026-11-05", "deleted_at"} {
+				if !strings.Contains(out, want) {
+					t.Errorf("expected history to contain %q, got:\n%s", want, out)
+				}
+			}
+			if user := os.Getenv("USER"); user != "" && !strings.Contains(out, user) {
+				t.Errorf("expected changes made by %s, got:\n%s", user, out)
+			}
+		})
+	}
+}
+
+func TestHistory_MissingItem(t *testing.T) {
+	home := tempHome(t)
+	code, _ := runStatus(t, home, "history", "9")
+	if code != exitNotFound {
+		t.Errorf("expected exit %d for a missing item, got %d", exitNotFound, code)
+	}
+}
+
+func TestLog_Since(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "First")
+	mustRun(t, home, "add", "Second")
+	mustRun(t, home, "done", "2")
+
+	out := mustRun(t, home, "log", "--since", "7d")
+	if !strings.Contains(out, "[1]") || !strings.Contains(out, "[2]") || !strings.Contains(out, "- → true") {
+		t.Errorf("expected the changes to both items, got:\n%s", out)
+	}
+	if out := mustRun(t, home, "log", "--since", "2099-01-01"); !strings.Contains(out, "No changes since") {
+		t.Errorf("expected no changes in the future, got:\n%s", out)
+	}
+	if out := mustRun(t, home, "log", "--since", "yesterday"); !strings.Contains(out, "[2]") {
+		t.Errorf("expected the changes since yesterday, got:\n%s", out)
+	}
+
+	code, stderr := runStatus(t, home, "log", "--since", "lately")
+	if code != exitError || !strings.Contains(stderr, "Invalid --since") {
+		t.Errorf("expected an invalid --since to be refused, got exit %d: %s", code, stderr)
+	}
+}
+
+// --- tui ---
+
+func TestTui_NeedsATerminal(t *testing.T) {
+	home := tempHome(t)
+	code, stderr := runStatus(t, home, "tui")
+	if code != exitError || !strings.Contains(stderr, "needs a terminal") {
+		t.Errorf("expected todo tui to refuse to run without a terminal, got exit %d: %s", code, stderr)
+	}
+}
+
+// --- projects ---
+
+func TestProject_AddListAndFilter(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "project", "add", "work/clients")
+	mustRun(t, home, "project", "add", "home")
+	mustRun(t, home, "add", "--project", "clients", "Call Acme")
+	mustRun(t, home, "add", "--project", "work", "Plan Q4")
+	mustRun(t, home, "add", "Loose end")
+	mustRun(t, home, "done", "2")
+
+	out := mustRun(t, home, "project", "list")
+	for _, want := range []string{"[1]\t1\t1\twork", "[2]\t1\t0\t  clients", "[3]\t0\t0\thome", "\t1\t0\t(no project)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in project list, got:\n%s", want, out)
+		}
+	}
+
+	out = mustRun(t, home, "list", "--all", "--project", "work")
+	if !strings.Contains(out, "Call Acme") || !strings.Contains(out, "Plan Q4") || strings.Contains(out, "Loose end") {
+		t.Errorf("expected the items in work and its sub-projects, got:\n%s", out)
+	}
+	if !strings.Contains(out, "+work/clients") {
+		t.Errorf("expected the project path in the list, got:\n%s", out)
+	}
+
+	code, _ := runStatus(t, home, "list", "--project", "garden")
+	if code != exitNotFound {
+		t.Errorf("expected exit %d for a missing project, got %d", exitNotFound, code)
+	}
+}
+
+func TestProject_RenameAndArchive(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "project", "add", "work/clients")
+	mustRun(t, home, "add", "--project", "clients", "Call Acme")
+	mustRun(t, home, "project", "rename", "clients", "customers")
+	if out := mustRun(t, home, "show", "1"); !strings.Contains(out, "work/customers") {
+		t.Errorf("expected the renamed project on the item, got:\n%s", out)
+	}
+
+	mustRun(t, home, "project", "archive", "work")
+	code, _ := runStatus(t, home, "add", "--project", "customers", "Call Initech")
+	if code != exitConflict {
+		t.Errorf("expected exit %d adding to an archived project, got %d", exitConflict, code)
+	}
+	if out := mustRun(t, home, "project", "list"); strings.Contains(out, "customers") {
+		t.Errorf("expected archived projects to be hidden, got:\n%s", out)
+	}
+	if out := mustRun(t, home, "project", "list", "--all"); !strings.Contains(out, "customers (archived)") {
+		t.Errorf("expected archived projects with --all, got:\n%s", out)
+	}
+
+	mustRun(t, home, "project", "unarchive", "work")
+	mustRun(t, home, "add", "--project", "customers", "Call Initech")
+}
+
+func TestProject_DefaultAndEdit(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "project", "add", "home")
+	mustRun(t, home, "project", "default", "home")
+	mustRun(t, home, "add", "Fix the sink")
+	mustRun(t, home, "add", "--project", "-", "Anywhere")
+
+	out := mustRun(t, home, "--output", "csv", "--fields", "id,project", "list")
+	if out != "id,project\n1,1\n2,0\n" {
+		t.Errorf("expected only the first item in the default project, got:\n%s", out)
+	}
+
+	mustRun(t, home, "edit", "1", "--project", "-")
+	mustRun(t, home, "edit", "2", "--project", "home")
+	out = mustRun(t, home, "--output", "csv", "--fields", "id,project", "list")
+	if out != "id,project\n1,0\n2,1\n" {
+		t.Errorf("expected the items to swap projects, got:\n%s", out)
+	}
+
+	mustRun(t, home, "project", "default", "-")
+	mustRun(t, home, "add", "Elsewhere")
+	if out := mustRun(t, home, "show", "3"); strings.Contains(out, "Project:") {
+		t.Errorf("expected no project once the default is cleared, got:\n%s", out)
+	}
+}
+
+// --- undo / redo ---
+
+func TestUndo_DeleteKeepsIdAndTimes(t *testing.T) {
+	for _, backend := range []string{"sqlite", "file"} {
+		t.Run(backend, func(t *testing.T) {
+			home := tempHome(t)
+			mustRun(t, home, "--backend", backend, "add", "First")
+			mustRun(t, home, "--backend", backend, "add", "Second")
+			before := mustRun(t, home, "--backend", backend, "--output", "json", "show", "2")
+			mustRun(t, home, "--backend", backend, "delete", "2")
+
+			out := mustRun(t, home, "--backend", backend, "undo")
+			if !strings.Contains(out, `Undid "delete 2"`) {
+				t.Errorf("expected the undone command, got:\n%s", out)
+			}
+			after := mustRun(t, home, "--backend", backend, "--output", "json", "show", "2")
+			if after != before {
+				t.Errorf("expected item 2 back as it was\nwant: %s\ngot:  %s", before, after)
+			}
+
+			mustRun(t, home, "--backend", backend, "redo")
+			if code, _ := runStatus(t, home, "--backend", backend, "show", "2"); code != exitNotFound {
+				t.Errorf("expected item 2 deleted again, got exit %d", code)
+			}
+		})
+	}
+}
+
+func TestUndo_SeveralAndRefusesToLoseChanges(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "Buy milk")
+	mustRun(t, home, "edit", "1", "--name", "Buy oat milk")
+	mustRun(t, home, "edit", "1", "--priority", "high")
+
+	list := mustRun(t, home, "undo", "--list")
+	if !strings.Contains(list, `"edit 1 --priority high"`) {
+		t.Errorf("expected the operations listed, got:\n%s", list)
+	}
+	mustRun(t, home, "undo", "2")
+	out := mustRun(t, home, "show", "1")
+	if !strings.Contains(out, "Buy milk") || strings.Contains(out, "high") {
+		t.Errorf("expected both edits undone, got:\n%s", out)
+	}
+
+	// A change made since would be lost, so the undone edits can't be redone.
+	mustRun(t, home, "edit", "1", "--name", "Buy soya milk")
+	out = mustRun(t, home, "redo")
+	if !strings.Contains(out, "Nothing to redo") {
+		t.Errorf("expected a new command to clear what could be redone, got:\n%s", out)
+	}
+	mustRun(t, home, "undo", "2")
+	out = mustRun(t, home, "list")
+	if strings.Contains(out, "milk") {
+		t.Errorf("expected the add undone too, got:\n%s", out)
+	}
+
+	code, stderr := runStatus(t, home, "undo", "0")
+	if code != exitError || !strings.Contains(stderr, "Invalid count") {
+		t.Errorf("expected an invalid count to fail, got exit %d: %s", code, stderr)
+	}
+}
+
+func TestClearall_AsksFirstAndCanBeUndone(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "First")
+	mustRun(t, home, "add", "Second")
+
+	// With no answer on stdin, nothing is deleted.
+	code, stderr := runStatus(t, home, "clearall")
+	if code != exitError || !strings.Contains(stderr, "Nothing deleted") {
+		t.Errorf("expected clearall to be refused, got exit %d: %s", code, stderr)
+	}
+
+	mustRun(t, home, "clearall", "--yes")
+	if out := mustRun(t, home, "list"); strings.Contains(out, "First") {
+		t.Errorf("expected every item deleted, got:\n%s", out)
+	}
+	mustRun(t, home, "undo")
+	out := mustRun(t, home, "list")
+	if !strings.Contains(out, "[1]") || !strings.Contains(out, "[2]\t") || !strings.Contains(out, "Second") {
+		t.Errorf("expected both items back with their IDs, got:\n%s", out)
+	}
+}
+
+// --- recurring ---
+
+func TestRepeat_DoneAddsNextOccurrence(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "--due", "2099-01-02", "--repeat", "weekly", "Weekly review")
+	mustRun(t, home, "done", "1")
+
+	out := mustRun(t, home, "list")
+	if !strings.Contains(out, "Weekly review") || !strings.Contains(out, "09 Jan 99") {
+		t.Errorf("expected next occurrence due a week later, got:\n%s", out)
+	}
+
+	// Completing it again must not duplicate the already-spawned item.
+	mustRun(t, home, "done", "1")
+	out = mustRun(t, home, "list", "--all")
+	if n := strings.Count(out, "Weekly review"); n != 2 {
+		t.Errorf("expected exactly 2 occurrences, got %d:\n%s", n, out)
+	}
+}
+
+func TestRepeat_InvalidRule(t *testing.T) {
+	home := tempHome(t)
+	_, _, ok := run(t, home, "add", "--repeat", "now and then", "Something")
+	if ok {
+		t.Error("expected non-zero exit for an invalid --repeat rule")
+	}
+}
+
+// --- output formats ---
+
+func TestOutput_JSONList(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "--tag", "work", "--due", "2026-11-01", "First")
+	mustRun(t, home, "add", "Second")
+
+	out := mustRun(t, home, "--output", "json", "list")
+	var items []map[string]any
+	if err := json.Unmarshal([]byte(out), &items); err != nil {
+		t.Fatalf("expected a JSON array, got %v:\n%s", err, out)
+	}
+	if len(items) != 2 || items[0]["name"] != "First" || items[0]["due_date"] != "2026-11-01T00:00:00Z" {
+		t.Errorf("unexpected items: %v", items)
+	}
+	if items[1]["due_date"] != nil || len(items[1]["tags"].([]any)) != 0 {
+		t.Errorf("expected empty fields to be present, got: %v", items[1])
+	}
+
+	// Fields keep their documented order.
+	if strings.Index(out, `"id"`) > strings.Index(out, `"name"`) || strings.Index(out, `"tags"`) > strings.Index(out, `"created_at"`) {
+		t.Errorf("expected fields in a stable order, got:\n%s", out)
+	}
+}
+
+func TestOutput_ShowWithFields(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "--tag", "a", "Item")
+
+	out := mustRun(t, home, "--output", "json", "--fields", "name,tags", "show", "1")
+	if strings.TrimSpace(out) != "{\n  \"name\": \"Item\",\n  \"tags\": [\n    \"a\"\n  ]\n}" {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	out = mustRun(t, home, "--output", "ndjson", "--fields", "id,name", "1")
+	if out != "{\"id\":1,\"name\":\"Item\"}\n" {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
+
+func TestOutput_YAML(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "Item")
+
+	out := mustRun(t, home, "--output", "yaml", "--fields", "id,name,due_date", "list")
+	if out != "- id: 1\n  name: Item\n  due_date: null\n" {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
+
+func TestOutput_CSVAndTSV(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "--tag", "a", "--tag", "b", "Comma, and\nnewline")
+
+	out := mustRun(t, home, "--output", "csv", "--fields", "id,name,tags", "list")
+	rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v\n%s", err, out)
+	}
+	want := [][]string{{"id", "name", "tags"}, {"1", "Comma, and\nnewline", "a,b"}}
+	if len(rows) != 2 || strings.Join(rows[0], "|") != strings.Join(want[0], "|") || strings.Join(rows[1], "|") != strings.Join(want[1], "|") {
+		t.Errorf("expected %q, got %q", want, rows)
+	}
+
+	out = mustRun(t, home, "--output", "tsv", "--fields", "id,name", "list")
+	if out != "id\tname\n1\tComma, and\\nnewline\n" {
+		t.Errorf("unexpected output:\n%q", out)
+	}
+}
+
+func TestOutput_Template(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "--due", "2026-11-01", "--tag", "x", "--tag", "y", "Item")
+
+	out := mustRun(t, home, "--format", "{{.ID}} {{.Name}} {{date .DueDate}} {{join .Tags \"+\"}}", "list")
+	if out != "1 Item 2026-11-01 x+y\n" {
+		t.Errorf("unexpected output:\n%q", out)
+	}
+}
+
+func TestOutput_InvalidOptions(t *testing.T) {
+	home := tempHome(t)
+	for _, args := range [][]string{
+		{"--output", "xml", "list"},
+		{"--output", "json", "--fields", "id,colour", "list"},
+		{"--fields", "id", "list"},
+		{"--format", "{{.ID", "list"},
+		{"--output", "csv", "--format", "{{.ID}}", "list"},
+	} {
+		if _, _, ok := run(t, home, args...); ok {
+			t.Errorf("expected %v to fail", args)
+		}
+	}
+}
+
+// --- search ---
+
+func TestSearch_FindsMatchingItems(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "Buy milk")
+	mustRun(t, home, "add", "--tag", "groceries", "Buy bread")
+	mustRun(t, home, "add", "Write report")
+
+	out := mustRun(t, home, "search", "buy", "NOT", "bread")
+	if !strings.Contains(out, "milk") || strings.Contains(out, "bread") || strings.Contains(out, "report") {
+		t.Errorf("expected only the milk item, got:\n%s", out)
+	}
+
+	out = mustRun(t, home, "search", "groceries")
+	if !strings.Contains(out, "[2]") {
+		t.Errorf("expected a match on the tag, got:\n%s", out)
+	}
+
+	out = mustRun(t, home, "search", "--", "-milk")
+	if strings.Contains(out, "milk") || !strings.Contains(out, "report") {
+		t.Errorf("expected everything but the milk item, got:\n%s", out)
+	}
+}
+
+func TestSearch_InvalidQuery(t *testing.T) {
+	home := tempHome(t)
+	_, _, ok := run(t, home, "search", `"unterminated`)
+	if ok {
+		t.Error("expected non-zero exit for an invalid query")
+	}
+}
+
+// --- subtasks ---
+
+func TestSubtask_ListedUnderParent(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "Parent task")
+	mustRun(t, home, "add", "Other task")
+	mustRun(t, home, "add", "--parent", "1", "Child task")
+
+	out := mustRun(t, home, "list")
+	parent := strings.Index(out, "Parent task")
+	child := strings.Index(out, "└ Child task")
+	other := strings.Index(out, "Other task")
+	if parent == -1 || child == -1 || other == -1 {
+		t.Fatalf("expected all items with the child indented, got:\n%s", out)
+	}
+	if !(parent < child && child < other) {
+		t.Errorf("expected child directly under its parent, got:\n%s", out)
+	}
+}
+
+func TestSubtask_DeleteParentRefusedWithoutCascade(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "Parent task")
+	mustRun(t, home, "add", "--parent", "1", "Child task")
+
+	code, _ := runStatus(t, home, "delete", "1")
+	if code != 4 {
+		t.Errorf("expected exit status 4 deleting a parent, got %d", code)
+	}
+
+	mustRun(t, home, "delete", "1", "--cascade")
+	out := mustRun(t, home, "list", "--all")
+	if strings.Contains(out, "Parent task") || strings.Contains(out, "Child task") {
+		t.Errorf("expected parent and child to be deleted, got:\n%s", out)
+	}
+}
+
+func TestSubtask_DoneCascade(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "Parent task")
+	mustRun(t, home, "add", "--parent", "1", "Child task")
+
+	mustRun(t, home, "done", "1")
+	out := mustRun(t, home, "list")
+	if !strings.Contains(out, "Child task") {
+		t.Errorf("child should stay open without --cascade, got:\n%s", out)
+	}
+
+	mustRun(t, home, "done", "1", "--cascade")
+	out = mustRun(t, home, "list")
+	if strings.Contains(out, "Child task") {
+		t.Errorf("child should be done with --cascade, got:\n%s", out)
+	}
+}
+
 // --- output format ---
 
 func TestList_MultilineNameShowsFirstLineOnly(t *testing.T) {
@@ -361,6 +1085,438 @@ func TestDetail_MultilineNameShowsFullText(t *testing.T) {
 	}
 }
 
+// --- serve ---
+
+// startServer Run `todo serve` on a free port with extra args, stopping
+// it when the test ends. Returns its URL and the running command.
+func startServer(t *testing.T, home string, args ...string) (string, *exec.Cmd) {
+	t.Helper()
+	return startListening(t, home, "serve", args...)
+}
+
+// startListening Run a todo command that serves on --addr, on a free port.
+func startListening(t *testing.T, home, command string, args ...string) (string, *exec.Cmd) {
+	t.Helper()
+	cmd := exec.Command(todoBin, append([]string{command, "--addr", "127.0.0.1:0"}, args...)...)
+	cmd.Env = append(os.Environ(), "HOME="+home, "TODO_BACKEND=sqlite")
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	// The first line names the address actually listened on.
+	line, err := bufio.NewReader(stderr).ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading serve output: %v", err)
+	}
+	for _, f := range strings.Fields(line) {
+		if strings.HasPrefix(f, "http://") {
+			return f, cmd
+		}
+	}
+	t.Fatalf("no address in %q", line)
+	return "", nil
+}
+
+// runRemote invokes the todo binary like run, against the server at url.
+func runRemote(t *testing.T, url, token string, args ...string) (stdout, stderr string, status int) {
+	t.Helper()
+	cmd := exec.Command(todoBin, args...)
+	cmd.Env = append(os.Environ(), "HOME="+tempHome(t), "TODO_BACKEND=remote", "TODO_REMOTE_URL="+url, "TODO_REMOTE_TOKEN="+token)
+
+	var outBuf, errBuf strings.Builder
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		status = exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatalf("%v did not run: %v", args, err)
+	}
+	return outBuf.String(), errBuf.String(), status
+}
+
+func TestServe_SharesTheBackend(t *testing.T) {
+	home := tempHome(t)
+	url, cmd := startServer(t, home)
+
+	resp, err := http.Post(url+"/todos", "application/json", strings.NewReader(`{"name":"From the API"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /todos: got %d, want 201", resp.StatusCode)
+	}
+
+	if out := mustRun(t, home, "list"); !strings.Contains(out, "From the API") {
+		t.Errorf("item created over HTTP missing from list:\n%s", out)
+	}
+
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Errorf("serve did not exit cleanly on interrupt: %v", err)
+	}
+}
+
+// --- remote backend ---
+
+func TestRemote_CommandsGoToTheServer(t *testing.T) {
+	home := tempHome(t)
+	url, _ := startServer(t, home, "--token", "s3cret")
+
+	for _, args := range [][]string{
+		{"add", "--tag", "team", "--repeat", "weekly", "--due", "2026-11-02", "Standup notes"},
+		{"add", "Order lunch"},
+		{"edit", "2", "--priority", "high"},
+		{"done", "1"},
+	} {
+		if _, stderr, status := runRemote(t, url, "s3cret", args...); status != 0 {
+			t.Fatalf("%v: exit %d\nstderr: %s", args, status, stderr)
+		}
+	}
+
+	// The server's own store has every change, including the next
+	// occurrence added by done.
+	out := mustRun(t, home, "list", "--all")
+	for _, want := range []string{"Standup notes", "[H]  Order lunch", "[x]"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("server store missing %q:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "Standup notes"); n != 2 {
+		t.Errorf("want the done item and its next occurrence, got %d:\n%s", n, out)
+	}
+
+	if _, _, status := runRemote(t, url, "s3cret", "delete", "2"); status != 0 {
+		t.Errorf("delete: exit %d", status)
+	}
+	if _, _, status := runRemote(t, url, "s3cret", "show", "2"); status != 3 {
+		t.Errorf("show deleted item: exit %d, want 3", status)
+	}
+}
+
+func TestRemote_WrongToken(t *testing.T) {
+	url, _ := startServer(t, tempHome(t), "--token", "s3cret")
+
+	_, stderr, status := runRemote(t, url, "wrong", "list")
+	if status != 1 || !strings.Contains(stderr, "TODO_REMOTE_TOKEN") {
+		t.Errorf("exit %d, stderr %q: want 1 and a hint about the token", status, stderr)
+	}
+}
+
+func TestRemote_UnreachableServerExitCode(t *testing.T) {
+	_, stderr, status := runRemote(t, "http://127.0.0.1:1", "", "list")
+	if status != 5 || !strings.Contains(stderr, "cannot reach todo server") {
+		t.Errorf("exit %d, stderr %q: want 5", status, stderr)
+	}
+}
+
+func TestRemote_MissingURL(t *testing.T) {
+	_, stderr, status := runRemote(t, "", "", "list")
+	if status != 1 || !strings.Contains(stderr, "TODO_REMOTE_URL") {
+		t.Errorf("exit %d, stderr %q: want 1 and a hint about the URL", status, stderr)
+	}
+}
+
+// --- sync ---
+
+func TestSync_BothWaysBetweenBackends(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "From sqlite")
+	mustRun(t, home, "--backend", "file", "add", "From file")
+
+	out := mustRun(t, home, "sync", "--with", "file")
+	if !strings.Contains(out, "1 added to file") || !strings.Contains(out, "1 added to sqlite") {
+		t.Errorf("unexpected sync report:\n%s", out)
+	}
+	for _, backend := range []string{"sqlite", "file"} {
+		list := mustRun(t, home, "--backend", backend, "list")
+		if !strings.Contains(list, "From sqlite") || !strings.Contains(list, "From file") {
+			t.Errorf("%s is missing items:\n%s", backend, list)
+		}
+	}
+
+	// The same state is used whichever backend is current.
+	mustRun(t, home, "--backend", "file", "edit", "1", "--priority", "high")
+	mustRun(t, home, "edit", "1", "--priority", "low")
+	out = mustRun(t, home, "--backend", "file", "sync", "--with", "sqlite")
+	if !strings.Contains(out, "1 updated in file") || !strings.Contains(out, "1 updated in sqlite") {
+		t.Errorf("unexpected sync report:\n%s", out)
+	}
+	if out := mustRun(t, home, "sync", "--with", "file"); !strings.Contains(out, "in sync") {
+		t.Errorf("want nothing left to sync, got:\n%s", out)
+	}
+}
+
+func TestSync_ReportsConflicts(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "Shared")
+	mustRun(t, home, "sync", "--with", "file")
+
+	mustRun(t, home, "edit", "1", "Renamed in sqlite")
+	mustRun(t, home, "--backend", "file", "edit", "1", "Renamed in file")
+
+	out := mustRun(t, home, "sync", "--with", "file", "--dry-run")
+	if !strings.Contains(out, "Would sync") || !strings.Contains(out, "name edited in both; kept file's version") {
+		t.Errorf("unexpected dry run report:\n%s", out)
+	}
+	if list := mustRun(t, home, "list"); !strings.Contains(list, "Renamed in sqlite") {
+		t.Errorf("dry run changed the item:\n%s", list)
+	}
+
+	mustRun(t, home, "sync", "--with", "file")
+	if list := mustRun(t, home, "list"); !strings.Contains(list, "Renamed in file") {
+		t.Errorf("the later edit should win:\n%s", list)
+	}
+}
+
+func TestSync_InvalidArguments(t *testing.T) {
+	home := tempHome(t)
+	for _, args := range [][]string{
+		{"sync"},
+		{"sync", "--with", "nowhere"},
+		{"sync", "--with", "sqlite"},
+		{"sync", "--with", "file", "--strategy", "coin-toss"},
+	} {
+		if status, _ := runStatus(t, home, args...); status != 1 {
+			t.Errorf("%v: exit %d, want 1", args, status)
+		}
+	}
+}
+
+func TestMigrate_CopiesEveryFieldAndIsIdempotent(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "--backend", "file", "add", "--priority", "high", "--tag", "work", "--due", "2026-11-02", "Plan launch")
+	mustRun(t, home, "--backend", "file", "add", "--parent", "1", "Book venue")
+	mustRun(t, home, "--backend", "file", "done", "2")
+
+	out := mustRun(t, home, "migrate", "--from", "file", "--to", "sqlite", "--dry-run")
+	if !strings.Contains(out, "Would migrate file to sqlite: 2 to add") || !strings.Contains(out, "+ [1] Plan launch") {
+		t.Errorf("unexpected dry run report:\n%s", out)
+	}
+	if list := mustRun(t, home, "list", "--all"); strings.Contains(list, "Plan launch") {
+		t.Errorf("dry run copied items:\n%s", list)
+	}
+
+	out = mustRun(t, home, "migrate", "--from", "file", "--to", "sqlite")
+	if !strings.Contains(out, "2 added") || !strings.Contains(out, "Verified 2 item(s) in sqlite") {
+		t.Errorf("unexpected migrate report:\n%s", out)
+	}
+	want := mustRun(t, home, "--backend", "file", "--output", "json", "list", "--all")
+	if got := mustRun(t, home, "--output", "json", "list", "--all"); got != want {
+		t.Errorf("sqlite differs from file:\n%s\nwant:\n%s", got, want)
+	}
+
+	// From the current backend by default; a second run copies nothing.
+	mustRun(t, home, "--backend", "file", "edit", "1", "--priority", "low")
+	out = mustRun(t, home, "--backend", "file", "migrate", "--to", "sqlite")
+	if !strings.Contains(out, "0 added, 1 updated, 1 unchanged") || !strings.Contains(out, "~ [1] Plan launch (priority, updated_at)") {
+		t.Errorf("unexpected migrate report:\n%s", out)
+	}
+	if out := mustRun(t, home, "migrate", "--from", "file", "--to", "sqlite"); !strings.Contains(out, "0 added, 0 updated, 2 unchanged") {
+		t.Errorf("want nothing left to migrate, got:\n%s", out)
+	}
+}
+
+func TestMigrate_InvalidArguments(t *testing.T) {
+	home := tempHome(t)
+	for _, args := range [][]string{
+		{"migrate"},
+		{"migrate", "--to", "nowhere"},
+		{"migrate", "--to", "sqlite"},
+		{"migrate", "--from", "nowhere", "--to", "file"},
+	} {
+		if status, _ := runStatus(t, home, args...); status != 1 {
+			t.Errorf("%v: exit %d, want 1", args, status)
+		}
+	}
+}
+
+func TestImportExport_TodoTxtRoundTrip(t *testing.T) {
+	home := tempHome(t)
+	file := filepath.Join(home, "todo.txt")
+	// Written as export writes it, so that it comes back unchanged.
+	lines := "(A) 2026-10-01 Plan launch ticket:123 +work @office due:2026-11-02 id:1\n" +
+		"2026-10-03 Water plants rec:1w\n" +
+		"x 2026-10-05 2026-10-01 Book venue pri:B parent:1\n"
+	if err := os.WriteFile(file, []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if out := mustRun(t, home, "import", "--format", "todotxt", file); !strings.Contains(out, "Imported 3 item(s).") {
+		t.Errorf("unexpected import output:\n%s", out)
+	}
+	list := mustRun(t, home, "list", "--all")
+	for _, want := range []string{"Plan launch ticket:123", "#work", "#@office", "└ Book venue", "Water plants"} {
+		if !strings.Contains(list, want) {
+			t.Errorf("list is missing %q:\n%s", want, list)
+		}
+	}
+
+	if out := mustRun(t, home, "export", "--format", "todotxt"); out != lines {
+		t.Errorf("export differs from what was imported:\n%s\nwant:\n%s", out, lines)
+	}
+
+	if status, _ := runStatus(t, home, "export", "--format", "csv"); status != 1 {
+		t.Errorf("unknown format: exit %d, want 1", status)
+	}
+}
+
+func TestImportExport_ICalendar(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "--priority", "high", "--tag", "work", "--due", "2026-11-02", "--repeat", "weekly", "Plan launch")
+	mustRun(t, home, "add", "--parent", "1", "Book venue")
+	mustRun(t, home, "done", "2")
+
+	out := mustRun(t, home, "export", "--format", "ics")
+	for _, want := range []string{"BEGIN:VTODO\r\n", "SUMMARY:Plan launch\r\n", "STATUS:COMPLETED\r\n", "RELATED-TO;RELTYPE=PARENT:"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("export is missing %q:\n%s", want, out)
+		}
+	}
+
+	// The format is told by the extension.
+	file := filepath.Join(home, "todos.ics")
+	if err := os.WriteFile(file, []byte(out), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mustRun(t, home, "--backend", "file", "import", file)
+	want := mustRun(t, home, "list", "--all")
+	if got := mustRun(t, home, "--backend", "file", "list", "--all"); got != want {
+		t.Errorf("imported items differ:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestImportExport_Markdown(t *testing.T) {
+	home := tempHome(t)
+	file := filepath.Join(home, "notes.md")
+	notes := "# Meeting notes\n\n" +
+		"Decided to ship on Friday.\n\n" +
+		"- [ ] Plan launch !high @2026-11-02 #work\n" +
+		"  - [x] Book venue\n" +
+		"- [ ] Water plants #home\n"
+	if err := os.WriteFile(file, []byte(notes), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The format is told by the extension, and skipped lines are reported.
+	stdout, stderr, ok := run(t, home, "import", file)
+	if !ok || !strings.Contains(stdout, "Imported 3 item(s).") {
+		t.Fatalf("unexpected import output:\n%s%s", stdout, stderr)
+	}
+	if !strings.Contains(stderr, "Warning: line 3: skipped a line that is not a list item: Decided to ship on Friday.") {
+		t.Errorf("missing warning for the skipped line:\n%s", stderr)
+	}
+	list := mustRun(t, home, "list", "--all")
+	for _, want := range []string{"Plan launch", "#work", "└ Book venue", "Water plants"} {
+		if !strings.Contains(list, want) {
+			t.Errorf("list is missing %q:\n%s", want, list)
+		}
+	}
+
+	want := "## home\n\n" +
+		"- [ ] Water plants #home\n\n" +
+		"## work\n\n" +
+		"- [ ] Plan launch !high @2026-11-02 #work\n" +
+		"  - [x] Book venue\n"
+	if out := mustRun(t, home, "export", "--format", "markdown", "--group", "tag"); out != want {
+		t.Errorf("unexpected export:\n%s\nwant:\n%s", out, want)
+	}
+
+	if status, _ := runStatus(t, home, "export", "--format", "todotxt", "--group", "tag"); status != 1 {
+		t.Errorf("--group with todotxt: exit %d, want 1", status)
+	}
+}
+
+func TestImportExport_Taskwarrior(t *testing.T) {
+	home := tempHome(t)
+	file := filepath.Join(home, "tasks.json")
+	tasks := `[
+{"description":"Plan launch","entry":"20261001T090000Z","modified":"20261002T090000Z","priority":"H","project":"work","status":"pending","tags":["office"],"uuid":"8a9b7c6d-1111-4222-8333-444455556666","annotations":[{"entry":"20261002T090000Z","description":"ask Sam"}]},
+{"description":"Old idea","entry":"20261001T090000Z","end":"20261003T080000Z","modified":"20261003T080000Z","status":"deleted","uuid":"aaaabbbb-3333-4444-8555-666677778888"}
+]`
+	if err := os.WriteFile(file, []byte(tasks), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if out := mustRun(t, home, "import", "--format", "taskwarrior", file); !strings.Contains(out, "Imported 2 item(s).") {
+		t.Errorf("unexpected import output:\n%s", out)
+	}
+	list := mustRun(t, home, "list", "--all")
+	for _, want := range []string{"Plan launch", "#office", "Old idea"} {
+		if !strings.Contains(list, want) {
+			t.Errorf("list is missing %q:\n%s", want, list)
+		}
+	}
+
+	// Attributes with no field of their own come back on export.
+	out := mustRun(t, home, "export", "--format", "taskwarrior")
+	for _, want := range []string{`"project":"work"`, `"uuid":"8a9b7c6d-1111-4222-8333-444455556666"`,
+		`"description":"ask Sam"`, `"status":"deleted"`, `"priority":"H"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("export is missing %s:\n%s", want, out)
+		}
+	}
+	if out := mustRun(t, home, "--output", "json", "--fields", "id,extras", "1"); !strings.Contains(out, `"project": "work"`) {
+		t.Errorf("extras missing from JSON output:\n%s", out)
+	}
+}
+
+// --- caldav ---
+
+func TestCalDAV_SharesTheBackend(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "Buy milk")
+	url, cmd := startListening(t, home, "caldav", "--token", "secret")
+
+	do := func(method, path, body string) (int, string) {
+		req, err := http.NewRequest(method, url+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("me", "secret")
+		req.Header.Set("Depth", "1")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	status, body := do("PROPFIND", "/calendars/todo/", "")
+	if status != http.StatusMultiStatus || !strings.Contains(body, "/calendars/todo/1.ics") {
+		t.Fatalf("PROPFIND: got %d:\n%s", status, body)
+	}
+
+	vtodo := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VTODO\r\nUID:phone-1\r\nSUMMARY:From the phone\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"
+	if status, body := do("PUT", "/calendars/todo/phone-1.ics", vtodo); status != http.StatusCreated {
+		t.Fatalf("PUT: got %d: %s", status, body)
+	}
+	if out := mustRun(t, home, "list"); !strings.Contains(out, "From the phone") {
+		t.Errorf("item created over CalDAV missing from list:\n%s", out)
+	}
+
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Errorf("caldav did not exit cleanly on interrupt: %v", err)
+	}
+}
+
 // --- error cases ---
 
 func TestError_UnknownCommand(t *testing.T) {
@@ -395,3 +1551,73 @@ func TestError_InvalidDueDate(t *testing.T) {
 		t.Error("expected non-zero exit for invalid due date")
 	}
 }
+
+func TestError_MissingItemExitCode(t *testing.T) {
+	home := tempHome(t)
+	code, stderr := runStatus(t, home, "42")
+	if code != 3 {
+		t.Errorf("expected exit status 3 for missing item, got %d", code)
+	}
+	if !strings.Contains(stderr, "not found") {
+		t.Errorf("expected not found message on stderr, got:\n%s", stderr)
+	}
+}
+
+func TestError_DeleteMissingItemExitCode(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "Keep me")
+	code, _ := runStatus(t, home, "delete", "1", "42")
+	if code != 3 {
+		t.Errorf("expected exit status 3 when deleting a missing item, got %d", code)
+	}
+
+	out := mustRun(t, home, "list")
+	if !strings.Contains(out, "Keep me") {
+		t.Errorf("failed delete should not remove other items, got:\n%s", out)
+	}
+}
+
+func TestError_UnavailableBackendExitCode(t *testing.T) {
+	home := tempHome(t)
+	// A directory where the database file should be cannot be opened.
+	if err := os.MkdirAll(filepath.Join(home, ".todo", "todo.db", "x"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	code, _ := runStatus(t, home, "list")
+	if code != 5 {
+		t.Errorf("expected exit status 5 for an unusable database, got %d", code)
+	}
+}
+
+func TestTimeout_LockedDatabaseFailsFast(t *testing.T) {
+	home := tempHome(t)
+	mustRun(t, home, "add", "Some task")
+
+	conn, err := sql.Open("sqlite3", filepath.Join(home, ".todo", "todo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx := context.Background()
+	lock, err := conn.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Close()
+	if _, err := lock.ExecContext(ctx, "BEGIN EXCLUSIVE"); err != nil {
+		t.Fatal(err)
+	}
+	defer lock.ExecContext(ctx, "ROLLBACK")
+
+	start := time.Now()
+	code, stderr := runStatus(t, home, "--timeout", "300ms", "list")
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected --timeout to fail fast, took %s", elapsed)
+	}
+	if code != 5 {
+		t.Errorf("expected exit status 5 on timeout, got %d", code)
+	}
+	if !strings.Contains(stderr, "timed out") {
+		t.Errorf("expected timeout message on stderr, got:\n%s", stderr)
+	}
+}
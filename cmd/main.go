@@ -1,20 +1,31 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 	"unicode/utf8"
 
 	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/dateparse"
+	"github.com/tcooper-uk/go-todo/internal/filter"
+	"github.com/tcooper-uk/go-todo/internal/recur"
+	"github.com/tcooper-uk/go-todo/internal/search"
+	"github.com/tcooper-uk/go-todo/internal/server"
 	"github.com/tcooper-uk/go-todo/internal/storage"
 	s "github.com/tcooper-uk/go-todo/internal/storage"
 	"github.com/tcooper-uk/go-todo/internal/storage/db"
+	"github.com/tcooper-uk/go-todo/internal/tui"
 )
 
 // tagList implements flag.Value for repeatable --tag flags.
@@ -29,51 +40,63 @@ func (t *tagList) Set(v string) error {
 func main() {
 	args := os.Args[1:]
 
-	// Global --backend flag parsed before the subcommand.
+	// Global flags parsed before the subcommand.
 	globalFlags := flag.NewFlagSet("", flag.ContinueOnError)
-	backendFlag := globalFlags.String("backend", "", "backend: sqlite|file|cloud (overrides $TODO_BACKEND)")
-	globalFlags.Parse(args)
+	backendFlag := globalFlags.String("backend", "", "backend: sqlite|file|cloud|remote (overrides $TODO_BACKEND)")
+	timeout := globalFlags.Duration("timeout", 0, "give up on each storage operation after this long, e.g. 5s (0 = wait forever)")
+	outputFlag := globalFlags.String("output", "", "output format for list and show: table|json|ndjson|yaml|csv|tsv")
+	fieldsFlag := globalFlags.String("fields", "", "comma-separated fields to output, e.g. id,name,due_date")
+	formatFlag := globalFlags.String("format", "", "Go template applied to each item, e.g. '{{.ID}} {{.Name}}'")
+	globalFlags.Usage = printHelp
+	if err := globalFlags.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return
+	} else if err != nil {
+		os.Exit(2)
+	}
 	remainingArgs := globalFlags.Args()
 
+	// todo date only reads its argument, so it needs no backend.
+	if len(remainingArgs) > 0 && remainingArgs[0] == "date" {
+		dateCommand(remainingArgs[1:])
+		return
+	}
+
+	out, err := newOutput(*outputFlag, *fieldsFlag, *formatFlag)
+	if err != nil {
+		fail("Error: %v", err)
+	}
+
 	mode := resolveMode(*backendFlag)
 
 	filePath, err := storage.Setup(mode)
 	exitOnErr(err)
 
-	var store s.TodoStore
-
-	switch mode {
-	case storage.DbMode:
-		store, err = db.NewSQLLiteStorage(filePath)
-	case storage.FileMode:
-		store = s.NewLocalFileStore(filePath)
-	case storage.CloudMode:
-		store, err = db.NewCloudStore(&db.CloudStoreConfig{
-			ProjectId: db.ProjectId,
-			KeyFile:   filePath,
-		})
-	}
+	ctx := s.WithActor(context.Background(), currentUser())
+	store, err := openStore(ctx, mode, filePath, *timeout)
 	exitOnErr(err)
 
 	if len(remainingArgs) == 0 {
-		printItems(store, s.ListOptions{})
+		exitOnErr(printItems(ctx, store, s.ListOptions{}, out))
 		return
 	}
 
 	// Single numeric arg: show that item.
 	if id, err := strconv.ParseInt(remainingArgs[0], 0, 0); len(remainingArgs) == 1 && err == nil {
-		item := store.GetItem(int(id))
-		if item == nil {
-			fmt.Printf("Cannot find item with ID %d\n", id)
-			os.Exit(1)
-		}
-		printItem(item)
+		item, err := store.GetItem(ctx, int(id))
+		exitOnErr(err)
+		exitOnErr(printItem(ctx, store, item, out))
 		return
 	}
 
 	cmd := remainingArgs[0]
 	cmdArgs := remainingArgs[1:]
 
+	// Changes are journaled, so that todo undo can revert them.
+	unjournaledStore := store
+	if !unjournaled[cmd] {
+		store = s.WithJournal(store, journalPath(mode), strings.Join(remainingArgs, " "))
+	}
+
 	switch cmd {
 	case "list", "l", "ps", "ls":
 		fs := flag.NewFlagSet("list", flag.ExitOnError)
@@ -82,28 +105,110 @@ func main() {
 		priority := fs.String("priority", "", "filter by priority: low|medium|high")
 		var tags tagList
 		fs.Var(&tags, "tag", "filter by tag (repeatable)")
+		project := fs.String("project", "", "show only items in this project or the projects inside it")
 		overdue := fs.Bool("overdue", false, "show only overdue items")
-		fs.Parse(cmdArgs)
+		includeDeferred := fs.Bool("include-deferred", false, "show deferred items too, whose start date is still to come")
+		sortSpec := fs.String("sort", "", "sort by fields, e.g. due,-priority")
+		limit := fs.Int("limit", 0, "show at most this many items")
+		offset := fs.Int("offset", 0, "skip this many items")
+		cursor := fs.String("cursor", "", "continue from a previous page")
+		query := strings.Join(parseArgs(fs, cmdArgs), " ")
+
+		if *limit < 0 || *offset < 0 {
+			fail("--limit and --offset must not be negative.")
+		}
 
 		opts := s.ListOptions{
-			ShowDone: *all,
-			OnlyDone: *onlyDone,
-			Priority: *priority,
-			Overdue:  *overdue,
+			ShowDone:        *all,
+			OnlyDone:        *onlyDone,
+			Priority:        *priority,
+			Tags:            tags,
+			Overdue:         *overdue,
+			Limit:           *limit,
+			Offset:          *offset,
+			Cursor:          *cursor,
+			IncludeDeferred: *includeDeferred,
 		}
-		if len(tags) > 0 {
-			opts.Tag = tags[0]
+		if *project != "" {
+			projects, err := s.Projects(ctx, store)
+			exitOnErr(err)
+			p, err := s.FindProject(projects, *project)
+			exitOnErr(err)
+			opts.Projects = internal.SubProjects(projects, p.ID)
 		}
-		printItems(store, opts)
+		if *sortSpec != "" {
+			keys, err := s.ParseSort(*sortSpec)
+			if err != nil {
+				fail("Error: %v", err)
+			}
+			opts.Sort = keys
+		}
+		if strings.TrimSpace(query) != "" {
+			expr, err := filter.Parse(query)
+			if err != nil {
+				failFilter(query, err)
+			}
+			opts.Filter = expr
+			// A filter on done decides for itself which items to show.
+			if filter.Uses(expr, filter.FieldDone) && !*onlyDone {
+				opts.ShowDone = true
+			}
+		}
+		exitOnErr(printItems(ctx, store, opts, out))
+
+	case "show", "get":
+		item, err := store.GetItem(ctx, requireId(cmdArgs))
+		exitOnErr(err)
+		exitOnErr(printItem(ctx, store, item, out))
+
+	case "search", "find", "s":
+		fs := flag.NewFlagSet("search", flag.ExitOnError)
+		all := fs.Bool("all", false, "search done items too")
+		onlyDone := fs.Bool("done", false, "search only done items")
+		query := strings.Join(parseArgs(fs, cmdArgs), " ")
+		if strings.TrimSpace(query) == "" {
+			fail("You must supply something to search for.")
+		}
+
+		results, err := s.Search(ctx, store, query, s.ListOptions{ShowDone: *all, OnlyDone: *onlyDone, IncludeDeferred: true})
+		exitOnErr(err)
+		printResults(results)
 
 	case "delete", "remove", "d", "rm":
-		ids := parseIds(cmdArgs...)
-		store.DeleteItem(ids...)
+		fs := flag.NewFlagSet("delete", flag.ExitOnError)
+		cascade := fs.Bool("cascade", false, "delete subtasks too")
+		ids := parseIds(parseArgs(fs, cmdArgs)...)
+		if len(ids) == 0 {
+			fail("You must supply a valid ID.")
+		}
+
+		all, err := store.GetAllItems(ctx, s.ListOptions{ShowDone: true, IncludeDeferred: true})
+		exitOnErr(err)
+		var subtaskIds []int
+		for _, id := range ids {
+			subtasks := internal.Descendants(all.Items, id)
+			if len(subtasks) > 0 && !*cascade {
+				exitOnErr(fmt.Errorf("%w: item %d has %d subtask(s), use --cascade to delete them too",
+					s.ErrConflict, id, len(subtasks)))
+			}
+			for _, sub := range subtasks {
+				if !containsId(ids, sub.ID) && !containsId(subtaskIds, sub.ID) {
+					subtaskIds = append(subtaskIds, sub.ID)
+				}
+			}
+		}
+
+		_, err = store.DeleteItem(ctx, append(ids, subtaskIds...)...)
+		exitOnErr(err)
 
 	case "add", "create", "put", "a":
 		fs := flag.NewFlagSet("add", flag.ExitOnError)
 		priority := fs.String("priority", "", "priority: low|medium|high")
-		due := fs.String("due", "", "due date: YYYY-MM-DD")
+		due := fs.String("due", "", "due date, e.g. 2026-11-01, fri, 'in 3 days' or eod")
+		start := fs.String("start", "", "hide the item until then, e.g. mon, 'next month' or 3d")
+		parent := fs.Int("parent", 0, "make this a subtask of the item with this ID")
+		project := fs.String("project", "", "project to put the item in (default the default project; '-' for none)")
+		repeat := fs.String("repeat", "", "repeat rule, e.g. weekly, 'every 2 weeks on mon,fri', 'every last fri'")
 		var tags tagList
 		fs.Var(&tags, "tag", "tag (repeatable)")
 		fs.Parse(cmdArgs)
@@ -115,51 +220,49 @@ func main() {
 			exitOnErr(editorErr)
 		}
 		if name == "" {
-			fmt.Println("No content provided.")
-			os.Exit(1)
+			fail("No content provided.")
 		}
 
 		todo := internal.Todo{
 			Name:     name,
 			Priority: internal.Priority(*priority),
 			Tags:     []string(tags),
+			ParentID: *parent,
 		}
+		exitOnErr(s.CheckParent(ctx, store, 0, todo.ParentID))
+		todo.Project, err = newItemProject(ctx, store, *project)
+		exitOnErr(err)
 		if *due != "" {
-			t, err := time.Parse("2006-01-02", *due)
-			if err != nil {
-				fmt.Printf("Invalid date %q — use YYYY-MM-DD\n", *due)
-				os.Exit(1)
-			}
-			todo.DueDate = &t
+			setDue(&todo, *due)
+		}
+		if *start != "" {
+			todo.StartDate = parseStart(*start)
 		}
-		store.AddItem(todo)
+		if *repeat != "" {
+			todo.Recurrence = parseRepeat(*repeat)
+		}
+		_, err := store.AddItem(ctx, todo)
+		exitOnErr(err)
 
 	case "e", "edit", "update":
 		fs := flag.NewFlagSet("edit", flag.ExitOnError)
 		priority := fs.String("priority", "", "priority: low|medium|high")
-		due := fs.String("due", "", "due date: YYYY-MM-DD (clear with '-')")
+		due := fs.String("due", "", "due date, e.g. 2026-11-01, fri or 'in 3 days' (clear with '-')")
+		start := fs.String("start", "", "start date, as for snooze (clear with '-')")
 		doneFlagStr := fs.String("done", "", "set done: true|false")
 		newName := fs.String("name", "", "new name (alternative to positional arg)")
+		parent := fs.String("parent", "", "parent ID (clear with '-')")
+		project := fs.String("project", "", "project to move the item to (clear with '-')")
+		repeat := fs.String("repeat", "", "repeat rule (clear with '-')")
 		var tags tagList
 		fs.Var(&tags, "tag", "tag (repeatable, replaces existing tags)")
 
 		// Consume the ID first so that flags after the ID are parsed correctly.
-		if len(cmdArgs) == 0 {
-			fmt.Println("You must supply an ID.")
-			os.Exit(1)
-		}
-		ids := parseIds(cmdArgs[0])
-		if len(ids) == 0 {
-			fmt.Println("You must supply a valid ID.")
-			os.Exit(1)
-		}
+		id := requireId(cmdArgs)
 		fs.Parse(cmdArgs[1:])
 
-		item := store.GetItem(ids[0])
-		if item == nil {
-			fmt.Printf("Cannot find item with ID %d\n", ids[0])
-			os.Exit(1)
-		}
+		item, err := store.GetItem(ctx, id)
+		exitOnErr(err)
 
 		// Determine new name: --name flag, then positional args, then editor.
 		// Only open the editor when no flags and no positional name were given.
@@ -167,7 +270,7 @@ func main() {
 		if nameText == "" {
 			nameText = strings.Join(fs.Args(), " ")
 		}
-		noFlagsSet := *priority == "" && *due == "" && *doneFlagStr == "" && len(tags) == 0
+		noFlagsSet := *priority == "" && *due == "" && *start == "" && *doneFlagStr == "" && len(tags) == 0 && *parent == "" && *project == "" && *repeat == ""
 		if nameText == "" && noFlagsSet {
 			var editorErr error
 			nameText, editorErr = openInEditor(item.Name)
@@ -181,18 +284,43 @@ func main() {
 			item.Priority = internal.Priority(*priority)
 		}
 		if *due == "-" {
-			item.DueDate = nil
+			item.DueDate, item.DueTimed, item.DueZone = nil, false, ""
 		} else if *due != "" {
-			t, err := time.Parse("2006-01-02", *due)
-			if err != nil {
-				fmt.Printf("Invalid date %q — use YYYY-MM-DD\n", *due)
-				os.Exit(1)
-			}
-			item.DueDate = &t
+			setDue(item, *due)
+		}
+		if *start == "-" {
+			item.StartDate = nil
+		} else if *start != "" {
+			item.StartDate = parseStart(*start)
 		}
 		if len(tags) > 0 {
 			item.Tags = []string(tags)
 		}
+		if *parent == "-" {
+			item.ParentID = 0
+		} else if *parent != "" {
+			parentIds := parseIds(*parent)
+			if len(parentIds) == 0 {
+				fail("Invalid --parent value %q — use an ID or '-'", *parent)
+			}
+			exitOnErr(s.CheckParent(ctx, store, id, parentIds[0]))
+			item.ParentID = parentIds[0]
+		}
+		if *project == "-" {
+			item.Project = 0
+		} else if *project != "" {
+			id, err := resolveProject(ctx, store, *project)
+			exitOnErr(err)
+			if id != item.Project {
+				exitOnErr(s.CheckProject(ctx, store, id))
+			}
+			item.Project = id
+		}
+		if *repeat == "-" {
+			item.Recurrence = ""
+		} else if *repeat != "" {
+			item.Recurrence = parseRepeat(*repeat)
+		}
 		if *doneFlagStr != "" {
 			switch strings.ToLower(*doneFlagStr) {
 			case "true", "1", "yes":
@@ -200,80 +328,349 @@ func main() {
 			case "false", "0", "no":
 				item.Done = false
 			default:
-				fmt.Printf("Invalid --done value %q — use true|false\n", *doneFlagStr)
-				os.Exit(1)
+				fail("Invalid --done value %q — use true|false", *doneFlagStr)
 			}
 		}
 
-		count := store.EditItem(ids[0], *item)
-		if count == 0 {
-			fmt.Printf("Cannot find item with ID %d\n", ids[0])
-		}
+		_, err = store.EditItem(ctx, id, *item)
+		exitOnErr(err)
 
 	case "done":
-		if len(cmdArgs) == 0 {
-			fmt.Println("You must supply an ID.")
-			os.Exit(1)
+		fs := flag.NewFlagSet("done", flag.ExitOnError)
+		cascade := fs.Bool("cascade", false, "mark subtasks done too")
+		id := requireId(parseArgs(fs, cmdArgs))
+
+		item, err := store.GetItem(ctx, id)
+		exitOnErr(err)
+		wasDone := item.Done
+		item.Done = true
+		_, err = store.EditItem(ctx, id, *item)
+		exitOnErr(err)
+
+		if !wasDone {
+			added, err := s.AddNextOccurrence(ctx, store, *item, time.Now())
+			exitOnErr(err)
+			if added != nil {
+				fmt.Printf("Next occurrence: [%d] due %s\n", added.ID, added.FormatDue("Mon 02 Jan 06", time.Local))
+			}
 		}
-		ids := parseIds(cmdArgs[0])
-		if len(ids) == 0 {
-			fmt.Println("You must supply a valid ID.")
-			os.Exit(1)
+
+		if *cascade {
+			subtasks, err := s.Subtasks(ctx, store, id)
+			exitOnErr(err)
+			for _, sub := range subtasks {
+				if sub.Done {
+					continue
+				}
+				sub.Done = true
+				_, err = store.EditItem(ctx, sub.ID, sub)
+				exitOnErr(err)
+			}
 		}
-		item := store.GetItem(ids[0])
-		if item == nil {
-			fmt.Printf("Cannot find item with ID %d\n", ids[0])
-			os.Exit(1)
+
+	case "snooze":
+		id := requireId(cmdArgs)
+		if len(cmdArgs) < 2 {
+			fail("Usage: todo snooze <id> <duration|date>, e.g. 3d, 2h, mon or 'next month'")
 		}
-		item.Done = true
-		store.EditItem(ids[0], *item)
+		item, err := store.GetItem(ctx, id)
+		exitOnErr(err)
+		item.StartDate = parseStart(strings.Join(cmdArgs[1:], " "))
+		_, err = store.EditItem(ctx, id, *item)
+		exitOnErr(err)
+		fmt.Printf("Snoozed [%d] until %s\n", id, item.StartDate.In(time.Local).Format("Mon 02 Jan 06 15:04"))
 
 	case "reopen":
-		if len(cmdArgs) == 0 {
-			fmt.Println("You must supply an ID.")
-			os.Exit(1)
+		id := requireId(cmdArgs)
+		item, err := store.GetItem(ctx, id)
+		exitOnErr(err)
+		item.Done = false
+		_, err = store.EditItem(ctx, id, *item)
+		exitOnErr(err)
+
+	case "serve":
+		fs := flag.NewFlagSet("serve", flag.ExitOnError)
+		addr := fs.String("addr", "localhost:8080", "address to listen on, e.g. :8080 for every interface")
+		token := fs.String("token", os.Getenv("TODO_SERVE_TOKEN"), "bearer token clients must send (default $TODO_SERVE_TOKEN)")
+		fs.Parse(cmdArgs)
+		exitOnErr(serve(ctx, store, *addr, *token))
+
+	case "caldav":
+		fs := flag.NewFlagSet("caldav", flag.ExitOnError)
+		addr := fs.String("addr", "localhost:5232", "address to listen on, e.g. :5232 for every interface")
+		token := fs.String("token", os.Getenv("TODO_CALDAV_TOKEN"), "password clients must send (default $TODO_CALDAV_TOKEN)")
+		fs.Parse(cmdArgs)
+		exitOnErr(serveCalDAV(ctx, backend{mode, filePath, store}, *addr, *token))
+
+	case "sync":
+		fs := flag.NewFlagSet("sync", flag.ExitOnError)
+		with := fs.String("with", "", "backend to sync with: sqlite|file|cloud|remote")
+		strategy := fs.String("strategy", string(s.SyncMerge), "settle items edited in both backends by: merge|lww")
+		dryRun := fs.Bool("dry-run", false, "show what would change without changing anything")
+		fs.Parse(cmdArgs)
+		exitOnErr(syncWith(ctx, backend{mode, filePath, store}, *with, s.SyncOptions{
+			Strategy: s.SyncStrategy(*strategy),
+			DryRun:   *dryRun,
+		}, *timeout))
+
+	case "migrate":
+		fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+		from := fs.String("from", "", "backend to copy items from: sqlite|file|cloud|remote (default the current one)")
+		to := fs.String("to", "", "backend to copy items to: sqlite|file|cloud|remote")
+		dryRun := fs.Bool("dry-run", false, "show what would be copied without copying anything")
+		fs.Parse(cmdArgs)
+		exitOnErr(migrate(ctx, backend{mode, filePath, store}, *from, *to, *dryRun, *timeout))
+
+	case "export":
+		fs := flag.NewFlagSet("export", flag.ExitOnError)
+		format := fs.String("format", "", "file format: todotxt|ics|markdown|taskwarrior")
+		group := fs.String("group", "", "for markdown, put items under a heading per: tag|priority")
+		fs.Parse(cmdArgs)
+		exitOnErr(exportItems(ctx, store, *format, *group, os.Stdout))
+
+	case "import":
+		fs := flag.NewFlagSet("import", flag.ExitOnError)
+		format := fs.String("format", "", "file format: todotxt|ics|markdown|taskwarrior (default from the file's extension)")
+		path := "-"
+		if args := parseArgs(fs, cmdArgs); len(args) > 0 {
+			path = args[0]
 		}
-		ids := parseIds(cmdArgs[0])
+		exitOnErr(importItems(ctx, store, *format, path))
+
+	case "history":
+		exitOnErr(printHistory(ctx, store, requireId(cmdArgs)))
+
+	case "log":
+		fs := flag.NewFlagSet("log", flag.ExitOnError)
+		sinceFlag := fs.String("since", "7d", "list changes since this long ago, e.g. 7d, 12h, or a date, e.g. yesterday or 2026-10-01")
+		fs.Parse(cmdArgs)
+
+		since, err := parseSince(*sinceFlag, time.Now())
+		if err != nil {
+			fail("Invalid --since %q — use e.g. 7d, 2w, 12h, yesterday or 2026-10-01", *sinceFlag)
+		}
+		exitOnErr(printLog(ctx, store, since))
+
+	case "tui":
+		exitOnErr(tui.Run(ctx, store))
+
+	case "project", "projects":
+		exitOnErr(projectCommand(ctx, store, cmdArgs))
+
+	case "trash":
+		fs := flag.NewFlagSet("trash", flag.ExitOnError)
+		olderThan := fs.String("older-than", "", "only purge items deleted longer ago than this, e.g. 30d")
+		args := parseArgs(fs, cmdArgs)
+		if len(args) == 0 {
+			if *olderThan != "" {
+				fail("--older-than is only for todo trash empty.")
+			}
+			exitOnErr(printItems(ctx, store, s.ListOptions{Trashed: true, ShowDone: true}, out))
+			return
+		}
+		if args[0] != "empty" || len(args) > 1 {
+			fail("Unknown trash command %s — use todo trash, or todo trash empty", strings.Join(args, " "))
+		}
+
+		var before time.Time
+		if *olderThan != "" {
+			age, err := parseAge(*olderThan)
+			if err != nil {
+				fail("Invalid --older-than %q — use e.g. 30d, 2w or 12h", *olderThan)
+			}
+			before = time.Now().Add(-age)
+		}
+		count, err := store.EmptyTrash(ctx, before)
+		exitOnErr(err)
+		fmt.Printf("Deleted %d item(s) for good.\n", count)
+
+	case "restore":
+		ids := parseIds(cmdArgs...)
 		if len(ids) == 0 {
-			fmt.Println("You must supply a valid ID.")
-			os.Exit(1)
+			fail("You must supply a valid ID.")
 		}
-		item := store.GetItem(ids[0])
-		if item == nil {
-			fmt.Printf("Cannot find item with ID %d\n", ids[0])
-			os.Exit(1)
+
+		// Subtasks deleted with an item come back with it.
+		trashed, err := store.GetAllItems(ctx, s.ListOptions{Trashed: true, ShowDone: true})
+		exitOnErr(err)
+		restoring := ids
+		for _, id := range ids {
+			for _, sub := range internal.Descendants(trashed.Items, id) {
+				if !containsId(restoring, sub.ID) {
+					restoring = append(restoring, sub.ID)
+				}
+			}
 		}
-		item.Done = false
-		store.EditItem(ids[0], *item)
+		_, err = store.RestoreItem(ctx, restoring...)
+		exitOnErr(err)
 
 	case "clearall":
-		store.DeleteAllItems()
+		fs := flag.NewFlagSet("clearall", flag.ExitOnError)
+		yes := fs.Bool("yes", false, "delete without asking first")
+		fs.BoolVar(yes, "y", false, "delete without asking first")
+		permanent := fs.Bool("permanent", false, "delete for good, emptying the trash too, instead of moving items to it")
+		fs.Parse(cmdArgs)
+
+		if !*yes {
+			all, err := store.GetAllItems(ctx, s.ListOptions{ShowDone: true, IncludeDeferred: true})
+			exitOnErr(err)
+			question := fmt.Sprintf("Move all %d item(s) to the trash?", len(all.Items))
+			if *permanent {
+				question = fmt.Sprintf("Delete all %d item(s) for good, emptying the trash?", len(all.Items))
+			} else if len(all.Items) == 0 {
+				return
+			}
+			if !confirm(question) {
+				fail("Nothing deleted.")
+			}
+		}
+		_, err := store.DeleteAllItems(ctx)
+		exitOnErr(err)
+		if *permanent {
+			_, err = store.EmptyTrash(ctx, time.Time{})
+			exitOnErr(err)
+		}
+
+	case "undo", "redo":
+		fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+		list := fs.Bool("list", false, "list what can be "+cmd+"ne instead")
+		n := 1
+		if args := parseArgs(fs, cmdArgs); len(args) > 0 {
+			count, err := strconv.Atoi(args[0])
+			if err != nil || count < 1 {
+				fail("Invalid count %q — use a number of operations, e.g. 3", args[0])
+			}
+			n = count
+		}
+		exitOnErr(undoRedo(ctx, unjournaledStore, journalPath(mode), cmd == "undo", n, *list))
 
 	case "help", "-h", "--help":
 		printHelp()
 
 	default:
-		fmt.Printf("Unknown command %s\n", cmd)
-		os.Exit(1)
+		fail("Unknown command %s", cmd)
 	}
 }
 
+// serve Run the REST API on addr until interrupted.
+func serve(ctx context.Context, store s.TodoStore, addr string, token string) error {
+	return listenAndServe(ctx, server.New(store, token), addr, token, "Serving the todo API on http://%s (OpenAPI at /openapi.json)")
+}
+
+// listenAndServe Serve handler on addr until interrupted. banner is
+// printed first, with the address listened on, and a warning follows if
+// anyone on the network could connect without a token.
+func listenAndServe(ctx context.Context, handler http.Handler, addr, token, banner string) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, banner+"\n", listener.Addr())
+	if token == "" && !isLoopback(listener.Addr()) {
+		fmt.Fprintln(os.Stderr, "Warning: no --token set, so anyone who can reach this address can change the list.")
+	}
+
+	errs := make(chan error, 1)
+	go func() { errs <- srv.Serve(listener) }()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+func isLoopback(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	return ok && tcp.IP.IsLoopback()
+}
+
+// openStore Connect to the backend for mode, bounding the connection and
+// every later call by timeout.
+func openStore(ctx context.Context, mode s.Mode, filePath string, timeout time.Duration) (s.TodoStore, error) {
+	openCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		openCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var store s.TodoStore
+	var err error
+
+	switch mode {
+	case storage.DbMode:
+		store, err = db.NewSQLLiteStorage(openCtx, filePath)
+	case storage.FileMode:
+		store, err = s.NewLocalFileStore(filePath)
+	case storage.CloudMode:
+		store, err = db.NewCloudStore(openCtx, &db.CloudStoreConfig{
+			ProjectId: db.ProjectId,
+			KeyFile:   filePath,
+		})
+	case storage.RemoteMode:
+		remoteURL := os.Getenv("TODO_REMOTE_URL")
+		if remoteURL == "" {
+			return nil, errors.New("the remote backend needs $TODO_REMOTE_URL, the address of a todo server, e.g. http://todo.lan:8080")
+		}
+		store, err = db.NewRemoteStore(&db.RemoteStoreConfig{
+			URL:   remoteURL,
+			Token: os.Getenv("TODO_REMOTE_TOKEN"),
+		})
+	}
+	if err != nil {
+		return nil, s.TimeoutErr(openCtx, timeout, err)
+	}
+
+	return s.WithTimeout(s.WithHistory(store), timeout), nil
+}
+
+// backendModes The backends --backend and $TODO_BACKEND accept.
+var backendModes = map[string]s.Mode{
+	"sqlite": s.DbMode,
+	"db":     s.DbMode,
+	"file":   s.FileMode,
+	"cloud":  s.CloudMode,
+	"remote": s.RemoteMode,
+}
+
 func resolveMode(backendFlag string) s.Mode {
 	src := backendFlag
 	if src == "" {
 		src = os.Getenv("TODO_BACKEND")
 	}
-	switch src {
-	case "file":
-		return s.FileMode
-	case "cloud":
-		return s.CloudMode
-	case "sqlite", "db":
-		return s.DbMode
+	if mode, ok := backendModes[src]; ok {
+		return mode
 	}
 	return s.DbMode
 }
 
+// backendName The name --backend takes for mode.
+func backendName(mode s.Mode) string {
+	switch mode {
+	case s.FileMode:
+		return "file"
+	case s.CloudMode:
+		return "cloud"
+	case s.RemoteMode:
+		return "remote"
+	}
+	return "sqlite"
+}
+
 func openInEditor(initial string) (string, error) {
 	editor := os.Getenv("EDITOR")
 	if editor == "" {
@@ -307,68 +704,293 @@ func openInEditor(initial string) (string, error) {
 	return strings.TrimSpace(string(content)), nil
 }
 
+// Exit codes, so scripts can tell failures apart. 2 is left to the flag
+// package, which uses it for usage errors.
+const (
+	exitError       = 1
+	exitNotFound    = 3
+	exitConflict    = 4
+	exitUnavailable = 5
+)
+
 func exitOnErr(err error) {
 	if err != nil {
-		fmt.Println("Error: ", err)
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(exitCode(err))
+	}
+}
+
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, s.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, s.ErrConflict):
+		return exitConflict
+	case errors.Is(err, s.ErrBackendUnavailable):
+		return exitUnavailable
+	}
+	return exitError
+}
+
+// fail Print a usage message to stderr and exit.
+func fail(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(exitError)
+}
+
+// failFilter Report an invalid filter, pointing at where it went wrong.
+func failFilter(query string, err error) {
+	var parseErr *filter.ParseError
+	if !errors.As(err, &parseErr) {
+		exitOnErr(err)
 	}
+	fail("Error: %v\n  %s\n  %s^", err, query, strings.Repeat(" ", parseErr.Column-1))
+}
+
+// parseArgs Parse fs allowing flags and positional arguments in any order,
+// returning the positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		rest := fs.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			// Everything after "--" is positional, even if it looks like a flag.
+			return append(positional, rest...)
+		}
+		args = rest
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// parseRepeat Parse a --repeat rule into the RRULE form stored on items.
+func parseRepeat(value string) string {
+	rule, err := recur.Parse(value)
+	if err != nil {
+		fail("Invalid --repeat value %q: %s", value, err)
+	}
+	return rule.String()
+}
+
+// parseAge Parse a length of time such as 30d or 2w, or anything
+// time.ParseDuration takes.
+func parseAge(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, errors.New("no age given")
+	}
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	if unit, ok := units[value[len(value)-1:]]; ok {
+		n, err := strconv.Atoi(value[:len(value)-1])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", value)
+		}
+		return time.Duration(n) * unit, nil
+	}
+	age, err := time.ParseDuration(value)
+	if err == nil && age < 0 {
+		return 0, fmt.Errorf("invalid age %q", value)
+	}
+	return age, err
+}
+
+// setDue Make the date value names item's due date, read in the local
+// time zone, exiting if it is not one.
+func setDue(item *internal.Todo, value string) {
+	d, err := dateparse.Parse(value, time.Now())
+	if err != nil {
+		fail("Invalid date %q — use e.g. %s", value, dateparse.Examples)
+	}
+	d.SetDue(item)
+}
+
+// parseStart The start date value names: a duration from now, such as 3d
+// or 2h, or a date, from the start of its day; exits if it is neither.
+func parseStart(value string) *time.Time {
+	now := time.Now()
+	if age, err := parseAge(value); err == nil {
+		start := now.Add(age)
+		return &start
+	}
+	d, err := dateparse.Parse(value, now)
+	if err != nil {
+		fail("Invalid start %q — use a duration such as 3d or 2h, or a date, e.g. %s", value, dateparse.Examples)
+	}
+	start := d.Start()
+	return &start
+}
+
+// requireId Parse the leading ID argument of a command, exiting if absent.
+func requireId(args []string) int {
+	if len(args) == 0 {
+		fail("You must supply an ID.")
+	}
+	ids := parseIds(args[0])
+	if len(ids) == 0 {
+		fail("You must supply a valid ID.")
+	}
+	return ids[0]
 }
 
 func printHelp() {
 	fmt.Println("Todo Store")
-	fmt.Println("USAGE: todo [--backend=sqlite|file|cloud] [COMMAND] [FLAGS] [ARGUMENT]")
+	fmt.Println("USAGE: todo [--backend=sqlite|file|cloud|remote] [--timeout=DURATION] [--output=FORMAT] [COMMAND] [FLAGS] [ARGUMENT]")
+	fmt.Println()
+	fmt.Println("Global flags")
+	fmt.Printf("\t--backend\tsqlite|file|cloud|remote (overrides $TODO_BACKEND)\n")
+	fmt.Printf("\t--timeout\tfail a storage operation after this long, e.g. 5s\n")
+	fmt.Printf("\t--output\ttable|json|ndjson|yaml|csv|tsv, for list and show\n")
+	fmt.Printf("\t--fields\tfields to output, e.g. id,name,due_date (not for table)\n")
+	fmt.Printf("\t--format\tGo template per item, e.g. '{{.ID}} {{.Name}} {{date .DueDate}}'\n")
 	fmt.Println()
 	fmt.Println("Commands")
 	fmt.Printf("\tlist, l, ps, ls \t- list todo items\n")
 	fmt.Printf("\t\t--all\t\tshow done items too\n")
 	fmt.Printf("\t\t--done\t\tshow only done items\n")
 	fmt.Printf("\t\t--priority\tfilter by priority: low|medium|high\n")
-	fmt.Printf("\t\t--tag\t\tfilter by tag\n")
+	fmt.Printf("\t\t--tag\t\tfilter by tag (repeatable, all must match)\n")
+	fmt.Printf("\t\t--project\tshow only items in this project, sub-projects included\n")
 	fmt.Printf("\t\t--overdue\tshow only overdue items\n")
+	fmt.Printf("\t\t--include-deferred\tshow items whose start date is still to come too\n")
+	fmt.Printf("\t\t--sort\t\te.g. due,-priority,created ('-' for descending)\n")
+	fmt.Printf("\t\t\t\tfields: id, due, priority, created, updated, name, done\n")
+	fmt.Printf("\t\t--limit\t\tshow at most this many items\n")
+	fmt.Printf("\t\t--offset\tskip this many items\n")
+	fmt.Printf("\t\t--cursor\tcontinue where the previous page ended\n")
+	fmt.Printf("\t\t[filter]\te.g. 'tag:work and (priority:high or due<2026-11-01) and not tag:blocked'\n")
+	fmt.Printf("\t\t\t\tfields: tag, priority, due, done, name, created, updated, id, parent\n")
+	fmt.Printf("\t\t\t\toperators: : = != < <= > >=, joined with and, or, not, ( )\n")
+	fmt.Printf("\t\t\t\tdates: YYYY-MM-DD or as todo date takes, e.g. due<tomorrow, due<\"next fri\"\n")
+	fmt.Println()
+	fmt.Printf("\tsearch, find, s <query>\t- search item names and tags, best match first\n")
+	fmt.Printf("\t\t\t\twords, \"phrases\", prefix*, AND, OR, NOT or -word, (groups)\n")
+	fmt.Printf("\t\t--all\t\tsearch done items too\n")
+	fmt.Printf("\t\t--done\t\tsearch only done items\n")
+	fmt.Printf("\t\t(put -- before a query starting with -word)\n")
 	fmt.Println()
-	fmt.Printf("\tdelete, remove, d, rm \t- delete a todo item by id\n")
+	fmt.Printf("\tdelete, remove, d, rm \t- move a todo item to the trash by id\n")
+	fmt.Printf("\t\t--cascade\talso delete subtasks (otherwise refused)\n")
 	fmt.Println()
 	fmt.Printf("\tadd, create, put, a \t- add a new item\n")
 	fmt.Printf("\t\t--priority\tlow|medium|high\n")
-	fmt.Printf("\t\t--due\t\tYYYY-MM-DD\n")
+	fmt.Printf("\t\t--due\t\te.g. 2026-11-01, tomorrow, fri, 'in 3 days', +2w, eom, eod\n")
+	fmt.Printf("\t\t--start\t\thide the item until then, as for snooze\n")
 	fmt.Printf("\t\t--tag\t\ttag (repeatable)\n")
+	fmt.Printf("\t\t--parent\tID of the item this is a subtask of\n")
+	fmt.Printf("\t\t--project\tproject, e.g. work/clients (default: todo project default)\n")
+	fmt.Printf("\t\t--repeat\te.g. daily, weekly, 'every 2 weeks on mon,fri',\n")
+	fmt.Printf("\t\t\t\t'every last fri until 2026-12-31', 'monthly for 6 times'\n")
 	fmt.Printf("\t\t(no text = opens $EDITOR)\n")
 	fmt.Println()
 	fmt.Printf("\te, edit, update \t- edit an existing todo by id\n")
 	fmt.Printf("\t\t--name\t\tnew name\n")
 	fmt.Printf("\t\t--priority\tlow|medium|high\n")
-	fmt.Printf("\t\t--due\t\tYYYY-MM-DD (use '-' to clear)\n")
+	fmt.Printf("\t\t--due\t\ta date, as for add (use '-' to clear)\n")
+	fmt.Printf("\t\t--start\t\ta start date, as for snooze (use '-' to clear)\n")
 	fmt.Printf("\t\t--tag\t\ttag (repeatable, replaces all tags)\n")
+	fmt.Printf("\t\t--parent\tparent ID (use '-' to detach)\n")
+	fmt.Printf("\t\t--project\tproject to move it to (use '-' for none)\n")
+	fmt.Printf("\t\t--repeat\trepeat rule (use '-' to stop repeating)\n")
 	fmt.Printf("\t\t--done\t\ttrue|false\n")
 	fmt.Printf("\t\t(no text = opens $EDITOR)\n")
 	fmt.Println()
-	fmt.Printf("\tdone <id>\t\t- mark item as complete\n")
+	fmt.Printf("\tshow, get <id>\t\t- show one item (same as todo <id>)\n")
+	fmt.Printf("\tdone <id>\t\t- mark item as complete (adds the next one if it repeats)\n")
+	fmt.Printf("\t\t--cascade\talso mark subtasks complete\n")
 	fmt.Printf("\treopen <id>\t\t- mark item as not complete\n")
-	fmt.Printf("\tclearall\t\t- delete all todo items\n")
+	fmt.Printf("\tsnooze <id> <when>\t- hide an item from list until then: a duration from now,\n")
+	fmt.Printf("\t\t\t\te.g. 3d, 2h, 1w, or a date, e.g. mon, 'next month', 'tomorrow 9am'\n")
+	fmt.Printf("\thistory <id>\t\t- list every change made to an item's fields, and by whom\n")
+	fmt.Printf("\tlog\t\t\t- list the changes made to any item\n")
+	fmt.Printf("\t\t--since\t\tsince this long ago or this date, e.g. 7d, 12h, yesterday, 2026-10-01 (default 7d)\n")
+	fmt.Printf("\tdate <expression>\t- show the date an expression like 'next monday' or +2w stands for\n")
+	fmt.Printf("\ttui\t\t\t- browse and edit items full screen in the terminal\n")
+	fmt.Printf("\tproject add <path>\t- add a project, and any parents missing, e.g. work/clients\n")
+	fmt.Printf("\tproject list\t\t- list projects with their open and done items\n")
+	fmt.Printf("\t\t--all\t\tshow archived projects too\n")
+	fmt.Printf("\tproject rename <project> <name>\t- rename a project, or move it with a path\n")
+	fmt.Printf("\tproject archive <project>\t- archive a project and those inside it (unarchive to undo)\n")
+	fmt.Printf("\tproject default [<project>|-]\t- show, set or clear the project new items go in\n")
+	fmt.Printf("\tserve\t\t\t- serve a JSON REST API over the current backend\n")
+	fmt.Printf("\t\t--addr\t\taddress to listen on (default localhost:8080; :8080 for the LAN)\n")
+	fmt.Printf("\t\t--token\t\tbearer token clients must send (default $TODO_SERVE_TOKEN)\n")
+	fmt.Printf("\tcaldav\t\t\t- serve the current backend as a CalDAV task list\n")
+	fmt.Printf("\t\t--addr\t\taddress to listen on (default localhost:5232; :5232 for the LAN)\n")
+	fmt.Printf("\t\t--token\t\tpassword clients must send (default $TODO_CALDAV_TOKEN)\n")
+	fmt.Printf("\tsync --with <backend>\t- sync the current backend with another, both ways\n")
+	fmt.Printf("\t\t--strategy\tmerge|lww: settle items edited in both (default merge)\n")
+	fmt.Printf("\t\t--dry-run\tshow what would change without changing anything\n")
+	fmt.Printf("\tmigrate --to <backend>\t- copy every item to another backend, keeping IDs\n")
+	fmt.Printf("\t\t--from\t\tbackend to copy from (default the current one)\n")
+	fmt.Printf("\t\t--dry-run\tshow what would be copied without copying anything\n")
+	fmt.Printf("\texport --format <format>\t- write every item to stdout: todotxt|ics|markdown|taskwarrior\n")
+	fmt.Printf("\t\t--group\t\tfor markdown, a heading per tag|priority\n")
+	fmt.Printf("\timport [file]\t\t- add the items in file (or stdin) as new items\n")
+	fmt.Printf("\t\t--format\ttodotxt|ics|markdown|taskwarrior (default from the file's extension)\n")
+	fmt.Printf("\ttrash\t\t\t- list the items in the trash\n")
+	fmt.Printf("\ttrash empty\t\t- delete the items in the trash for good\n")
+	fmt.Printf("\t\t--older-than\tonly those deleted longer ago than this, e.g. 30d, 2w, 12h\n")
+	fmt.Printf("\trestore <id>...\t\t- take items, and their subtasks, out of the trash\n")
+	fmt.Printf("\tclearall\t\t- move all todo items to the trash, after asking\n")
+	fmt.Printf("\t\t--yes, -y\tdon't ask first\n")
+	fmt.Printf("\t\t--permanent\tdelete them for good, emptying the trash too\n")
+	fmt.Printf("\tundo [n]\t\t- revert the last n commands that changed items (default 1)\n")
+	fmt.Printf("\t\t--list\t\tlist what can be undone, most recent first\n")
+	fmt.Printf("\tredo [n]\t\t- make the last n undone commands again (default 1)\n")
+	fmt.Printf("\t\t--list\t\tlist what can be redone, most recently undone first\n")
 	fmt.Printf("\thelp, -h, --help\t- show this help text\n")
 	fmt.Println()
 	fmt.Println("Environment")
-	fmt.Printf("\tTODO_BACKEND=sqlite|file|cloud\t- select backend at runtime\n")
+	fmt.Printf("\tTODO_BACKEND=sqlite|file|cloud|remote\t- select backend at runtime\n")
+	fmt.Printf("\tTODO_REMOTE_URL\t\t\t- URL of the todo server used by the remote backend\n")
+	fmt.Printf("\tTODO_REMOTE_TOKEN\t\t- bearer token sent to that server\n")
+	fmt.Printf("\tTODO_SERVE_TOKEN\t\t- bearer token required by todo serve\n")
+	fmt.Printf("\tTODO_CALDAV_TOKEN\t\t- password required by todo caldav\n")
+	fmt.Println()
+	fmt.Println("Exit status")
+	fmt.Printf("\t0\tsuccess\n")
+	fmt.Printf("\t1\tgeneral or usage error\n")
+	fmt.Printf("\t3\titem not found\n")
+	fmt.Printf("\t4\tconflicting change\n")
+	fmt.Printf("\t5\tstorage backend unavailable or timed out\n")
 }
 
 const (
 	ansiRed   = "\033[31m"
+	ansiBold  = "\033[1m"
 	ansiReset = "\033[0m"
 )
 
-func printItems(store s.TodoStore, opts s.ListOptions) {
-	items := store.GetAllItems(opts)
+func printItems(ctx context.Context, store s.TodoStore, opts s.ListOptions, out *output) error {
+	items, err := store.GetAllItems(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	if items.NextCursor != "" {
+		defer fmt.Fprintf(os.Stderr, "More items follow; continue with --cursor %s\n", items.NextCursor)
+	}
+
+	if !out.isTable() {
+		return out.writeItems(os.Stdout, items.Items)
+	}
 
 	const maxChars = 100
 
-	// Pre-compute display names (first line only, truncated) and track max width.
+	// Pre-compute display names (first line only, truncated, indented under
+	// their parent) and track max width.
 	type row struct {
 		item      internal.Todo
 		printName string
 	}
-	rows := make([]row, len(items.Items))
+	nested := internal.Nest(items.Items)
+	rows := make([]row, len(nested))
 	nameColWidth := 4
-	for i, item := range items.Items {
+	for i, n := range nested {
+		item := n.Item
 		firstLine := item.Name
 		hasMoreLines := false
 		if idx := strings.IndexByte(item.Name, '\n'); idx != -1 {
@@ -382,6 +1004,9 @@ func printItems(store s.TodoStore, opts s.ListOptions) {
 		} else if hasMoreLines {
 			printName += " …"
 		}
+		if n.Depth > 0 {
+			printName = strings.Repeat("  ", n.Depth-1) + "└ " + printName
+		}
 		rows[i] = row{item, printName}
 		if w := utf8.RuneCountInString(printName); w > nameColWidth {
 			nameColWidth = w
@@ -393,9 +1018,18 @@ func printItems(store s.TodoStore, opts s.ListOptions) {
 		headerPadding = 0
 	}
 
-	fmt.Printf("ID\tSt   Pri  Item%s\tTags\tDue\tCreated\n",
-		strings.Repeat(" ", headerPadding))
+	// Items in the trash show when they were deleted instead.
+	dateHeader := "Created"
+	if opts.Trashed {
+		dateHeader = "Deleted"
+	}
+	fmt.Printf("ID\tSt   Pri  Item%s\tTags\tDue\t%s\n",
+		strings.Repeat(" ", headerPadding), dateHeader)
 
+	paths, err := projectPaths(ctx, store, items.Items)
+	if err != nil {
+		return err
+	}
 	now := time.Now()
 
 	for _, r := range rows {
@@ -419,8 +1053,11 @@ func printItems(store s.TodoStore, opts s.ListOptions) {
 		nameLen := utf8.RuneCountInString(printName)
 		namePadding := strings.Repeat(" ", nameColWidth-nameLen)
 
-		// Tags.
+		// Project and tags.
 		tagStr := ""
+		if item.Project != 0 {
+			tagStr = "+" + paths[item.Project] + " "
+		}
 		for _, tg := range item.Tags {
 			tagStr += "#" + tg + " "
 		}
@@ -429,13 +1066,27 @@ func printItems(store s.TodoStore, opts s.ListOptions) {
 		// Due date.
 		dueStr := ""
 		if item.DueDate != nil {
-			formatted := item.DueDate.Format("Mon 02 Jan 06")
-			if item.DueDate.Before(now) {
+			formatted := item.FormatDue("Mon 02 Jan 06", time.Local)
+			switch {
+			case item.Overdue(now):
 				dueStr = ansiRed + formatted + ansiReset
-			} else {
+			case item.DueToday(now):
+				dueStr = ansiBold + formatted + ansiReset
+			default:
 				dueStr = formatted
 			}
 		}
+		if item.Recurrence != "" {
+			dueStr = strings.TrimSpace(dueStr + " ↻")
+		}
+		if item.Deferred(now) {
+			dueStr = strings.TrimSpace(dueStr + " ⏸")
+		}
+
+		date := item.CreatedAt
+		if opts.Trashed && item.DeletedAt != nil {
+			date = *item.DeletedAt
+		}
 
 		fmt.Printf("[%d]\t%s %s  %s%s\t%s\t%s\t%s\n",
 			item.ID,
@@ -445,12 +1096,39 @@ func printItems(store s.TodoStore, opts s.ListOptions) {
 			namePadding,
 			tagStr,
 			dueStr,
-			item.CreatedAt.Format("Mon 02 Jan 06"),
+			date.Format("Mon 02 Jan 06"),
 		)
 	}
+	return nil
+}
+
+// printResults List search matches with the matched words in bold.
+func printResults(results []search.Result) {
+	if len(results) == 0 {
+		fmt.Println("No matching items.")
+		return
+	}
+
+	highlight := strings.NewReplacer(
+		search.MarkStart, ansiBold,
+		search.MarkEnd, ansiReset,
+		"\r\n", " ",
+		"\n", " ",
+	)
+	for _, r := range results {
+		doneMarker := "[ ]"
+		if r.Item.Done {
+			doneMarker = "[x]"
+		}
+		fmt.Printf("[%d]\t%s  %s\n", r.Item.ID, doneMarker, highlight.Replace(r.Snippet))
+	}
 }
 
-func printItem(item *internal.Todo) {
+func printItem(ctx context.Context, store s.TodoStore, item *internal.Todo, out *output) error {
+	if !out.isTable() {
+		return out.writeItem(os.Stdout, *item)
+	}
+
 	doneStr := "no"
 	if item.Done {
 		doneStr = "yes"
@@ -459,14 +1137,49 @@ func printItem(item *internal.Todo) {
 	fmt.Printf("Item:\t\t%s\n", item.Name)
 	fmt.Printf("Done:\t\t%s\n", doneStr)
 	fmt.Printf("Priority:\t%s\n", item.Priority)
+	if item.ParentID != 0 {
+		fmt.Printf("Parent:\t\t%d\n", item.ParentID)
+	}
+	if item.Project != 0 {
+		paths, err := projectPaths(ctx, store, []internal.Todo{*item})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Project:\t%s\n", paths[item.Project])
+	}
 	if item.DueDate != nil {
-		fmt.Printf("Due:\t\t%s\n", item.DueDate.Format("Mon 02 Jan 06"))
+		due := item.FormatDue("Mon 02 Jan 06", time.Local)
+		if item.DueTimed && item.DueZone != "" && item.DueZone != internal.LocalZone() {
+			// Also show the deadline as it was set, e.g. "(09:00 America/New_York)".
+			due += fmt.Sprintf(" (%s %s)", item.DueDate.In(item.DueLocation(time.Local)).Format("15:04"), item.DueZone)
+		}
+		fmt.Printf("Due:\t\t%s\n", due)
+	}
+	if item.StartDate != nil {
+		fmt.Printf("Starts:\t\t%s\n", item.StartDate.In(time.Local).Format("Mon 02 Jan 06 15:04"))
+	}
+	if item.Recurrence != "" {
+		repeat := item.Recurrence
+		if rule, err := recur.Parse(item.Recurrence); err == nil {
+			repeat = rule.Describe()
+		}
+		fmt.Printf("Repeats:\t%s\n", repeat)
 	}
 	if len(item.Tags) > 0 {
 		fmt.Printf("Tags:\t\t%s\n", strings.Join(item.Tags, ", "))
 	}
 	fmt.Printf("Created At:\t%s\n", item.CreatedAt.Format("Mon 02 Jan 06 15:04"))
 	fmt.Printf("Updated At:\t%s\n", item.UpdatedAt.Format("Mon 02 Jan 06 15:04"))
+	return nil
+}
+
+func containsId(ids []int, id int) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
 }
 
 func parseIds(possibleIds ...string) []int {
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	s "github.com/tcooper-uk/go-todo/internal/storage"
+)
+
+// migrate Copy every item from the backend named from, or current if it is
+// empty, to the backend named to.
+func migrate(ctx context.Context, current backend, from, to string, dryRun bool, timeout time.Duration) error {
+	source := current
+	if from != "" {
+		fromMode, ok := backendModes[from]
+		if !ok {
+			return fmt.Errorf("unknown --from backend %q (expected sqlite, file, cloud or remote)", from)
+		}
+		if fromMode != current.mode {
+			var err error
+			if source, err = openBackend(ctx, fromMode, timeout); err != nil {
+				return err
+			}
+		}
+	}
+
+	toMode, ok := backendModes[to]
+	if !ok {
+		return fmt.Errorf("--to needs the backend to migrate to: sqlite, file, cloud or remote")
+	}
+	if toMode == source.mode {
+		return fmt.Errorf("cannot migrate the %s backend to itself", source.name())
+	}
+	target := current
+	if toMode != current.mode {
+		var err error
+		if target, err = openBackend(ctx, toMode, timeout); err != nil {
+			return err
+		}
+	}
+
+	report, err := s.Migrate(ctx, source.store, target.store, s.MigrateOptions{DryRun: dryRun})
+	if report != nil {
+		printMigrateReport(report, source.name(), target.name(), dryRun, err == nil)
+	}
+	return err
+}
+
+func printMigrateReport(report *s.MigrateReport, from, to string, dryRun, verified bool) {
+	if dryRun {
+		fmt.Printf("Would migrate %s to %s: %d to add, %d to update, %d unchanged.\n",
+			from, to, len(report.Added), len(report.Updated), report.Unchanged)
+	} else {
+		fmt.Printf("Migrated %s to %s: %d added, %d updated, %d unchanged.\n",
+			from, to, len(report.Added), len(report.Updated), report.Unchanged)
+	}
+
+	if len(report.Projects) > 0 {
+		verb := "Copied"
+		if dryRun {
+			verb = "Would copy"
+		}
+		fmt.Printf("%s %d project(s).\n", verb, len(report.Projects))
+	}
+	for _, item := range report.Added {
+		fmt.Printf("+ [%d] %s\n", item.ID, item.Name)
+	}
+	for _, u := range report.Updated {
+		fmt.Printf("~ [%d] %s (%s)\n", u.Item.ID, u.Item.Name, strings.Join(u.Fields, ", "))
+	}
+	if report.OnlyInTarget > 0 {
+		fmt.Printf("%d item(s) only in %s left as they are.\n", report.OnlyInTarget, to)
+	}
+	if !dryRun && verified {
+		fmt.Printf("Verified %d item(s) in %s, sha256 %s.\n", report.Count, to, report.Checksum)
+	}
+}
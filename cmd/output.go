@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/tcooper-uk/go-todo/internal"
+)
+
+// Output formats accepted by --output.
+const (
+	outputTable    = "table"
+	outputJSON     = "json"
+	outputNDJSON   = "ndjson"
+	outputYAML     = "yaml"
+	outputCSV      = "csv"
+	outputTSV      = "tsv"
+	outputTemplate = "template"
+)
+
+// outputField One column of machine-readable output, named after the
+// internal.Todo JSON tag it comes from.
+type outputField struct {
+	name  string
+	value func(item internal.Todo) any
+}
+
+// outputFields Every field, in the order they are written.
+var outputFields = []outputField{
+	{"id", func(item internal.Todo) any { return item.ID }},
+	{"name", func(item internal.Todo) any { return item.Name }},
+	{"done", func(item internal.Todo) any { return item.Done }},
+	{"priority", func(item internal.Todo) any { return string(item.Priority) }},
+	{"due_date", func(item internal.Todo) any {
+		if item.DueDate == nil {
+			return nil
+		}
+		return item.DueDate.Format(time.RFC3339)
+	}},
+	{"due_timed", func(item internal.Todo) any { return item.DueTimed }},
+	{"due_zone", func(item internal.Todo) any { return item.DueZone }},
+	{"start_date", func(item internal.Todo) any {
+		if item.StartDate == nil {
+			return nil
+		}
+		return item.StartDate.Format(time.RFC3339)
+	}},
+	{"tags", func(item internal.Todo) any {
+		if item.Tags == nil {
+			return []string{}
+		}
+		return item.Tags
+	}},
+	{"parent_id", func(item internal.Todo) any { return item.ParentID }},
+	{"project", func(item internal.Todo) any { return item.Project }},
+	{"recurrence", func(item internal.Todo) any { return item.Recurrence }},
+	{"extras", func(item internal.Todo) any {
+		if item.Extras == nil {
+			return map[string]any{}
+		}
+		return item.Extras
+	}},
+	{"created_at", func(item internal.Todo) any { return item.CreatedAt.Format(time.RFC3339) }},
+	{"updated_at", func(item internal.Todo) any { return item.UpdatedAt.Format(time.RFC3339) }},
+	{"deleted_at", func(item internal.Todo) any {
+		if item.DeletedAt == nil {
+			return nil
+		}
+		return item.DeletedAt.Format(time.RFC3339)
+	}},
+}
+
+// output Writes items in the format chosen by the global --output,
+// --fields and --format flags.
+type output struct {
+	format   string
+	fields   []outputField
+	template *template.Template
+}
+
+// newOutput Check the output flags. fields is a comma-separated list of
+// field names; a non-empty tmpl selects template output.
+func newOutput(format, fields, tmpl string) (*output, error) {
+	out := &output{format: strings.ToLower(format), fields: outputFields}
+
+	if tmpl != "" {
+		if out.format != "" && out.format != outputTemplate {
+			return nil, fmt.Errorf("--format cannot be combined with --output %s", format)
+		}
+		t, err := template.New("format").Funcs(templateFuncs).Parse(tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --format: %w", err)
+		}
+		out.format = outputTemplate
+		out.template = t
+	}
+
+	switch out.format {
+	case "":
+		out.format = outputTable
+	case outputTable, outputJSON, outputNDJSON, outputYAML, outputCSV, outputTSV:
+	case outputTemplate:
+		if out.template == nil {
+			return nil, fmt.Errorf("--output template needs a --format")
+		}
+	default:
+		return nil, fmt.Errorf("unknown output format %q (expected table, json, ndjson, yaml, csv or tsv)", format)
+	}
+
+	if fields != "" {
+		if out.format == outputTable || out.format == outputTemplate {
+			return nil, fmt.Errorf("--fields needs --output json, ndjson, yaml, csv or tsv")
+		}
+		selected, err := selectFields(fields)
+		if err != nil {
+			return nil, err
+		}
+		out.fields = selected
+	}
+
+	return out, nil
+}
+
+func selectFields(list string) ([]outputField, error) {
+	var selected []outputField
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, f := range outputFields {
+			if f.name == name {
+				selected = append(selected, f)
+				found = true
+				break
+			}
+		}
+		if !found {
+			names := make([]string, len(outputFields))
+			for i, f := range outputFields {
+				names[i] = f.name
+			}
+			return nil, fmt.Errorf("unknown field %q (expected one of %s)", name, strings.Join(names, ", "))
+		}
+	}
+	return selected, nil
+}
+
+// templateFuncs Helpers available to --format templates.
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+	// date Format a time, or a due date which may be nil, as YYYY-MM-DD.
+	"date": func(v any) string {
+		switch t := v.(type) {
+		case time.Time:
+			return t.Format("2006-01-02")
+		case *time.Time:
+			if t != nil {
+				return t.Format("2006-01-02")
+			}
+		}
+		return ""
+	},
+}
+
+func (o *output) isTable() bool {
+	return o.format == outputTable
+}
+
+// writeItems Write a listing. JSON and YAML produce a list, even of one.
+func (o *output) writeItems(w io.Writer, items []internal.Todo) error {
+	switch o.format {
+	case outputJSON:
+		objects := make([]json.RawMessage, len(items))
+		for i, item := range items {
+			obj, err := o.jsonObject(item)
+			if err != nil {
+				return err
+			}
+			objects[i] = obj
+		}
+		return writeJSON(w, objects)
+	case outputNDJSON:
+		for _, item := range items {
+			obj, err := o.jsonObject(item)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "%s\n", obj); err != nil {
+				return err
+			}
+		}
+		return nil
+	case outputYAML:
+		list := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, item := range items {
+			node, err := o.yamlNode(item)
+			if err != nil {
+				return err
+			}
+			list.Content = append(list.Content, node)
+		}
+		if len(items) == 0 {
+			list.Style = yaml.FlowStyle
+		}
+		return writeYAML(w, list)
+	case outputCSV, outputTSV:
+		return o.writeTable(w, items)
+	case outputTemplate:
+		for _, item := range items {
+			if err := o.writeTemplate(w, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("cannot write items as %s", o.format)
+}
+
+// writeItem Write a single item. JSON and YAML produce one object.
+func (o *output) writeItem(w io.Writer, item internal.Todo) error {
+	switch o.format {
+	case outputJSON:
+		obj, err := o.jsonObject(item)
+		if err != nil {
+			return err
+		}
+		return writeJSON(w, obj)
+	case outputYAML:
+		node, err := o.yamlNode(item)
+		if err != nil {
+			return err
+		}
+		return writeYAML(w, node)
+	}
+	return o.writeItems(w, []internal.Todo{item})
+}
+
+// jsonObject Encode the selected fields of item, keeping their order.
+func (o *output) jsonObject(item internal.Todo) (json.RawMessage, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range o.fields {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		value, err := json.Marshal(f.value(item))
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, "%q:%s", f.name, value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// yamlNode A mapping of the selected fields of item, keeping their order.
+func (o *output) yamlNode(item internal.Todo) (*yaml.Node, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range o.fields {
+		value := &yaml.Node{}
+		if err := value.Encode(f.value(item)); err != nil {
+			return nil, err
+		}
+		if value.Kind == yaml.SequenceNode && len(value.Content) == 0 {
+			value.Style = yaml.FlowStyle
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: f.name}, value)
+	}
+	return node, nil
+}
+
+func writeYAML(w io.Writer, node *yaml.Node) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// writeTable Write a header row of field names, then a row per item.
+func (o *output) writeTable(w io.Writer, items []internal.Todo) error {
+	rows := [][]string{make([]string, len(o.fields))}
+	for i, f := range o.fields {
+		rows[0][i] = f.name
+	}
+	for _, item := range items {
+		row := make([]string, len(o.fields))
+		for i, f := range o.fields {
+			row[i] = cell(f.value(item))
+		}
+		rows = append(rows, row)
+	}
+
+	if o.format == outputTSV {
+		// Tabs and newlines within a value are escaped, so every line is
+		// one row and every tab separates a column.
+		escape := strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+		for _, row := range rows {
+			for i := range row {
+				row[i] = escape.Replace(row[i])
+			}
+			if _, err := fmt.Fprintln(w, strings.Join(row, "\t")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// cell A field value as CSV or TSV text. Tags are joined with commas, and
+// extras written as JSON.
+func cell(v any) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case int:
+		return strconv.Itoa(value)
+	case bool:
+		return strconv.FormatBool(value)
+	case []string:
+		return strings.Join(value, ",")
+	case map[string]any:
+		if len(value) == 0 {
+			return ""
+		}
+		data, _ := json.Marshal(value)
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
+
+func (o *output) writeTemplate(w io.Writer, item internal.Todo) error {
+	if err := o.template.Execute(w, item); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/config"
+	s "github.com/tcooper-uk/go-todo/internal/storage"
+)
+
+// configPath Where the user's settings are kept.
+func configPath() string {
+	return config.Path(os.Getenv("HOME"))
+}
+
+// projectCommand Run todo project and its subcommands.
+func projectCommand(ctx context.Context, store s.TodoStore, args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	sub, args := args[0], args[1:]
+
+	switch sub {
+	case "add":
+		if len(args) != 1 {
+			fail("Usage: todo project add <name>, e.g. work or work/clients")
+		}
+		return addProject(ctx, store, args[0])
+
+	case "list", "ls":
+		fs := flag.NewFlagSet("project list", flag.ExitOnError)
+		all := fs.Bool("all", false, "show archived projects too")
+		fs.Parse(args)
+		return listProjects(ctx, store, *all)
+
+	case "rename", "mv":
+		if len(args) != 2 {
+			fail("Usage: todo project rename <project> <new name>")
+		}
+		return renameProject(ctx, store, args[0], args[1])
+
+	case "archive", "unarchive":
+		if len(args) != 1 {
+			fail("Usage: todo project %s <project>", sub)
+		}
+		return archiveProject(ctx, store, args[0], sub == "archive")
+
+	case "default":
+		if len(args) > 1 {
+			fail("Usage: todo project default [<project>|-]")
+		}
+		return defaultProject(ctx, store, args)
+	}
+	fail("Unknown project command %s — use add, list, rename, archive, unarchive or default", sub)
+	return nil
+}
+
+// addProject Add the project at path, and any of its parents missing.
+func addProject(ctx context.Context, store s.TodoStore, path string) error {
+	projects, err := s.Projects(ctx, store)
+	if err != nil {
+		return err
+	}
+
+	parent := 0
+	names := strings.Split(strings.Trim(path, "/"), "/")
+	for i, name := range names {
+		if existing := childProject(projects, parent, name); existing != nil {
+			if i == len(names)-1 {
+				return fmt.Errorf("%w: there is already a project called %s",
+					s.ErrConflict, internal.ProjectPath(projects, existing.ID))
+			}
+			parent = existing.ID
+			continue
+		}
+
+		added, err := s.PutProject(ctx, store, internal.Project{Name: name, ParentID: parent})
+		if err != nil {
+			return err
+		}
+		projects = append(projects, *added)
+		fmt.Printf("Added project [%d] %s\n", added.ID, internal.ProjectPath(projects, added.ID))
+		parent = added.ID
+	}
+	return nil
+}
+
+// childProject The project called name in the project with id parent, or
+// at the top level if parent is 0.
+func childProject(projects []internal.Project, parent int, name string) *internal.Project {
+	name = strings.TrimSpace(name)
+	for i, p := range projects {
+		if p.ParentID == parent && strings.EqualFold(p.Name, name) {
+			return &projects[i]
+		}
+	}
+	return nil
+}
+
+// listProjects Print the project tree with how many open and done items
+// each project holds, sub-projects included.
+func listProjects(ctx context.Context, store s.TodoStore, all bool) error {
+	projects, err := s.Projects(ctx, store)
+	if err != nil {
+		return err
+	}
+	if len(projects) == 0 {
+		fmt.Println("No projects yet; add one with todo project add <name>.")
+		return nil
+	}
+	items, err := store.GetAllItems(ctx, s.ListOptions{ShowDone: true, IncludeDeferred: true})
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load(configPath())
+	if err != nil {
+		return err
+	}
+
+	type counts struct{ open, done int }
+	direct := make(map[int]counts)
+	for _, item := range items.Items {
+		c := direct[item.Project]
+		if item.Done {
+			c.done++
+		} else {
+			c.open++
+		}
+		direct[item.Project] = c
+	}
+
+	archived := make(map[int]bool)
+	for _, n := range internal.NestProjects(projects) {
+		if n.Project.Archived || archived[n.Project.ParentID] {
+			archived[n.Project.ID] = true
+		}
+	}
+
+	fmt.Printf("ID\tOpen\tDone\tProject\n")
+	for _, n := range internal.NestProjects(projects) {
+		p := n.Project
+		if archived[p.ID] && !all {
+			continue
+		}
+		var total counts
+		for _, id := range internal.SubProjects(projects, p.ID) {
+			total.open += direct[id].open
+			total.done += direct[id].done
+		}
+
+		name := strings.Repeat("  ", n.Depth) + p.Name
+		if p.Archived {
+			name += " (archived)"
+		}
+		if cfg.DefaultProject != "" && strings.EqualFold(cfg.DefaultProject, internal.ProjectPath(projects, p.ID)) {
+			name += " (default)"
+		}
+		fmt.Printf("[%d]\t%d\t%d\t%s\n", p.ID, total.open, total.done, name)
+	}
+	if c := direct[0]; c.open+c.done > 0 {
+		fmt.Printf("\t%d\t%d\t(no project)\n", c.open, c.done)
+	}
+	return nil
+}
+
+// renameProject Give the project name refers to a new name, or move it
+// to a new path if newName has a "/" in it, e.g. work/customers.
+func renameProject(ctx context.Context, store s.TodoStore, name, newName string) error {
+	projects, err := s.Projects(ctx, store)
+	if err != nil {
+		return err
+	}
+	project, err := s.FindProject(projects, name)
+	if err != nil {
+		return err
+	}
+	oldPath := internal.ProjectPath(projects, project.ID)
+
+	newName = strings.TrimSpace(newName)
+	project.Name, project.UpdatedAt = newName, time.Time{}
+	if i := strings.LastIndex(newName, "/"); i >= 0 {
+		project.Name, project.ParentID = newName[i+1:], 0
+		if parentPath := strings.Trim(newName[:i], "/"); parentPath != "" {
+			parent, err := s.FindProject(projects, parentPath)
+			if err != nil {
+				return err
+			}
+			project.ParentID = parent.ID
+		}
+	}
+
+	// The default project is kept by path, so follow it if it moves.
+	cfg, err := config.Load(configPath())
+	if err != nil {
+		return err
+	}
+	defaultID := 0
+	if cfg.DefaultProject != "" {
+		if p, err := s.FindProject(projects, cfg.DefaultProject); err == nil {
+			defaultID = p.ID
+		}
+	}
+
+	renamed, err := s.PutProject(ctx, store, *project)
+	if err != nil {
+		return err
+	}
+	for i := range projects {
+		if projects[i].ID == renamed.ID {
+			projects[i] = *renamed
+		}
+	}
+	fmt.Printf("Renamed project %s to %s\n", oldPath, internal.ProjectPath(projects, renamed.ID))
+
+	if defaultID != 0 && slices.Contains(internal.SubProjects(projects, renamed.ID), defaultID) {
+		cfg.DefaultProject = internal.ProjectPath(projects, defaultID)
+		return cfg.Save(configPath())
+	}
+	return nil
+}
+
+// archiveProject Archive, or unarchive, the project name refers to and
+// every project below it.
+func archiveProject(ctx context.Context, store s.TodoStore, name string, archive bool) error {
+	projects, err := s.Projects(ctx, store)
+	if err != nil {
+		return err
+	}
+	project, err := s.FindProject(projects, name)
+	if err != nil {
+		return err
+	}
+
+	changed := 0
+	for _, id := range internal.SubProjects(projects, project.ID) {
+		for _, p := range projects {
+			if p.ID != id || p.Archived == archive {
+				continue
+			}
+			p.Archived, p.UpdatedAt = archive, time.Time{}
+			if _, err := s.PutProject(ctx, store, p); err != nil {
+				return err
+			}
+			changed++
+		}
+	}
+
+	verb := "Archived"
+	if !archive {
+		verb = "Unarchived"
+	}
+	fmt.Printf("%s %d project(s) in %s\n", verb, changed, internal.ProjectPath(projects, project.ID))
+	return nil
+}
+
+// defaultProject Show the project new items go in by default, set it to
+// the one args names, or clear it with "-".
+func defaultProject(ctx context.Context, store s.TodoStore, args []string) error {
+	cfg, err := config.Load(configPath())
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		if cfg.DefaultProject == "" {
+			fmt.Println("No default project.")
+		} else {
+			fmt.Println(cfg.DefaultProject)
+		}
+		return nil
+	}
+
+	if args[0] == "-" {
+		cfg.DefaultProject = ""
+		return cfg.Save(configPath())
+	}
+	id, err := resolveProject(ctx, store, args[0])
+	if err != nil {
+		return err
+	}
+	if err := s.CheckProject(ctx, store, id); err != nil {
+		return err
+	}
+	projects, err := s.Projects(ctx, store)
+	if err != nil {
+		return err
+	}
+	cfg.DefaultProject = internal.ProjectPath(projects, id)
+	if err := cfg.Save(configPath()); err != nil {
+		return err
+	}
+	fmt.Printf("New items go in %s unless given another --project.\n", cfg.DefaultProject)
+	return nil
+}
+
+// resolveProject The id of the project name refers to, by path or by its
+// own name.
+func resolveProject(ctx context.Context, store s.TodoStore, name string) (int, error) {
+	projects, err := s.Projects(ctx, store)
+	if err != nil {
+		return 0, err
+	}
+	project, err := s.FindProject(projects, name)
+	if err != nil {
+		return 0, err
+	}
+	return project.ID, nil
+}
+
+// newItemProject The project a new item goes in: the one --project names,
+// or else the default project, if one is set.
+func newItemProject(ctx context.Context, store s.TodoStore, flag string) (int, error) {
+	if flag == "" {
+		cfg, err := config.Load(configPath())
+		if err != nil {
+			return 0, err
+		}
+		if cfg.DefaultProject == "" {
+			return 0, nil
+		}
+		flag = cfg.DefaultProject
+	}
+	if flag == "-" {
+		return 0, nil
+	}
+
+	id, err := resolveProject(ctx, store, flag)
+	if err != nil {
+		return 0, err
+	}
+	return id, s.CheckProject(ctx, store, id)
+}
+
+// projectPaths The paths of the projects items are in, by id, or nil if
+// they are in none.
+func projectPaths(ctx context.Context, store s.TodoStore, items []internal.Todo) (map[int]string, error) {
+	inProject := false
+	for _, item := range items {
+		inProject = inProject || item.Project != 0
+	}
+	if !inProject {
+		return nil, nil
+	}
+
+	projects, err := s.Projects(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	paths := make(map[int]string, len(projects))
+	for _, p := range projects {
+		paths[p.ID] = internal.ProjectPath(projects, p.ID)
+	}
+	return paths, nil
+}
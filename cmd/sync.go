@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	s "github.com/tcooper-uk/go-todo/internal/storage"
+	"github.com/tcooper-uk/go-todo/internal/storage/db"
+)
+
+// backend An open store and where it lives.
+type backend struct {
+	mode     s.Mode
+	location string
+	store    s.TodoStore
+}
+
+func (b backend) name() string {
+	return backendName(b.mode)
+}
+
+// describe Identify the backend's data, for the sync state to be checked
+// against.
+func (b backend) describe() string {
+	switch b.mode {
+	case s.CloudMode:
+		return "cloud:" + db.ProjectId
+	case s.RemoteMode:
+		return "remote:" + os.Getenv("TODO_REMOTE_URL")
+	}
+	return b.name() + ":" + b.location
+}
+
+// openBackend Set up and open the backend for mode.
+func openBackend(ctx context.Context, mode s.Mode, timeout time.Duration) (backend, error) {
+	location, err := s.Setup(mode)
+	if err != nil {
+		return backend{}, err
+	}
+	store, err := openStore(ctx, mode, location, timeout)
+	if err != nil {
+		return backend{}, err
+	}
+	return backend{mode, location, store}, nil
+}
+
+// syncWith Sync current with the backend named other. The state of each
+// pair of backends is kept in ~/.todo, whichever of the two is current.
+func syncWith(ctx context.Context, current backend, other string, opts s.SyncOptions, timeout time.Duration) error {
+	otherMode, ok := backendModes[other]
+	if !ok {
+		return fmt.Errorf("--with needs the backend to sync with: sqlite, file, cloud or remote")
+	}
+	if otherMode == current.mode {
+		return fmt.Errorf("cannot sync the %s backend with itself", current.name())
+	}
+	if opts.Strategy != s.SyncMerge && opts.Strategy != s.SyncLastWriterWins {
+		return fmt.Errorf("unknown --strategy %q (expected merge or lww)", opts.Strategy)
+	}
+
+	otherBackend, err := openBackend(ctx, otherMode, timeout)
+	if err != nil {
+		return err
+	}
+
+	a, b := current, otherBackend
+	if a.name() > b.name() {
+		a, b = b, a
+	}
+
+	statePath := filepath.Join(os.Getenv("HOME"), ".todo", fmt.Sprintf("sync-%s-%s.json", a.name(), b.name()))
+	state, err := s.LoadSyncState(statePath)
+	if err != nil {
+		return err
+	}
+	if state.A == "" && len(state.Items) == 0 {
+		state.A, state.B = a.describe(), b.describe()
+	}
+	if state.A != a.describe() || state.B != b.describe() {
+		return fmt.Errorf("%s holds the sync state of %s and %s; delete it to sync %s and %s afresh",
+			statePath, state.A, state.B, a.describe(), b.describe())
+	}
+
+	report, err := s.Sync(ctx, a.store, b.store, state, opts)
+	if !opts.DryRun {
+		if saveErr := state.Save(statePath); err == nil {
+			err = saveErr
+		}
+	}
+	if report != nil {
+		printSyncReport(report, a.name(), b.name(), opts.DryRun)
+	}
+	return err
+}
+
+func printSyncReport(report *s.SyncReport, a, b string, dryRun bool) {
+	if report.Changes() == 0 && len(report.Conflicts) == 0 {
+		fmt.Printf("%s and %s are in sync.\n", a, b)
+		return
+	}
+
+	var changes []string
+	for _, c := range []struct {
+		count int
+		what  string
+	}{
+		{report.AddedToA, "added to " + a},
+		{report.AddedToB, "added to " + b},
+		{report.UpdatedInA, "updated in " + a},
+		{report.UpdatedInB, "updated in " + b},
+		{report.DeletedFromA, "deleted from " + a},
+		{report.DeletedFromB, "deleted from " + b},
+		{report.ProjectsAddedToA, "project(s) added to " + a},
+		{report.ProjectsAddedToB, "project(s) added to " + b},
+	} {
+		if c.count > 0 {
+			changes = append(changes, fmt.Sprintf("%d %s", c.count, c.what))
+		}
+	}
+	verb := "Synced"
+	if dryRun {
+		verb = "Would sync"
+	}
+	fmt.Printf("%s %s and %s: %s.\n", verb, a, b, strings.Join(changes, ", "))
+
+	names := map[s.SyncSide]string{s.SideA: a, s.SideB: b}
+	for _, c := range report.Conflicts {
+		fmt.Printf("Conflict: %q (%s %d, %s %d): ", c.Name, a, c.A, b, c.B)
+		if c.Fields == nil {
+			deletedFrom := a
+			if c.Kept == s.SideA {
+				deletedFrom = b
+			}
+			fmt.Printf("edited in %s but deleted from %s; kept it.\n", names[c.Kept], deletedFrom)
+			continue
+		}
+		fmt.Printf("%s edited in both; kept %s's version.\n", strings.Join(c.Fields, ", "), names[c.Kept])
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/ical"
+	"github.com/tcooper-uk/go-todo/internal/markdown"
+	s "github.com/tcooper-uk/go-todo/internal/storage"
+	"github.com/tcooper-uk/go-todo/internal/taskwarrior"
+	"github.com/tcooper-uk/go-todo/internal/todotxt"
+)
+
+// exchangeFormat A file format todo import and export understand.
+type exchangeFormat struct {
+	// ext The file extension import recognises the format by.
+	ext string
+	// read Parse a file, with a warning for each part of it that was
+	// skipped or only partly understood.
+	read  func(r io.Reader) ([]internal.Todo, []string, error)
+	write func(w io.Writer, items []internal.Todo) error
+	// writeGrouped Write items under a heading for each group, for formats
+	// that have headings.
+	writeGrouped func(w io.Writer, items []internal.Todo, group string) error
+}
+
+// exchangeFormats The formats --format accepts on import and export.
+var exchangeFormats = map[string]exchangeFormat{
+	"todotxt":     {ext: ".txt", read: withoutWarnings(todotxt.Read), write: todotxt.Write},
+	"ics":         {ext: ".ics", read: withoutWarnings(ical.Read), write: ical.Write},
+	"markdown":    {ext: ".md", read: readMarkdown, writeGrouped: writeMarkdown},
+	"taskwarrior": {ext: ".json", read: withoutWarnings(taskwarrior.Read), write: taskwarrior.Write},
+}
+
+func withoutWarnings(read func(r io.Reader) ([]internal.Todo, error)) func(r io.Reader) ([]internal.Todo, []string, error) {
+	return func(r io.Reader) ([]internal.Todo, []string, error) {
+		items, err := read(r)
+		return items, nil, err
+	}
+}
+
+func readMarkdown(r io.Reader) ([]internal.Todo, []string, error) {
+	items, warnings, err := markdown.Read(r)
+	messages := make([]string, len(warnings))
+	for i, w := range warnings {
+		messages[i] = w.String()
+	}
+	return items, messages, err
+}
+
+func writeMarkdown(w io.Writer, items []internal.Todo, group string) error {
+	return markdown.Write(w, items, markdown.Group(group))
+}
+
+func lookupExchangeFormat(name string) (exchangeFormat, error) {
+	format, ok := exchangeFormats[name]
+	if !ok {
+		names := make([]string, 0, len(exchangeFormats))
+		for name := range exchangeFormats {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return exchangeFormat{}, fmt.Errorf("--format needs one of: %s", strings.Join(names, ", "))
+	}
+	return format, nil
+}
+
+// exportItems Write every item, done or not, to w in the named format,
+// under a heading for each group if group is set.
+func exportItems(ctx context.Context, store s.TodoStore, formatName, group string, w io.Writer) error {
+	format, err := lookupExchangeFormat(formatName)
+	if err != nil {
+		return err
+	}
+	if group != "" && format.writeGrouped == nil {
+		return fmt.Errorf("--group needs a format with headings: markdown")
+	}
+	all, err := store.GetAllItems(ctx, s.ListOptions{ShowDone: true, IncludeDeferred: true})
+	if err != nil {
+		return err
+	}
+	if format.writeGrouped != nil {
+		return format.writeGrouped(w, all.Items, group)
+	}
+	return format.write(w, all.Items)
+}
+
+// importItems Add the items in path, or stdin if it is "-", as new items.
+// Without a format name, the format is told by the file's extension.
+func importItems(ctx context.Context, store s.TodoStore, formatName, path string) error {
+	if formatName == "" && path != "-" {
+		ext := strings.ToLower(filepath.Ext(path))
+		for name, format := range exchangeFormats {
+			if format.ext == ext {
+				formatName = name
+			}
+		}
+	}
+	format, err := lookupExchangeFormat(formatName)
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	items, warnings, err := format.read(r)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	added, err := s.Import(ctx, store, items)
+	fmt.Printf("Imported %d item(s).\n", len(added))
+	return err
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	s "github.com/tcooper-uk/go-todo/internal/storage"
+)
+
+// unjournaled Commands whose changes are not journaled: the servers and the
+// TUI, which run too long for their changes to be one operation, undo and
+// redo, which work on the journal themselves, and project, which changes
+// projects rather than items.
+var unjournaled = map[string]bool{"serve": true, "caldav": true, "tui": true, "undo": true, "redo": true, "project": true}
+
+// journalPath Where the journal of changes to the backend for mode is kept.
+func journalPath(mode s.Mode) string {
+	return filepath.Join(os.Getenv("HOME"), ".todo", fmt.Sprintf("journal-%s.json", backendName(mode)))
+}
+
+// undoRedo Undo, or redo, the last n operations on store, or list those
+// that could be.
+func undoRedo(ctx context.Context, store s.TodoStore, path string, undo bool, n int, list bool) error {
+	journal, err := s.LoadJournal(path)
+	if err != nil {
+		return err
+	}
+
+	verb, ops := "redo", journal.Undone
+	if undo {
+		verb, ops = "undo", journal.Done
+	}
+	if len(ops) == 0 {
+		fmt.Printf("Nothing to %s.\n", verb)
+		return nil
+	}
+	if list {
+		for i := len(ops) - 1; i >= 0; i-- {
+			fmt.Printf("%s\t%s\n", ops[i].At.Local().Format("Mon 02 Jan 06 15:04"), describeOperation(ops[i]))
+		}
+		return nil
+	}
+
+	var done []*s.Operation
+	past := "Redid"
+	if undo {
+		done, err = journal.Undo(ctx, store, n)
+		past = "Undid"
+	} else {
+		done, err = journal.Redo(ctx, store, n)
+	}
+	for _, op := range done {
+		fmt.Printf("%s %s\n", past, describeOperation(op))
+	}
+	return err
+}
+
+// describeOperation The command that made op and how many items it
+// changed.
+func describeOperation(op *s.Operation) string {
+	count := len(op.Net())
+	noun := "items"
+	if count == 1 {
+		noun = "item"
+	}
+	return fmt.Sprintf("%q (%d %s)", op.Command, count, noun)
+}
+
+// confirm Ask a yes or no question on stdin, taking anything but yes as no.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,431 @@
+// Package caldav serves a TodoStore as a CalDAV task list (RFC 4791), so
+// that phone and desktop task apps can read and edit the same items as the
+// CLI.
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/ical"
+	"github.com/tcooper-uk/go-todo/internal/storage"
+)
+
+// The resources served, bar the items themselves at
+// /calendars/todo/{name}.ics.
+const (
+	rootPath       = "/"
+	wellKnownPath  = "/.well-known/caldav"
+	principalPath  = "/principal/"
+	homePath       = "/calendars/"
+	collectionPath = "/calendars/todo/"
+)
+
+// maxBodyBytes The largest request body accepted.
+const maxBodyBytes = 1 << 20
+
+const allowedMethods = "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT"
+
+// Handler Serves the items of a store as VTODO resources in a single
+// calendar collection:
+//
+//	/                       where clients start, pointing at the principal
+//	/principal/             the one user, pointing at the calendar home
+//	/calendars/             the calendar home, holding the collection
+//	/calendars/todo/        the collection of items
+//	/calendars/todo/{name}  an item, as an iCalendar object
+//
+// Items carry an ETag derived from their UpdatedAt, which PUT and DELETE
+// check against If-Match.
+type Handler struct {
+	store     storage.TodoStore
+	token     string
+	resources *resourceState
+
+	// mu Serialises writes, so an If-Match check and the change it guards
+	// cannot be interleaved with another request.
+	mu sync.RWMutex
+}
+
+// Options How a Handler authenticates clients and where it keeps its state.
+type Options struct {
+	// Token Required as the password of HTTP basic authentication, with
+	// any user name, or as a bearer token. Anyone may connect if empty.
+	Token string
+	// StatePath Where to keep the names and UIDs clients give the items
+	// they add, which stores have no field for. Kept in memory if empty.
+	StatePath string
+}
+
+// New Create a handler for store.
+func New(store storage.TodoStore, opts Options) (*Handler, error) {
+	resources, err := loadResources(opts.StatePath)
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{store: store, token: opts.Token, resources: resources}, nil
+}
+
+// httpError An error with the status code it should be reported with.
+type httpError struct {
+	status int
+	msg    string
+}
+
+func (e *httpError) Error() string {
+	return e.msg
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("DAV", "1, 3, calendar-access")
+
+	switch {
+	case r.Method == http.MethodOptions:
+		w.Header().Set("Allow", allowedMethods)
+		w.WriteHeader(http.StatusOK)
+		return
+	case !h.authorized(r):
+		w.Header().Set("WWW-Authenticate", `Basic realm="todo"`)
+		http.Error(w, "missing or wrong credentials", http.StatusUnauthorized)
+		return
+	case strings.TrimSuffix(r.URL.Path, "/") == wellKnownPath:
+		http.Redirect(w, r, rootPath, http.StatusMovedPermanently)
+		return
+	}
+
+	// Changes are recorded as made by the user the app signed in as.
+	actor := "caldav"
+	if user, _, ok := r.BasicAuth(); ok && user != "" {
+		actor = "caldav:" + user
+	}
+	r = r.WithContext(storage.WithActor(r.Context(), actor))
+
+	var err error
+	switch r.Method {
+	case "PROPFIND":
+		err = h.propfind(w, r)
+	case "REPORT":
+		err = h.report(w, r)
+	case http.MethodGet, http.MethodHead:
+		err = h.get(w, r)
+	case http.MethodPut:
+		err = h.put(w, r)
+	case http.MethodDelete:
+		err = h.delete(w, r)
+	default:
+		w.Header().Set("Allow", allowedMethods)
+		err = &httpError{http.StatusMethodNotAllowed, "method not allowed"}
+	}
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+// authorized Whether r carries the handler's token, if it has one.
+func (h *Handler) authorized(r *http.Request) bool {
+	if h.token == "" {
+		return true
+	}
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		_, given, ok = r.BasicAuth()
+	}
+	return ok && subtle.ConstantTimeCompare([]byte(given), []byte(h.token)) == 1
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var httpErr *httpError
+	switch {
+	case errors.As(err, &httpErr):
+		status = httpErr.status
+	case errors.Is(err, storage.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrConflict):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrBackendUnavailable):
+		status = http.StatusServiceUnavailable
+	}
+
+	msg := err.Error()
+	if status == http.StatusInternalServerError {
+		log.Printf("internal error: %v", err)
+		msg = "internal error"
+	}
+	http.Error(w, msg, status)
+}
+
+// entry An item as a resource of the collection.
+type entry struct {
+	item internal.Todo
+	name string
+	uid  string
+}
+
+func (e entry) href() string {
+	return collectionPath + url.PathEscape(e.name)
+}
+
+// etag The item's version, which changes whenever it is saved.
+func (e entry) etag() string {
+	return `"` + strconv.FormatInt(e.item.UpdatedAt.UnixNano(), 36) + `"`
+}
+
+// collection Every item in the store as a resource, done or not.
+type collection struct {
+	entries []entry
+	byName  map[string]*entry
+	byID    map[int]*entry
+	byUID   map[string]*entry
+}
+
+func (h *Handler) collection(ctx context.Context) (*collection, error) {
+	all, err := h.store.GetAllItems(ctx, storage.ListOptions{ShowDone: true, IncludeDeferred: true})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &collection{
+		entries: make([]entry, len(all.Items)),
+		byName:  make(map[string]*entry, len(all.Items)),
+		byID:    make(map[int]*entry, len(all.Items)),
+		byUID:   make(map[string]*entry, len(all.Items)),
+	}
+	for i, item := range all.Items {
+		e := &c.entries[i]
+		*e = entry{item: item, name: strconv.Itoa(item.ID) + ".ics", uid: ical.UID(item)}
+		if res, ok := h.resources.get(item.ID); ok {
+			e.name, e.uid = res.Name, res.UID
+		}
+		c.byName[e.name] = e
+		c.byID[item.ID] = e
+		c.byUID[e.uid] = e
+	}
+	return c, nil
+}
+
+// ctag Changes whenever any item is added, saved or deleted, so clients
+// can tell whether there is anything new to fetch.
+func (c *collection) ctag() string {
+	h := sha256.New()
+	for _, e := range c.entries {
+		fmt.Fprintf(h, "%s %s\n", e.name, e.etag())
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// calendarData The iCalendar object for e.
+func (c *collection) calendarData(e *entry) []byte {
+	component := ical.Component{Todo: e.item, UID: e.uid}
+	if parent, ok := c.byID[e.item.ParentID]; ok {
+		component.ParentUID = parent.uid
+	}
+	var buf bytes.Buffer
+	ical.WriteComponents(&buf, []ical.Component{component})
+	return buf.Bytes()
+}
+
+// itemName The name of the item r.URL.Path is for, if it is one.
+func itemName(path string) (string, bool) {
+	name, ok := strings.CutPrefix(path, collectionPath)
+	if !ok || name == "" || strings.Contains(name, "/") {
+		return "", false
+	}
+	return name, true
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) error {
+	name, ok := itemName(r.URL.Path)
+	if !ok {
+		return &httpError{http.StatusNotFound, "not found"}
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	c, err := h.collection(r.Context())
+	if err != nil {
+		return err
+	}
+	e, ok := c.byName[name]
+	if !ok {
+		return &httpError{http.StatusNotFound, "no item " + name}
+	}
+
+	tag := e.etag()
+	w.Header().Set("ETag", tag)
+	w.Header().Set("Last-Modified", e.item.UpdatedAt.UTC().Format(http.TimeFormat))
+	if r.Header.Get("If-None-Match") == tag {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8; component=vtodo")
+	w.Write(c.calendarData(e))
+	return nil
+}
+
+// put Add or replace an item from the VTODO in the body. Properties with
+// no counterpart in internal.Todo, like DESCRIPTION or alarms, are not
+// kept, so no ETag is returned: clients fetch the item as stored instead.
+func (h *Handler) put(w http.ResponseWriter, r *http.Request) error {
+	name, ok := itemName(r.URL.Path)
+	if !ok {
+		return &httpError{http.StatusForbidden, "items can only be added to " + collectionPath}
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		return &httpError{http.StatusRequestEntityTooLarge, "body too large"}
+	}
+	components, err := ical.ReadComponents(bytes.NewReader(body))
+	if err != nil {
+		return &httpError{http.StatusBadRequest, err.Error()}
+	}
+	if len(components) != 1 {
+		return &httpError{http.StatusForbidden, "the calendar object must hold exactly one VTODO"}
+	}
+	component := components[0]
+	if component.UID == "" {
+		return &httpError{http.StatusBadRequest, "the VTODO has no UID"}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ctx := r.Context()
+	c, err := h.collection(ctx)
+	if err != nil {
+		return err
+	}
+	existing := c.byName[name]
+	if err := checkPreconditions(r, existing); err != nil {
+		return err
+	}
+
+	todo := component.Todo
+	if parent, ok := c.byUID[component.ParentUID]; ok {
+		todo.ParentID = parent.item.ID
+	}
+
+	if existing == nil {
+		if other, ok := c.byUID[component.UID]; ok {
+			return &httpError{http.StatusConflict, "UID already used by " + other.href()}
+		}
+		if err := storage.CheckParent(ctx, h.store, 0, todo.ParentID); err != nil {
+			return err
+		}
+		added, err := h.store.AddItem(ctx, todo)
+		if err != nil {
+			return err
+		}
+		if err := h.resources.set(added.ID, resource{Name: name, UID: component.UID}); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusCreated)
+		return nil
+	}
+
+	id := existing.item.ID
+	if todo.ParentID != existing.item.ParentID {
+		if err := storage.CheckParent(ctx, h.store, id, todo.ParentID); err != nil {
+			return err
+		}
+	}
+	todo.ID = id
+	todo.CreatedAt = existing.item.CreatedAt
+	todo.Extras = existing.item.Extras
+	// iCalendar has nowhere to keep the project, so the item stays in its own.
+	todo.Project = existing.item.Project
+	edited, err := h.store.EditItem(ctx, id, todo)
+	if err != nil {
+		return err
+	}
+	// Completing a repeating item schedules the next one, as `todo done` does.
+	if edited.Done && !existing.item.Done {
+		if _, err := storage.AddNextOccurrence(ctx, h.store, *edited, time.Now()); err != nil {
+			return err
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// delete Delete an item, with its subtasks.
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) error {
+	name, ok := itemName(r.URL.Path)
+	if !ok {
+		return &httpError{http.StatusForbidden, "only items can be deleted"}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ctx := r.Context()
+	c, err := h.collection(ctx)
+	if err != nil {
+		return err
+	}
+	e, ok := c.byName[name]
+	if !ok {
+		return &httpError{http.StatusNotFound, "no item " + name}
+	}
+	if err := checkPreconditions(r, e); err != nil {
+		return err
+	}
+
+	items := make([]internal.Todo, len(c.entries))
+	for i, e := range c.entries {
+		items[i] = e.item
+	}
+	ids := []int{e.item.ID}
+	for _, sub := range internal.Descendants(items, e.item.ID) {
+		ids = append(ids, sub.ID)
+	}
+	if _, err := h.store.DeleteItem(ctx, ids...); err != nil {
+		return err
+	}
+	if err := h.resources.remove(ids...); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// checkPreconditions Check If-Match and If-None-Match against the item, or
+// nil if there is none.
+func checkPreconditions(r *http.Request, e *entry) error {
+	failed := &httpError{http.StatusPreconditionFailed, "the item has changed"}
+	if match := r.Header.Get("If-Match"); match != "" {
+		if e == nil || (match != "*" && !containsETag(match, e.etag())) {
+			return failed
+		}
+	}
+	if none := r.Header.Get("If-None-Match"); none != "" && e != nil {
+		if none == "*" || containsETag(none, e.etag()) {
+			return failed
+		}
+	}
+	return nil
+}
+
+func containsETag(header, tag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(t), "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
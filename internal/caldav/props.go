@@ -0,0 +1,354 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	nsDAV    = "DAV:"
+	nsCalDAV = "urn:ietf:params:xml:ns:caldav"
+	// nsCS Apple's calendar server extensions, for getctag.
+	nsCS = "http://calendarserver.org/ns/"
+)
+
+// prefixes The prefix each namespace is declared with in responses.
+var prefixes = map[string]string{nsDAV: "D", nsCalDAV: "C", nsCS: "CS"}
+
+// kind What a resource is.
+type kind int
+
+const (
+	kindRoot kind = iota
+	kindPrincipal
+	kindHome
+	kindCollection
+	kindItem
+)
+
+// target A resource whose properties are asked for.
+type target struct {
+	href  string
+	kind  kind
+	entry *entry
+}
+
+// liveProps The properties each kind of resource has, as listed for
+// allprop and propname. calendar-data is only listed when asked for.
+var liveProps = map[kind][]xml.Name{
+	kindRoot: {
+		{Space: nsDAV, Local: "resourcetype"},
+		{Space: nsDAV, Local: "current-user-principal"},
+	},
+	kindPrincipal: {
+		{Space: nsDAV, Local: "resourcetype"},
+		{Space: nsDAV, Local: "displayname"},
+		{Space: nsDAV, Local: "current-user-principal"},
+		{Space: nsDAV, Local: "principal-URL"},
+		{Space: nsCalDAV, Local: "calendar-home-set"},
+	},
+	kindHome: {
+		{Space: nsDAV, Local: "resourcetype"},
+		{Space: nsDAV, Local: "current-user-principal"},
+	},
+	kindCollection: {
+		{Space: nsDAV, Local: "resourcetype"},
+		{Space: nsDAV, Local: "displayname"},
+		{Space: nsDAV, Local: "current-user-principal"},
+		{Space: nsDAV, Local: "owner"},
+		{Space: nsDAV, Local: "current-user-privilege-set"},
+		{Space: nsDAV, Local: "supported-report-set"},
+		{Space: nsCalDAV, Local: "supported-calendar-component-set"},
+		{Space: nsCS, Local: "getctag"},
+	},
+	kindItem: {
+		{Space: nsDAV, Local: "resourcetype"},
+		{Space: nsDAV, Local: "getetag"},
+		{Space: nsDAV, Local: "getcontenttype"},
+		{Space: nsDAV, Local: "getlastmodified"},
+	},
+}
+
+// propRequest Which properties a PROPFIND or REPORT asks for.
+type propRequest struct {
+	AllProp  *struct{} `xml:"DAV: allprop"`
+	PropName *struct{} `xml:"DAV: propname"`
+	Prop     *struct {
+		Names []anyElement `xml:",any"`
+	} `xml:"DAV: prop"`
+}
+
+type anyElement struct {
+	XMLName xml.Name
+}
+
+type propfindBody struct {
+	XMLName xml.Name `xml:"DAV: propfind"`
+	propRequest
+}
+
+// readBody Decode an XML request body into v, reporting whether there was
+// one.
+func readBody(w http.ResponseWriter, r *http.Request, v any) (bool, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		return false, &httpError{http.StatusRequestEntityTooLarge, "body too large"}
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return false, nil
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		return false, &httpError{http.StatusBadRequest, "invalid XML body: " + err.Error()}
+	}
+	return true, nil
+}
+
+func (h *Handler) propfind(w http.ResponseWriter, r *http.Request) error {
+	var body propfindBody
+	if _, err := readBody(w, r, &body); err != nil {
+		return err
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	c, err := h.collection(r.Context())
+	if err != nil {
+		return err
+	}
+	self, ok := c.target(r.URL.Path)
+	if !ok {
+		return &httpError{http.StatusNotFound, "not found"}
+	}
+
+	targets := []target{self}
+	// Depth infinity is answered as 1: nothing here is nested deeper.
+	if r.Header.Get("Depth") != "0" {
+		targets = append(targets, c.children(self)...)
+	}
+
+	responses := make([]response, len(targets))
+	for i, t := range targets {
+		responses[i] = c.props(t, body.propRequest)
+	}
+	writeMultistatus(w, responses)
+	return nil
+}
+
+// target The resource at path, if there is one.
+func (c *collection) target(path string) (target, bool) {
+	if path != rootPath {
+		path = strings.TrimSuffix(path, "/") + "/"
+	}
+	switch path {
+	case rootPath:
+		return target{href: rootPath, kind: kindRoot}, true
+	case principalPath:
+		return target{href: principalPath, kind: kindPrincipal}, true
+	case homePath:
+		return target{href: homePath, kind: kindHome}, true
+	case collectionPath:
+		return target{href: collectionPath, kind: kindCollection}, true
+	}
+	if name, ok := itemName(strings.TrimSuffix(path, "/")); ok {
+		if e, ok := c.byName[name]; ok {
+			return target{href: e.href(), kind: kindItem, entry: e}, true
+		}
+	}
+	return target{}, false
+}
+
+// children The resources directly within t.
+func (c *collection) children(t target) []target {
+	switch t.kind {
+	case kindHome:
+		return []target{{href: collectionPath, kind: kindCollection}}
+	case kindCollection:
+		targets := make([]target, len(c.entries))
+		for i := range c.entries {
+			e := &c.entries[i]
+			targets[i] = target{href: e.href(), kind: kindItem, entry: e}
+		}
+		return targets
+	}
+	return nil
+}
+
+// response One resource of a multistatus: the properties found and those
+// that were not, or just a status.
+type response struct {
+	href    string
+	status  int
+	found   []prop
+	missing []xml.Name
+}
+
+type prop struct {
+	name  xml.Name
+	inner string
+}
+
+// props The properties req asks for of t.
+func (c *collection) props(t target, req propRequest) response {
+	res := response{href: t.href}
+	switch {
+	case req.Prop != nil:
+		for _, el := range req.Prop.Names {
+			if inner, ok := c.prop(t, el.XMLName); ok {
+				res.found = append(res.found, prop{el.XMLName, inner})
+			} else {
+				res.missing = append(res.missing, el.XMLName)
+			}
+		}
+	case req.PropName != nil:
+		for _, name := range liveProps[t.kind] {
+			res.found = append(res.found, prop{name, ""})
+		}
+	default:
+		for _, name := range liveProps[t.kind] {
+			inner, _ := c.prop(t, name)
+			res.found = append(res.found, prop{name, inner})
+		}
+	}
+	return res
+}
+
+// prop The value of the property name of t, as XML.
+func (c *collection) prop(t target, name xml.Name) (string, bool) {
+	href := func(path string) string {
+		return element(xml.Name{Space: nsDAV, Local: "href"}, escapeText(path))
+	}
+	dav := func(local string) string {
+		return element(xml.Name{Space: nsDAV, Local: local}, "")
+	}
+
+	switch name {
+	case xml.Name{Space: nsDAV, Local: "current-user-principal"}, xml.Name{Space: nsDAV, Local: "principal-URL"}:
+		return href(principalPath), true
+	case xml.Name{Space: nsDAV, Local: "resourcetype"}:
+		switch t.kind {
+		case kindPrincipal:
+			return dav("principal"), true
+		case kindCollection:
+			return dav("collection") + element(xml.Name{Space: nsCalDAV, Local: "calendar"}, ""), true
+		case kindItem:
+			return "", true
+		}
+		return dav("collection"), true
+	}
+
+	switch t.kind {
+	case kindPrincipal:
+		switch name {
+		case xml.Name{Space: nsDAV, Local: "displayname"}:
+			return "todo", true
+		case xml.Name{Space: nsCalDAV, Local: "calendar-home-set"}:
+			return href(homePath), true
+		}
+
+	case kindCollection:
+		switch name {
+		case xml.Name{Space: nsDAV, Local: "displayname"}:
+			return "Todo", true
+		case xml.Name{Space: nsDAV, Local: "owner"}:
+			return href(principalPath), true
+		case xml.Name{Space: nsDAV, Local: "current-user-privilege-set"}:
+			var b strings.Builder
+			for _, p := range []string{"read", "write", "write-content", "write-properties", "bind", "unbind"} {
+				b.WriteString(element(xml.Name{Space: nsDAV, Local: "privilege"}, dav(p)))
+			}
+			return b.String(), true
+		case xml.Name{Space: nsDAV, Local: "supported-report-set"}:
+			var b strings.Builder
+			for _, report := range []string{"calendar-query", "calendar-multiget"} {
+				r := element(xml.Name{Space: nsCalDAV, Local: report}, "")
+				b.WriteString(element(xml.Name{Space: nsDAV, Local: "supported-report"},
+					element(xml.Name{Space: nsDAV, Local: "report"}, r)))
+			}
+			return b.String(), true
+		case xml.Name{Space: nsCalDAV, Local: "supported-calendar-component-set"}:
+			return `<C:comp name="VTODO"/>`, true
+		case xml.Name{Space: nsCS, Local: "getctag"}:
+			return escapeText(c.ctag()), true
+		}
+
+	case kindItem:
+		switch name {
+		case xml.Name{Space: nsDAV, Local: "getetag"}:
+			return escapeText(t.entry.etag()), true
+		case xml.Name{Space: nsDAV, Local: "getcontenttype"}:
+			return "text/calendar; charset=utf-8; component=vtodo", true
+		case xml.Name{Space: nsDAV, Local: "getlastmodified"}:
+			return t.entry.item.UpdatedAt.UTC().Format(http.TimeFormat), true
+		case xml.Name{Space: nsCalDAV, Local: "calendar-data"}:
+			return escapeText(string(c.calendarData(t.entry))), true
+		}
+	}
+	return "", false
+}
+
+// writeMultistatus Reply 207 with responses.
+func writeMultistatus(w http.ResponseWriter, responses []response) {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<D:multistatus xmlns:D="DAV:" xmlns:C="` + nsCalDAV + `" xmlns:CS="` + nsCS + `">`)
+	for _, res := range responses {
+		b.WriteString("<D:response>")
+		b.WriteString("<D:href>" + escapeText(res.href) + "</D:href>")
+		if res.status != 0 {
+			b.WriteString("<D:status>" + statusLine(res.status) + "</D:status>")
+		}
+		if len(res.found) > 0 {
+			b.WriteString("<D:propstat><D:prop>")
+			for _, p := range res.found {
+				b.WriteString(element(p.name, p.inner))
+			}
+			b.WriteString("</D:prop><D:status>" + statusLine(http.StatusOK) + "</D:status></D:propstat>")
+		}
+		if len(res.missing) > 0 {
+			b.WriteString("<D:propstat><D:prop>")
+			for _, name := range res.missing {
+				b.WriteString(element(name, ""))
+			}
+			b.WriteString("</D:prop><D:status>" + statusLine(http.StatusNotFound) + "</D:status></D:propstat>")
+		}
+		b.WriteString("</D:response>")
+	}
+	b.WriteString("</D:multistatus>")
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, b.String())
+}
+
+func statusLine(status int) string {
+	return "HTTP/1.1 " + strconv.Itoa(status) + " " + http.StatusText(status)
+}
+
+// element name holding inner, which is already XML.
+func element(name xml.Name, inner string) string {
+	open := name.Local
+	if prefix, ok := prefixes[name.Space]; ok {
+		open = prefix + ":" + name.Local
+	} else {
+		open = "X:" + name.Local + ` xmlns:X="` + escapeText(name.Space) + `"`
+		if name.Space == "" {
+			open = name.Local + ` xmlns=""`
+		}
+	}
+	closing, _, _ := strings.Cut(open, " ")
+	if inner == "" {
+		return "<" + open + "/>"
+	}
+	return "<" + open + ">" + inner + "</" + closing + ">"
+}
+
+func escapeText(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,269 @@
+package caldav
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal/ical"
+)
+
+// reportBody A calendar-query or calendar-multiget REPORT.
+type reportBody struct {
+	XMLName xml.Name
+	propRequest
+	Hrefs  []string `xml:"DAV: href"`
+	Filter *filter  `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+type filter struct {
+	Comp compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+type compFilter struct {
+	Name         string       `xml:"name,attr"`
+	IsNotDefined *struct{}    `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+	TimeRange    *timeRange   `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+	Comps        []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	Props        []propFilter `xml:"urn:ietf:params:xml:ns:caldav prop-filter"`
+}
+
+type propFilter struct {
+	Name         string     `xml:"name,attr"`
+	IsNotDefined *struct{}  `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+	TextMatch    *textMatch `xml:"urn:ietf:params:xml:ns:caldav text-match"`
+}
+
+type textMatch struct {
+	Value  string `xml:",chardata"`
+	Negate string `xml:"negate-condition,attr"`
+}
+
+type timeRange struct {
+	Start string `xml:"start,attr"`
+	End   string `xml:"end,attr"`
+}
+
+func (h *Handler) report(w http.ResponseWriter, r *http.Request) error {
+	var body reportBody
+	ok, err := readBody(w, r, &body)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &httpError{http.StatusBadRequest, "missing REPORT body"}
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	c, err := h.collection(r.Context())
+	if err != nil {
+		return err
+	}
+
+	var responses []response
+	switch body.XMLName {
+	case xml.Name{Space: nsCalDAV, Local: "calendar-query"}:
+		self, ok := c.target(r.URL.Path)
+		if !ok {
+			return &httpError{http.StatusNotFound, "not found"}
+		}
+		targets := []target{self}
+		if self.kind == kindCollection {
+			targets = c.children(self)
+		}
+		for _, t := range targets {
+			if t.kind != kindItem {
+				continue
+			}
+			match, err := matches(c, t.entry, body.Filter)
+			if err != nil {
+				return err
+			}
+			if match {
+				responses = append(responses, c.props(t, body.propRequest))
+			}
+		}
+
+	case xml.Name{Space: nsCalDAV, Local: "calendar-multiget"}:
+		for _, href := range body.Hrefs {
+			t, ok := c.target(hrefPath(href))
+			if !ok || t.kind != kindItem {
+				responses = append(responses, response{href: href, status: http.StatusNotFound})
+				continue
+			}
+			responses = append(responses, c.props(t, body.propRequest))
+		}
+
+	default:
+		return &httpError{http.StatusForbidden, "unsupported report " + body.XMLName.Local}
+	}
+
+	writeMultistatus(w, responses)
+	return nil
+}
+
+// hrefPath The path an href of a multiget names, whether it is a path or a
+// full URL.
+func hrefPath(href string) string {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return ""
+	}
+	return u.Path
+}
+
+// matches Whether e passes a calendar-query filter. Every item is a VTODO
+// within a VCALENDAR, so filters on other components match nothing, unless
+// they ask for those to be missing.
+func matches(c *collection, e *entry, f *filter) (bool, error) {
+	if f == nil {
+		return true, nil
+	}
+	calendar := f.Comp
+	if !strings.EqualFold(calendar.Name, "VCALENDAR") || calendar.IsNotDefined != nil {
+		return false, nil
+	}
+
+	for _, comp := range calendar.Comps {
+		isTodo := strings.EqualFold(comp.Name, "VTODO")
+		if comp.IsNotDefined != nil {
+			if isTodo {
+				return false, nil
+			}
+			continue
+		}
+		if !isTodo {
+			return false, nil
+		}
+		match, err := matchesTodo(c, e, comp)
+		if err != nil || !match {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchesTodo Whether e passes the filter on its VTODO.
+func matchesTodo(c *collection, e *entry, todo compFilter) (bool, error) {
+	if todo.TimeRange != nil {
+		match, err := inRange(e, *todo.TimeRange)
+		if err != nil || !match {
+			return false, err
+		}
+	}
+
+	props := properties(c, e)
+	for _, pf := range todo.Props {
+		values := props[strings.ToUpper(pf.Name)]
+		switch {
+		case pf.IsNotDefined != nil:
+			if len(values) > 0 {
+				return false, nil
+			}
+		case len(values) == 0:
+			return false, nil
+		case pf.TextMatch != nil:
+			if !textMatches(values, *pf.TextMatch) {
+				return false, nil
+			}
+		}
+	}
+
+	// The items have no components within them, like VALARM.
+	for _, comp := range todo.Comps {
+		if comp.IsNotDefined == nil {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// inRange Whether the item is due within tr. Items without a due date are
+// in every range, as they could be done at any time.
+func inRange(e *entry, tr timeRange) (bool, error) {
+	due := e.item.DueDate
+	if due == nil {
+		return true, nil
+	}
+	if tr.Start != "" {
+		start, err := parseUTC(tr.Start)
+		if err != nil {
+			return false, err
+		}
+		if due.Before(start) {
+			return false, nil
+		}
+	}
+	if tr.End != "" {
+		end, err := parseUTC(tr.End)
+		if err != nil {
+			return false, err
+		}
+		if !due.Before(end) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func parseUTC(value string) (time.Time, error) {
+	t, err := time.Parse("20060102T150405Z", value)
+	if err != nil {
+		return time.Time{}, &httpError{http.StatusBadRequest, "invalid time-range " + strconv.Quote(value)}
+	}
+	return t, nil
+}
+
+// properties The values of the VTODO properties of e that filters can
+// test, as they are written.
+func properties(c *collection, e *entry) map[string][]string {
+	item := e.item
+	props := map[string][]string{
+		"UID":     {e.uid},
+		"SUMMARY": {item.Name},
+		"STATUS":  {"NEEDS-ACTION"},
+	}
+	if item.Done {
+		props["STATUS"] = []string{"COMPLETED"}
+		props["COMPLETED"] = []string{item.UpdatedAt.UTC().Format("20060102T150405Z")}
+	}
+	switch {
+	case item.DueDate == nil:
+	case item.DueTimed:
+		props["DUE"] = []string{item.DueDate.UTC().Format("20060102T150405Z")}
+	default:
+		props["DUE"] = []string{item.DueDate.UTC().Format("20060102")}
+	}
+	if p, ok := ical.Priority(item.Priority); ok {
+		props["PRIORITY"] = []string{strconv.Itoa(p)}
+	}
+	if len(item.Tags) > 0 {
+		props["CATEGORIES"] = item.Tags
+	}
+	if item.Recurrence != "" {
+		props["RRULE"] = []string{item.Recurrence}
+	}
+	if parent, ok := c.byID[item.ParentID]; ok {
+		props["RELATED-TO"] = []string{parent.uid}
+	}
+	return props
+}
+
+// textMatches Whether any of values contains the text, ignoring case, or
+// none does if the match is negated.
+func textMatches(values []string, tm textMatch) bool {
+	want := strings.ToLower(tm.Value)
+	found := false
+	for _, v := range values {
+		if strings.Contains(strings.ToLower(v), want) {
+			found = true
+			break
+		}
+	}
+	return found != (tm.Negate == "yes")
+}
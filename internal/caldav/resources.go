@@ -0,0 +1,96 @@
+package caldav
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// resource The name and UID a client gave an item it added.
+type resource struct {
+	Name string `json:"name"`
+	UID  string `json:"uid"`
+}
+
+// resourceState The resources of items added by clients, by item id. Other
+// items are named after their id, with the UID ical gives them.
+type resourceState struct {
+	path  string
+	Items map[int]resource `json:"items"`
+}
+
+// loadResources Read the state saved at path, or an empty one if there is
+// none yet or path is empty.
+func loadResources(path string) (*resourceState, error) {
+	state := &resourceState{path: path, Items: map[int]resource{}}
+	if path == "" {
+		return state, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid CalDAV state in %s: %w", path, err)
+	}
+	if state.Items == nil {
+		state.Items = map[int]resource{}
+	}
+	return state, nil
+}
+
+func (s *resourceState) get(id int) (resource, bool) {
+	res, ok := s.Items[id]
+	return res, ok
+}
+
+func (s *resourceState) set(id int, res resource) error {
+	s.Items[id] = res
+	return s.save()
+}
+
+func (s *resourceState) remove(ids ...int) error {
+	changed := false
+	for _, id := range ids {
+		if _, ok := s.Items[id]; ok {
+			delete(s.Items, id)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.save()
+}
+
+// save Write the state to its path, replacing it in one step so an
+// interrupted save leaves the previous state intact.
+func (s *resourceState) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
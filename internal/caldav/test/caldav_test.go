@@ -0,0 +1,403 @@
+package caldav_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/caldav"
+	"github.com/tcooper-uk/go-todo/internal/storage"
+)
+
+const collection = "/calendars/todo/"
+
+func newStore(t *testing.T) storage.TodoStore {
+	store, err := storage.NewLocalFileStore(filepath.Join(t.TempDir(), "todo.json"))
+	assert.Nil(t, err)
+	return store
+}
+
+func newServer(t *testing.T, store storage.TodoStore, opts caldav.Options) *httptest.Server {
+	handler, err := caldav.New(store, opts)
+	assert.Nil(t, err)
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// do Send a request with an optional body and headers given as name,
+// value pairs, returning the response and its body.
+func do(t *testing.T, method, url, body string, headers ...string) (*http.Response, string) {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	assert.Nil(t, err)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	return resp, string(data)
+}
+
+func vtodo(uid, summary string, extra ...string) string {
+	lines := []string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//test//EN", "BEGIN:VTODO",
+		"UID:" + uid, "SUMMARY:" + summary}
+	lines = append(lines, extra...)
+	lines = append(lines, "END:VTODO", "END:VCALENDAR", "")
+	return strings.Join(lines, "\r\n")
+}
+
+func addItem(t *testing.T, store storage.TodoStore, todo internal.Todo) *internal.Todo {
+	added, err := store.AddItem(context.Background(), todo)
+	assert.Nil(t, err)
+	return added
+}
+
+func TestOptions(t *testing.T) {
+	srv := newServer(t, newStore(t), caldav.Options{Token: "secret"})
+
+	// OPTIONS needs no credentials, so clients can find out what is served.
+	resp, _ := do(t, "OPTIONS", srv.URL+collection, "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("DAV"), "calendar-access")
+	assert.Contains(t, resp.Header.Get("Allow"), "REPORT")
+}
+
+func TestAuth(t *testing.T) {
+	srv := newServer(t, newStore(t), caldav.Options{Token: "secret"})
+
+	resp, _ := do(t, "PROPFIND", srv.URL+collection, "", "Depth", "0")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("WWW-Authenticate"), "Basic")
+
+	req, _ := http.NewRequest("PROPFIND", srv.URL+collection, nil)
+	req.SetBasicAuth("anyone", "wrong")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	req, _ = http.NewRequest("PROPFIND", srv.URL+collection, nil)
+	req.SetBasicAuth("anyone", "secret")
+	req.Header.Set("Depth", "0")
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+
+	resp, _ = do(t, "PROPFIND", srv.URL+collection, "", "Depth", "0", "Authorization", "Bearer secret")
+	assert.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+}
+
+func TestDiscovery(t *testing.T) {
+	srv := newServer(t, newStore(t), caldav.Options{})
+
+	resp, _ := do(t, "PROPFIND", srv.URL+"/.well-known/caldav", "")
+	assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+	assert.Equal(t, "/", resp.Header.Get("Location"))
+
+	resp, body := do(t, "PROPFIND", srv.URL+"/", `<?xml version="1.0"?>
+<propfind xmlns="DAV:"><prop><current-user-principal/></prop></propfind>`, "Depth", "0")
+	assert.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	assert.Contains(t, body, "<D:current-user-principal><D:href>/principal/</D:href></D:current-user-principal>")
+
+	_, body = do(t, "PROPFIND", srv.URL+"/principal/", `<?xml version="1.0"?>
+<propfind xmlns="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><prop><C:calendar-home-set/></prop></propfind>`, "Depth", "0")
+	assert.Contains(t, body, "<C:calendar-home-set><D:href>/calendars/</D:href></C:calendar-home-set>")
+
+	_, body = do(t, "PROPFIND", srv.URL+"/calendars/", `<?xml version="1.0"?>
+<propfind xmlns="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <prop><resourcetype/><displayname/><C:supported-calendar-component-set/></prop>
+</propfind>`, "Depth", "1")
+	assert.Contains(t, body, "<D:href>/calendars/todo/</D:href>")
+	assert.Contains(t, body, "<D:resourcetype><D:collection/><C:calendar/></D:resourcetype>")
+	assert.Contains(t, body, `<C:supported-calendar-component-set><C:comp name="VTODO"/></C:supported-calendar-component-set>`)
+}
+
+func TestPropfindListsItems(t *testing.T) {
+	store := newStore(t)
+	addItem(t, store, internal.Todo{Name: "Buy milk"})
+	addItem(t, store, internal.Todo{Name: "Walk the dog"})
+	srv := newServer(t, store, caldav.Options{})
+
+	resp, body := do(t, "PROPFIND", srv.URL+collection, `<?xml version="1.0"?>
+<propfind xmlns="DAV:" xmlns:CS="http://calendarserver.org/ns/" xmlns:X="urn:example">
+  <prop><getetag/><CS:getctag/><X:color/></prop>
+</propfind>`, "Depth", "1")
+	assert.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	assert.Contains(t, body, "<D:href>/calendars/todo/1.ics</D:href>")
+	assert.Contains(t, body, "<D:href>/calendars/todo/2.ics</D:href>")
+	assert.Contains(t, body, "<CS:getctag>")
+	// Properties that are not known are reported missing.
+	assert.Contains(t, body, `<X:color xmlns:X="urn:example"/></D:prop><D:status>HTTP/1.1 404 Not Found</D:status>`)
+
+	// Depth 0 is just the collection.
+	_, body = do(t, "PROPFIND", srv.URL+collection, "", "Depth", "0")
+	assert.NotContains(t, body, "1.ics")
+	assert.Contains(t, body, "<D:displayname>Todo</D:displayname>")
+
+	resp, _ = do(t, "PROPFIND", srv.URL+collection+"9.ics", "", "Depth", "0")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestGet(t *testing.T) {
+	store := newStore(t)
+	due := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	parent := addItem(t, store, internal.Todo{Name: "Party", Priority: internal.PriorityHigh, DueDate: &due})
+	addItem(t, store, internal.Todo{Name: "Book venue", ParentID: parent.ID})
+	srv := newServer(t, store, caldav.Options{})
+
+	resp, body := do(t, "GET", srv.URL+collection+"2.ics", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/calendar")
+	etag := resp.Header.Get("ETag")
+	assert.NotEmpty(t, etag)
+	assert.Contains(t, body, "SUMMARY:Book venue\r\n")
+	assert.Contains(t, body, "RELATED-TO;RELTYPE=PARENT:1-")
+
+	resp, _ = do(t, "GET", srv.URL+collection+"2.ics", "", "If-None-Match", etag)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	resp, _ = do(t, "GET", srv.URL+collection+"3.ics", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestPutAddsItem(t *testing.T) {
+	store := newStore(t)
+	srv := newServer(t, store, caldav.Options{})
+
+	resp, _ := do(t, "PUT", srv.URL+collection+"abc-123.ics",
+		vtodo("abc-123", "Call mum", "PRIORITY:1", "DUE;VALUE=DATE:20261105", "CATEGORIES:family"),
+		"If-None-Match", "*")
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	item, err := store.GetItem(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Call mum", item.Name)
+	assert.Equal(t, internal.PriorityHigh, item.Priority)
+	assert.Equal(t, []string{"family"}, item.Tags)
+
+	// The item keeps the name and UID the client gave it.
+	resp, body := do(t, "GET", srv.URL+collection+"abc-123.ics", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, "UID:abc-123\r\n")
+
+	// A second item may not reuse the name, or the UID.
+	resp, _ = do(t, "PUT", srv.URL+collection+"abc-123.ics", vtodo("abc-123", "Again"), "If-None-Match", "*")
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	resp, _ = do(t, "PUT", srv.URL+collection+"other.ics", vtodo("abc-123", "Again"))
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp, _ = do(t, "PUT", srv.URL+collection+"bad.ics", "not a calendar")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestPutAddsSubtask(t *testing.T) {
+	store := newStore(t)
+	parent := addItem(t, store, internal.Todo{Name: "Party"})
+	srv := newServer(t, store, caldav.Options{})
+
+	_, body := do(t, "GET", srv.URL+collection+"1.ics", "")
+	var parentUID string
+	for _, line := range strings.Split(body, "\r\n") {
+		if uid, ok := strings.CutPrefix(line, "UID:"); ok {
+			parentUID = uid
+		}
+	}
+
+	resp, _ := do(t, "PUT", srv.URL+collection+"sub.ics", vtodo("sub", "Book venue", "RELATED-TO:"+parentUID))
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	sub, err := store.GetItem(context.Background(), 2)
+	assert.Nil(t, err)
+	assert.Equal(t, parent.ID, sub.ParentID)
+}
+
+func TestPutEditsItem(t *testing.T) {
+	store := newStore(t)
+	added := addItem(t, store, internal.Todo{Name: "Buy milk", Tags: []string{"shop"}})
+	srv := newServer(t, store, caldav.Options{})
+
+	resp, body := do(t, "GET", srv.URL+collection+"1.ics", "")
+	etag := resp.Header.Get("ETag")
+	edited := strings.Replace(body, "SUMMARY:Buy milk", "SUMMARY:Buy oat milk", 1)
+
+	resp, _ = do(t, "PUT", srv.URL+collection+"1.ics", edited, "If-Match", etag)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	item, err := store.GetItem(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Buy oat milk", item.Name)
+	assert.Equal(t, []string{"shop"}, item.Tags)
+	assert.True(t, added.CreatedAt.Equal(item.CreatedAt))
+
+	// The ETag changed with the edit, so the old one no longer matches.
+	resp, _ = do(t, "PUT", srv.URL+collection+"1.ics", body, "If-Match", etag)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	resp, _ = do(t, "GET", srv.URL+collection+"1.ics", "")
+	assert.NotEqual(t, etag, resp.Header.Get("ETag"))
+}
+
+func TestPutCompletingRepeatingItemAddsNext(t *testing.T) {
+	store := newStore(t)
+	due := time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)
+	addItem(t, store, internal.Todo{Name: "Water plants", DueDate: &due, Recurrence: "FREQ=WEEKLY"})
+	srv := newServer(t, store, caldav.Options{})
+
+	_, body := do(t, "GET", srv.URL+collection+"1.ics", "")
+	done := strings.Replace(body, "STATUS:NEEDS-ACTION", "STATUS:COMPLETED", 1)
+	resp, _ := do(t, "PUT", srv.URL+collection+"1.ics", done, "If-Match", "*")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	all, err := store.GetAllItems(context.Background(), storage.ListOptions{ShowDone: true})
+	assert.Nil(t, err)
+	assert.Len(t, all.Items, 2)
+	next, err := store.GetItem(context.Background(), 2)
+	assert.Nil(t, err)
+	assert.False(t, next.Done)
+	assert.Equal(t, "2026-10-27", next.DueDate.Format("2006-01-02"))
+}
+
+func TestDelete(t *testing.T) {
+	store := newStore(t)
+	parent := addItem(t, store, internal.Todo{Name: "Party"})
+	addItem(t, store, internal.Todo{Name: "Book venue", ParentID: parent.ID})
+	addItem(t, store, internal.Todo{Name: "Buy milk"})
+	srv := newServer(t, store, caldav.Options{})
+
+	resp, _ := do(t, "DELETE", srv.URL+collection+"1.ics", "", "If-Match", `"stale"`)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp, _ = do(t, "DELETE", srv.URL+collection+"1.ics", "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	// Subtasks go with their parent.
+	all, err := store.GetAllItems(context.Background(), storage.ListOptions{ShowDone: true})
+	assert.Nil(t, err)
+	assert.Len(t, all.Items, 1)
+	assert.Equal(t, "Buy milk", all.Items[0].Name)
+
+	resp, _ = do(t, "DELETE", srv.URL+collection+"1.ics", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestCalendarQuery(t *testing.T) {
+	store := newStore(t)
+	soon := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	later := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	addItem(t, store, internal.Todo{Name: "Buy milk", DueDate: &soon})
+	addItem(t, store, internal.Todo{Name: "Renew passport", DueDate: &later})
+	addItem(t, store, internal.Todo{Name: "Call mum", Done: true})
+	srv := newServer(t, store, caldav.Options{})
+
+	query := func(filter string) string {
+		resp, body := do(t, "REPORT", srv.URL+collection, `<?xml version="1.0"?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/><C:calendar-data/></D:prop>
+  <C:filter><C:comp-filter name="VCALENDAR">`+filter+`</C:comp-filter></C:filter>
+</C:calendar-query>`, "Depth", "1")
+		assert.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+		return body
+	}
+
+	body := query(`<C:comp-filter name="VTODO"/>`)
+	assert.Equal(t, 3, strings.Count(body, "<D:response>"))
+	assert.Contains(t, body, "SUMMARY:Buy milk")
+
+	// Apps commonly ask for only the items not yet done.
+	body = query(`<C:comp-filter name="VTODO">
+  <C:prop-filter name="COMPLETED"><C:is-not-defined/></C:prop-filter>
+  <C:prop-filter name="STATUS"><C:text-match negate-condition="yes">CANCELLED</C:text-match></C:prop-filter>
+</C:comp-filter>`)
+	assert.Equal(t, 2, strings.Count(body, "<D:response>"))
+	assert.NotContains(t, body, "Call mum")
+
+	body = query(`<C:comp-filter name="VTODO"><C:time-range start="20261025T000000Z" end="20261201T000000Z"/></C:comp-filter>`)
+	assert.Contains(t, body, "Buy milk")
+	assert.NotContains(t, body, "Renew passport")
+	// Items with no due date are in every range.
+	assert.Contains(t, body, "Call mum")
+
+	body = query(`<C:comp-filter name="VTODO"><C:prop-filter name="SUMMARY"><C:text-match>MILK</C:text-match></C:prop-filter></C:comp-filter>`)
+	assert.Equal(t, 1, strings.Count(body, "<D:response>"))
+
+	body = query(`<C:comp-filter name="VEVENT"/>`)
+	assert.Equal(t, 0, strings.Count(body, "<D:response>"))
+}
+
+func TestCalendarMultiget(t *testing.T) {
+	store := newStore(t)
+	addItem(t, store, internal.Todo{Name: "Buy milk"})
+	addItem(t, store, internal.Todo{Name: "Walk the dog"})
+	srv := newServer(t, store, caldav.Options{})
+
+	resp, body := do(t, "REPORT", srv.URL+collection, `<?xml version="1.0"?>
+<C:calendar-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/><C:calendar-data/></D:prop>
+  <D:href>/calendars/todo/2.ics</D:href>
+  <D:href>`+srv.URL+`/calendars/todo/1.ics</D:href>
+  <D:href>/calendars/todo/9.ics</D:href>
+</C:calendar-multiget>`)
+	assert.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	assert.Contains(t, body, "SUMMARY:Buy milk")
+	assert.Contains(t, body, "SUMMARY:Walk the dog")
+	assert.Contains(t, body, "<D:href>/calendars/todo/9.ics</D:href><D:status>HTTP/1.1 404 Not Found</D:status>")
+
+	resp, _ = do(t, "REPORT", srv.URL+collection, `<D:sync-collection xmlns:D="DAV:"/>`)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestCtagChangesWithItems(t *testing.T) {
+	store := newStore(t)
+	addItem(t, store, internal.Todo{Name: "Buy milk"})
+	srv := newServer(t, store, caldav.Options{})
+
+	ctag := func() string {
+		_, body := do(t, "PROPFIND", srv.URL+collection, `<?xml version="1.0"?>
+<propfind xmlns="DAV:" xmlns:CS="http://calendarserver.org/ns/"><prop><CS:getctag/></prop></propfind>`, "Depth", "0")
+		_, after, _ := strings.Cut(body, "<CS:getctag>")
+		tag, _, _ := strings.Cut(after, "</CS:getctag>")
+		return tag
+	}
+
+	before := ctag()
+	assert.NotEmpty(t, before)
+	assert.Equal(t, before, ctag())
+	addItem(t, store, internal.Todo{Name: "Walk the dog"})
+	assert.NotEqual(t, before, ctag())
+}
+
+func TestResourceNamesPersist(t *testing.T) {
+	store := newStore(t)
+	statePath := filepath.Join(t.TempDir(), "caldav.json")
+	srv := newServer(t, store, caldav.Options{StatePath: statePath})
+
+	resp, _ := do(t, "PUT", srv.URL+collection+"phone-item.ics", vtodo("phone-uid", "From the phone"))
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// A new server over the same state serves the item where the app left it.
+	restarted := newServer(t, store, caldav.Options{StatePath: statePath})
+	resp, body := do(t, "GET", restarted.URL+collection+"phone-item.ics", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, "UID:phone-uid\r\n")
+
+	resp, _ = do(t, "DELETE", restarted.URL+collection+"phone-item.ics", "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
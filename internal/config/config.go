@@ -0,0 +1,53 @@
+// Package config reads and writes the user's settings in
+// ~/.todo/config.yaml.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config The user's settings. Unset fields take their defaults.
+type Config struct {
+	// DefaultProject The path of the project new items go in when no other
+	// is given, e.g. "work/clients".
+	DefaultProject string `yaml:"default_project,omitempty"`
+}
+
+// Path Where the config is kept for the user whose home is home.
+func Path(home string) string {
+	return filepath.Join(home, ".todo", "config.yaml")
+}
+
+// Load Read the config at path, or an empty one if there is none yet.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid config in %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+// Save Write the config to path, creating its directory if need be.
+func (config *Config) Save(path string) error {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
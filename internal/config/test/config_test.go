@@ -0,0 +1,39 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tcooper-uk/go-todo/internal/config"
+)
+
+func TestLoadMissing(t *testing.T) {
+	c, err := config.Load(filepath.Join(t.TempDir(), "config.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, &config.Config{}, c)
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := config.Path(t.TempDir())
+
+	c := &config.Config{DefaultProject: "work/clients"}
+	assert.NoError(t, c.Save(path))
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "default_project: work/clients\n", string(data))
+
+	loaded, err := config.Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, c, loaded)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("default_project: [\n"), 0o644))
+
+	_, err := config.Load(path)
+	assert.ErrorContains(t, err, "invalid config in "+path)
+}
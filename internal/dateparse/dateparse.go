@@ -0,0 +1,386 @@
+// Package dateparse reads the dates people write, e.g.
+//
+//	today, tomorrow, fri, next monday, in 3 days, +2w, end of month, eod,
+//	nov 5, 2026-11-01 14:30
+//
+// relative to a given now and in its time zone.
+package dateparse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal"
+)
+
+// Date A date as written: a day, or an instant if a time of day, or a
+// number of hours or minutes from now, was given.
+type Date struct {
+	// Time The instant, or midnight at the start of the day if HasTime is
+	// false, in the location of the now it was read against.
+	Time    time.Time
+	HasTime bool
+}
+
+// Day The calendar day of the date, as midnight UTC, the way due dates are
+// kept.
+func (d Date) Day() time.Time {
+	y, m, day := d.Time.Date()
+	return time.Date(y, m, day, 0, 0, 0, 0, time.UTC)
+}
+
+// Start The instant the date begins where it was read: its time, or the
+// start of its day, as deferring an item until then needs.
+func (d Date) Start() time.Time {
+	if d.HasTime {
+		return d.Time
+	}
+	y, m, day := d.Time.Date()
+	return time.Date(y, m, day, 0, 0, 0, 0, d.Time.Location())
+}
+
+// SetDue Make the date item's due date: an all-day one, or a deadline
+// in the time zone it was read in if it has a time.
+func (d Date) SetDue(item *internal.Todo) {
+	if !d.HasTime {
+		day := d.Day()
+		item.DueDate, item.DueTimed, item.DueZone = &day, false, ""
+		return
+	}
+	deadline := d.Time.UTC()
+	zone := d.Time.Location().String()
+	if d.Time.Location() == time.Local {
+		zone = internal.LocalZone()
+	} else if _, err := time.LoadLocation(zone); err != nil {
+		zone = ""
+	}
+	item.DueDate, item.DueTimed, item.DueZone = &deadline, true, zone
+}
+
+// Examples Expressions Parse takes, for help and error messages.
+const Examples = "today, tomorrow, fri, next monday, in 3 days, +2w, end of month, eod or 2026-11-01 14:30"
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "weds": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+var months = map[string]time.Month{
+	"jan": time.January, "january": time.January,
+	"feb": time.February, "february": time.February,
+	"mar": time.March, "march": time.March,
+	"apr": time.April, "april": time.April,
+	"may": time.May,
+	"jun": time.June, "june": time.June,
+	"jul": time.July, "july": time.July,
+	"aug": time.August, "august": time.August,
+	"sep": time.September, "sept": time.September, "september": time.September,
+	"oct": time.October, "october": time.October,
+	"nov": time.November, "november": time.November,
+	"dec": time.December, "december": time.December,
+}
+
+type unit int
+
+const (
+	minutes unit = iota
+	hours
+	days
+	weeks
+	monthsUnit
+	years
+)
+
+var units = map[string]unit{
+	"min": minutes, "mins": minutes, "minute": minutes, "minutes": minutes,
+	"h": hours, "hr": hours, "hrs": hours, "hour": hours, "hours": hours,
+	"d": days, "day": days, "days": days,
+	"w": weeks, "wk": weeks, "wks": weeks, "week": weeks, "weeks": weeks,
+	"m": monthsUnit, "mo": monthsUnit, "month": monthsUnit, "months": monthsUnit,
+	"y": years, "yr": years, "yrs": years, "year": years, "years": years,
+}
+
+// layouts Absolute dates with a time, tried before anything else.
+var layouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04"}
+
+// Parse Read expr as a date relative to now, in now's location. It takes:
+//
+//   - today, tomorrow, yesterday and now
+//   - a weekday, e.g. fri, which is today if it is Friday; next fri, the
+//     first Friday after today; last fri, the last one before it
+//   - in 3 days, +2w, 3 days ago, -1m, next week (units d, w, m for months,
+//     y, h and min)
+//   - eod, eow, eom and eoy, or end of day, week, month or year
+//   - 2026-11-01, nov 1, 1 november 2026 (a day without a year is the next
+//     one to come)
+//
+// any of which but the relative times may be followed by a time of day,
+// e.g. 14:30, 3pm, at 9:15am, noon or midnight. A time alone is today.
+func Parse(expr string, now time.Time) (Date, error) {
+	invalid := fmt.Errorf("invalid date %q, use e.g. %s", expr, Examples)
+
+	trimmed := strings.TrimSpace(expr)
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, trimmed, now.Location()); err == nil {
+			return Date{Time: t.In(now.Location()), HasTime: true}, nil
+		}
+	}
+
+	words := strings.Fields(strings.ToLower(trimmed))
+	if len(words) == 0 {
+		return Date{}, invalid
+	}
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+
+	switch strings.Join(words, " ") {
+	case "now":
+		return Date{Time: now, HasTime: true}, nil
+	case "eod", "end of day", "end of today":
+		return Date{Time: time.Date(y, m, d, 23, 59, 0, 0, now.Location()), HasTime: true}, nil
+	}
+	if t, ok := relative(words, now); ok {
+		return t, nil
+	}
+
+	// A trailing time of day, e.g. "fri 3pm", "fri at 3 pm".
+	hour, min, timed := 0, 0, false
+	if n := len(words); n >= 2 && (words[n-1] == "am" || words[n-1] == "pm") {
+		words = append(words[:n-2], words[n-2]+words[n-1])
+	}
+	if h, mm, ok := clock(words[len(words)-1]); ok {
+		hour, min, timed = h, mm, true
+		words = words[:len(words)-1]
+		if n := len(words); n > 0 && words[n-1] == "at" {
+			words = words[:n-1]
+		}
+	}
+
+	day := today
+	if len(words) > 0 {
+		var ok bool
+		if day, ok = calendarDay(words, today); !ok {
+			return Date{}, invalid
+		}
+	} else if !timed {
+		return Date{}, invalid
+	}
+
+	// Set the clock rather than add hours to midnight, which would be out
+	// by the change on a day the clocks go forward or back.
+	if timed {
+		y, m, d := day.Date()
+		return Date{Time: time.Date(y, m, d, hour, min, 0, 0, day.Location()), HasTime: true}, nil
+	}
+	return Date{Time: day}, nil
+}
+
+// relative A date a number of units from now: "in 3 days", "+2w", "3 days
+// ago", "-1m" or "next week". Hours and minutes give an instant.
+func relative(words []string, now time.Time) (Date, bool) {
+	words = append([]string(nil), words...)
+	sign := 1
+	switch {
+	case words[0] == "in" && len(words) > 1:
+		words = words[1:]
+	case len(words) > 1 && words[len(words)-1] == "ago":
+		sign, words = -1, words[:len(words)-1]
+	case len(words) == 2 && words[0] == "next":
+		words = []string{"1", words[1]}
+	case strings.HasPrefix(words[0], "+"):
+		words[0] = words[0][1:]
+	case strings.HasPrefix(words[0], "-"):
+		sign, words[0] = -1, words[0][1:]
+	default:
+		return Date{}, false
+	}
+
+	// "2w" and "2 w" are both allowed; so is "a week".
+	if len(words) == 1 {
+		w := words[0]
+		i := strings.IndexFunc(w, func(r rune) bool { return r < '0' || r > '9' })
+		if i <= 0 {
+			return Date{}, false
+		}
+		words = []string{w[:i], w[i:]}
+	}
+	if len(words) != 2 {
+		return Date{}, false
+	}
+	n, err := strconv.Atoi(words[0])
+	if words[0] == "a" || words[0] == "an" {
+		n, err = 1, nil
+	}
+	u, ok := units[words[1]]
+	if err != nil || n < 0 || !ok {
+		return Date{}, false
+	}
+	n *= sign
+
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	switch u {
+	case minutes:
+		return Date{Time: now.Add(time.Duration(n) * time.Minute), HasTime: true}, true
+	case hours:
+		return Date{Time: now.Add(time.Duration(n) * time.Hour), HasTime: true}, true
+	case days:
+		return Date{Time: today.AddDate(0, 0, n)}, true
+	case weeks:
+		return Date{Time: today.AddDate(0, 0, 7*n)}, true
+	case monthsUnit:
+		return Date{Time: addMonths(today, n)}, true
+	}
+	return Date{Time: addMonths(today, 12*n)}, true
+}
+
+// calendarDay The day words name, relative to today.
+func calendarDay(words []string, today time.Time) (time.Time, bool) {
+	switch strings.Join(words, " ") {
+	case "today", "tod":
+		return today, true
+	case "tomorrow", "tmr", "tom":
+		return today.AddDate(0, 0, 1), true
+	case "yesterday":
+		return today.AddDate(0, 0, -1), true
+	case "eow", "end of week", "end of the week":
+		return today.AddDate(0, 0, (7-int(today.Weekday()))%7), true
+	case "eom", "end of month", "end of the month":
+		return time.Date(today.Year(), today.Month()+1, 0, 0, 0, 0, 0, today.Location()), true
+	case "eoy", "end of year", "end of the year":
+		return time.Date(today.Year(), time.December, 31, 0, 0, 0, 0, today.Location()), true
+	}
+
+	if len(words) == 1 {
+		if t, err := time.ParseInLocation("2006-01-02", words[0], today.Location()); err == nil {
+			return t, true
+		}
+	}
+
+	// Weekdays: fri, this fri, next fri, last fri.
+	which, name := "this", words[0]
+	if len(words) == 2 {
+		which, name = words[0], words[1]
+	}
+	if wd, ok := weekdays[name]; ok && len(words) <= 2 {
+		ahead := (int(wd) - int(today.Weekday()) + 7) % 7
+		switch which {
+		case "this", "on":
+			return today.AddDate(0, 0, ahead), true
+		case "next":
+			if ahead == 0 {
+				ahead = 7
+			}
+			return today.AddDate(0, 0, ahead), true
+		case "last":
+			behind := (int(today.Weekday()) - int(wd) + 7) % 7
+			if behind == 0 {
+				behind = 7
+			}
+			return today.AddDate(0, 0, -behind), true
+		}
+		return time.Time{}, false
+	}
+
+	return monthDay(words, today)
+}
+
+// monthDay A day written as "nov 5", "5 nov" or either with a year after
+// it. Without a year it is the next such day from today on.
+func monthDay(words []string, today time.Time) (time.Time, bool) {
+	if len(words) != 2 && len(words) != 3 {
+		return time.Time{}, false
+	}
+	month, ok := months[words[0]]
+	dayWord := words[1]
+	if !ok {
+		month, ok = months[words[1]]
+		dayWord = words[0]
+	}
+	if !ok {
+		return time.Time{}, false
+	}
+	day, err := strconv.Atoi(strings.TrimRight(dayWord, "stndrh"))
+	if err != nil || day < 1 || day > 31 {
+		return time.Time{}, false
+	}
+
+	year := today.Year()
+	if len(words) == 3 {
+		if year, err = strconv.Atoi(words[2]); err != nil {
+			return time.Time{}, false
+		}
+	}
+	t := time.Date(year, month, day, 0, 0, 0, 0, today.Location())
+	if t.Day() != day {
+		return time.Time{}, false
+	}
+	if len(words) == 2 && t.Before(today) {
+		t = t.AddDate(1, 0, 0)
+	}
+	return t, true
+}
+
+// clock The hour and minute word gives, e.g. 14:30, 9am, 3:15pm, noon or
+// midnight.
+func clock(word string) (int, int, bool) {
+	switch word {
+	case "noon":
+		return 12, 0, true
+	case "midnight":
+		return 0, 0, true
+	}
+
+	pm := strings.HasSuffix(word, "pm")
+	twelveHour := pm || strings.HasSuffix(word, "am")
+	if twelveHour {
+		word = word[:len(word)-2]
+	}
+
+	hourText, minText, hasMin := strings.Cut(word, ":")
+	if !hasMin && !twelveHour {
+		return 0, 0, false
+	}
+	hour, err := strconv.Atoi(hourText)
+	if err != nil || len(hourText) > 2 {
+		return 0, 0, false
+	}
+	min := 0
+	if hasMin {
+		if min, err = strconv.Atoi(minText); err != nil || len(minText) != 2 || min > 59 {
+			return 0, 0, false
+		}
+	}
+
+	if twelveHour {
+		if hour < 1 || hour > 12 {
+			return 0, 0, false
+		}
+		hour %= 12
+		if pm {
+			hour += 12
+		}
+	} else if hour > 23 {
+		return 0, 0, false
+	}
+	return hour, min, true
+}
+
+// addMonths Add n months to t, keeping to the last day of the month where
+// the day would run past it, e.g. Jan 31 + 1 month is Feb 28.
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(n), 1, 0, 0, 0, 0, t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
@@ -0,0 +1,161 @@
+package dateparse_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/dateparse"
+)
+
+var zone = time.FixedZone("BST", 60*60)
+
+// now Sunday 18 October 2026, 10:00 local time.
+var now = time.Date(2026, 10, 18, 10, 0, 0, 0, zone)
+
+func TestParseDays(t *testing.T) {
+	cases := map[string]string{
+		"today":          "2026-10-18",
+		"Tomorrow":       "2026-10-19",
+		"yesterday":      "2026-10-17",
+		"fri":            "2026-10-23",
+		"sunday":         "2026-10-18",
+		"next sunday":    "2026-10-25",
+		"next monday":    "2026-10-19",
+		"last sun":       "2026-10-11",
+		"in 3 days":      "2026-10-21",
+		"+2w":            "2026-11-01",
+		"+1 m":           "2026-11-18",
+		"in a year":      "2027-10-18",
+		"3 days ago":     "2026-10-15",
+		"-1w":            "2026-10-11",
+		"next week":      "2026-10-25",
+		"eow":            "2026-10-18",
+		"end of month":   "2026-10-31",
+		"eoy":            "2026-12-31",
+		"2026-11-01":     "2026-11-01",
+		"nov 5":          "2026-11-05",
+		"5th november":   "2026-11-05",
+		"jan 2":          "2027-01-02",
+		"1 feb 2028":     "2028-02-01",
+		"  next   FRI  ": "2026-10-23",
+	}
+
+	for in, want := range cases {
+		d, err := dateparse.Parse(in, now)
+		if assert.Nil(t, err, in) {
+			assert.False(t, d.HasTime, in)
+			assert.Equal(t, want, d.Time.Format("2006-01-02"), in)
+			assert.Equal(t, time.UTC, d.Day().Location(), in)
+			var item internal.Todo
+			d.SetDue(&item)
+			assert.Equal(t, d.Day(), *item.DueDate, in)
+			assert.False(t, item.DueTimed, in)
+		}
+	}
+}
+
+func TestParseTimes(t *testing.T) {
+	cases := map[string]string{
+		"2026-11-01 14:30":     "2026-11-01 14:30",
+		"2026-11-01T09:05":     "2026-11-01 09:05",
+		"2026-11-01T09:05:00Z": "2026-11-01 10:05",
+		"eod":                  "2026-10-18 23:59",
+		"fri 3pm":              "2026-10-23 15:00",
+		"tomorrow at 9:15 am":  "2026-10-19 09:15",
+		"noon":                 "2026-10-18 12:00",
+		"12am":                 "2026-10-18 00:00",
+		"+2h":                  "2026-10-18 12:00",
+		"in 30 minutes":        "2026-10-18 10:30",
+		"now":                  "2026-10-18 10:00",
+	}
+
+	for in, want := range cases {
+		d, err := dateparse.Parse(in, now)
+		if assert.Nil(t, err, in) {
+			assert.True(t, d.HasTime, in)
+			assert.Equal(t, want, d.Time.Format("2006-01-02 15:04"), in)
+			assert.Equal(t, zone, d.Time.Location(), in)
+			var item internal.Todo
+			d.SetDue(&item)
+			assert.True(t, d.Time.Equal(*item.DueDate), in)
+			assert.True(t, item.DueTimed, in)
+		}
+	}
+}
+
+// TestDayIsLocal A day is the one it is where the user is, even when it
+// is already another day in UTC.
+func TestDayIsLocal(t *testing.T) {
+	late := time.Date(2026, 10, 18, 23, 30, 0, 0, time.FixedZone("PDT", -7*60*60))
+	d, err := dateparse.Parse("today", late)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), d.Day())
+
+	d, err = dateparse.Parse("+1m", time.Date(2026, 1, 31, 12, 0, 0, 0, zone))
+	assert.Nil(t, err)
+	assert.Equal(t, "2026-02-28", d.Time.Format("2006-01-02"))
+}
+
+// TestParseTimesAcrossDST A time of day is the one on the clock, on the
+// days the clocks go forward and back too.
+func TestParseTimesAcrossDST(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	now := time.Date(2027, 3, 1, 10, 0, 0, 0, london)
+
+	for in, want := range map[string]string{
+		"mar 28 2027 2pm":  "2027-03-28 14:00 BST",
+		"oct 31 2027 noon": "2027-10-31 12:00 GMT",
+		"2027-10-31 9am":   "2027-10-31 09:00 GMT",
+	} {
+		d, err := dateparse.Parse(in, now)
+		if assert.Nil(t, err, in) {
+			assert.Equal(t, want, d.Time.Format("2006-01-02 15:04 MST"), in)
+		}
+	}
+
+	d, err := dateparse.Parse("eod", time.Date(2027, 3, 28, 10, 0, 0, 0, london))
+	assert.Nil(t, err)
+	assert.Equal(t, "2027-03-28 23:59 BST", d.Time.Format("2006-01-02 15:04 MST"))
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, in := range []string{"", "someday", "next", "in 3 fortnights", "feb 30", "25:00", "13pm", "2026-13-01", "fri 3", "+w"} {
+		_, err := dateparse.Parse(in, now)
+		assert.ErrorContains(t, err, "invalid date", in)
+	}
+}
+
+// TestSetDueKeepsZone A deadline remembers the time zone it was set in.
+func TestSetDueKeepsZone(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	d, err := dateparse.Parse("fri 9am", time.Date(2026, 10, 18, 10, 0, 0, 0, london))
+	assert.Nil(t, err)
+
+	var item internal.Todo
+	d.SetDue(&item)
+	assert.Equal(t, time.Date(2026, 10, 23, 8, 0, 0, 0, time.UTC), *item.DueDate)
+	assert.Equal(t, "Europe/London", item.DueZone)
+
+	d, _ = dateparse.Parse("fri 9am", now)
+	d.SetDue(&item)
+	assert.Empty(t, item.DueZone)
+}
+
+func TestStart(t *testing.T) {
+	d, err := dateparse.Parse("tomorrow", now)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2026, 10, 19, 0, 0, 0, 0, zone), d.Start())
+
+	d, err = dateparse.Parse("fri 3pm", now)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2026, 10, 23, 15, 0, 0, 0, zone), d.Start())
+}
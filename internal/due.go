@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Day The calendar day t falls on in its own location, as midnight UTC,
+// the way all-day due dates are kept.
+func Day(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// DueDay The day the item is due, as midnight UTC, with the day of a
+// timed deadline reckoned in loc, or nil if it has no due date.
+func (t Todo) DueDay(loc *time.Location) *time.Time {
+	if t.DueDate == nil {
+		return nil
+	}
+	day := Day(t.DueDate.UTC())
+	if t.DueTimed {
+		day = Day(t.DueDate.In(loc))
+	}
+	return &day
+}
+
+// Overdue Whether the item is past due at now: a timed deadline once now
+// is after it, an all-day item once its day is over where now is.
+func (t Todo) Overdue(now time.Time) bool {
+	if t.DueDate == nil {
+		return false
+	}
+	if t.DueTimed {
+		return t.DueDate.Before(now)
+	}
+	return t.DueDate.Before(Day(now))
+}
+
+// DueToday Whether the item is due on now's day, where now is, and is
+// not yet overdue.
+func (t Todo) DueToday(now time.Time) bool {
+	day := t.DueDay(now.Location())
+	return day != nil && day.Equal(Day(now)) && !t.Overdue(now)
+}
+
+// Deferred Whether the item is not yet actionable at now, as its start
+// date is still to come.
+func (t Todo) Deferred(now time.Time) bool {
+	return t.StartDate != nil && now.Before(*t.StartDate)
+}
+
+// FormatDue The due date as layout writes it, followed for a timed
+// deadline by its time of day in loc, or "" if there is none.
+func (t Todo) FormatDue(layout string, loc *time.Location) string {
+	if t.DueDate == nil {
+		return ""
+	}
+	if t.DueTimed {
+		return t.DueDate.In(loc).Format(layout + " 15:04")
+	}
+	return t.DueDate.UTC().Format(layout)
+}
+
+// DueLocation The time zone the item's deadline was set in, or loc if it
+// is not known.
+func (t Todo) DueLocation(loc *time.Location) *time.Location {
+	if t.DueZone != "" {
+		if zone, err := time.LoadLocation(t.DueZone); err == nil {
+			return zone
+		}
+	}
+	return loc
+}
+
+// LocalZone The IANA name of the local time zone, e.g. "Europe/London",
+// from $TZ or the zoneinfo file /etc/localtime links to, or "" if neither
+// names one.
+func LocalZone() string {
+	if name := time.Local.String(); name != "Local" && name != "" {
+		return name
+	}
+	if tz := strings.TrimPrefix(os.Getenv("TZ"), ":"); tz != "" && !filepath.IsAbs(tz) {
+		return tz
+	}
+	if target, err := filepath.EvalSymlinks("/etc/localtime"); err == nil {
+		if _, name, ok := strings.Cut(target, "zoneinfo/"); ok {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,260 @@
+package filter
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal"
+)
+
+// Expr A parsed filter expression.
+type Expr interface {
+	// Match Whether item satisfies the expression, with the days of its
+	// times reckoned in loc.
+	Match(item internal.Todo, loc *time.Location) bool
+}
+
+// Field An item attribute a condition tests.
+type Field string
+
+const (
+	FieldTag      Field = "tag"
+	FieldPriority Field = "priority"
+	FieldDue      Field = "due"
+	FieldDone     Field = "done"
+	FieldName     Field = "name"
+	FieldCreated  Field = "created"
+	FieldUpdated  Field = "updated"
+	FieldID       Field = "id"
+	FieldParent   Field = "parent"
+)
+
+// Op A comparison. OpIs (':') means "has" for tags, "contains" for names
+// and "equals" for everything else.
+type Op string
+
+const (
+	OpIs           Op = ":"
+	OpEqual        Op = "="
+	OpNotEqual     Op = "!="
+	OpLess         Op = "<"
+	OpLessEqual    Op = "<="
+	OpGreater      Op = ">"
+	OpGreaterEqual Op = ">="
+)
+
+// Cond A single comparison, e.g. priority>=medium. Value is a string for
+// name and tag, a bool for done, an internal.Priority for priority, an int
+// for id and parent (0 meaning none), and a time.Time holding a UTC day
+// for dates, or nil for due:none.
+type Cond struct {
+	Field Field
+	Op    Op
+	Value any
+}
+
+type And struct {
+	Left, Right Expr
+}
+
+type Or struct {
+	Left, Right Expr
+}
+
+type Not struct {
+	Expr Expr
+}
+
+type valueKind int
+
+const (
+	kindText valueKind = iota
+	kindTag
+	kindBool
+	kindPriority
+	kindDate
+	kindID
+)
+
+var fields = map[Field]valueKind{
+	FieldTag:      kindTag,
+	FieldPriority: kindPriority,
+	FieldDue:      kindDate,
+	FieldDone:     kindBool,
+	FieldName:     kindText,
+	FieldCreated:  kindDate,
+	FieldUpdated:  kindDate,
+	FieldID:       kindID,
+	FieldParent:   kindID,
+}
+
+func fieldNames() string {
+	names := make([]string, 0, len(fields))
+	for f := range fields {
+		names = append(names, string(f))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// allows Whether op can be applied to a field of this kind and value.
+func (k valueKind) allows(op Op, value any) bool {
+	switch op {
+	case OpIs, OpEqual, OpNotEqual:
+		return true
+	}
+	switch k {
+	case kindPriority, kindID:
+		return true
+	case kindDate:
+		return value != nil
+	}
+	return false
+}
+
+// priorityRank Orders priorities for <, >, etc.
+var priorityRank = map[internal.Priority]int{
+	internal.PriorityNone:   0,
+	internal.PriorityLow:    1,
+	internal.PriorityMedium: 2,
+	internal.PriorityHigh:   3,
+}
+
+// PriorityRank The position of p from none (0) to high (3).
+func PriorityRank(p internal.Priority) int {
+	return priorityRank[p]
+}
+
+// Conjuncts Flatten nested Ands into the expressions that must all hold.
+func Conjuncts(expr Expr) []Expr {
+	if a, ok := expr.(And); ok {
+		return append(Conjuncts(a.Left), Conjuncts(a.Right)...)
+	}
+	return []Expr{expr}
+}
+
+// Uses Whether any condition in expr tests field.
+func Uses(expr Expr, field Field) bool {
+	switch e := expr.(type) {
+	case Cond:
+		return e.Field == field
+	case And:
+		return Uses(e.Left, field) || Uses(e.Right, field)
+	case Or:
+		return Uses(e.Left, field) || Uses(e.Right, field)
+	case Not:
+		return Uses(e.Expr, field)
+	}
+	return false
+}
+
+// Range The times a date condition selects, from inclusive to exclusive,
+// with nil meaning unbounded. Conditions on a day cover the whole of it,
+// so due<=2026-11-01 includes anything due that day. For OpNotEqual this
+// is the range being excluded.
+func (c Cond) Range() (from, to *time.Time) {
+	day, ok := c.Value.(time.Time)
+	if !ok {
+		return nil, nil
+	}
+	next := day.AddDate(0, 0, 1)
+
+	switch c.Op {
+	case OpLess:
+		return nil, &day
+	case OpLessEqual:
+		return nil, &next
+	case OpGreater:
+		return &next, nil
+	case OpGreaterEqual:
+		return &day, nil
+	}
+	return &day, &next
+}
+
+// RangeIn The times a date condition selects, as Range, with the days
+// starting at midnight in loc instead of UTC.
+func (c Cond) RangeIn(loc *time.Location) (from, to *time.Time) {
+	from, to = c.Range()
+	inLoc := func(t *time.Time) *time.Time {
+		if t == nil {
+			return nil
+		}
+		local := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		return &local
+	}
+	return inLoc(from), inLoc(to)
+}
+
+func (c Cond) Match(item internal.Todo, loc *time.Location) bool {
+	if c.Op == OpNotEqual {
+		return !Cond{Field: c.Field, Op: OpEqual, Value: c.Value}.Match(item, loc)
+	}
+
+	switch c.Field {
+	case FieldTag:
+		for _, tag := range item.Tags {
+			if tag == c.Value {
+				return true
+			}
+		}
+		return false
+	case FieldName:
+		if c.Op == OpIs {
+			return strings.Contains(strings.ToLower(item.Name), strings.ToLower(c.Value.(string)))
+		}
+		return strings.EqualFold(item.Name, c.Value.(string))
+	case FieldDone:
+		return item.Done == c.Value
+	case FieldPriority:
+		return compare(PriorityRank(item.Priority), c.Op, PriorityRank(c.Value.(internal.Priority)))
+	case FieldID:
+		return compare(item.ID, c.Op, c.Value.(int))
+	case FieldParent:
+		return compare(item.ParentID, c.Op, c.Value.(int))
+	case FieldDue:
+		if c.Value == nil {
+			return item.DueDate == nil
+		}
+		day := item.DueDay(loc)
+		return day != nil && c.inRange(*day)
+	case FieldCreated:
+		return c.inRange(internal.Day(item.CreatedAt.In(loc)))
+	case FieldUpdated:
+		return c.inRange(internal.Day(item.UpdatedAt.In(loc)))
+	}
+	return false
+}
+
+// inRange Whether day, as midnight UTC, is in the condition's range.
+func (c Cond) inRange(day time.Time) bool {
+	from, to := c.Range()
+	return (from == nil || !day.Before(*from)) && (to == nil || day.Before(*to))
+}
+
+func compare(a int, op Op, b int) bool {
+	switch op {
+	case OpLess:
+		return a < b
+	case OpLessEqual:
+		return a <= b
+	case OpGreater:
+		return a > b
+	case OpGreaterEqual:
+		return a >= b
+	}
+	return a == b
+}
+
+func (a And) Match(item internal.Todo, loc *time.Location) bool {
+	return a.Left.Match(item, loc) && a.Right.Match(item, loc)
+}
+
+func (o Or) Match(item internal.Todo, loc *time.Location) bool {
+	return o.Left.Match(item, loc) || o.Right.Match(item, loc)
+}
+
+func (n Not) Match(item internal.Todo, loc *time.Location) bool {
+	return !n.Expr.Match(item, loc)
+}
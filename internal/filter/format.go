@@ -0,0 +1,66 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal"
+)
+
+// Format Write expr in the syntax Parse reads, so that it can be sent to
+// another process and parsed back into an equivalent expression.
+func Format(expr Expr) string {
+	switch e := expr.(type) {
+	case Cond:
+		return string(e.Field) + string(e.Op) + formatValue(e.Value)
+	case And:
+		return group(e.Left, false) + " and " + group(e.Right, false)
+	case Or:
+		return Format(e.Left) + " or " + Format(e.Right)
+	case Not:
+		return "not " + group(e.Expr, true)
+	}
+	return ""
+}
+
+// group Format expr, in parentheses where it would otherwise bind to its
+// neighbours differently: an Or within an And, or any And or Or after not.
+func group(expr Expr, underNot bool) string {
+	switch expr.(type) {
+	case Or:
+		return "(" + Format(expr) + ")"
+	case And:
+		if underNot {
+			return "(" + Format(expr) + ")"
+		}
+	}
+	return Format(expr)
+}
+
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "none"
+	case string:
+		if v == "" || strings.ContainsFunc(v, func(r rune) bool {
+			return r <= ' ' || strings.ContainsRune(`()":=<>!`, r)
+		}) {
+			return `"` + v + `"`
+		}
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case internal.Priority:
+		if v == internal.PriorityNone {
+			return "none"
+		}
+		return string(v)
+	case int:
+		return strconv.Itoa(v)
+	case time.Time:
+		return v.Format("2006-01-02")
+	}
+	return fmt.Sprint(value)
+}
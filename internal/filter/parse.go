@@ -0,0 +1,303 @@
+// Package filter implements the expression language of `todo list`, e.g.
+//
+//	tag:work and (priority:high or due<2026-11-01) and not tag:blocked
+//
+// An expression is parsed into a tree of Cond, And, Or and Not that each
+// storage backend either translates into its own query or evaluates with
+// Match.
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/dateparse"
+)
+
+// ParseError Reports what is wrong with an expression and where.
+type ParseError struct {
+	// Column The 1-based position, in characters, of the offending text.
+	Column int
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid filter at column %d: %s", e.Column, e.Msg)
+}
+
+// Parse Read a filter expression. Conditions are joined with and, or and
+// not (in any case), grouped with parentheses; adjacent conditions must
+// all hold. Errors are a *ParseError.
+func Parse(query string) (Expr, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, &ParseError{Column: 1, Msg: "empty filter"}
+	}
+
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, &ParseError{Column: tok.col, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+	}
+	return expr, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	col  int
+}
+
+// operators Longest first, so "<=" is not read as "<" then "=".
+var operators = []Op{OpNotEqual, OpLessEqual, OpGreaterEqual, OpIs, OpEqual, OpLess, OpGreater}
+
+func lex(query string) ([]token, error) {
+	var tokens []token
+	runes := []rune(query)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		col := i + 1
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(':
+			tokens = append(tokens, token{tokLParen, "(", col})
+			i++
+			continue
+		case r == ')':
+			tokens = append(tokens, token{tokRParen, ")", col})
+			i++
+			continue
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, &ParseError{Column: col, Msg: "unterminated quoted value"}
+			}
+			tokens = append(tokens, token{tokString, string(runes[i+1 : end]), col})
+			i = end + 1
+			continue
+		}
+
+		if op, ok := operatorAt(runes[i:]); ok {
+			tokens = append(tokens, token{tokOp, string(op), col})
+			i += len(op)
+			continue
+		}
+		if r == '!' {
+			return nil, &ParseError{Column: col, Msg: `unexpected "!", did you mean "!="?`}
+		}
+
+		end := i
+		for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune(`()":=<>!`, runes[end]) {
+			end++
+		}
+		tokens = append(tokens, token{tokWord, string(runes[i:end]), col})
+		i = end
+	}
+
+	return append(tokens, token{tokEOF, "", len(runes) + 1}), nil
+}
+
+func operatorAt(runes []rune) (Op, bool) {
+	for _, op := range operators {
+		if strings.HasPrefix(string(runes), string(op)) {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// isKeyword Whether tok is the operator word kw.
+func isKeyword(tok token, kw string) bool {
+	return tok.kind == tokWord && strings.EqualFold(tok.text, kw)
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for isKeyword(p.peek(), "or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = Or{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if tok.kind == tokEOF || tok.kind == tokRParen || isKeyword(tok, "or") {
+			return left, nil
+		}
+		if isKeyword(tok, "and") {
+			p.next()
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = And{left, right}
+	}
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	tok := p.next()
+
+	switch {
+	case tok.kind == tokEOF:
+		return nil, &ParseError{Column: tok.col, Msg: "filter ends unexpectedly"}
+	case isKeyword(tok, "not"):
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{expr}, nil
+	case tok.kind == tokLParen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokRParen {
+			return nil, &ParseError{Column: tok.col, Msg: `"(" is never closed`}
+		}
+		p.next()
+		return expr, nil
+	case tok.kind == tokWord && !isKeyword(tok, "and") && !isKeyword(tok, "or"):
+		return p.parseCond(tok)
+	}
+
+	return nil, &ParseError{Column: tok.col, Msg: fmt.Sprintf("expected a condition, found %q", tok.text)}
+}
+
+func (p *parser) parseCond(fieldTok token) (Expr, error) {
+	field := Field(strings.ToLower(fieldTok.text))
+	kind, ok := fields[field]
+	if !ok {
+		return nil, &ParseError{Column: fieldTok.col, Msg: fmt.Sprintf("unknown field %q (expected one of %s)", fieldTok.text, fieldNames())}
+	}
+
+	opTok := p.next()
+	if opTok.kind != tokOp {
+		return nil, &ParseError{Column: opTok.col, Msg: fmt.Sprintf("expected an operator such as ':' after %q", fieldTok.text)}
+	}
+	op := Op(opTok.text)
+
+	valueTok := p.next()
+	if valueTok.kind != tokWord && valueTok.kind != tokString {
+		return nil, &ParseError{Column: valueTok.col, Msg: fmt.Sprintf("expected a value after %s%s", fieldTok.text, op)}
+	}
+
+	value, err := parseValue(kind, valueTok.text)
+	if err != nil {
+		return nil, &ParseError{Column: valueTok.col, Msg: err.Error()}
+	}
+
+	if !kind.allows(op, value) {
+		return nil, &ParseError{Column: opTok.col, Msg: fmt.Sprintf("%s cannot be compared with %q", field, op)}
+	}
+
+	return Cond{Field: field, Op: op, Value: value}, nil
+}
+
+// parseValue Read the value of a condition on a field of the given kind.
+func parseValue(kind valueKind, text string) (any, error) {
+	switch kind {
+	case kindText:
+		return text, nil
+	case kindTag:
+		if text == "" {
+			return nil, fmt.Errorf("empty tag")
+		}
+		return text, nil
+	case kindBool:
+		switch strings.ToLower(text) {
+		case "true", "yes", "1":
+			return true, nil
+		case "false", "no", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("invalid value %q, use true or false", text)
+	case kindPriority:
+		p := internal.Priority(strings.ToLower(text))
+		if p == "none" {
+			p = internal.PriorityNone
+		}
+		if _, ok := priorityRank[p]; !ok {
+			return nil, fmt.Errorf("invalid priority %q, use low, medium, high or none", text)
+		}
+		return p, nil
+	case kindDate:
+		if strings.EqualFold(text, "none") {
+			return nil, nil
+		}
+		d, err := dateparse.Parse(text, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q, use YYYY-MM-DD, e.g. tomorrow or \"next fri\", or none", text)
+		}
+		return d.Day(), nil
+	case kindID:
+		if strings.EqualFold(text, "none") {
+			return 0, nil
+		}
+		id, err := strconv.Atoi(text)
+		if err != nil || id < 0 {
+			return nil, fmt.Errorf("invalid id %q", text)
+		}
+		return id, nil
+	}
+	return nil, fmt.Errorf("invalid value %q", text)
+}
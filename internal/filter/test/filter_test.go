@@ -0,0 +1,194 @@
+package filter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/filter"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestParseExpression(t *testing.T) {
+	expr, err := filter.Parse("tag:work and (priority:high or due<2026-11-01) and not tag:blocked")
+	assert.Nil(t, err)
+
+	want := filter.And{
+		Left: filter.And{
+			Left: filter.Cond{Field: filter.FieldTag, Op: filter.OpIs, Value: "work"},
+			Right: filter.Or{
+				Left:  filter.Cond{Field: filter.FieldPriority, Op: filter.OpIs, Value: internal.PriorityHigh},
+				Right: filter.Cond{Field: filter.FieldDue, Op: filter.OpLess, Value: day("2026-11-01")},
+			},
+		},
+		Right: filter.Not{Expr: filter.Cond{Field: filter.FieldTag, Op: filter.OpIs, Value: "blocked"}},
+	}
+	assert.Equal(t, want, expr)
+}
+
+func TestParseImplicitAndAndPrecedence(t *testing.T) {
+	expr, err := filter.Parse(`TAG:a OR tag:b name:"buy milk"`)
+	assert.Nil(t, err)
+
+	want := filter.Or{
+		Left: filter.Cond{Field: filter.FieldTag, Op: filter.OpIs, Value: "a"},
+		Right: filter.And{
+			Left:  filter.Cond{Field: filter.FieldTag, Op: filter.OpIs, Value: "b"},
+			Right: filter.Cond{Field: filter.FieldName, Op: filter.OpIs, Value: "buy milk"},
+		},
+	}
+	assert.Equal(t, want, expr)
+}
+
+func TestParseErrorsPointAtColumn(t *testing.T) {
+	cases := []struct {
+		query  string
+		column int
+	}{
+		{"", 1},
+		{"tag:work and prio:high", 14},
+		{"tag:work and", 13},
+		{"due<2026-13-01", 5},
+		{"tag<work", 4},
+		{"due>none", 4},
+		{"(tag:work", 1},
+		{"tag:work)", 9},
+		{`name:"buy`, 6},
+		{"priority:urgent", 10},
+		{"done", 5},
+		{"tag!work", 4},
+	}
+
+	for _, c := range cases {
+		_, err := filter.Parse(c.query)
+		var parseErr *filter.ParseError
+		if assert.ErrorAs(t, err, &parseErr, c.query) {
+			assert.Equal(t, c.column, parseErr.Column, c.query)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	due := day("2026-11-01")
+	item := internal.Todo{
+		ID:        3,
+		Name:      "Write the report",
+		Priority:  internal.PriorityMedium,
+		DueDate:   &due,
+		Tags:      []string{"work"},
+		ParentID:  1,
+		CreatedAt: day("2026-10-01").Add(15 * time.Hour),
+	}
+
+	cases := map[string]bool{
+		"tag:work":                     true,
+		"tag:home":                     false,
+		"tag!=home":                    true,
+		"name:REPORT":                  true,
+		"name=report":                  false,
+		"priority>=medium":             true,
+		"priority>medium":              false,
+		"priority:none":                false,
+		"due:2026-11-01":               true,
+		"due<2026-11-01":               false,
+		"due<=2026-11-01":              true,
+		"due>2026-10-31":               true,
+		"due:none":                     false,
+		"due!=none":                    true,
+		"created:2026-10-01":           true,
+		"done:false":                   true,
+		"id>2 and id<4":                true,
+		"parent:1":                     true,
+		"parent:none":                  false,
+		"not (tag:work or tag:home)":   false,
+		"tag:home or priority:medium":  true,
+		"tag:work and not name:report": false,
+	}
+
+	for query, want := range cases {
+		expr, err := filter.Parse(query)
+		if assert.Nil(t, err, query) {
+			assert.Equal(t, want, expr.Match(item, time.UTC), query)
+		}
+	}
+}
+
+// TestMatchReckonsDaysInLocation A timed deadline, or a creation time, is
+// on the day it is where the list is asked for; an all-day date is the
+// same day everywhere.
+func TestMatchReckonsDaysInLocation(t *testing.T) {
+	deadline := time.Date(2026, 11, 1, 23, 30, 0, 0, time.UTC)
+	allDay := day("2026-11-01")
+	timed := internal.Todo{DueDate: &deadline, DueTimed: true, CreatedAt: deadline}
+	untimed := internal.Todo{DueDate: &allDay}
+	paris := time.FixedZone("CET", 60*60)
+
+	cases := []struct {
+		query string
+		item  internal.Todo
+		loc   *time.Location
+		want  bool
+	}{
+		{"due:2026-11-01", timed, time.UTC, true},
+		{"due:2026-11-01", timed, paris, false},
+		{"due:2026-11-02", timed, paris, true},
+		{"created:2026-11-02", timed, paris, true},
+		{"due:2026-11-01", untimed, paris, true},
+		{"due:2026-11-01", untimed, time.FixedZone("PST", -8*60*60), true},
+	}
+	for _, c := range cases {
+		expr, err := filter.Parse(c.query)
+		if assert.Nil(t, err, c.query) {
+			assert.Equal(t, c.want, expr.Match(c.item, c.loc), "%s in %s", c.query, c.loc)
+		}
+	}
+}
+
+func TestFormatParsesBackToTheSameExpression(t *testing.T) {
+	for _, query := range []string{
+		"tag:work and (priority:high or due<2026-11-01) and not tag:blocked",
+		`TAG:a OR tag:b name:"buy milk"`,
+		"not (done:true or priority:none) due:none parent=none",
+		"(tag:a or tag:b) and (tag:c or tag:d)",
+		"not (tag:a and tag:b) or id>=3 created<=2026-01-31",
+		`name:"" name:"a(b)"`,
+	} {
+		expr, err := filter.Parse(query)
+		assert.Nil(t, err, query)
+
+		formatted := filter.Format(expr)
+		again, err := filter.Parse(formatted)
+		assert.Nil(t, err, formatted)
+		assert.Equal(t, expr, again, "%s formatted as %s", query, formatted)
+	}
+}
+
+func TestRelativeDates(t *testing.T) {
+	y, m, d := time.Now().Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	tomorrow, nextWeek := today.AddDate(0, 0, 1), today.AddDate(0, 0, 7)
+
+	cases := map[string]bool{
+		"due:today":        false,
+		"due:tomorrow":     true,
+		"due<+1w":          true,
+		`due<"in 2 days"`:  true,
+		`due>="in 3 days"`: false,
+	}
+	for query, want := range cases {
+		expr, err := filter.Parse(query)
+		if assert.Nil(t, err, query) {
+			assert.Equal(t, want, expr.Match(internal.Todo{DueDate: &tomorrow}, time.Local), query)
+		}
+	}
+
+	// Formatted, relative dates become the days they were read as.
+	expr, err := filter.Parse("due<+1w")
+	assert.Nil(t, err)
+	assert.Equal(t, "due<"+nextWeek.Format("2006-01-02"), filter.Format(expr))
+}
@@ -0,0 +1,157 @@
+// Package ical reads and writes todo items as iCalendar VTODO components
+// (RFC 5545), so that they can be shared with calendar apps.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tcooper-uk/go-todo/internal"
+)
+
+const (
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405Z"
+	// maxLineOctets The longest a content line may be before it is folded.
+	maxLineOctets = 75
+	uidDomain     = "go-todo"
+)
+
+// priorities The PRIORITY written for each priority: the highest, middle
+// and lowest of RFC 5545's 1 to 9.
+var priorities = map[internal.Priority]int{
+	internal.PriorityHigh:   1,
+	internal.PriorityMedium: 5,
+	internal.PriorityLow:    9,
+}
+
+// Priority The PRIORITY written for p, if it has one.
+func Priority(p internal.Priority) (int, bool) {
+	value, ok := priorities[p]
+	return value, ok
+}
+
+// UID A stable identifier for todo, the same every time it is exported
+// from the same store.
+func UID(todo internal.Todo) string {
+	return fmt.Sprintf("%d-%d@%s", todo.ID, todo.CreatedAt.Unix(), uidDomain)
+}
+
+// Component A VTODO: an item and the UIDs that identify it and its
+// parent.
+type Component struct {
+	Todo      internal.Todo
+	UID       string
+	ParentUID string
+}
+
+// Write Write items to w as one VCALENDAR of VTODOs. Subtasks are related
+// to their parent by its UID, when the parent is among items.
+func Write(w io.Writer, items []internal.Todo) error {
+	uids := make(map[int]string, len(items))
+	for _, item := range items {
+		uids[item.ID] = UID(item)
+	}
+
+	components := make([]Component, len(items))
+	for i, item := range items {
+		components[i] = Component{Todo: item, UID: uids[item.ID]}
+		if item.ParentID != 0 {
+			components[i].ParentUID = uids[item.ParentID]
+		}
+	}
+	return WriteComponents(w, components)
+}
+
+// WriteComponents Write components to w as one VCALENDAR.
+func WriteComponents(w io.Writer, components []Component) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		fold(bw, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//go-todo//todo//EN")
+	for _, c := range components {
+		item := c.Todo
+		line("BEGIN", "VTODO")
+		line("UID", c.UID)
+		// Without a METHOD, DTSTAMP is when the item was last revised.
+		line("DTSTAMP", formatTime(item.UpdatedAt))
+		line("CREATED", formatTime(item.CreatedAt))
+		line("LAST-MODIFIED", formatTime(item.UpdatedAt))
+		line("SUMMARY", escape(item.Name))
+		if item.Done {
+			line("STATUS", "COMPLETED")
+			line("COMPLETED", formatTime(item.UpdatedAt))
+		} else {
+			line("STATUS", "NEEDS-ACTION")
+		}
+		if p, ok := Priority(item.Priority); ok {
+			line("PRIORITY", strconv.Itoa(p))
+		}
+		if item.DueDate != nil {
+			if item.DueTimed {
+				line("DUE", formatTime(*item.DueDate))
+			} else {
+				line("DUE;VALUE=DATE", item.DueDate.UTC().Format(dateLayout))
+			}
+		}
+		// DTSTART must be a date too alongside an all-day DUE.
+		if item.StartDate != nil {
+			if item.DueDate != nil && !item.DueTimed {
+				line("DTSTART;VALUE=DATE", item.StartDate.In(time.Local).Format(dateLayout))
+			} else {
+				line("DTSTART", formatTime(*item.StartDate))
+			}
+		}
+		if len(item.Tags) > 0 {
+			tags := make([]string, len(item.Tags))
+			for i, tag := range item.Tags {
+				tags[i] = escape(tag)
+			}
+			line("CATEGORIES", strings.Join(tags, ","))
+		}
+		if item.Recurrence != "" {
+			line("RRULE", item.Recurrence)
+		}
+		if c.ParentUID != "" {
+			line("RELATED-TO;RELTYPE=PARENT", c.ParentUID)
+		}
+		line("END", "VTODO")
+	}
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(dateTimeLayout)
+}
+
+// escape A TEXT value as RFC 5545 writes it.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// fold Write a content line, broken into lines of at most 75 octets, each
+// after the first starting with a space, without splitting a character.
+func fold(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// The leading space counts towards the next line's length.
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(line + "\r\n")
+}
@@ -0,0 +1,344 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/recur"
+)
+
+// contentLine One unfolded line of an iCalendar file: NAME;PARAM=x:VALUE.
+type contentLine struct {
+	num    int
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Read Parse the VTODOs in an iCalendar file, ignoring every other kind of
+// component. Items get IDs in the order they appear, and subtasks the
+// ParentID of the item their RELATED-TO names, so that the two stay linked
+// when imported. A rule in RRULE that recur does not support is dropped.
+func Read(r io.Reader) ([]internal.Todo, error) {
+	components, err := ReadComponents(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]int, len(components))
+	for i, c := range components {
+		if c.UID != "" {
+			ids[c.UID] = i + 1
+		}
+	}
+	items := make([]internal.Todo, len(components))
+	for i, c := range components {
+		items[i] = c.Todo
+		items[i].ID = i + 1
+		items[i].ParentID = ids[c.ParentUID]
+	}
+	return items, nil
+}
+
+// ReadComponents Parse the VTODOs in an iCalendar file, as Read does, but
+// leave items without IDs and keep the UIDs that link them.
+func ReadComponents(r io.Reader) ([]Component, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || lines[0].name != "BEGIN" || !strings.EqualFold(lines[0].value, "VCALENDAR") {
+		return nil, errors.New("not an iCalendar file: it should start with BEGIN:VCALENDAR")
+	}
+
+	var components []Component
+	var current *Component
+	// stack The components the current line is within.
+	var stack []string
+	for _, line := range lines {
+		switch line.name {
+		case "BEGIN":
+			component := strings.ToUpper(line.value)
+			stack = append(stack, component)
+			if component == "VTODO" && len(stack) == 2 {
+				current = &Component{}
+			}
+			continue
+		case "END":
+			component := strings.ToUpper(line.value)
+			if len(stack) == 0 || stack[len(stack)-1] != component {
+				return nil, fmt.Errorf("line %d: END:%s does not match a BEGIN", line.num, line.value)
+			}
+			stack = stack[:len(stack)-1]
+			if component == "VTODO" && current != nil && len(stack) == 1 {
+				if current.Todo.Name == "" {
+					current.Todo.Name = "Untitled"
+				}
+				components = append(components, *current)
+				current = nil
+			}
+			continue
+		}
+
+		// Properties of components within the VTODO, like VALARM, are not
+		// the item's.
+		if current != nil && len(stack) == 2 {
+			if err := current.set(line); err != nil {
+				return nil, fmt.Errorf("line %d: %s: %w", line.num, line.name, err)
+			}
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%s is never ended", stack[len(stack)-1])
+	}
+	return components, nil
+}
+
+// set Apply a property of the VTODO to the item.
+func (c *Component) set(line contentLine) error {
+	var err error
+	switch line.name {
+	case "UID":
+		c.UID = line.value
+	case "SUMMARY":
+		c.Todo.Name = unescape(line.value)
+	case "STATUS":
+		c.Todo.Done = strings.EqualFold(line.value, "COMPLETED")
+	case "COMPLETED":
+		c.Todo.Done = true
+		if c.Todo.UpdatedAt.IsZero() {
+			c.Todo.UpdatedAt, err = parseTime(line)
+		}
+	case "LAST-MODIFIED":
+		c.Todo.UpdatedAt, err = parseTime(line)
+	case "CREATED":
+		c.Todo.CreatedAt, err = parseTime(line)
+	case "PRIORITY":
+		var p int
+		if p, err = strconv.Atoi(line.value); err == nil {
+			c.Todo.Priority = priorityOf(p)
+		}
+	case "DUE":
+		var due time.Time
+		if due, err = parseTime(line); err == nil {
+			c.Todo.DueTimed = !isDateValue(line)
+			if c.Todo.DueTimed {
+				due = due.UTC()
+				c.Todo.DueZone = zoneOf(line)
+			}
+			c.Todo.DueDate = &due
+		}
+	case "DTSTART":
+		// A start day begins at local midnight.
+		var start time.Time
+		if start, err = parseTime(line); err == nil {
+			if isDateValue(line) {
+				start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.Local)
+			}
+			start = start.UTC()
+			c.Todo.StartDate = &start
+		}
+	case "CATEGORIES":
+		for _, tag := range splitList(line.value) {
+			if tag = strings.TrimSpace(unescape(tag)); tag != "" {
+				c.Todo.Tags = append(c.Todo.Tags, tag)
+			}
+		}
+	case "RRULE":
+		if rule, err := recur.Parse(line.value); err == nil {
+			c.Todo.Recurrence = rule.String()
+		}
+	case "RELATED-TO":
+		if reltype := line.params["RELTYPE"]; reltype == "" || strings.EqualFold(reltype, "PARENT") {
+			c.ParentUID = line.value
+		}
+	}
+	return err
+}
+
+// priorityOf The priority for a PRIORITY of 1 to 9, highest first; 0 is
+// none.
+func priorityOf(p int) internal.Priority {
+	switch {
+	case p >= 1 && p <= 4:
+		return internal.PriorityHigh
+	case p == 5:
+		return internal.PriorityMedium
+	case p >= 6 && p <= 9:
+		return internal.PriorityLow
+	}
+	return internal.PriorityNone
+}
+
+// parseTime Read a DATE or DATE-TIME value. Dates are midnight UTC, as due
+// dates are stored; times are in UTC, their TZID, or local time if they
+// have neither.
+func parseTime(line contentLine) (time.Time, error) {
+	value := line.value
+	if isDateValue(line) {
+		t, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q", value)
+		}
+		return t, nil
+	}
+
+	loc := time.Local
+	if strings.HasSuffix(value, "Z") {
+		loc = time.UTC
+	} else if tzid := line.params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", strings.TrimSuffix(value, "Z"), loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date-time %q", value)
+	}
+	return t, nil
+}
+
+// isDateValue Whether line holds a DATE rather than a DATE-TIME.
+func isDateValue(line contentLine) bool {
+	return strings.EqualFold(line.params["VALUE"], "DATE") || len(line.value) == len(dateLayout)
+}
+
+// zoneOf The IANA time zone a DATE-TIME is given in by its TZID, or "" if
+// it has none, or one Go does not know.
+func zoneOf(line contentLine) string {
+	tzid := line.params["TZID"]
+	if _, err := time.LoadLocation(tzid); tzid == "" || err != nil {
+		return ""
+	}
+	return tzid
+}
+
+// unfold Read r into content lines, joining folded lines back together.
+func unfold(r io.Reader) ([]contentLine, error) {
+	var raw []string
+	var nums []int
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if (strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t")) && len(raw) > 0 {
+			raw[len(raw)-1] += text[1:]
+			continue
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		raw = append(raw, text)
+		nums = append(nums, n)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading iCalendar: %w", err)
+	}
+
+	lines := make([]contentLine, len(raw))
+	for i, text := range raw {
+		line, ok := parseContentLine(text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: invalid content line %q", nums[i], text)
+		}
+		line.num = nums[i]
+		lines[i] = line
+	}
+	return lines, nil
+}
+
+// parseContentLine Split a line into its name, parameters and value. The
+// value starts after the first colon that is not within a quoted
+// parameter value.
+func parseContentLine(text string) (contentLine, bool) {
+	inQuotes := false
+	colon := -1
+	for i, r := range text {
+		if r == '"' {
+			inQuotes = !inQuotes
+		} else if r == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon <= 0 {
+		return contentLine{}, false
+	}
+
+	parts := splitUnquoted(text[:colon], ';')
+	line := contentLine{
+		name:   strings.ToUpper(parts[0]),
+		params: map[string]string{},
+		value:  text[colon+1:],
+	}
+	for _, param := range parts[1:] {
+		key, value, _ := strings.Cut(param, "=")
+		line.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+	return line, line.name != ""
+}
+
+func splitUnquoted(s string, sep rune) []string {
+	var parts []string
+	inQuotes := false
+	start := 0
+	for i, r := range s {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == sep && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// splitList Split a list of TEXT values on the commas that are not
+// escaped.
+func splitList(s string) []string {
+	var parts []string
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			b.WriteByte(s[i])
+			b.WriteByte(s[i+1])
+			i++
+		case s[i] == ',':
+			parts = append(parts, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return append(parts, b.String())
+}
+
+// unescape A TEXT value as RFC 5545 escapes it, read back.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,164 @@
+package ical_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/ical"
+)
+
+func sampleItems() []internal.Todo {
+	created := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	updated := time.Date(2026, 10, 18, 17, 30, 0, 0, time.UTC)
+	due := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+	deadline := time.Date(2026, 11, 1, 9, 30, 0, 0, time.UTC)
+	start := time.Date(2026, 10, 25, 8, 0, 0, 0, time.UTC)
+	return []internal.Todo{
+		{ID: 1, Name: "Plan the party; invite everyone, bring snacks", Priority: internal.PriorityHigh,
+			DueDate: &due, Tags: []string{"home", "a,b"}, Recurrence: "FREQ=YEARLY",
+			CreatedAt: created, UpdatedAt: created},
+		{ID: 2, Name: "Book the venue", Done: true, ParentID: 1, Priority: internal.PriorityLow,
+			DueDate: &deadline, DueTimed: true, StartDate: &start, CreatedAt: created, UpdatedAt: updated},
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, ical.Write(&buf, sampleItems()))
+	out := buf.String()
+
+	assert.True(t, strings.HasPrefix(out, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(out, "END:VCALENDAR\r\n"))
+	for _, want := range []string{
+		"UID:1-1790845200@go-todo\r\n",
+		"SUMMARY:Plan the party\\; invite everyone\\, bring snacks\r\n",
+		"STATUS:NEEDS-ACTION\r\n",
+		"PRIORITY:1\r\n",
+		"DUE;VALUE=DATE:20261102\r\n",
+		"DUE:20261101T093000Z\r\n",
+		"DTSTART:20261025T080000Z\r\n",
+		"CATEGORIES:home,a\\,b\r\n",
+		"RRULE:FREQ=YEARLY\r\n",
+		"CREATED:20261001T090000Z\r\n",
+		"STATUS:COMPLETED\r\n",
+		"COMPLETED:20261018T173000Z\r\n",
+		"LAST-MODIFIED:20261018T173000Z\r\n",
+		"PRIORITY:9\r\n",
+		"RELATED-TO;RELTYPE=PARENT:1-1790845200@go-todo\r\n",
+	} {
+		assert.Contains(t, out, want)
+	}
+
+	// The same items always get the same UIDs.
+	var again bytes.Buffer
+	ical.Write(&again, sampleItems())
+	assert.Equal(t, out, again.String())
+}
+
+func TestWriteFoldsLongLines(t *testing.T) {
+	item := internal.Todo{ID: 1, Name: strings.Repeat("café ", 40)}
+	var buf bytes.Buffer
+	assert.Nil(t, ical.Write(&buf, []internal.Todo{item}))
+
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 75, line)
+		assert.True(t, strings.ToValidUTF8(line, "?") == line, "split a character: %q", line)
+	}
+
+	items, err := ical.Read(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, item.Name, items[0].Name)
+}
+
+func TestRoundTrip(t *testing.T) {
+	want := sampleItems()
+	var buf bytes.Buffer
+	assert.Nil(t, ical.Write(&buf, want))
+
+	got, err := ical.Read(&buf)
+	assert.Nil(t, err)
+	assert.Len(t, got, 2)
+	for i := range want {
+		assert.Equal(t, want[i].ID, got[i].ID)
+		assert.Equal(t, want[i].Name, got[i].Name)
+		assert.Equal(t, want[i].Done, got[i].Done)
+		assert.Equal(t, want[i].Priority, got[i].Priority)
+		assert.Equal(t, want[i].Tags, got[i].Tags)
+		assert.Equal(t, want[i].ParentID, got[i].ParentID)
+		assert.Equal(t, want[i].Recurrence, got[i].Recurrence)
+		assert.True(t, want[i].CreatedAt.Equal(got[i].CreatedAt))
+		assert.True(t, want[i].UpdatedAt.Equal(got[i].UpdatedAt))
+	}
+	for i := range want {
+		assert.True(t, want[i].DueDate.Equal(*got[i].DueDate))
+		assert.Equal(t, want[i].DueTimed, got[i].DueTimed)
+	}
+	assert.Nil(t, got[0].StartDate)
+	assert.True(t, want[1].StartDate.Equal(*got[1].StartDate))
+}
+
+func TestReadFromOtherApps(t *testing.T) {
+	data := "BEGIN:VCALENDAR\n" +
+		"PRODID:-//Another//App//EN\n" +
+		"BEGIN:VEVENT\n" +
+		"SUMMARY:Not a todo\n" +
+		"END:VEVENT\n" +
+		"BEGIN:VTODO\n" +
+		"UID:parent@example.com\n" +
+		"SUMMARY:Renew passport\n" +
+		"PRIORITY:3\n" +
+		"DUE;TZID=Europe/London:20261102T090000\n" +
+		"DTSTART;TZID=Europe/London:20261026T090000\n" +
+		"CATEGORIES:admin\n" +
+		"CATEGORIES:travel\n" +
+		"BEGIN:VALARM\n" +
+		"ACTION:DISPLAY\n" +
+		"SUMMARY:Alarm text\n" +
+		"END:VALARM\n" +
+		"END:VTODO\n" +
+		"BEGIN:VTODO\n" +
+		"UID:child@example.com\n" +
+		"SUMMARY:Find the old\n" +
+		"  one\n" +
+		"STATUS:COMPLETED\n" +
+		"RELATED-TO:parent@example.com\n" +
+		"RRULE:FREQ=SECONDLY\n" +
+		"END:VTODO\n" +
+		"END:VCALENDAR\n"
+
+	items, err := ical.Read(strings.NewReader(data))
+	assert.Nil(t, err)
+	assert.Len(t, items, 2)
+
+	assert.Equal(t, "Renew passport", items[0].Name)
+	assert.Equal(t, internal.PriorityHigh, items[0].Priority)
+	assert.Equal(t, []string{"admin", "travel"}, items[0].Tags)
+	assert.Equal(t, "2026-11-02T09:00:00Z", items[0].DueDate.UTC().Format(time.RFC3339))
+	assert.True(t, items[0].DueTimed)
+	assert.Equal(t, "Europe/London", items[0].DueZone)
+	assert.Equal(t, "2026-10-26T09:00:00Z", items[0].StartDate.Format(time.RFC3339))
+
+	assert.Equal(t, "Find the old one", items[1].Name)
+	assert.True(t, items[1].Done)
+	assert.Equal(t, items[0].ID, items[1].ParentID)
+	assert.Empty(t, items[1].Recurrence)
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"SUMMARY:no calendar\n",
+		"BEGIN:VCALENDAR\nBEGIN:VTODO\nSUMMARY:x\nEND:VCALENDAR\n",
+		"BEGIN:VCALENDAR\nBEGIN:VTODO\nnot a content line\nEND:VTODO\nEND:VCALENDAR\n",
+		"BEGIN:VCALENDAR\nBEGIN:VTODO\nDUE:tomorrow\nEND:VTODO\nEND:VCALENDAR\n",
+		"BEGIN:VCALENDAR\nBEGIN:VTODO\nEND:VTODO\n",
+	} {
+		_, err := ical.Read(strings.NewReader(data))
+		assert.NotNil(t, err, data)
+	}
+}
@@ -0,0 +1,376 @@
+// Package markdown reads and writes todo lists as GitHub-style Markdown
+// checklists, as meeting notes keep them:
+//
+//	## party
+//
+//	- [ ] Plan the party !high @2026-11-02 #party
+//	  - [x] Book the venue #party
+package markdown
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal"
+)
+
+const dateLayout = "2006-01-02"
+
+// Group How Write puts items under headings.
+type Group string
+
+const (
+	// GroupNone One list, without headings.
+	GroupNone Group = ""
+	// GroupTag A heading for each tag, with items under their first one.
+	GroupTag Group = "tag"
+	// GroupPriority A heading for each priority, highest first.
+	GroupPriority Group = "priority"
+)
+
+// indentWidth How far a subtask is indented under its parent, and how
+// many columns a tab counts as when reading.
+const indentWidth = 2
+
+var priorityNames = map[internal.Priority]string{
+	internal.PriorityHigh:   "high",
+	internal.PriorityMedium: "medium",
+	internal.PriorityLow:    "low",
+}
+
+var (
+	// listItem A bullet or numbered list item: its marker and text.
+	listItem = regexp.MustCompile(`^(?:[-*+]|\d{1,9}[.)])(?:\s+(.*))?$`)
+	// checkbox The box of a checklist item, checked or not, and its text.
+	checkbox = regexp.MustCompile(`^\[([ xX])\](?:\s+(.*))?$`)
+	// otherBox A box of some other state, like [-] or [?].
+	otherBox = regexp.MustCompile(`^\[.\](?:\s|$)`)
+	// tagToken An inline #tag. Numbers alone, like #12, are left in the
+	// name, as they usually refer to an issue.
+	tagToken = regexp.MustCompile(`^#[^\s#]*[^\s#\d][^\s#]*$`)
+	// dueToken An inline @ followed by something that is meant to be a date.
+	dueToken = regexp.MustCompile(`^@\d`)
+	heading  = regexp.MustCompile(`^#{1,6}(\s|$)`)
+	fence    = regexp.MustCompile("^(```|~~~)")
+)
+
+// Warning A problem with a line of the file: one that was skipped, or an
+// item with a marker that could not be read.
+type Warning struct {
+	Line   int
+	Text   string
+	Reason string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("line %d: %s: %s", w.Line, w.Reason, w.Text)
+}
+
+// open A list item that later, more indented items are nested within.
+type open struct {
+	indent int
+	// id The item's ID, or 0 for a bullet without a checkbox, whose text
+	// is put before the names of the items nested within it instead.
+	id   int
+	text string
+	line int
+	used bool
+}
+
+// Read Parse the checklist items of r: - [ ] for items to do and - [x] for
+// those done. Each item's text may hold #tags, a priority of !high,
+// !medium or !low, and a due date as @2026-11-01. Items nested within a
+// checklist item are its subtasks, linked by ID and ParentID as todotxt
+// links them; items nested within a bullet without a checkbox get the
+// bullet's text before their name, e.g. "Groceries: milk". Headings, blank
+// lines and code blocks are skipped quietly, and any other line with a
+// warning.
+func Read(r io.Reader) ([]internal.Todo, []Warning, error) {
+	var items []internal.Todo
+	var warnings []Warning
+	warn := func(line int, text, reason string) {
+		warnings = append(warnings, Warning{Line: line, Text: text, Reason: reason})
+	}
+
+	var stack []open
+	pop := func(indent int) {
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			top := stack[len(stack)-1]
+			if top.id == 0 && !top.used {
+				warn(top.line, top.text, "skipped a list item without a checkbox")
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	inFence := false
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for num := 1; scanner.Scan(); num++ {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		text := strings.TrimLeft(line, " \t")
+		switch {
+		case fence.MatchString(text):
+			inFence = !inFence
+			continue
+		case inFence || text == "":
+			continue
+		case heading.MatchString(text):
+			pop(0)
+			continue
+		}
+
+		m := listItem.FindStringSubmatch(text)
+		if m == nil {
+			warn(num, text, "skipped a line that is not a list item")
+			continue
+		}
+		indent := indentOf(line[:len(line)-len(text)])
+		pop(indent)
+
+		box := checkbox.FindStringSubmatch(m[1])
+		if box == nil {
+			if otherBox.MatchString(m[1]) {
+				warn(num, text, "skipped a checkbox that is neither [ ] nor [x]")
+				continue
+			}
+			stack = append(stack, open{indent: indent, text: m[1], line: num})
+			continue
+		}
+
+		todo, problems := parseText(box[2])
+		for _, problem := range problems {
+			warn(num, text, problem)
+		}
+		if todo.Name == "" {
+			warn(num, text, "skipped a checklist item with no text")
+			continue
+		}
+		todo.Done = box[1] != " "
+
+		// The bullets between the item and its parent, if any, name it.
+		var prefixes []string
+		for i := len(stack) - 1; i >= 0 && stack[i].id == 0; i-- {
+			prefixes = append([]string{stack[i].text}, prefixes...)
+			stack[i].used = true
+		}
+		if len(prefixes) > 0 {
+			todo.Name = strings.Join(append(prefixes, todo.Name), ": ")
+		}
+		if i := len(stack) - len(prefixes) - 1; i >= 0 {
+			todo.ParentID = stack[i].id
+		}
+
+		todo.ID = len(items) + 1
+		items = append(items, todo)
+		stack = append(stack, open{indent: indent, id: todo.ID, text: text, line: num})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("reading Markdown: %w", err)
+	}
+	pop(0)
+	sort.SliceStable(warnings, func(i, j int) bool { return warnings[i].Line < warnings[j].Line })
+	return items, warnings, nil
+}
+
+// indentOf The column leading whitespace reaches, with tabs as 4 columns.
+func indentOf(lead string) int {
+	n := 0
+	for _, r := range lead {
+		if r == '\t' {
+			n += 2 * indentWidth
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// parseText Read the markers out of a checklist item's text, leaving the
+// rest as its name. Markers that can't be read stay in the name, with a
+// problem reported for each.
+func parseText(text string) (internal.Todo, []string) {
+	var todo internal.Todo
+	var name, problems []string
+	for _, token := range strings.Fields(text) {
+		switch {
+		case isEscaped(token):
+			name = append(name, token[1:])
+		case tagToken.MatchString(token):
+			todo.Tags = append(todo.Tags, token[1:])
+		case token[0] == '!' && priorityOf(token[1:]) != internal.PriorityNone:
+			todo.Priority = priorityOf(token[1:])
+		case dueToken.MatchString(token):
+			due, err := time.Parse(dateLayout, token[1:])
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s is not a date like @2026-11-01; kept it in the name", token))
+				name = append(name, token)
+				continue
+			}
+			todo.DueDate = &due
+		default:
+			name = append(name, token)
+		}
+	}
+	todo.Name = strings.Join(name, " ")
+	return todo, problems
+}
+
+func priorityOf(name string) internal.Priority {
+	for priority, n := range priorityNames {
+		if strings.EqualFold(n, name) {
+			return priority
+		}
+	}
+	return internal.PriorityNone
+}
+
+// isMarker Whether a word of a name would be read as a marker.
+func isMarker(word string) bool {
+	return tagToken.MatchString(word) || dueToken.MatchString(word) ||
+		(word[0] == '!' && priorityOf(word[1:]) != internal.PriorityNone) || isEscaped(word)
+}
+
+// isEscaped Whether a word is a marker, or an escaped one, with a
+// backslash before it to keep it in the name.
+func isEscaped(word string) bool {
+	return len(word) > 1 && word[0] == '\\' && isMarker(word[1:])
+}
+
+// Write Write items to w as a checklist, with subtasks nested under their
+// parents, grouped under headings as group asks. Each item's priority,
+// due date and tags follow its name as markers that Read understands;
+// words of the name that would be read as markers are escaped.
+func Write(w io.Writer, items []internal.Todo, group Group) error {
+	if group != GroupNone && group != GroupTag && group != GroupPriority {
+		return fmt.Errorf("unknown group %q (expected tag or priority)", group)
+	}
+
+	present := make(map[int]bool, len(items))
+	for _, item := range items {
+		present[item.ID] = true
+	}
+	children := map[int][]internal.Todo{}
+	var roots []internal.Todo
+	for _, item := range items {
+		if item.ParentID != 0 && present[item.ParentID] {
+			children[item.ParentID] = append(children[item.ParentID], item)
+		} else {
+			roots = append(roots, item)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	var writeTree func(item internal.Todo, depth int)
+	writeTree = func(item internal.Todo, depth int) {
+		box := "[ ]"
+		if item.Done {
+			box = "[x]"
+		}
+		fmt.Fprintf(bw, "%s- %s %s\n", strings.Repeat(" ", depth*indentWidth), box, format(item))
+		for _, child := range children[item.ID] {
+			writeTree(child, depth+1)
+		}
+	}
+
+	for i, g := range groups(roots, group) {
+		if i > 0 {
+			bw.WriteString("\n")
+		}
+		if g.heading != "" {
+			fmt.Fprintf(bw, "## %s\n\n", g.heading)
+		}
+		for _, item := range g.items {
+			writeTree(item, 0)
+		}
+	}
+	return bw.Flush()
+}
+
+type itemGroup struct {
+	heading string
+	items   []internal.Todo
+}
+
+// groups Split items under headings: tags in alphabetical order with
+// untagged items last, or priorities highest first.
+func groups(items []internal.Todo, group Group) []itemGroup {
+	switch group {
+	case GroupTag:
+		byTag := map[string][]internal.Todo{}
+		var untagged []internal.Todo
+		for _, item := range items {
+			if len(item.Tags) == 0 {
+				untagged = append(untagged, item)
+				continue
+			}
+			byTag[item.Tags[0]] = append(byTag[item.Tags[0]], item)
+		}
+		tags := make([]string, 0, len(byTag))
+		for tag := range byTag {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+
+		var result []itemGroup
+		for _, tag := range tags {
+			result = append(result, itemGroup{tag, byTag[tag]})
+		}
+		if len(untagged) > 0 {
+			result = append(result, itemGroup{"Untagged", untagged})
+		}
+		return result
+
+	case GroupPriority:
+		var result []itemGroup
+		for _, p := range []internal.Priority{internal.PriorityHigh, internal.PriorityMedium, internal.PriorityLow, internal.PriorityNone} {
+			var matching []internal.Todo
+			for _, item := range items {
+				if item.Priority == p {
+					matching = append(matching, item)
+				}
+			}
+			if len(matching) == 0 {
+				continue
+			}
+			heading := "No priority"
+			if name, ok := priorityNames[p]; ok {
+				heading = strings.ToUpper(name[:1]) + name[1:] + " priority"
+			}
+			result = append(result, itemGroup{heading, matching})
+		}
+		return result
+	}
+
+	if len(items) == 0 {
+		return nil
+	}
+	return []itemGroup{{items: items}}
+}
+
+// format The text of todo's checklist item.
+func format(todo internal.Todo) string {
+	var parts []string
+	for _, word := range strings.Fields(todo.Name) {
+		if isMarker(word) {
+			word = `\` + word
+		}
+		parts = append(parts, word)
+	}
+	if name, ok := priorityNames[todo.Priority]; ok {
+		parts = append(parts, "!"+name)
+	}
+	// Markdown lists have no times, so a deadline is written as its day.
+	if due := todo.DueDay(todo.DueLocation(time.Local)); due != nil {
+		parts = append(parts, "@"+due.Format(dateLayout))
+	}
+	for _, tag := range todo.Tags {
+		parts = append(parts, "#"+strings.Join(strings.Fields(tag), "_"))
+	}
+	return strings.Join(parts, " ")
+}
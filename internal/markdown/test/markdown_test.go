@@ -0,0 +1,152 @@
+package markdown_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/markdown"
+)
+
+func TestRead(t *testing.T) {
+	notes := `# Planning meeting
+
+Attendees: everyone.
+
+- [ ] Plan the party !high @2026-11-02 #party
+  - [x] Book the venue #party #venue
+  - [ ] Send invites
+* [X] Pay rent !LOW
+1. [ ] Renew passport @2026-12-01
+- Groceries
+  - [ ] milk #shop
+  - [ ] eggs
+- [ ] Fix bug #12 with \#party in the title
+`
+	items, warnings, err := markdown.Read(strings.NewReader(notes))
+	assert.Nil(t, err)
+	assert.Len(t, items, 8)
+
+	due := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, internal.Todo{ID: 1, Name: "Plan the party", Priority: internal.PriorityHigh,
+		DueDate: &due, Tags: []string{"party"}}, items[0])
+
+	assert.Equal(t, "Book the venue", items[1].Name)
+	assert.True(t, items[1].Done)
+	assert.Equal(t, 1, items[1].ParentID)
+	assert.Equal(t, []string{"party", "venue"}, items[1].Tags)
+	assert.Equal(t, 1, items[2].ParentID)
+
+	assert.Equal(t, "Pay rent", items[3].Name)
+	assert.True(t, items[3].Done)
+	assert.Equal(t, internal.PriorityLow, items[3].Priority)
+	assert.Equal(t, 0, items[3].ParentID)
+
+	assert.Equal(t, "2026-12-01", items[4].DueDate.Format("2006-01-02"))
+
+	// Items nested within a plain bullet take its text into their name.
+	assert.Equal(t, "Groceries: milk", items[5].Name)
+	assert.Equal(t, []string{"shop"}, items[5].Tags)
+	assert.Equal(t, "Groceries: eggs", items[6].Name)
+	assert.Equal(t, 0, items[6].ParentID)
+
+	assert.Equal(t, "Fix bug #12 with #party in the title", items[7].Name)
+	assert.Empty(t, items[7].Tags)
+
+	assert.Equal(t, []markdown.Warning{
+		{Line: 3, Text: "Attendees: everyone.", Reason: "skipped a line that is not a list item"},
+	}, warnings)
+}
+
+func TestReadWarnings(t *testing.T) {
+	notes := "- [ ] Call mum @2026-13-40\n" +
+		"- [-] Cancelled thing\n" +
+		"- [ ] #only-tags\n" +
+		"- A bullet with nothing nested\n" +
+		"```\n- [ ] not an item\n```\n" +
+		"- [ ] Last one\n"
+	items, warnings, err := markdown.Read(strings.NewReader(notes))
+	assert.Nil(t, err)
+	assert.Len(t, items, 2)
+	assert.Equal(t, "Call mum @2026-13-40", items[0].Name)
+	assert.Nil(t, items[0].DueDate)
+	assert.Equal(t, "Last one", items[1].Name)
+
+	var lines []int
+	for _, w := range warnings {
+		lines = append(lines, w.Line)
+	}
+	assert.Equal(t, []int{1, 2, 3, 4}, lines)
+	assert.Contains(t, warnings[0].Reason, "not a date")
+	assert.Contains(t, warnings[1].Reason, "neither [ ] nor [x]")
+	assert.Contains(t, warnings[2].Reason, "no text")
+	assert.Contains(t, warnings[3].Reason, "without a checkbox")
+}
+
+func sampleItems() []internal.Todo {
+	due := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+	return []internal.Todo{
+		{ID: 1, Name: "Plan the party", Priority: internal.PriorityHigh, DueDate: &due, Tags: []string{"party"}},
+		{ID: 2, Name: "Book the venue", Done: true, ParentID: 1, Tags: []string{"venue"}},
+		{ID: 3, Name: "Fix #12 for !high", Priority: internal.PriorityLow},
+		{ID: 4, Name: "Buy milk", Tags: []string{"shop", "home office"}},
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, markdown.Write(&buf, sampleItems(), markdown.GroupNone))
+	assert.Equal(t, `- [ ] Plan the party !high @2026-11-02 #party
+  - [x] Book the venue #venue
+- [ ] Fix #12 for \!high !low
+- [ ] Buy milk #shop #home_office
+`, buf.String())
+
+	// What is written reads back the same.
+	items, warnings, err := markdown.Read(&buf)
+	assert.Nil(t, err)
+	assert.Empty(t, warnings)
+	want := sampleItems()
+	want[3].Tags = []string{"shop", "home_office"}
+	assert.Equal(t, want, items)
+}
+
+func TestWriteGrouped(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, markdown.Write(&buf, sampleItems(), markdown.GroupTag))
+	assert.Equal(t, `## party
+
+- [ ] Plan the party !high @2026-11-02 #party
+  - [x] Book the venue #venue
+
+## shop
+
+- [ ] Buy milk #shop #home_office
+
+## Untagged
+
+- [ ] Fix #12 for \!high !low
+`, buf.String())
+
+	buf.Reset()
+	assert.Nil(t, markdown.Write(&buf, sampleItems(), markdown.GroupPriority))
+	assert.Equal(t, `## High priority
+
+- [ ] Plan the party !high @2026-11-02 #party
+  - [x] Book the venue #venue
+
+## Low priority
+
+- [ ] Fix #12 for \!high !low
+
+## No priority
+
+- [ ] Buy milk #shop #home_office
+`, buf.String())
+
+	assert.NotNil(t, markdown.Write(&buf, sampleItems(), markdown.Group("colour")))
+}
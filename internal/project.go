@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Project A named list of items, inside another project if ParentID is
+// set.
+type Project struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	ParentID int    `json:"parent_id,omitempty"`
+	// Archived Set once the project is finished with: it is left out of
+	// the project list and takes no new items.
+	Archived  bool      `json:"archived,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NestedProject A project together with its depth in the project tree.
+type NestedProject struct {
+	Project Project
+	Depth   int
+}
+
+// NestProjects Order projects depth-first so every project follows its
+// parent, siblings by name. Projects whose parent is not in projects are
+// treated as top-level.
+func NestProjects(projects []Project) []NestedProject {
+	sorted := append([]Project(nil), projects...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return strings.ToLower(sorted[i].Name) < strings.ToLower(sorted[j].Name)
+	})
+
+	present := make(map[int]bool, len(sorted))
+	for _, p := range sorted {
+		present[p.ID] = true
+	}
+	var roots []Project
+	children := make(map[int][]Project)
+	for _, p := range sorted {
+		if p.ParentID != 0 && p.ParentID != p.ID && present[p.ParentID] {
+			children[p.ParentID] = append(children[p.ParentID], p)
+		} else {
+			roots = append(roots, p)
+		}
+	}
+
+	nested := make([]NestedProject, 0, len(sorted))
+	visited := make(map[int]bool, len(sorted))
+	var walk func(p Project, depth int)
+	walk = func(p Project, depth int) {
+		if visited[p.ID] {
+			return
+		}
+		visited[p.ID] = true
+		nested = append(nested, NestedProject{Project: p, Depth: depth})
+		for _, child := range children[p.ID] {
+			walk(child, depth+1)
+		}
+	}
+	for _, root := range roots {
+		walk(root, 0)
+	}
+	// Anything left over is part of a parent cycle; show it rather than lose it.
+	for _, p := range sorted {
+		walk(p, 0)
+	}
+	return nested
+}
+
+// SubProjects The ids of the project with id and every project below it.
+func SubProjects(projects []Project, id int) []int {
+	ids := []int{id}
+	visited := map[int]bool{id: true}
+	for i := 0; i < len(ids); i++ {
+		for _, p := range projects {
+			if p.ParentID == ids[i] && !visited[p.ID] {
+				visited[p.ID] = true
+				ids = append(ids, p.ID)
+			}
+		}
+	}
+	return ids
+}
+
+// ProjectPath The names of the project with id and its ancestors, outermost
+// first, joined by "/", e.g. "work/clients". Empty if there is no such
+// project.
+func ProjectPath(projects []Project, id int) string {
+	byID := make(map[int]Project, len(projects))
+	for _, p := range projects {
+		byID[p.ID] = p
+	}
+
+	var names []string
+	for seen := map[int]bool{}; id != 0 && !seen[id]; {
+		p, ok := byID[id]
+		if !ok {
+			break
+		}
+		seen[id] = true
+		names = append([]string{p.Name}, names...)
+		id = p.ParentID
+	}
+	return strings.Join(names, "/")
+}
@@ -0,0 +1,652 @@
+// Package recur implements RRULE-style recurrence rules for repeating todos.
+package recur
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/taskwarrior"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxPeriods Bounds the search for the next occurrence, so a rule that can
+// never match (e.g. the 5th Monday of every February) gives up.
+const maxPeriods = 1000
+
+// ByDay A weekday, optionally restricted to its Nth occurrence within the
+// month: 1 is the first, -1 the last, 0 every occurrence.
+type ByDay struct {
+	N       int
+	Weekday time.Weekday
+}
+
+// Rule A recurrence rule, a subset of RFC 5545 RRULE.
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	ByDay    []ByDay
+	Until    *time.Time
+	Count    int
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+var ordinals = map[string]int{
+	"first": 1, "1st": 1, "second": 2, "2nd": 2, "third": 3, "3rd": 3,
+	"fourth": 4, "4th": 4, "fifth": 5, "5th": 5, "last": -1,
+}
+
+// Parse Read a rule written either as an RRULE ("FREQ=WEEKLY;BYDAY=MO,FR")
+// or in short English ("weekly", "every 2 weeks on mon,fri",
+// "every last friday until 2026-12-31", "monthly for 6 times").
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, errors.New("empty recurrence rule")
+	}
+
+	var rule *Rule
+	var err error
+	if upper := strings.ToUpper(s); strings.HasPrefix(upper, "RRULE:") || strings.Contains(upper, "FREQ=") {
+		rule, err = parseRRule(strings.TrimPrefix(upper, "RRULE:"))
+	} else {
+		rule, err = parseEnglish(s)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if rule.Interval < 1 {
+		rule.Interval = 1
+	}
+	if rule.Freq == Yearly && len(rule.ByDay) > 0 {
+		return nil, errors.New("weekdays are not supported on yearly rules")
+	}
+	for _, d := range rule.ByDay {
+		if d.N != 0 && rule.Freq != Monthly {
+			return nil, errors.New("numbered weekdays like \"last fri\" need a monthly rule")
+		}
+	}
+	return rule, nil
+}
+
+func parseRRule(s string) (*Rule, error) {
+	rule := &Rule{}
+
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid RRULE part %q", part)
+		}
+
+		switch key {
+		case "FREQ":
+			switch f := Frequency(value); f {
+			case Daily, Weekly, Monthly, Yearly:
+				rule.Freq = f
+			default:
+				return nil, fmt.Errorf("unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid INTERVAL %q", value)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid COUNT %q", value)
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				d, err := parseByDay(code)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, d)
+			}
+		case "WKST":
+			// Weeks always start on Monday.
+		default:
+			return nil, fmt.Errorf("unsupported RRULE part %q", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, errors.New("RRULE is missing FREQ")
+	}
+	return rule, nil
+}
+
+func parseByDay(code string) (ByDay, error) {
+	if len(code) < 2 {
+		return ByDay{}, fmt.Errorf("invalid BYDAY %q", code)
+	}
+	weekday, ok := weekdayCodes[code[len(code)-2:]]
+	if !ok {
+		return ByDay{}, fmt.Errorf("invalid BYDAY %q", code)
+	}
+
+	var n int
+	if prefix := code[:len(code)-2]; prefix != "" {
+		var err error
+		n, err = strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return ByDay{}, fmt.Errorf("invalid BYDAY %q", code)
+		}
+	}
+	return ByDay{N: n, Weekday: weekday}, nil
+}
+
+func parseUntil(s string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid end date %q — use YYYY-MM-DD", s)
+}
+
+func parseEnglish(s string) (*Rule, error) {
+	s = strings.ToLower(strings.ReplaceAll(s, ",", " "))
+	var tokens []string
+	for _, tok := range strings.Fields(s) {
+		if tok != "and" {
+			tokens = append(tokens, tok)
+		}
+	}
+
+	rule := &Rule{Interval: 1}
+	i := 0
+	next := func() string {
+		if i < len(tokens) {
+			return tokens[i]
+		}
+		return ""
+	}
+
+	switch tok := next(); tok {
+	case "daily":
+		rule.Freq = Daily
+		i++
+	case "weekly":
+		rule.Freq = Weekly
+		i++
+	case "fortnightly":
+		rule.Freq = Weekly
+		rule.Interval = 2
+		i++
+	case "monthly":
+		rule.Freq = Monthly
+		i++
+	case "yearly", "annually":
+		rule.Freq = Yearly
+		i++
+	case "every":
+		i++
+		if n, err := strconv.Atoi(next()); err == nil {
+			if n < 1 {
+				return nil, fmt.Errorf("invalid interval %d", n)
+			}
+			rule.Interval = n
+			i++
+		}
+
+		switch unit := strings.TrimSuffix(next(), "s"); {
+		case unit == "day":
+			rule.Freq = Daily
+			i++
+		case unit == "week":
+			rule.Freq = Weekly
+			i++
+		case unit == "month":
+			rule.Freq = Monthly
+			i++
+		case unit == "year":
+			rule.Freq = Yearly
+			i++
+		case unit == "weekday":
+			rule.Freq = Daily
+			for _, code := range []string{"MO", "TU", "WE", "TH", "FR"} {
+				rule.ByDay = append(rule.ByDay, ByDay{Weekday: weekdayCodes[code]})
+			}
+			i++
+		default:
+			days, n, err := parseEnglishDays(tokens[i:])
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 {
+				return nil, fmt.Errorf("expected a period or weekday after \"every\", got %q", next())
+			}
+			rule.Freq = Weekly
+			for _, d := range days {
+				if d.N != 0 {
+					rule.Freq = Monthly
+				}
+			}
+			rule.ByDay = days
+			i += n
+		}
+	default:
+		return nil, fmt.Errorf("cannot understand %q — try \"weekly\", \"every 2 days\" or \"every last fri\"", s)
+	}
+
+	for i < len(tokens) {
+		switch tok := next(); tok {
+		case "on":
+			i++
+			days, n, err := parseEnglishDays(tokens[i:])
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 {
+				return nil, fmt.Errorf("expected weekdays after \"on\", got %q", next())
+			}
+			rule.ByDay = append(rule.ByDay, days...)
+			i += n
+		case "until":
+			i++
+			until, err := parseUntil(next())
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = &until
+			i++
+		case "for", "x", "count":
+			i++
+			n, err := strconv.Atoi(next())
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("expected a number of times after %q", tok)
+			}
+			rule.Count = n
+			i++
+			if t := next(); t == "times" || t == "time" || t == "occurrences" {
+				i++
+			}
+		default:
+			return nil, fmt.Errorf("unexpected %q in recurrence rule", tok)
+		}
+	}
+
+	return rule, nil
+}
+
+// parseEnglishDays Read weekdays such as "mon fri" or "last friday" from
+// the start of tokens, returning how many tokens were consumed.
+func parseEnglishDays(tokens []string) ([]ByDay, int, error) {
+	var days []ByDay
+	i := 0
+	for i < len(tokens) {
+		n := 0
+		if ord, ok := ordinals[tokens[i]]; ok {
+			if i+1 >= len(tokens) {
+				return nil, 0, fmt.Errorf("expected a weekday after %q", tokens[i])
+			}
+			n = ord
+			i++
+		}
+		weekday, ok := weekdayNames[tokens[i]]
+		if !ok {
+			if n != 0 {
+				return nil, 0, fmt.Errorf("expected a weekday, got %q", tokens[i])
+			}
+			break
+		}
+		days = append(days, ByDay{N: n, Weekday: weekday})
+		i++
+	}
+	return days, i, nil
+}
+
+// String The rule in canonical RRULE form, as stored with the todo.
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		var codes []string
+		for _, d := range r.ByDay {
+			code := strings.ToUpper(d.Weekday.String()[:2])
+			if d.N != 0 {
+				code = strconv.Itoa(d.N) + code
+			}
+			codes = append(codes, code)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	}
+	if r.Count > 0 {
+		parts = append(parts, fmt.Sprintf("COUNT=%d", r.Count))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Describe The rule in short English, e.g. "every 2 weeks on Mon, Fri".
+func (r Rule) Describe() string {
+	units := map[Frequency]string{Daily: "day", Weekly: "week", Monthly: "month", Yearly: "year"}
+
+	desc := "every " + units[r.Freq]
+	if r.Interval > 1 {
+		desc = fmt.Sprintf("every %d %ss", r.Interval, units[r.Freq])
+	}
+
+	if len(r.ByDay) > 0 {
+		var days []string
+		for _, d := range r.ByDay {
+			day := d.Weekday.String()[:3]
+			switch {
+			case d.N == -1:
+				day = "last " + day
+			case d.N > 0:
+				day = ordinal(d.N) + " " + day
+			}
+			days = append(days, day)
+		}
+		desc += " on " + strings.Join(days, ", ")
+	}
+	if r.Until != nil {
+		desc += " until " + r.Until.Format("2006-01-02")
+	}
+	if r.Count > 0 {
+		desc += fmt.Sprintf(", %d occurrence(s) left", r.Count)
+	}
+	return desc
+}
+
+func ordinal(n int) string {
+	switch n {
+	case 1:
+		return "1st"
+	case 2:
+		return "2nd"
+	case 3:
+		return "3rd"
+	}
+	if n < 0 {
+		return fmt.Sprintf("%d", n)
+	}
+	return fmt.Sprintf("%dth", n)
+}
+
+// Next The first occurrence strictly after from, keeping from's time of day.
+// Returns false when the rule has no further occurrences.
+func (r Rule) Next(from time.Time) (time.Time, bool) {
+	if r.Count == 1 {
+		return time.Time{}, false
+	}
+
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	var next time.Time
+	var found bool
+
+	switch r.Freq {
+	case Daily:
+		next, found = r.nextDaily(from, interval)
+	case Weekly:
+		next, found = r.nextWeekly(from, interval)
+	case Monthly:
+		next, found = r.nextMonthly(from, interval)
+	case Yearly:
+		next, found = addMonths(from, 12*interval), true
+	}
+
+	if !found || r.afterUntil(next) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// Advance The rule to store on the next occurrence: the same rule with one
+// fewer occurrence remaining when it has a COUNT.
+func (r Rule) Advance() Rule {
+	if r.Count > 1 {
+		r.Count--
+	}
+	return r
+}
+
+func (r Rule) afterUntil(t time.Time) bool {
+	if r.Until == nil {
+		return false
+	}
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.After(*r.Until)
+}
+
+func (r Rule) matchesWeekday(t time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, d := range r.ByDay {
+		if d.Weekday == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) nextDaily(from time.Time, interval int) (time.Time, bool) {
+	next := from
+	for i := 0; i < maxPeriods; i++ {
+		next = next.AddDate(0, 0, interval)
+		if r.matchesWeekday(next) {
+			return next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (r Rule) nextWeekly(from time.Time, interval int) (time.Time, bool) {
+	if len(r.ByDay) == 0 {
+		return from.AddDate(0, 0, 7*interval), true
+	}
+
+	// Weeks start on Monday.
+	offset := (int(from.Weekday()) + 6) % 7
+	weekStart := from.AddDate(0, 0, -offset)
+
+	for period := 0; period < maxPeriods; period++ {
+		start := weekStart.AddDate(0, 0, 7*interval*period)
+		var candidates []time.Time
+		for _, d := range r.ByDay {
+			candidates = append(candidates, start.AddDate(0, 0, (int(d.Weekday)+6)%7))
+		}
+		if next, ok := firstAfter(candidates, from); ok {
+			return next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (r Rule) nextMonthly(from time.Time, interval int) (time.Time, bool) {
+	if len(r.ByDay) == 0 {
+		return addMonths(from, interval), true
+	}
+
+	for period := 0; period < maxPeriods; period++ {
+		month := time.Date(from.Year(), from.Month()+time.Month(interval*period), 1,
+			from.Hour(), from.Minute(), from.Second(), from.Nanosecond(), from.Location())
+
+		var candidates []time.Time
+		for _, d := range r.ByDay {
+			candidates = append(candidates, weekdaysInMonth(month, d)...)
+		}
+		if next, ok := firstAfter(candidates, from); ok {
+			return next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// weekdaysInMonth The days in month matching d, given the first of the month.
+func weekdaysInMonth(first time.Time, d ByDay) []time.Time {
+	var days []time.Time
+	for day := first.AddDate(0, 0, (int(d.Weekday)-int(first.Weekday())+7)%7); day.Month() == first.Month(); day = day.AddDate(0, 0, 7) {
+		days = append(days, day)
+	}
+
+	switch {
+	case d.N == 0:
+		return days
+	case d.N > 0 && d.N <= len(days):
+		return days[d.N-1 : d.N]
+	case d.N < 0 && -d.N <= len(days):
+		return days[len(days)+d.N : len(days)+d.N+1]
+	}
+	return nil
+}
+
+func firstAfter(candidates []time.Time, from time.Time) (time.Time, bool) {
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Before(candidates[j])
+	})
+	for _, c := range candidates {
+		if c.After(from) {
+			return c, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// addMonths Move t by n months, clamping to the end of shorter months so
+// the 31st becomes the 30th rather than spilling into the next month.
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(n), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// NextOccurrence The item to add after completing a repeating todo at
+// completedAt, or nil when it does not repeat or its series has ended.
+// The next due date follows the current one, or completedAt if it has none.
+func NextOccurrence(todo internal.Todo, completedAt time.Time) (*internal.Todo, error) {
+	if todo.Recurrence == "" {
+		return nil, nil
+	}
+
+	rule, err := Parse(todo.Recurrence)
+	if err != nil {
+		return nil, fmt.Errorf("item %d has an invalid recurrence rule: %w", todo.ID, err)
+	}
+
+	// Without a due date, repeat from the day it was completed. A timed
+	// deadline repeats at the same time of day where it was set, whatever
+	// the change in its offset from UTC.
+	from := internal.Day(completedAt)
+	if todo.DueDate != nil {
+		from = *todo.DueDate
+		if todo.DueTimed {
+			from = from.In(todo.DueLocation(time.Local))
+		}
+	}
+
+	due, ok := rule.Next(from)
+	if !ok {
+		return nil, nil
+	}
+	due = due.UTC()
+
+	// A start date moves with the due date, keeping the item deferred for
+	// as long before it.
+	var start *time.Time
+	if todo.StartDate != nil {
+		moved := todo.StartDate.Add(due.Sub(from)).UTC()
+		start = &moved
+	}
+
+	// The next occurrence is a new task to Taskwarrior.
+	extras := cloneExtras(todo.Extras)
+	taskwarrior.Unlink(extras)
+
+	return &internal.Todo{
+		Name:       todo.Name,
+		Priority:   todo.Priority,
+		Tags:       todo.Tags,
+		ParentID:   todo.ParentID,
+		Project:    todo.Project,
+		DueDate:    &due,
+		DueTimed:   todo.DueDate != nil && todo.DueTimed,
+		DueZone:    todo.DueZone,
+		StartDate:  start,
+		Recurrence: rule.Advance().String(),
+		Extras:     extras,
+	}, nil
+}
+
+// cloneExtras A copy of extras sharing nothing with it, so that changing the
+// attributes kept on one occurrence leaves the others alone.
+func cloneExtras(extras map[string]any) map[string]any {
+	if extras == nil {
+		return nil
+	}
+	return cloneValue(extras).(map[string]any)
+}
+
+// cloneValue A deep copy of a value decoded from JSON.
+func cloneValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(v))
+		for k, e := range v {
+			c[k] = cloneValue(e)
+		}
+		return c
+	case []any:
+		c := make([]any, len(v))
+		for i, e := range v {
+			c[i] = cloneValue(e)
+		}
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,193 @@
+package recur_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/recur"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func mustParse(t *testing.T, s string) *recur.Rule {
+	rule, err := recur.Parse(s)
+	assert.Nil(t, err)
+	return rule
+}
+
+func TestParseEnglishRules(t *testing.T) {
+	cases := map[string]string{
+		"daily":                                 "FREQ=DAILY",
+		"weekly":                                "FREQ=WEEKLY",
+		"fortnightly":                           "FREQ=WEEKLY;INTERVAL=2",
+		"every 3 days":                          "FREQ=DAILY;INTERVAL=3",
+		"every weekday":                         "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR",
+		"every mon, fri":                        "FREQ=WEEKLY;BYDAY=MO,FR",
+		"every 2 weeks on tuesday and thursday": "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH",
+		"every last friday":                     "FREQ=MONTHLY;BYDAY=-1FR",
+		"monthly on 2nd tue":                    "FREQ=MONTHLY;BYDAY=2TU",
+		"yearly until 2030-01-01":               "FREQ=YEARLY;UNTIL=20300101",
+		"every month for 6 times":               "FREQ=MONTHLY;COUNT=6",
+	}
+
+	for in, want := range cases {
+		rule, err := recur.Parse(in)
+		if assert.Nil(t, err, in) {
+			assert.Equal(t, want, rule.String(), in)
+		}
+	}
+}
+
+func TestParseRRuleRoundTrips(t *testing.T) {
+	in := "FREQ=MONTHLY;INTERVAL=2;BYDAY=-1FR;UNTIL=20261231;COUNT=4"
+	assert.Equal(t, in, mustParse(t, "RRULE:"+in).String())
+}
+
+func TestParseRejectsNonsense(t *testing.T) {
+	for _, in := range []string{"", "sometimes", "every", "FREQ=HOURLY", "every 2 weeks on blursday", "weekly on last fri", "BYDAY=MO"} {
+		_, err := recur.Parse(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestNextWeeklyOnWeekdays(t *testing.T) {
+	rule := mustParse(t, "every mon, fri")
+
+	// Wed 2026-10-14 -> Fri 2026-10-16 -> Mon 2026-10-19.
+	next, ok := rule.Next(date(2026, 10, 14))
+	assert.True(t, ok)
+	assert.Equal(t, date(2026, 10, 16), next)
+
+	next, _ = rule.Next(next)
+	assert.Equal(t, date(2026, 10, 19), next)
+}
+
+func TestNextEveryOtherWeek(t *testing.T) {
+	rule := mustParse(t, "every 2 weeks on mon,fri")
+
+	// Fri 2026-10-16 ends the week, so skip a week to Mon 2026-10-26.
+	next, _ := rule.Next(date(2026, 10, 16))
+	assert.Equal(t, date(2026, 10, 26), next)
+}
+
+func TestNextLastFridayOfMonth(t *testing.T) {
+	rule := mustParse(t, "every last fri")
+
+	next, _ := rule.Next(date(2026, 10, 30))
+	assert.Equal(t, date(2026, 11, 27), next)
+
+	next, _ = rule.Next(date(2026, 11, 1))
+	assert.Equal(t, date(2026, 11, 27), next)
+}
+
+func TestNextMonthlyClampsToMonthEnd(t *testing.T) {
+	rule := mustParse(t, "monthly")
+
+	next, _ := rule.Next(date(2027, 1, 31))
+	assert.Equal(t, date(2027, 2, 28), next)
+}
+
+func TestNextKeepsTimeOfDay(t *testing.T) {
+	rule := mustParse(t, "daily")
+
+	from := time.Date(2026, 10, 18, 14, 30, 0, 0, time.UTC)
+	next, _ := rule.Next(from)
+	assert.Equal(t, time.Date(2026, 10, 19, 14, 30, 0, 0, time.UTC), next)
+}
+
+func TestNextStopsAtUntil(t *testing.T) {
+	rule := mustParse(t, "weekly until 2026-10-20")
+
+	_, ok := rule.Next(date(2026, 10, 13))
+	assert.True(t, ok)
+
+	_, ok = rule.Next(date(2026, 10, 14))
+	assert.False(t, ok)
+}
+
+func TestNextOccurrenceCountsDown(t *testing.T) {
+	due := date(2026, 10, 30)
+	todo := internal.Todo{
+		ID:         1,
+		Name:       "retro",
+		Priority:   internal.PriorityHigh,
+		Tags:       []string{"team"},
+		DueDate:    &due,
+		Recurrence: "FREQ=MONTHLY;BYDAY=-1FR;COUNT=2",
+	}
+
+	next, err := recur.NextOccurrence(todo, due)
+	assert.Nil(t, err)
+	assert.Equal(t, "retro", next.Name)
+	assert.Equal(t, internal.PriorityHigh, next.Priority)
+	assert.Equal(t, []string{"team"}, next.Tags)
+	assert.False(t, next.Done)
+	assert.Equal(t, date(2026, 11, 27), *next.DueDate)
+	assert.Equal(t, "FREQ=MONTHLY;BYDAY=-1FR;COUNT=1", next.Recurrence)
+
+	last, err := recur.NextOccurrence(*next, *next.DueDate)
+	assert.Nil(t, err)
+	assert.Nil(t, last)
+}
+
+func TestNextOccurrenceKeepsProject(t *testing.T) {
+	todo := internal.Todo{Name: "standup notes", Project: 4, Recurrence: "FREQ=WEEKLY"}
+
+	next, err := recur.NextOccurrence(todo, date(2026, 10, 18))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, next.Project)
+}
+
+func TestNextOccurrenceWithoutDueDate(t *testing.T) {
+	todo := internal.Todo{Name: "water plants", Recurrence: "FREQ=DAILY;INTERVAL=3"}
+
+	next, err := recur.NextOccurrence(todo, time.Date(2026, 10, 18, 9, 15, 0, 0, time.UTC))
+	assert.Nil(t, err)
+	assert.Equal(t, date(2026, 10, 21), *next.DueDate)
+}
+
+// TestNextOccurrenceKeepsDeadlineTime A deadline repeats at the same time
+// where it was set, here across the end of British Summer Time.
+func TestNextOccurrenceKeepsDeadlineTime(t *testing.T) {
+	due := time.Date(2026, 10, 23, 8, 0, 0, 0, time.UTC) // 09:00 BST
+	todo := internal.Todo{Name: "timesheet", DueDate: &due, DueTimed: true, DueZone: "Europe/London", Recurrence: "FREQ=WEEKLY"}
+
+	next, err := recur.NextOccurrence(todo, due)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2026, 10, 30, 9, 0, 0, 0, time.UTC), *next.DueDate) // 09:00 GMT
+	assert.True(t, next.DueTimed)
+	assert.Equal(t, "Europe/London", next.DueZone)
+}
+
+func TestNextOccurrenceMovesStartDate(t *testing.T) {
+	due := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+	start := due.AddDate(0, 0, -3)
+	todo := internal.Todo{Name: "rent", DueDate: &due, StartDate: &start, Recurrence: "FREQ=MONTHLY"}
+
+	next, err := recur.NextOccurrence(todo, due)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2026, 12, 2, 0, 0, 0, 0, time.UTC), *next.DueDate)
+	assert.Equal(t, time.Date(2026, 11, 29, 0, 0, 0, 0, time.UTC), *next.StartDate)
+}
+
+// TestNextOccurrenceKeepsExtras The attributes of other tools carry over to
+// the next occurrence, as copies of their own, bar the Taskwarrior uuid.
+func TestNextOccurrenceKeepsExtras(t *testing.T) {
+	todo := internal.Todo{
+		Name:       "backup",
+		Recurrence: "FREQ=WEEKLY",
+		Extras:     map[string]any{"taskwarrior": map[string]any{"uuid": "5e5f6b3c-8d1e-4a8e-9c1f-0b5f7a2d3e41", "uda": "x", "annotations": []any{"a"}}},
+	}
+
+	next, err := recur.NextOccurrence(todo, date(2026, 10, 18))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]any{"taskwarrior": map[string]any{"uda": "x", "annotations": []any{"a"}}}, next.Extras)
+
+	next.Extras["taskwarrior"].(map[string]any)["uda"] = "y"
+	assert.Equal(t, "x", todo.Extras["taskwarrior"].(map[string]any)["uda"])
+}
@@ -0,0 +1,217 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/tcooper-uk/go-todo/internal"
+)
+
+// Markers placed around matched words in a Result snippet.
+const (
+	MarkStart = "\x02"
+	MarkEnd   = "\x03"
+	Ellipsis  = "…"
+)
+
+// snippetWords The number of words shown either side of the first match.
+const snippetWords = 5
+
+// Result A matching item with its relevance and a highlighted excerpt of
+// its name.
+type Result struct {
+	Item    internal.Todo
+	Score   float64
+	Snippet string
+}
+
+// document An item split into the words it can be found by.
+type document struct {
+	item  internal.Todo
+	words []string
+}
+
+func newDocument(item internal.Todo) document {
+	words := Tokenize(item.Name)
+	for _, tag := range item.Tags {
+		words = append(words, Tokenize(tag)...)
+	}
+	return document{item: item, words: words}
+}
+
+// Filter Search items in memory, for stores without a search index.
+// Results are ranked by TF-IDF, best first.
+func Filter(items []internal.Todo, expr Expr) []Result {
+	docs := make([]document, len(items))
+	for i, item := range items {
+		docs[i] = newDocument(item)
+	}
+
+	terms := positives(expr)
+
+	// How many documents contain each term, for weighting rare words higher.
+	docFreq := make([]int, len(terms))
+	for _, doc := range docs {
+		for i, term := range terms {
+			if count(term, doc.words) > 0 {
+				docFreq[i]++
+			}
+		}
+	}
+
+	var results []Result
+	for _, doc := range docs {
+		if !matches(expr, doc.words) {
+			continue
+		}
+
+		var score float64
+		for i, term := range terms {
+			if tf := count(term, doc.words); tf > 0 {
+				score += float64(tf) * math.Log(1+float64(len(docs))/float64(docFreq[i]))
+			}
+		}
+
+		results = append(results, Result{
+			Item:    doc.item,
+			Score:   score,
+			Snippet: Highlight(doc.item.Name, expr),
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Item.ID < results[j].Item.ID
+	})
+
+	return results
+}
+
+func matches(expr Expr, words []string) bool {
+	switch e := expr.(type) {
+	case Term, Phrase:
+		return count(e, words) > 0
+	case And:
+		return matches(e.Left, words) && matches(e.Right, words)
+	case Or:
+		return matches(e.Left, words) || matches(e.Right, words)
+	case Not:
+		return !matches(e.Expr, words)
+	}
+	return false
+}
+
+// count How many times a term or phrase occurs in words.
+func count(expr Expr, words []string) int {
+	n := 0
+	switch e := expr.(type) {
+	case Term:
+		for _, w := range words {
+			if w == e.Word || e.Prefix && strings.HasPrefix(w, e.Word) {
+				n++
+			}
+		}
+	case Phrase:
+		for i := 0; i+len(e.Words) <= len(words); i++ {
+			if equalWords(words[i:i+len(e.Words)], e.Words) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func equalWords(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// positives The terms and phrases that make an item match, as opposed to
+// those it must not contain.
+func positives(expr Expr) []Expr {
+	switch e := expr.(type) {
+	case Term, Phrase:
+		return []Expr{e}
+	case And:
+		return append(positives(e.Left), positives(e.Right)...)
+	case Or:
+		return append(positives(e.Left), positives(e.Right)...)
+	}
+	return nil
+}
+
+// Highlight Mark the words of text matched by expr, trimming long text to
+// the words around the first match.
+func Highlight(text string, expr Expr) string {
+	spans := tokenSpans(text)
+	words := make([]string, len(spans))
+	for i, sp := range spans {
+		words[i] = strings.ToLower(text[sp.start:sp.end])
+	}
+
+	marked := make([]bool, len(spans))
+	first := -1
+	for _, term := range positives(expr) {
+		for i := range words {
+			n := 0
+			switch t := term.(type) {
+			case Term:
+				if words[i] == t.Word || t.Prefix && strings.HasPrefix(words[i], t.Word) {
+					n = 1
+				}
+			case Phrase:
+				if i+len(t.Words) <= len(words) && equalWords(words[i:i+len(t.Words)], t.Words) {
+					n = len(t.Words)
+				}
+			}
+			for j := i; j < i+n; j++ {
+				marked[j] = true
+			}
+			if n > 0 && (first == -1 || i < first) {
+				first = i
+			}
+		}
+	}
+
+	// Show a window of words around the first match.
+	from, to := 0, len(spans)
+	if first == -1 {
+		first = 0
+	}
+	if first-snippetWords > 0 {
+		from = first - snippetWords
+	}
+	if first+snippetWords+1 < len(spans) {
+		to = first + snippetWords + 1
+	}
+
+	var b strings.Builder
+	start := 0
+	if from > 0 {
+		b.WriteString(Ellipsis)
+		start = spans[from].start
+	}
+	for i := from; i < to; i++ {
+		b.WriteString(text[start:spans[i].start])
+		if marked[i] {
+			b.WriteString(MarkStart + text[spans[i].start:spans[i].end] + MarkEnd)
+		} else {
+			b.WriteString(text[spans[i].start:spans[i].end])
+		}
+		start = spans[i].end
+	}
+	if to < len(spans) {
+		b.WriteString(Ellipsis)
+	} else {
+		b.WriteString(text[start:])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,343 @@
+// Package search implements the query language of `todo search`: words,
+// "quoted phrases", prefix* matches and the AND, OR and NOT operators.
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Expr A parsed search query.
+type Expr interface {
+	// fts5 Render the expression as an SQLite FTS5 MATCH string.
+	fts5() string
+}
+
+// Term A single word, or every word starting with it when Prefix is set.
+type Term struct {
+	Word   string
+	Prefix bool
+}
+
+// Phrase Consecutive words.
+type Phrase struct {
+	Words []string
+}
+
+type And struct {
+	Left, Right Expr
+}
+
+type Or struct {
+	Left, Right Expr
+}
+
+type Not struct {
+	Expr Expr
+}
+
+// Parse Read a query. Adjacent terms must all match; operators must be
+// written in capitals, and NOT binds tighter than AND, which binds tighter
+// than OR. A leading '-' is shorthand for NOT.
+func Parse(query string) (Expr, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("empty search query")
+	}
+
+	p := &parser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in search query", p.tokens[p.pos].text)
+	}
+	return expr, nil
+}
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokPhrase
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	prefix bool
+}
+
+func lex(query string) ([]token, error) {
+	var tokens []token
+	runes := []rune(query)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")"})
+			i++
+		case r == '-':
+			tokens = append(tokens, token{kind: tokNot, text: "-"})
+			i++
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, errors.New("unterminated phrase in search query")
+			}
+			tokens = append(tokens, token{kind: tokPhrase, text: string(runes[i+1 : end])})
+			i = end + 1
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune(`()"`, runes[end]) {
+				end++
+			}
+			word := string(runes[i:end])
+			i = end
+
+			switch word {
+			case "AND":
+				tokens = append(tokens, token{kind: tokAnd, text: word})
+			case "OR":
+				tokens = append(tokens, token{kind: tokOr, text: word})
+			case "NOT":
+				tokens = append(tokens, token{kind: tokNot, text: word})
+			default:
+				prefix := strings.HasSuffix(word, "*")
+				tokens = append(tokens, token{kind: tokWord, text: strings.TrimRight(word, "*"), prefix: prefix})
+			}
+		}
+	}
+
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() *token {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for tok := p.peek(); tok != nil && tok.kind == tokOr; tok = p.peek() {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = Or{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for tok := p.peek(); tok != nil && tok.kind != tokOr && tok.kind != tokRParen; tok = p.peek() {
+		if tok.kind == tokAnd {
+			p.pos++
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = And{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	tok := p.peek()
+	if tok == nil {
+		return nil, errors.New("search query ends unexpectedly")
+	}
+	p.pos++
+
+	switch tok.kind {
+	case tokNot:
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{expr}, nil
+	case tokLParen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if next := p.peek(); next == nil || next.kind != tokRParen {
+			return nil, errors.New("missing ')' in search query")
+		}
+		p.pos++
+		return expr, nil
+	case tokWord:
+		words := Tokenize(tok.text)
+		if len(words) == 0 {
+			return nil, fmt.Errorf("nothing to search for in %q", tok.text)
+		}
+		if len(words) > 1 {
+			// Punctuated words like "e-mail" match as a phrase, as in FTS5.
+			return Phrase{Words: words}, nil
+		}
+		return Term{Word: words[0], Prefix: tok.prefix}, nil
+	case tokPhrase:
+		words := Tokenize(tok.text)
+		if len(words) == 0 {
+			return nil, fmt.Errorf("nothing to search for in %q", tok.text)
+		}
+		return Phrase{Words: words}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected %q in search query", tok.text)
+}
+
+// Tokenize Split text into lower-case words, as both the FTS5 index and
+// the in-memory search do.
+func Tokenize(text string) []string {
+	var words []string
+	for _, t := range tokenSpans(text) {
+		words = append(words, strings.ToLower(text[t.start:t.end]))
+	}
+	return words
+}
+
+type span struct {
+	start, end int
+}
+
+// tokenSpans The byte offsets of each word in text.
+func tokenSpans(text string) []span {
+	var spans []span
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start == -1:
+			start = i
+		case !isWord && start != -1:
+			spans = append(spans, span{start, i})
+			start = -1
+		}
+	}
+	if start != -1 {
+		spans = append(spans, span{start, len(text)})
+	}
+	return spans
+}
+
+// FTS5 Render expr as an SQLite FTS5 MATCH expression. Returns false when
+// FTS5 cannot express it, e.g. a query that only excludes words.
+func FTS5(expr Expr) (string, bool) {
+	if !expressible(expr) {
+		return "", false
+	}
+	return expr.fts5(), true
+}
+
+// expressible Whether FTS5 can evaluate expr. Its NOT is binary, so every
+// exclusion must sit in an AND alongside something that includes.
+func expressible(expr Expr) bool {
+	switch e := expr.(type) {
+	case Term, Phrase:
+		return true
+	case Or:
+		return expressible(e.Left) && expressible(e.Right)
+	case And:
+		include := false
+		for _, c := range conjuncts(e) {
+			if n, ok := c.(Not); ok {
+				if !expressible(n.Expr) {
+					return false
+				}
+			} else if expressible(c) {
+				include = true
+			} else {
+				return false
+			}
+		}
+		return include
+	}
+	return false
+}
+
+// conjuncts Flatten nested ANDs into the expressions that must all hold.
+func conjuncts(expr Expr) []Expr {
+	if a, ok := expr.(And); ok {
+		return append(conjuncts(a.Left), conjuncts(a.Right)...)
+	}
+	return []Expr{expr}
+}
+
+func quote(word string) string {
+	return `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+}
+
+func (t Term) fts5() string {
+	if t.Prefix {
+		return quote(t.Word) + "*"
+	}
+	return quote(t.Word)
+}
+
+func (p Phrase) fts5() string {
+	return quote(strings.Join(p.Words, " "))
+}
+
+func (a And) fts5() string {
+	// Put the excluded parts after everything that must match.
+	var include, exclude []string
+	for _, c := range conjuncts(a) {
+		if n, ok := c.(Not); ok {
+			exclude = append(exclude, n.Expr.fts5())
+		} else {
+			include = append(include, c.fts5())
+		}
+	}
+
+	out := "(" + strings.Join(include, " AND ") + ")"
+	for _, e := range exclude {
+		out += " NOT " + e
+	}
+	return "(" + out + ")"
+}
+
+func (o Or) fts5() string {
+	return "(" + o.Left.fts5() + " OR " + o.Right.fts5() + ")"
+}
+
+func (n Not) fts5() string {
+	// Never reached for expressions that pass expressible.
+	return "NOT " + n.Expr.fts5()
+}
@@ -0,0 +1,118 @@
+package search_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/search"
+)
+
+func TestParseQueries(t *testing.T) {
+	cases := []struct {
+		query string
+		want  search.Expr
+	}{
+		{"milk", search.Term{Word: "milk"}},
+		{"Milk*", search.Term{Word: "milk", Prefix: true}},
+		{`"buy milk"`, search.Phrase{Words: []string{"buy", "milk"}}},
+		{"e-mail", search.Phrase{Words: []string{"e", "mail"}}},
+		{"buy milk", search.And{Left: search.Term{Word: "buy"}, Right: search.Term{Word: "milk"}}},
+		{"buy AND milk", search.And{Left: search.Term{Word: "buy"}, Right: search.Term{Word: "milk"}}},
+		{"milk OR bread", search.Or{Left: search.Term{Word: "milk"}, Right: search.Term{Word: "bread"}}},
+		{"-milk", search.Not{Expr: search.Term{Word: "milk"}}},
+		{"buy milk OR bread", search.Or{
+			Left:  search.And{Left: search.Term{Word: "buy"}, Right: search.Term{Word: "milk"}},
+			Right: search.Term{Word: "bread"},
+		}},
+		{"buy (milk OR bread)", search.And{
+			Left:  search.Term{Word: "buy"},
+			Right: search.Or{Left: search.Term{Word: "milk"}, Right: search.Term{Word: "bread"}},
+		}},
+		{"buy NOT milk", search.And{Left: search.Term{Word: "buy"}, Right: search.Not{Expr: search.Term{Word: "milk"}}}},
+		{"milk or bread", search.And{
+			Left:  search.And{Left: search.Term{Word: "milk"}, Right: search.Term{Word: "or"}},
+			Right: search.Term{Word: "bread"},
+		}},
+	}
+
+	for _, c := range cases {
+		got, err := search.Parse(c.query)
+		assert.Nil(t, err, c.query)
+		assert.Equal(t, c.want, got, c.query)
+	}
+}
+
+func TestParseRejectsBadQueries(t *testing.T) {
+	for _, query := range []string{"", "   ", `"buy milk`, "(milk", "milk)", "milk OR", "!!!"} {
+		_, err := search.Parse(query)
+		assert.NotNil(t, err, query)
+	}
+}
+
+func TestFTS5Rendering(t *testing.T) {
+	cases := []struct {
+		query string
+		want  string
+		ok    bool
+	}{
+		{"milk", `"milk"`, true},
+		{"rep*", `"rep"*`, true},
+		{`"buy milk"`, `"buy milk"`, true},
+		{"milk OR bread", `("milk" OR "bread")`, true},
+		{"-draft report", `(("report") NOT "draft")`, true},
+		{"-draft", "", false},
+		{"milk OR -bread", "", false},
+	}
+
+	for _, c := range cases {
+		expr, err := search.Parse(c.query)
+		assert.Nil(t, err, c.query)
+		got, ok := search.FTS5(expr)
+		assert.Equal(t, c.ok, ok, c.query)
+		assert.Equal(t, c.want, got, c.query)
+	}
+}
+
+func TestFilterRanksRareWordsHigher(t *testing.T) {
+	items := []internal.Todo{
+		{ID: 1, Name: "Buy milk"},
+		{ID: 2, Name: "Buy bread"},
+		{ID: 3, Name: "Buy milk and bread for the party"},
+		{ID: 4, Name: "Call mum", Tags: []string{"party"}},
+	}
+
+	expr, _ := search.Parse("party OR buy")
+	results := search.Filter(items, expr)
+
+	ids := make([]int, len(results))
+	for i, r := range results {
+		ids[i] = r.Item.ID
+	}
+	assert.Equal(t, []int{3, 4, 1, 2}, ids)
+}
+
+func TestFilterExcludesNegatedWords(t *testing.T) {
+	items := []internal.Todo{
+		{ID: 1, Name: "Write report", Tags: []string{"draft"}},
+		{ID: 2, Name: "Send report"},
+	}
+
+	expr, _ := search.Parse("report -draft")
+	results := search.Filter(items, expr)
+
+	assert.Len(t, results, 1)
+	assert.Equal(t, 2, results[0].Item.ID)
+}
+
+func TestHighlightMarksMatches(t *testing.T) {
+	expr, _ := search.Parse(`rep* OR "quarterly review"`)
+	got := search.Highlight("Write the quarterly review and report", expr)
+	assert.Equal(t, "Write the \x02quarterly\x03 \x02review\x03 and \x02report\x03", got)
+}
+
+func TestHighlightTrimsLongText(t *testing.T) {
+	expr, _ := search.Parse("milk")
+	got := search.Highlight("one two three four five six seven milk eight nine ten eleven twelve thirteen", expr)
+	assert.Equal(t, "…three four five six seven \x02milk\x03 eight nine ten eleven twelve…", got)
+}
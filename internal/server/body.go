@@ -0,0 +1,205 @@
+package server
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/recur"
+)
+
+// readOnlyFields Set by the store, so rejected in request bodies.
+var readOnlyFields = map[string]bool{"id": true, "created_at": true, "updated_at": true, "deleted_at": true}
+
+// applyFields Set the fields of todo present in a request body, keyed by
+// their JSON names. A null clears a field. A due_date given as a date is
+// all-day, and as a time a deadline, unless due_timed says otherwise.
+func applyFields(todo *internal.Todo, fields map[string]json.RawMessage) error {
+	// Apply in a fixed order so the first error reported does not vary.
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		raw := fields[name]
+		null := string(raw) == "null"
+
+		switch name {
+		case "name":
+			if err := json.Unmarshal(raw, &todo.Name); err != nil || null {
+				return badRequest("name must be a string")
+			}
+		case "done":
+			if err := json.Unmarshal(raw, &todo.Done); err != nil || null {
+				return badRequest("done must be true or false")
+			}
+		case "priority":
+			var p string
+			if err := json.Unmarshal(raw, &p); err != nil || !validPriority(p) && p != "" {
+				return badRequest("priority must be low, medium, high or empty")
+			}
+			todo.Priority = internal.Priority(p)
+		case "due_date":
+			if null {
+				todo.DueDate = nil
+				continue
+			}
+			due, timed, err := parseDate(raw)
+			if err != nil {
+				return err
+			}
+			todo.DueDate, todo.DueTimed = &due, timed
+		case "due_timed":
+			if err := json.Unmarshal(raw, &todo.DueTimed); err != nil || null {
+				return badRequest("due_timed must be true or false")
+			}
+		case "due_zone":
+			var zone string
+			if err := json.Unmarshal(raw, &zone); err != nil {
+				return badRequest("due_zone must be a string")
+			}
+			if _, err := time.LoadLocation(zone); err != nil {
+				return badRequest("invalid due_zone %q, use an IANA time zone such as Europe/London", zone)
+			}
+			todo.DueZone = zone
+		case "start_date":
+			var start time.Time
+			if err := json.Unmarshal(raw, &start); err != nil {
+				return badRequest("start_date must be an RFC 3339 time or null")
+			}
+			todo.StartDate = nil
+			if !null {
+				todo.StartDate = &start
+			}
+		case "tags":
+			var tags []string
+			if err := json.Unmarshal(raw, &tags); err != nil {
+				return badRequest("tags must be a list of strings")
+			}
+			todo.Tags = tags
+		case "parent_id":
+			var parent int
+			if err := json.Unmarshal(raw, &parent); err != nil || parent < 0 {
+				return badRequest("parent_id must be an item id, 0 or null")
+			}
+			todo.ParentID = parent
+		case "project":
+			var project int
+			if err := json.Unmarshal(raw, &project); err != nil || project < 0 {
+				return badRequest("project must be a project id, 0 or null")
+			}
+			todo.Project = project
+		case "recurrence":
+			var rule string
+			if err := json.Unmarshal(raw, &rule); err != nil {
+				return badRequest("recurrence must be a string")
+			}
+			if rule == "" {
+				todo.Recurrence = ""
+				continue
+			}
+			parsed, err := recur.Parse(rule)
+			if err != nil {
+				return badRequest("invalid recurrence %q: %v", rule, err)
+			}
+			todo.Recurrence = parsed.String()
+		case "extras":
+			var extras map[string]any
+			if err := json.Unmarshal(raw, &extras); err != nil {
+				return badRequest("extras must be an object or null")
+			}
+			todo.Extras = extras
+		default:
+			if readOnlyFields[name] {
+				return badRequest("%s is read-only", name)
+			}
+			return badRequest("unknown field %q", name)
+		}
+	}
+
+	// An all-day date is its day, as midnight UTC, and has no zone.
+	switch {
+	case todo.DueDate == nil:
+		todo.DueTimed, todo.DueZone = false, ""
+	case !todo.DueTimed:
+		day := internal.Day(todo.DueDate.UTC())
+		todo.DueDate, todo.DueZone = &day, ""
+	default:
+		utc := todo.DueDate.UTC()
+		todo.DueDate = &utc
+	}
+	return nil
+}
+
+// applyProjectFields Set the fields of project present in a request body,
+// as applyFields does for items.
+func applyProjectFields(project *internal.Project, fields map[string]json.RawMessage) error {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		raw := fields[name]
+		null := string(raw) == "null"
+
+		switch name {
+		case "name":
+			if err := json.Unmarshal(raw, &project.Name); err != nil || null {
+				return badRequest("name must be a string")
+			}
+		case "parent_id":
+			var parent int
+			if err := json.Unmarshal(raw, &parent); err != nil || parent < 0 {
+				return badRequest("parent_id must be a project id, 0 or null")
+			}
+			project.ParentID = parent
+		case "archived":
+			if err := json.Unmarshal(raw, &project.Archived); err != nil || null {
+				return badRequest("archived must be true or false")
+			}
+		default:
+			if readOnlyFields[name] {
+				return badRequest("%s is read-only", name)
+			}
+			return badRequest("unknown field %q", name)
+		}
+	}
+
+	project.Name = strings.TrimSpace(project.Name)
+	if project.Name == "" {
+		return badRequest("name is required")
+	}
+	if strings.Contains(project.Name, "/") {
+		return badRequest("name must not contain /")
+	}
+	return nil
+}
+
+// parseDate Read a due date given as YYYY-MM-DD or an RFC 3339 time, and
+// whether it was a time.
+func parseDate(raw json.RawMessage) (time.Time, bool, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if t, err := time.Parse("2006-01-02", s); err == nil {
+			return t, false, nil
+		}
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t, true, nil
+		}
+	}
+	return time.Time{}, false, badRequest("due_date must be YYYY-MM-DD, an RFC 3339 time or null")
+}
+
+func validPriority(p string) bool {
+	switch internal.Priority(p) {
+	case internal.PriorityLow, internal.PriorityMedium, internal.PriorityHigh:
+		return true
+	}
+	return false
+}
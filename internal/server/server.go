@@ -0,0 +1,931 @@
+// Package server exposes a storage.TodoStore as a JSON REST API.
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/filter"
+	"github.com/tcooper-uk/go-todo/internal/storage"
+)
+
+//go:embed openapi.json
+var openAPI []byte
+
+// maxBodyBytes The largest request body accepted.
+const maxBodyBytes = 1 << 20
+
+// Server Serves the todo API:
+//
+//	GET    /todos          list items, filtered by query parameters
+//	POST   /todos          add an item
+//	DELETE /todos          move the items given by id, or all of them, to the trash
+//	GET    /todos/{id}     get an item
+//	PUT    /todos/{id}     replace an item
+//	PATCH  /todos/{id}     change some fields of an item
+//	DELETE /todos/{id}     move an item to the trash
+//	GET    /todos/{id}/history  the changes made to an item, oldest first
+//	GET    /history        the changes made to any item since a time
+//	GET    /trash          list deleted items, filtered as GET /todos
+//	POST   /trash/restore  take the items given by id out of the trash
+//	DELETE /trash          delete the items in the trash for good
+//	GET    /projects       list every project, archived or not
+//	POST   /projects       add a project
+//	PUT    /projects/{id}  replace a project, or add it with that id
+//	GET    /openapi.json   the OpenAPI description of all of the above
+//
+// Items carry an ETag identifying their current version; PUT, PATCH and
+// DELETE honour If-Match so clients can avoid overwriting each other's
+// changes. Changes are recorded in the store's history as made by the
+// actor a RemoteStore names in the Todo-Actor header, or by the token.
+type Server struct {
+	store storage.TodoStore
+	token string
+
+	// mu Serialises writes, so an If-Match check and the change it guards
+	// cannot be interleaved with another request, and keeps stores that are
+	// not safe for concurrent use, like LocalFileStore, consistent.
+	mu sync.RWMutex
+}
+
+// New Create a server for store. Unless token is empty, every request
+// bar /openapi.json must carry it as a bearer token.
+func New(store storage.TodoStore, token string) *Server {
+	return &Server{store: store, token: token}
+}
+
+// httpError An error with the status code it should be reported with.
+type httpError struct {
+	status int
+	msg    string
+}
+
+func (e *httpError) Error() string {
+	return e.msg
+}
+
+func badRequest(format string, args ...any) error {
+	return &httpError{http.StatusBadRequest, fmt.Sprintf(format, args...)}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	r = r.WithContext(storage.WithActor(r.Context(), s.actor(r)))
+
+	switch {
+	case path == "/openapi.json":
+		if !allow(w, r, http.MethodGet) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPI)
+
+	case !s.authorized(r):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="todo"`)
+		writeError(w, &httpError{http.StatusUnauthorized, "missing or wrong bearer token"})
+
+	case path == "/todos":
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			s.list(w, r)
+		case http.MethodPost:
+			s.create(w, r)
+		case http.MethodDelete:
+			s.deleteMany(w, r)
+		default:
+			allow(w, r, http.MethodGet, http.MethodPost, http.MethodDelete)
+		}
+
+	case path == "/trash":
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			s.list(w, r)
+		case http.MethodDelete:
+			s.emptyTrash(w, r)
+		default:
+			allow(w, r, http.MethodGet, http.MethodDelete)
+		}
+
+	case path == "/trash/restore":
+		if allow(w, r, http.MethodPost) {
+			s.restore(w, r)
+		}
+
+	case path == "/history":
+		if allow(w, r, http.MethodGet) {
+			s.changesSince(w, r)
+		}
+
+	case strings.HasPrefix(path, "/todos/") && strings.HasSuffix(path, "/history"):
+		id, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(path, "/todos/"), "/history"))
+		if err != nil || id <= 0 {
+			writeError(w, &httpError{http.StatusNotFound, "no such item"})
+			return
+		}
+		if allow(w, r, http.MethodGet) {
+			s.itemHistory(w, r, id)
+		}
+
+	case strings.HasPrefix(path, "/todos/"):
+		id, err := strconv.Atoi(strings.TrimPrefix(path, "/todos/"))
+		if err != nil || id <= 0 {
+			writeError(w, &httpError{http.StatusNotFound, "no such item"})
+			return
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			s.get(w, r, id)
+		case http.MethodPut:
+			s.put(w, r, id)
+		case http.MethodPatch:
+			s.patch(w, r, id)
+		case http.MethodDelete:
+			s.delete(w, r, id)
+		default:
+			allow(w, r, http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete)
+		}
+
+	case path == "/projects":
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			s.listProjects(w, r)
+		case http.MethodPost:
+			s.putProject(w, r, 0)
+		default:
+			allow(w, r, http.MethodGet, http.MethodPost)
+		}
+
+	case strings.HasPrefix(path, "/projects/"):
+		id, err := strconv.Atoi(strings.TrimPrefix(path, "/projects/"))
+		if err != nil || id <= 0 {
+			writeError(w, &httpError{http.StatusNotFound, "no such project"})
+			return
+		}
+		if allow(w, r, http.MethodPut) {
+			s.putProject(w, r, id)
+		}
+
+	default:
+		writeError(w, &httpError{http.StatusNotFound, "no such resource"})
+	}
+}
+
+// authorized Whether r carries the server's token, if it has one.
+func (s *Server) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(given), []byte(s.token)) == 1
+}
+
+// actor Who the changes r makes are recorded as made by: the user a
+// RemoteStore names, or else the token the request carries, by the start
+// of its hash, or "api" if the server has none.
+func (s *Server) actor(r *http.Request) string {
+	if actor := r.Header.Get(storage.ActorHeader); actor != "" {
+		return actor
+	}
+	if s.token == "" {
+		return "api"
+	}
+	sum := sha256.Sum256([]byte(s.token))
+	return "token:" + hex.EncodeToString(sum[:4])
+}
+
+// allow Reply 405 unless r uses one of methods. GET also allows HEAD.
+func allow(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m || m == http.MethodGet && r.Method == http.MethodHead {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, &httpError{http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method)})
+	return false
+}
+
+// listResponse The body of GET /todos.
+type listResponse struct {
+	Items      []internal.Todo `json:"items"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	opts, err := listOptions(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.mu.RLock()
+	items, err := s.store.GetAllItems(r.Context(), opts)
+	s.mu.RUnlock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp := listResponse{Items: items.Items, NextCursor: items.NextCursor}
+	if resp.Items == nil {
+		resp.Items = []internal.Todo{}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// listOptions Read ListOptions from the query parameters all, done,
+// priority, tag (repeatable), project (repeatable), overdue,
+// include_deferred, filter, sort, limit, offset and cursor, named as the
+// flags of `todo list`, and tz, the time zone days are reckoned in.
+// Requests to /trash list the items in the trash.
+func listOptions(r *http.Request) (storage.ListOptions, error) {
+	q := r.URL.Query()
+	opts := storage.ListOptions{Trashed: strings.TrimSuffix(r.URL.Path, "/") == "/trash"}
+	var err error
+
+	for name, dest := range map[string]*bool{"all": &opts.ShowDone, "done": &opts.OnlyDone, "overdue": &opts.Overdue, "include_deferred": &opts.IncludeDeferred} {
+		if v := q.Get(name); v != "" {
+			if *dest, err = strconv.ParseBool(v); err != nil {
+				return opts, badRequest("invalid %s %q, use true or false", name, v)
+			}
+		}
+	}
+
+	if v := q.Get("priority"); v != "" {
+		if !validPriority(v) {
+			return opts, badRequest("invalid priority %q, use low, medium or high", v)
+		}
+		opts.Priority = v
+	}
+	opts.Tags = q["tag"]
+	for _, v := range q["project"] {
+		project, err := strconv.Atoi(v)
+		if err != nil || project < 0 {
+			return opts, badRequest("invalid project %q", v)
+		}
+		opts.Projects = append(opts.Projects, project)
+	}
+
+	if v := q.Get("filter"); v != "" {
+		if opts.Filter, err = filter.Parse(v); err != nil {
+			return opts, badRequest("%v", err)
+		}
+		if filter.Uses(opts.Filter, filter.FieldDone) && !opts.OnlyDone {
+			opts.ShowDone = true
+		}
+	}
+
+	if v := q.Get("sort"); v != "" {
+		if opts.Sort, err = storage.ParseSort(v); err != nil {
+			return opts, badRequest("%v", err)
+		}
+	}
+
+	for name, dest := range map[string]*int{"limit": &opts.Limit, "offset": &opts.Offset} {
+		if v := q.Get(name); v != "" {
+			if *dest, err = strconv.Atoi(v); err != nil || *dest < 0 {
+				return opts, badRequest("invalid %s %q", name, v)
+			}
+		}
+	}
+	opts.Cursor = q.Get("cursor")
+
+	// Days, for overdue and date filters, are the client's if it says
+	// where it is.
+	if v := q.Get("tz"); v != "" {
+		if opts.Location, err = time.LoadLocation(v); err != nil {
+			return opts, badRequest("invalid tz %q, use an IANA time zone such as Europe/London", v)
+		}
+	}
+
+	return opts, nil
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, id int) {
+	s.mu.RLock()
+	item, err := s.store.GetItem(r.Context(), id)
+	s.mu.RUnlock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	tag := etag(item)
+	if matchesETag(r.Header.Get("If-None-Match"), tag) {
+		w.Header().Set("ETag", tag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeItem(w, http.StatusOK, item)
+}
+
+func (s *Server) create(w http.ResponseWriter, r *http.Request) {
+	fields, err := readBody(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var todo internal.Todo
+	// A new item may keep the creation time it had in another store.
+	if err := takeTime(fields, "created_at", &todo.CreatedAt); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := applyFields(&todo, fields); err != nil {
+		writeError(w, err)
+		return
+	}
+	if strings.TrimSpace(todo.Name) == "" {
+		writeError(w, badRequest("name is required"))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx := r.Context()
+	if err := storage.CheckParent(ctx, s.store, 0, todo.ParentID); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := storage.CheckProject(ctx, s.store, todo.Project); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	added, err := s.store.AddItem(ctx, todo)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/todos/%d", added.ID))
+	writeItem(w, http.StatusCreated, added)
+}
+
+// put Replace every field of an item a client may set, or add the item
+// with that id if there is none and the request has no If-Match. Unlike
+// PATCH it has no side effects, so clients keeping their own copy of an
+// item, like db.RemoteStore, can write it back as it is. Timestamps in the
+// body are kept, so that items can be copied between stores, trash and all.
+func (s *Server) put(w http.ResponseWriter, r *http.Request, id int) {
+	fields, err := readBody(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	todo := internal.Todo{ID: id}
+	if err := takeTime(fields, "created_at", &todo.CreatedAt); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := takeTime(fields, "updated_at", &todo.UpdatedAt); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := takeTime(fields, "deleted_at", &todo.DeletedAt); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := applyFields(&todo, fields); err != nil {
+		writeError(w, err)
+		return
+	}
+	if strings.TrimSpace(todo.Name) == "" {
+		writeError(w, badRequest("name is required"))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx := r.Context()
+	item, err := s.store.GetItem(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) && r.Header.Get("If-Match") == "" {
+		if err := storage.CheckParent(ctx, s.store, id, todo.ParentID); err != nil {
+			writeError(w, err)
+			return
+		}
+		added, err := s.store.PutItem(ctx, todo)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Location", fmt.Sprintf("/todos/%d", added.ID))
+		writeItem(w, http.StatusCreated, added)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := checkIfMatch(r, item); err != nil {
+		writeError(w, err)
+		return
+	}
+	if todo.ParentID != item.ParentID {
+		if err := storage.CheckParent(ctx, s.store, id, todo.ParentID); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if todo.Project != item.Project {
+		if err := storage.CheckProject(ctx, s.store, todo.Project); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	timed := !todo.CreatedAt.IsZero() || !todo.UpdatedAt.IsZero() || todo.DeletedAt != nil
+	if todo.CreatedAt.IsZero() {
+		todo.CreatedAt = item.CreatedAt
+	}
+	var stored *internal.Todo
+	if timed {
+		stored, err = s.store.PutItem(ctx, todo)
+	} else {
+		stored, err = s.store.EditItem(ctx, id, todo)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeItem(w, http.StatusOK, stored)
+}
+
+func (s *Server) patch(w http.ResponseWriter, r *http.Request, id int) {
+	fields, err := readBody(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx := r.Context()
+	item, err := s.store.GetItem(ctx, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := checkIfMatch(r, item); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	before := *item
+	if err := applyFields(item, fields); err != nil {
+		writeError(w, err)
+		return
+	}
+	if item.ParentID != before.ParentID {
+		if err := storage.CheckParent(ctx, s.store, id, item.ParentID); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if item.Project != before.Project {
+		if err := storage.CheckProject(ctx, s.store, item.Project); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	edited, err := s.store.EditItem(ctx, id, *item)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Completing a repeating item schedules the next one, as `todo done` does.
+	if edited.Done && !before.Done {
+		if _, err := storage.AddNextOccurrence(ctx, s.store, *edited, time.Now()); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	writeItem(w, http.StatusOK, edited)
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request, id int) {
+	cascade, err := boolParam(r, "cascade")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, err := s.store.GetItem(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := checkIfMatch(r, item); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if _, err := s.deleteItems(r.Context(), []int{id}, cascade); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteResponse The body of DELETE /todos.
+type deleteResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+// deleteMany Delete the items listed by the repeatable id parameter, all
+// or none of them, or every item with all=true.
+func (s *Server) deleteMany(w http.ResponseWriter, r *http.Request) {
+	all, err := boolParam(r, "all")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	cascade, err := boolParam(r, "cascade")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	ids, err := idParams(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if all == (len(ids) > 0) {
+		writeError(w, badRequest("give the items to delete with id, or all=true"))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int
+	if all {
+		count, err = s.store.DeleteAllItems(r.Context())
+	} else {
+		count, err = s.deleteItems(r.Context(), ids, cascade)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, deleteResponse{Deleted: count})
+}
+
+// idParams Read the repeatable id query parameter.
+func idParams(r *http.Request) ([]int, error) {
+	var ids []int
+	for _, v := range r.URL.Query()["id"] {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			return nil, badRequest("invalid id %q", v)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// historyResponse The body of GET /todos/{id}/history and GET /history.
+type historyResponse struct {
+	Changes []storage.FieldChange `json:"changes"`
+}
+
+func writeHistory(w http.ResponseWriter, changes []storage.FieldChange) {
+	if changes == nil {
+		changes = []storage.FieldChange{}
+	}
+	writeJSON(w, http.StatusOK, historyResponse{Changes: changes})
+}
+
+func (s *Server) itemHistory(w http.ResponseWriter, r *http.Request, id int) {
+	s.mu.RLock()
+	changes, err := storage.ItemHistory(r.Context(), s.store, id)
+	s.mu.RUnlock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeHistory(w, changes)
+}
+
+// changesSince List the changes made to any item at or after the time
+// given by the since parameter.
+func (s *Server) changesSince(w http.ResponseWriter, r *http.Request) {
+	v := r.URL.Query().Get("since")
+	if v == "" {
+		writeError(w, badRequest("give the time to list changes since with since"))
+		return
+	}
+	since, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		writeError(w, badRequest("invalid since %q, use an RFC 3339 time", v))
+		return
+	}
+
+	s.mu.RLock()
+	changes, err := storage.ChangesSince(r.Context(), s.store, since)
+	s.mu.RUnlock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeHistory(w, changes)
+}
+
+// restoreResponse The body of POST /trash/restore.
+type restoreResponse struct {
+	Restored int `json:"restored"`
+}
+
+// restore Take the items listed by the repeatable id parameter out of the
+// trash, all or none of them.
+func (s *Server) restore(w http.ResponseWriter, r *http.Request) {
+	ids, err := idParams(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(ids) == 0 {
+		writeError(w, badRequest("give the items to restore with id"))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count, err := s.store.RestoreItem(r.Context(), ids...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, restoreResponse{Restored: count})
+}
+
+// emptyTrash Delete the items in the trash for good: those moved there
+// before the time given by the before parameter, or all of them.
+func (s *Server) emptyTrash(w http.ResponseWriter, r *http.Request) {
+	var before time.Time
+	if v := r.URL.Query().Get("before"); v != "" {
+		var err error
+		if before, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			writeError(w, badRequest("invalid before %q, use an RFC 3339 time", v))
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count, err := s.store.EmptyTrash(r.Context(), before)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, deleteResponse{Deleted: count})
+}
+
+// projectsResponse The body of GET /projects.
+type projectsResponse struct {
+	Projects []internal.Project `json:"projects"`
+}
+
+func (s *Server) listProjects(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	projects, err := storage.Projects(r.Context(), s.store)
+	s.mu.RUnlock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if projects == nil {
+		projects = []internal.Project{}
+	}
+	writeJSON(w, http.StatusOK, projectsResponse{Projects: projects})
+}
+
+// putProject Add a project, under the next free id if id is 0, or replace
+// the project with id, adding it if there is none. As with items,
+// timestamps in the body are kept so projects can be copied between
+// stores.
+func (s *Server) putProject(w http.ResponseWriter, r *http.Request, id int) {
+	fields, err := readBody(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	project := internal.Project{ID: id}
+	if err := takeTime(fields, "created_at", &project.CreatedAt); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := takeTime(fields, "updated_at", &project.UpdatedAt); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := applyProjectFields(&project, fields); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx := r.Context()
+	projects, err := storage.Projects(ctx, s.store)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	status := http.StatusCreated
+	for _, existing := range projects {
+		if existing.ID == id {
+			status = http.StatusOK
+			if project.CreatedAt.IsZero() {
+				project.CreatedAt = existing.CreatedAt
+			}
+		}
+	}
+
+	stored, err := storage.PutProject(ctx, s.store, project)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if status == http.StatusCreated {
+		w.Header().Set("Location", fmt.Sprintf("/projects/%d", stored.ID))
+	}
+	writeJSON(w, status, stored)
+}
+
+// deleteItems Delete ids, and their subtasks if cascade is set; refuse with
+// ErrConflict rather than leave subtasks without a parent.
+func (s *Server) deleteItems(ctx context.Context, ids []int, cascade bool) (int, error) {
+	all, err := s.store.GetAllItems(ctx, storage.ListOptions{ShowDone: true, IncludeDeferred: true})
+	if err != nil {
+		return 0, err
+	}
+
+	deleting := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		deleting[id] = true
+	}
+	for _, id := range ids {
+		for _, sub := range internal.Descendants(all.Items, id) {
+			if deleting[sub.ID] {
+				continue
+			}
+			if !cascade {
+				return 0, fmt.Errorf("%w: item %d has subtasks, use cascade=true to delete them too", storage.ErrConflict, id)
+			}
+			deleting[sub.ID] = true
+			ids = append(ids, sub.ID)
+		}
+	}
+
+	return s.store.DeleteItem(ctx, ids...)
+}
+
+// boolParam Read an optional true or false query parameter.
+func boolParam(r *http.Request, name string) (bool, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, badRequest("invalid %s %q, use true or false", name, v)
+	}
+	return b, nil
+}
+
+// etag The entity tag of item's current version: a hash of everything
+// about it, UpdatedAt included, so it changes with every edit even when a
+// store keeps timestamps to the millisecond.
+func etag(item *internal.Todo) string {
+	data, _ := json.Marshal(item)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:12]) + `"`
+}
+
+// matchesETag Whether an If-Match or If-None-Match header lists tag.
+func matchesETag(header string, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch Fail with 412 if the request is conditional on a version
+// of item other than the current one.
+func checkIfMatch(r *http.Request, item *internal.Todo) error {
+	header := r.Header.Get("If-Match")
+	if header == "" || matchesETag(header, etag(item)) {
+		return nil
+	}
+	return &httpError{http.StatusPreconditionFailed, fmt.Sprintf("item %d has changed", item.ID)}
+}
+
+// takeTime Move the time named name out of fields into t, if it is there.
+// A null leaves an optional time unset.
+func takeTime[T time.Time | *time.Time](fields map[string]json.RawMessage, name string, t *T) error {
+	raw, ok := fields[name]
+	if !ok {
+		return nil
+	}
+	if err := json.Unmarshal(raw, t); err != nil {
+		return badRequest(name + " must be an RFC 3339 time")
+	}
+	delete(fields, name)
+	return nil
+}
+
+// readBody Decode a JSON object from the request body.
+func readBody(w http.ResponseWriter, r *http.Request) (map[string]json.RawMessage, error) {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType := strings.TrimSpace(strings.Split(ct, ";")[0])
+		if mediaType != "application/json" && mediaType != "application/merge-patch+json" {
+			return nil, &httpError{http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported content type %q, use application/json", ct)}
+		}
+	}
+
+	var fields map[string]json.RawMessage
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err := dec.Decode(&fields); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, &httpError{http.StatusRequestEntityTooLarge, "request body too large"}
+		}
+		return nil, badRequest("request body must be a JSON object: %v", err)
+	}
+	if fields == nil {
+		return nil, badRequest("request body must be a JSON object")
+	}
+	return fields, nil
+}
+
+func writeItem(w http.ResponseWriter, status int, item *internal.Todo) {
+	w.Header().Set("ETag", etag(item))
+	writeJSON(w, status, item)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("writing response: %v", err)
+	}
+}
+
+// writeError Reply with err as {"error": "..."} and the status matching
+// its cause.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var httpErr *httpError
+	switch {
+	case errors.As(err, &httpErr):
+		status = httpErr.status
+	case errors.Is(err, storage.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrConflict):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrBackendUnavailable):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, storage.ErrInvalidCursor):
+		status = http.StatusBadRequest
+	}
+
+	msg := err.Error()
+	if status == http.StatusInternalServerError {
+		log.Printf("internal error: %v", err)
+		msg = "internal error"
+	}
+	writeJSON(w, status, map[string]string{"error": msg})
+}
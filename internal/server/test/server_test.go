@@ -0,0 +1,511 @@
+package server_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/server"
+	"github.com/tcooper-uk/go-todo/internal/storage"
+)
+
+func newServer(t *testing.T) *httptest.Server {
+	return newServerWithToken(t, "")
+}
+
+func newServerWithToken(t *testing.T, token string) *httptest.Server {
+	store, err := storage.NewLocalFileStore(filepath.Join(t.TempDir(), "todo.json"))
+	assert.Nil(t, err)
+
+	// History is kept as todo serve keeps it.
+	srv := httptest.NewServer(server.New(storage.WithHistory(store), token))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// do Send a request with an optional JSON body and headers given as
+// name, value pairs.
+func do(t *testing.T, method, url, body string, headers ...string) *http.Response {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	assert.Nil(t, err)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func decode[T any](t *testing.T, resp *http.Response) T {
+	var v T
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&v))
+	return v
+}
+
+func TestCreateAndGet(t *testing.T) {
+	srv := newServer(t)
+
+	resp := do(t, "POST", srv.URL+"/todos", `{"name":"Buy milk","priority":"high","due_date":"2026-11-01","tags":["home"]}`)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "/todos/1", resp.Header.Get("Location"))
+	assert.NotEmpty(t, resp.Header.Get("ETag"))
+
+	created := decode[internal.Todo](t, resp)
+	assert.Equal(t, 1, created.ID)
+	assert.Equal(t, internal.PriorityHigh, created.Priority)
+	assert.Equal(t, "2026-11-01", created.DueDate.Format("2006-01-02"))
+
+	resp = do(t, "GET", srv.URL+"/todos/1", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "Buy milk", decode[internal.Todo](t, resp).Name)
+
+	resp = do(t, "GET", srv.URL+"/todos/1", "", "If-None-Match", resp.Header.Get("ETag"))
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+}
+
+func TestCreateRejectsInvalidBodies(t *testing.T) {
+	srv := newServer(t)
+
+	for body, status := range map[string]int{
+		`{}`:                                     http.StatusBadRequest,
+		`{"name":"x","priority":"urgent"}`:       http.StatusBadRequest,
+		`{"name":"x","due_date":"tomorrow"}`:     http.StatusBadRequest,
+		`{"name":"x","due_zone":"Mars/Olympus"}`: http.StatusBadRequest,
+		`{"name":"x","start_date":"next week"}`:  http.StatusBadRequest,
+		`{"name":"x","colour":"red"}`:            http.StatusBadRequest,
+		`{"name":"x","id":7}`:                    http.StatusBadRequest,
+		`{"name":"x","recurrence":"sometimes"}`:  http.StatusBadRequest,
+		`{"name":"x","parent_id":42}`:            http.StatusNotFound,
+		`["not an object"]`:                      http.StatusBadRequest,
+	} {
+		resp := do(t, "POST", srv.URL+"/todos", body)
+		assert.Equal(t, status, resp.StatusCode, body)
+		assert.NotEmpty(t, decode[map[string]string](t, resp)["error"], body)
+	}
+
+	req, _ := http.NewRequest("POST", srv.URL+"/todos", strings.NewReader("name=x"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+}
+
+func TestListWithQueryParameters(t *testing.T) {
+	srv := newServer(t)
+	do(t, "POST", srv.URL+"/todos", `{"name":"work high","priority":"high","tags":["work"]}`)
+	do(t, "POST", srv.URL+"/todos", `{"name":"work low","priority":"low","tags":["work"]}`)
+	do(t, "POST", srv.URL+"/todos", `{"name":"home","tags":["home"]}`)
+	do(t, "PATCH", srv.URL+"/todos/3", `{"done":true}`)
+
+	names := func(query string) []string {
+		resp := do(t, "GET", srv.URL+"/todos"+query, "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode, query)
+		var list struct {
+			Items []internal.Todo `json:"items"`
+		}
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&list))
+		var names []string
+		for _, item := range list.Items {
+			names = append(names, item.Name)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"work high", "work low"}, names(""))
+	assert.Equal(t, []string{"work high", "work low", "home"}, names("?all=true"))
+	assert.Equal(t, []string{"home"}, names("?done=true"))
+	assert.Equal(t, []string{"work low", "work high"}, names("?sort=priority"))
+	assert.Equal(t, []string{"work high"}, names("?filter="+"tag:work+and+priority>=medium"))
+	assert.Equal(t, []string{"home"}, names("?filter=done:true"))
+	assert.Equal(t, []string{"home"}, names("?tag=home&all=1"))
+
+	resp := do(t, "GET", srv.URL+"/todos?limit=1", "")
+	page := decode[map[string]any](t, resp)
+	assert.Len(t, page["items"], 1)
+	assert.Equal(t, []string{"work low"}, names("?limit=1&cursor="+page["next_cursor"].(string)))
+
+	for _, query := range []string{"?all=maybe", "?priority=urgent", "?filter=tag:", "?sort=colour", "?limit=-1", "?limit=1&cursor=x", "?tz=Mars/Olympus", "?include_deferred=maybe"} {
+		resp := do(t, "GET", srv.URL+"/todos"+query, "")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+	}
+}
+
+func TestDueDatesAndDeadlines(t *testing.T) {
+	srv := newServer(t)
+
+	resp := do(t, "POST", srv.URL+"/todos", `{"name":"day","due_date":"2026-11-01","due_zone":"Europe/London"}`)
+	day := decode[internal.Todo](t, resp)
+	assert.Equal(t, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), *day.DueDate)
+	assert.False(t, day.DueTimed)
+	assert.Empty(t, day.DueZone, "an all-day item has no zone")
+
+	resp = do(t, "POST", srv.URL+"/todos", `{"name":"deadline","due_date":"2026-11-01T09:30:00+01:00","due_zone":"Europe/Paris"}`)
+	deadline := decode[internal.Todo](t, resp)
+	assert.Equal(t, time.Date(2026, 11, 1, 8, 30, 0, 0, time.UTC), *deadline.DueDate)
+	assert.True(t, deadline.DueTimed)
+	assert.Equal(t, "Europe/Paris", deadline.DueZone)
+
+	resp = do(t, "PATCH", srv.URL+"/todos/2", `{"due_timed":false}`)
+	assert.Equal(t, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), *decode[internal.Todo](t, resp).DueDate)
+}
+
+func TestDeferredItems(t *testing.T) {
+	srv := newServer(t)
+	later := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	do(t, "POST", srv.URL+"/todos", `{"name":"now"}`)
+	resp := do(t, "POST", srv.URL+"/todos", `{"name":"later","start_date":"`+later+`"}`)
+	assert.Equal(t, later, decode[internal.Todo](t, resp).StartDate.Format(time.RFC3339))
+
+	count := func(query string) int {
+		resp := do(t, "GET", srv.URL+"/todos"+query, "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		return len(decode[map[string][]any](t, resp)["items"])
+	}
+	assert.Equal(t, 1, count(""))
+	assert.Equal(t, 2, count("?include_deferred=true"))
+
+	resp = do(t, "PATCH", srv.URL+"/todos/2", `{"start_date":null}`)
+	assert.Nil(t, decode[internal.Todo](t, resp).StartDate)
+	assert.Equal(t, 2, count(""))
+}
+
+// TestOverdueReckonsDaysInTZ An all-day item is overdue once its day is
+// over in the client's time zone. Kiritimati is always a day ahead of
+// Honolulu.
+func TestOverdueReckonsDaysInTZ(t *testing.T) {
+	honolulu, err := time.LoadLocation("Pacific/Honolulu")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	srv := newServer(t)
+	today := time.Now().In(honolulu).Format("2006-01-02")
+	do(t, "POST", srv.URL+"/todos", `{"name":"x","due_date":"`+today+`"}`)
+
+	count := func(tz string) int {
+		resp := do(t, "GET", srv.URL+"/todos?overdue=true&tz="+tz, "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		return len(decode[map[string][]any](t, resp)["items"])
+	}
+	assert.Equal(t, 0, count("Pacific/Honolulu"))
+	assert.Equal(t, 1, count("Pacific/Kiritimati"))
+}
+
+func TestPatchHonoursIfMatch(t *testing.T) {
+	srv := newServer(t)
+	resp := do(t, "POST", srv.URL+"/todos", `{"name":"draft"}`)
+	tag := resp.Header.Get("ETag")
+
+	resp = do(t, "PATCH", srv.URL+"/todos/1", `{"name":"final"}`, "If-Match", tag)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "final", decode[internal.Todo](t, resp).Name)
+	assert.NotEqual(t, tag, resp.Header.Get("ETag"))
+
+	// The old tag no longer matches.
+	resp = do(t, "PATCH", srv.URL+"/todos/1", `{"name":"lost update"}`, "If-Match", tag)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	resp = do(t, "DELETE", srv.URL+"/todos/1", "", "If-Match", tag)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp = do(t, "GET", srv.URL+"/todos/1", "")
+	assert.Equal(t, "final", decode[internal.Todo](t, resp).Name)
+}
+
+func TestPatchClearsFieldsWithNull(t *testing.T) {
+	srv := newServer(t)
+	do(t, "POST", srv.URL+"/todos", `{"name":"x","due_date":"2026-11-01","tags":["a"],"priority":"low"}`)
+
+	resp := do(t, "PATCH", srv.URL+"/todos/1", `{"due_date":null,"tags":null,"priority":null}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	item := decode[internal.Todo](t, resp)
+	assert.Nil(t, item.DueDate)
+	assert.Empty(t, item.Tags)
+	assert.Equal(t, internal.PriorityNone, item.Priority)
+	assert.Equal(t, "x", item.Name)
+}
+
+func TestPatchDoneAddsNextOccurrence(t *testing.T) {
+	srv := newServer(t)
+	do(t, "POST", srv.URL+"/todos", `{"name":"review","due_date":"2026-11-02","recurrence":"weekly"}`)
+
+	resp := do(t, "PATCH", srv.URL+"/todos/1", `{"done":true}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = do(t, "GET", srv.URL+"/todos/2", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "2026-11-09", decode[internal.Todo](t, resp).DueDate.Format("2006-01-02"))
+}
+
+func TestPatchRejectsParentCycles(t *testing.T) {
+	srv := newServer(t)
+	do(t, "POST", srv.URL+"/todos", `{"name":"parent"}`)
+	do(t, "POST", srv.URL+"/todos", `{"name":"child","parent_id":1}`)
+
+	resp := do(t, "PATCH", srv.URL+"/todos/1", `{"parent_id":2}`)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestDelete(t *testing.T) {
+	srv := newServer(t)
+	do(t, "POST", srv.URL+"/todos", `{"name":"parent"}`)
+	do(t, "POST", srv.URL+"/todos", `{"name":"child","parent_id":1}`)
+
+	resp := do(t, "DELETE", srv.URL+"/todos/1", "")
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp = do(t, "DELETE", srv.URL+"/todos/1?cascade=true", "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	for _, path := range []string{"/todos/1", "/todos/2"} {
+		resp = do(t, "GET", srv.URL+path, "")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+	}
+	resp = do(t, "DELETE", srv.URL+"/todos/1", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestPutReplacesWithoutSideEffects(t *testing.T) {
+	srv := newServer(t)
+	resp := do(t, "POST", srv.URL+"/todos", `{"name":"review","due_date":"2026-11-02","recurrence":"weekly","tags":["a"]}`)
+	tag := resp.Header.Get("ETag")
+
+	resp = do(t, "PUT", srv.URL+"/todos/1", `{"name":"review","done":true}`, "If-Match", tag)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	item := decode[internal.Todo](t, resp)
+	assert.True(t, item.Done)
+	assert.Nil(t, item.DueDate)
+	assert.Empty(t, item.Tags)
+	assert.Empty(t, item.Recurrence)
+
+	// Unlike PATCH, completing an item does not schedule the next one.
+	resp = do(t, "GET", srv.URL+"/todos/2", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = do(t, "PUT", srv.URL+"/todos/1", `{"name":"stale"}`, "If-Match", tag)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	resp = do(t, "PUT", srv.URL+"/todos/1", `{"done":false}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestPutAddsMissingItemsWithTheirTimes(t *testing.T) {
+	srv := newServer(t)
+	body := `{"name":"copied","created_at":"2025-01-02T03:04:05Z","updated_at":"2025-06-07T08:09:10Z"}`
+
+	resp := do(t, "PUT", srv.URL+"/todos/9", body, "If-Match", "*")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = do(t, "PUT", srv.URL+"/todos/9", body)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "/todos/9", resp.Header.Get("Location"))
+	item := decode[internal.Todo](t, resp)
+	assert.Equal(t, 9, item.ID)
+	assert.Equal(t, "2025-01-02T03:04:05Z", item.CreatedAt.UTC().Format(time.RFC3339))
+	assert.Equal(t, "2025-06-07T08:09:10Z", item.UpdatedAt.UTC().Format(time.RFC3339))
+
+	// Without times, replacing an item keeps its creation time.
+	resp = do(t, "PUT", srv.URL+"/todos/9", `{"name":"renamed"}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	item = decode[internal.Todo](t, resp)
+	assert.Equal(t, "2025-01-02T03:04:05Z", item.CreatedAt.UTC().Format(time.RFC3339))
+	assert.True(t, item.UpdatedAt.After(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	resp = do(t, "PUT", srv.URL+"/todos/10", `{"name":"x","created_at":"yesterday"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// deleted_at puts the item in the trash, and null takes it out.
+	resp = do(t, "PUT", srv.URL+"/todos/9", `{"name":"renamed","deleted_at":"2025-07-08T09:10:11Z"}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, http.StatusNotFound, do(t, "GET", srv.URL+"/todos/9", "").StatusCode)
+	trash := decode[struct{ Items []internal.Todo }](t, do(t, "GET", srv.URL+"/trash", ""))
+	if assert.Len(t, trash.Items, 1) {
+		assert.Equal(t, "2025-07-08T09:10:11Z", trash.Items[0].DeletedAt.UTC().Format(time.RFC3339))
+	}
+	resp = do(t, "PUT", srv.URL+"/todos/9", `{"name":"renamed","deleted_at":null}`)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, http.StatusOK, do(t, "GET", srv.URL+"/todos/9", "").StatusCode)
+
+	resp = do(t, "PATCH", srv.URL+"/todos/9", `{"deleted_at":"2025-07-08T09:10:11Z"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestDeleteMany(t *testing.T) {
+	srv := newServer(t)
+	do(t, "POST", srv.URL+"/todos", `{"name":"parent"}`)
+	do(t, "POST", srv.URL+"/todos", `{"name":"child","parent_id":1}`)
+	do(t, "POST", srv.URL+"/todos", `{"name":"other"}`)
+	do(t, "POST", srv.URL+"/todos", `{"name":"last"}`)
+
+	for query, status := range map[string]int{
+		"":               http.StatusBadRequest,
+		"?id=x":          http.StatusBadRequest,
+		"?id=1&all=true": http.StatusBadRequest,
+		"?id=1":          http.StatusConflict,
+		"?id=3&id=99":    http.StatusNotFound,
+	} {
+		resp := do(t, "DELETE", srv.URL+"/todos"+query, "")
+		assert.Equal(t, status, resp.StatusCode, query)
+	}
+
+	// A parent can go along with all of its subtasks.
+	resp := do(t, "DELETE", srv.URL+"/todos?id=1&id=2", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, decode[map[string]int](t, resp)["deleted"])
+
+	resp = do(t, "DELETE", srv.URL+"/todos?all=true", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, decode[map[string]int](t, resp)["deleted"])
+}
+
+func TestToken(t *testing.T) {
+	srv := newServerWithToken(t, "s3cret")
+
+	resp := do(t, "GET", srv.URL+"/todos", "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("WWW-Authenticate"), "Bearer")
+
+	resp = do(t, "GET", srv.URL+"/todos", "", "Authorization", "Bearer wrong")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = do(t, "GET", srv.URL+"/todos", "", "Authorization", "Bearer s3cret")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The API description is public.
+	resp = do(t, "GET", srv.URL+"/openapi.json", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestHistory(t *testing.T) {
+	srv := newServerWithToken(t, "s3cret")
+	auth := []string{"Authorization", "Bearer s3cret"}
+
+	resp := do(t, "POST", srv.URL+"/todos", `{"name":"Buy milk","priority":"low"}`, append(auth, "Todo-Actor", "alice")...)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	// Without an actor, changes are put down to the token.
+	resp = do(t, "PATCH", srv.URL+"/todos/1", `{"priority":"high"}`, auth...)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	type history struct {
+		Changes []storage.FieldChange `json:"changes"`
+	}
+	resp = do(t, "GET", srv.URL+"/todos/1/history", "", auth...)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	changes := decode[history](t, resp).Changes
+	assert.Len(t, changes, 3)
+	assert.Equal(t, "alice", changes[0].Actor)
+	last := changes[2]
+	assert.Equal(t, "priority", last.Field)
+	assert.Equal(t, "low", last.Old)
+	assert.Equal(t, "high", last.New)
+	assert.Regexp(t, `^token:[0-9a-f]{8}$`, last.Actor)
+
+	since := time.Now().Add(time.Minute).UTC().Format(time.RFC3339)
+	resp = do(t, "GET", srv.URL+"/history?since="+since, "", auth...)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, decode[history](t, resp).Changes)
+
+	resp = do(t, "GET", srv.URL+"/history?since=yesterday", "", auth...)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp = do(t, "GET", srv.URL+"/todos/1/history", "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestProjects(t *testing.T) {
+	srv := newServer(t)
+
+	resp := do(t, "POST", srv.URL+"/projects", `{"name":"work"}`)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "/projects/1", resp.Header.Get("Location"))
+	resp = do(t, "POST", srv.URL+"/projects", `{"name":"clients","parent_id":1}`)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, 2, decode[internal.Project](t, resp).ID)
+
+	for body, status := range map[string]int{
+		`{"name":"Work"}`:              http.StatusConflict,
+		`{"name":"x","parent_id":9}`:   http.StatusNotFound,
+		`{"name":"a/b"}`:               http.StatusBadRequest,
+		`{"name":" "}`:                 http.StatusBadRequest,
+		`{"name":"x","id":3}`:          http.StatusBadRequest,
+		`{"name":"x","archived":"no"}`: http.StatusBadRequest,
+	} {
+		assert.Equal(t, status, do(t, "POST", srv.URL+"/projects", body).StatusCode, body)
+	}
+
+	resp = do(t, "POST", srv.URL+"/todos", `{"name":"call acme","project":2}`)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, 2, decode[internal.Todo](t, resp).Project)
+	assert.Equal(t, http.StatusNotFound, do(t, "POST", srv.URL+"/todos", `{"name":"x","project":9}`).StatusCode)
+	do(t, "POST", srv.URL+"/todos", `{"name":"plan q4","project":1}`)
+
+	list := decode[struct{ Items []internal.Todo }](t, do(t, "GET", srv.URL+"/todos?project=2", ""))
+	assert.Len(t, list.Items, 1)
+	list = decode[struct{ Items []internal.Todo }](t, do(t, "GET", srv.URL+"/todos?project=1&project=2", ""))
+	assert.Len(t, list.Items, 2)
+	assert.Equal(t, http.StatusBadRequest, do(t, "GET", srv.URL+"/todos?project=x", "").StatusCode)
+
+	// Archiving keeps items in the project but takes no new ones.
+	resp = do(t, "PUT", srv.URL+"/projects/2", `{"name":"clients","parent_id":1,"archived":true}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, decode[internal.Project](t, resp).Archived)
+	assert.Equal(t, http.StatusConflict, do(t, "POST", srv.URL+"/todos", `{"name":"x","project":2}`).StatusCode)
+	assert.Equal(t, http.StatusConflict, do(t, "PATCH", srv.URL+"/todos/2", `{"project":2}`).StatusCode)
+	assert.Equal(t, http.StatusOK, do(t, "PATCH", srv.URL+"/todos/1", `{"done":true}`).StatusCode)
+
+	resp = do(t, "PUT", srv.URL+"/projects/7", `{"name":"home","created_at":"2026-01-02T03:04:05Z"}`)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "2026-01-02T03:04:05Z", decode[internal.Project](t, resp).CreatedAt.Format(time.RFC3339))
+
+	projects := decode[struct{ Projects []internal.Project }](t, do(t, "GET", srv.URL+"/projects", ""))
+	assert.Len(t, projects.Projects, 3)
+	assert.Equal(t, http.StatusMethodNotAllowed, do(t, "DELETE", srv.URL+"/projects/1", "").StatusCode)
+}
+
+func TestRoutingErrors(t *testing.T) {
+	srv := newServer(t)
+
+	resp := do(t, "POST", srv.URL+"/todos/1", `{}`)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	assert.Equal(t, "GET, PUT, PATCH, DELETE", resp.Header.Get("Allow"))
+
+	resp = do(t, "PUT", srv.URL+"/todos", `{}`)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	assert.Equal(t, "GET, POST, DELETE", resp.Header.Get("Allow"))
+
+	for _, path := range []string{"/todos/abc", "/todos/0", "/nothing"} {
+		resp = do(t, "GET", srv.URL+path, "")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	srv := newServer(t)
+
+	resp := do(t, "GET", srv.URL+"/openapi.json", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	doc := decode[map[string]any](t, resp)
+	assert.Equal(t, "3.0.3", doc["openapi"])
+	assert.Contains(t, doc["paths"], "/todos/{id}")
+
+	// The served document is the one in the repository.
+	onDisk, err := os.ReadFile("../openapi.json")
+	assert.Nil(t, err)
+	var want map[string]any
+	assert.Nil(t, json.Unmarshal(onDisk, &want))
+	assert.Equal(t, want, doc)
+}
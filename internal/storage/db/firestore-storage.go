@@ -2,20 +2,43 @@ package db
 
 import (
 	"cloud.google.com/go/firestore"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/filter"
 	"github.com/tcooper-uk/go-todo/internal/storage"
-	"golang.org/x/net/context"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"sort"
+	"strconv"
 	"time"
 )
 
 const (
 	ProjectId  = "todo-de411"
 	collection = "todos"
+	// trashCollection Holds the items moved to the trash, under the IDs
+	// of the documents they were in, so that nothing else needs to filter
+	// them out.
+	trashCollection = "trash"
+	// batchSize The most items moved in one batch, each a write to add
+	// and another to delete, within Firestore's limit of 500 writes.
+	batchSize = 250
+	// historyCollection Holds a document per item, by ID, whose
+	// changesCollection subcollection is the item's history. It is apart
+	// from the items, so it stays put as they move to and from the trash,
+	// and outlives them once the trash is emptied.
+	historyCollection = "history"
+	changesCollection = "changes"
+	// projectsCollection Holds a document per project, under its ID.
+	projectsCollection = "projects"
+	// metaCollection Holds metaDoc, which records the changes made to
+	// stored items when the store gained a field that queries rely on.
+	metaCollection = "meta"
+	metaDoc        = "items"
 )
 
 type CloudStore struct {
@@ -28,7 +51,7 @@ type CloudStoreConfig struct {
 	Client    *firestore.Client
 }
 
-func NewCloudStore(config *CloudStoreConfig) (*CloudStore, error) {
+func NewCloudStore(ctx context.Context, config *CloudStoreConfig) (*CloudStore, error) {
 
 	if len(config.ProjectId) == 0 {
 		return nil, errors.New("you must supply a firestore project id")
@@ -38,10 +61,9 @@ func NewCloudStore(config *CloudStoreConfig) (*CloudStore, error) {
 	var err error
 
 	if config.Client == nil {
-		ctx := context.Background()
 		client, err = firestore.NewClient(ctx, config.ProjectId, option.WithCredentialsFile(config.KeyFile))
 		if err != nil {
-			return nil, fmt.Errorf("unable to create connection to cloudstore project %s, err: %w", config.ProjectId, err)
+			return nil, fmt.Errorf("%w: unable to create connection to cloudstore project %s, err: %w", storage.ErrBackendUnavailable, config.ProjectId, err)
 		}
 	} else {
 		client = config.Client
@@ -51,158 +73,421 @@ func NewCloudStore(config *CloudStoreConfig) (*CloudStore, error) {
 }
 
 // GetAllItems List all the items filtered by opts.
-func (store *CloudStore) GetAllItems(opts storage.ListOptions) *internal.TodoCollection {
-	docRefs, err := store.client.CollectionGroup(collection).Query.
-		Documents(context.Background()).
-		GetAll()
+func (store *CloudStore) GetAllItems(ctx context.Context, opts storage.ListOptions) (*internal.TodoCollection, error) {
+	name := collection
+	if opts.Trashed {
+		name = trashCollection
+	}
+	query := pushDown(store.client.CollectionGroup(name).Query, opts)
+	now := time.Now()
 
-	if err != nil {
-		return &internal.TodoCollection{}
+	native := pagesNatively(opts)
+	queries := []firestore.Query{query}
+	if native {
+		if !opts.ShowDeferred() {
+			// Firestore has no OR, so the items without a start date and
+			// those whose start date has come are fetched apart and merged.
+			if err := store.fillStartDates(ctx); err != nil {
+				return nil, err
+			}
+			queries = []firestore.Query{query.Where("StartDate", "==", nil), query.Where("StartDate", "<=", now)}
+		}
+		for i := range queries {
+			var err error
+			if queries[i], err = store.page(ctx, queries[i], name, opts); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	now := time.Now()
 	var items []internal.Todo
-	var maxTitle = 0
-
-	for _, each := range docRefs {
-		var todo = internal.Todo{}
-		err := each.DataTo(&todo)
+	docIDs := make(map[int]string)
+	for _, query := range queries {
+		docRefs, err := query.Documents(ctx).GetAll()
 		if err != nil {
-			continue
+			return nil, cloudErr(err)
 		}
 
-		if !opts.ShowDone && !opts.OnlyDone && todo.Done {
-			continue
-		}
-		if opts.OnlyDone && !todo.Done {
-			continue
-		}
-		if opts.Priority != "" && string(todo.Priority) != opts.Priority {
-			continue
-		}
-		if opts.Overdue && (todo.DueDate == nil || !todo.DueDate.Before(now)) {
-			continue
+		for _, each := range docRefs {
+			var todo = internal.Todo{}
+			err := each.DataTo(&todo)
+			if err != nil {
+				return nil, fmt.Errorf("unable to decode document %s: %w", each.Ref.ID, err)
+			}
+
+			// Whatever could not be pushed down is checked here.
+			if !opts.Match(todo, now) {
+				continue
+			}
+
+			items = append(items, todo)
+			docIDs[todo.ID] = each.Ref.ID
 		}
-		if opts.Tag != "" && !hasTag(todo.Tags, opts.Tag) {
-			continue
+	}
+
+	var next string
+	if native {
+		items, next = cutPage(items, docIDs, opts, len(queries) > 1)
+	} else {
+		var err error
+		if items, next, err = storage.Paginate(items, opts); err != nil {
+			return nil, err
 		}
+	}
 
+	maxTitle := 0
+	for _, todo := range items {
 		nameLen := len(todo.Name)
 		if nameLen > maxTitle {
 			maxTitle = nameLen
 		}
-
-		items = append(items, todo)
 	}
 
-	sort.Slice(items, func(i, j int) bool {
-		return items[i].ID < items[j].ID
-	})
-
 	return &internal.TodoCollection{
 		Items:         items,
 		Size:          len(items),
 		MaxLengthItem: maxTitle,
-	}
+		NextCursor:    next,
+	}, nil
 
 }
 
-// GetItem Get a single todo item by its unique id.
-func (store *CloudStore) GetItem(id int) *internal.Todo {
+// orderFields The document field each sort key orders by, for the keys
+// Firestore can order natively. Priorities are stored by name, not rank,
+// and names sort case-sensitively, so those are sorted in memory.
+var orderFields = map[storage.SortField]string{
+	storage.SortID:      "ID",
+	storage.SortDue:     "DueDate",
+	storage.SortCreated: "CreatedAt",
+	storage.SortUpdated: "UpdatedAt",
+	storage.SortDone:    "Done",
+}
 
-	query := store.client.Collection(collection).Query.
-		Where("ID", "==", id).
-		Limit(1)
+// pagesNatively Whether a limited listing can be ordered and cut by
+// Firestore itself: every filter must have been pushed down, or pages
+// would come back short, and every sort key must be orderable.
+// Unlimited listings are sorted in memory, which needs no composite index.
+func pagesNatively(opts storage.ListOptions) bool {
+	if opts.Limit <= 0 || opts.Filter != nil || opts.Overdue || len(opts.Tags) > 1 || len(opts.Projects) > 1 {
+		return false
+	}
+	for _, key := range opts.Sort {
+		if _, ok := orderFields[key.Field]; !ok {
+			return false
+		}
+	}
+	return true
+}
 
-	doc, err := query.Documents(context.Background()).Next()
+// page Order query by opts.Sort and then ID, and start it after the
+// document of the named collection given by opts.Cursor. The documents
+// opts.Offset skips are fetched too, as Firestore would read them anyway,
+// and one past opts.Limit to tell whether another page follows.
+func (store *CloudStore) page(ctx context.Context, query firestore.Query, name string, opts storage.ListOptions) (firestore.Query, error) {
+	for _, key := range opts.Sort {
+		dir := firestore.Asc
+		if key.Desc {
+			dir = firestore.Desc
+		}
+		query = query.OrderBy(orderFields[key.Field], dir)
+	}
+	query = query.OrderBy("ID", firestore.Asc)
 
-	if err != nil {
+	if opts.Cursor != "" {
+		ref := store.client.Collection(name).Doc(opts.Cursor)
+		if ref == nil {
+			return query, fmt.Errorf("%w: %q", storage.ErrInvalidCursor, opts.Cursor)
+		}
+		doc, err := ref.Get(ctx)
+		if status.Code(err) == codes.NotFound {
+			return query, fmt.Errorf("%w: %q", storage.ErrInvalidCursor, opts.Cursor)
+		}
+		if err != nil {
+			return query, cloudErr(err)
+		}
+		query = query.StartAfter(doc)
+	}
+
+	return query.Limit(opts.Offset + opts.Limit + 1), nil
+}
+
+// cutPage Cut the page opts asks for out of the items fetched by page,
+// putting those of several queries back in order first: Firestore orders
+// every key in orderFields as SortItems does. The cursor of the next page
+// is the document of the page's last item, if any were fetched past it.
+func cutPage(items []internal.Todo, docIDs map[int]string, opts storage.ListOptions, merged bool) ([]internal.Todo, string) {
+	if merged {
+		storage.SortItems(items, opts.Sort)
+	}
+	if opts.Offset >= len(items) {
+		return nil, ""
+	}
+	items = items[opts.Offset:]
+	if len(items) <= opts.Limit {
+		return items, ""
+	}
+	items = items[:opts.Limit]
+	return items, docIDs[items[len(items)-1].ID]
+}
+
+// fillStartDates Give the items stored before start dates were added a
+// null StartDate, once, as a filter on a field matches no document that
+// lacks it. metaDoc records that it is done, so later calls cost one read.
+func (store *CloudStore) fillStartDates(ctx context.Context) error {
+	meta := store.client.Collection(metaCollection).Doc(metaDoc)
+	doc, err := meta.Get(ctx)
+	if err == nil && doc.Data()["StartDates"] == true {
 		return nil
 	}
+	if err != nil && status.Code(err) != codes.NotFound {
+		return cloudErr(err)
+	}
 
-	var todo = internal.Todo{}
-	err = doc.DataTo(&todo)
+	var refs []*firestore.DocumentRef
+	for _, name := range []string{collection, trashCollection} {
+		docs, err := store.client.Collection(name).Documents(ctx).GetAll()
+		if err != nil {
+			return cloudErr(err)
+		}
+		for _, each := range docs {
+			if _, ok := each.Data()["StartDate"]; !ok {
+				refs = append(refs, each.Ref)
+			}
+		}
+	}
+
+	for start := 0; start < len(refs); start += batchSize {
+		end := start + batchSize
+		if end > len(refs) {
+			end = len(refs)
+		}
+
+		batch := store.client.Batch()
+		for _, ref := range refs[start:end] {
+			batch.Update(ref, []firestore.Update{{Path: "StartDate", Value: nil}})
+		}
+		if _, err := batch.Commit(ctx); err != nil {
+			return cloudErr(err)
+		}
+	}
+
+	if _, err := meta.Set(ctx, map[string]any{"StartDates": true}); err != nil {
+		return cloudErr(err)
+	}
+	return nil
+}
+
+// pushDown Narrow query with the parts of opts Firestore can evaluate
+// without a composite index: equality on single fields and at most one
+// array-contains. The results still need checking with opts.Match.
+func pushDown(query firestore.Query, opts storage.ListOptions) firestore.Query {
+	if opts.OnlyDone {
+		query = query.Where("Done", "==", true)
+	} else if !opts.ShowDone {
+		query = query.Where("Done", "==", false)
+	}
+	if opts.Priority != "" {
+		query = query.Where("Priority", "==", opts.Priority)
+	}
+	if len(opts.Projects) == 1 {
+		query = query.Where("Project", "==", opts.Projects[0])
+	}
+
+	var tags []string
+	tags = append(tags, opts.Tags...)
+
+	if opts.Filter != nil {
+		for _, expr := range filter.Conjuncts(opts.Filter) {
+			c, ok := expr.(filter.Cond)
+			if !ok || (c.Op != filter.OpIs && c.Op != filter.OpEqual) {
+				continue
+			}
+			switch c.Field {
+			case filter.FieldTag:
+				tags = append(tags, c.Value.(string))
+			case filter.FieldDone:
+				if !opts.ShowDone && !opts.OnlyDone {
+					// Already constrained to false above.
+					continue
+				}
+				query = query.Where("Done", "==", c.Value)
+			case filter.FieldPriority:
+				query = query.Where("Priority", "==", string(c.Value.(internal.Priority)))
+			case filter.FieldID:
+				query = query.Where("ID", "==", c.Value)
+			case filter.FieldParent:
+				query = query.Where("ParentID", "==", c.Value)
+			}
+		}
+	}
+
+	if len(tags) > 0 {
+		query = query.Where("Tags", "array-contains", tags[0])
+	}
+
+	return query
+}
+
+// GetItem Get a single todo item by its unique id.
+func (store *CloudStore) GetItem(ctx context.Context, id int) (*internal.Todo, error) {
+
+	doc, err := store.findDocument(ctx, collection, id)
 	if err != nil {
-		return nil
+		return nil, err
 	}
+	return decode(doc)
+}
 
-	return &todo
+func decode(doc *firestore.DocumentSnapshot) (*internal.Todo, error) {
+	var todo = internal.Todo{}
+	if err := doc.DataTo(&todo); err != nil {
+		return nil, fmt.Errorf("unable to decode document %s: %w", doc.Ref.ID, err)
+	}
+	return &todo, nil
 }
 
 // AddItem Add a single item. The store assigns ID and timestamps.
-func (store *CloudStore) AddItem(todo internal.Todo) int {
+func (store *CloudStore) AddItem(ctx context.Context, todo internal.Todo) (*internal.Todo, error) {
 
 	nextId := 1
 	now := time.Now()
 
-	collectionRef := store.client.Collection(collection)
-	documentIterator := collectionRef.Query.
-		OrderBy("CreatedAt", firestore.Desc).
-		Limit(1).
-		Documents(context.Background())
-
-	doc, err := documentIterator.Next()
-
-	if err == nil {
-		id := doc.Data()["ID"].(int64)
-		nextId = int(id) + 1
+	// Items in the trash keep their IDs, so are counted too.
+	for _, name := range []string{collection, trashCollection} {
+		// Ordered by ID, not CreatedAt, since items copied from another
+		// store keep their creation time.
+		documentIterator := store.client.Collection(name).Query.
+			OrderBy("ID", firestore.Desc).
+			Limit(1).
+			Documents(ctx)
+
+		doc, err := documentIterator.Next()
+
+		switch {
+		case err == nil:
+			if id := int(doc.Data()["ID"].(int64)); id >= nextId {
+				nextId = id + 1
+			}
+		case !errors.Is(err, iterator.Done):
+			return nil, cloudErr(err)
+		}
 	}
 
 	todo.ID = nextId
-	todo.CreatedAt = now
+	todo.DeletedAt = nil
+	if todo.CreatedAt.IsZero() {
+		todo.CreatedAt = now
+	}
 	todo.UpdatedAt = now
 
-	_, _, err = collectionRef.Add(context.Background(), todo)
+	_, _, err := store.client.Collection(collection).Add(ctx, todo)
 	if err != nil {
-		return 0
+		return nil, cloudErr(err)
 	}
-	return 1
+	return &todo, nil
 }
 
-// DeleteItem Delete items by id.
-func (store *CloudStore) DeleteItem(ids ...int) int {
-	deleteCount := 0
-	bulkWriter := store.client.BulkWriter(context.Background())
-	query := store.client.Collection(collection).Query.
-		Where("ID", "in", ids).
-		Limit(1)
+// DeleteItem Move items to the trash by id.
+func (store *CloudStore) DeleteItem(ctx context.Context, ids ...int) (int, error) {
+	docs, err := store.findDocuments(ctx, collection, ids)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	return store.moveDocuments(ctx, docs, trashCollection, &now)
+}
 
-	iter := query.Documents(context.Background())
+// DeleteAllItems Move all items to the trash.
+func (store *CloudStore) DeleteAllItems(ctx context.Context) (int, error) {
+	docs, err := store.client.Collection(collection).Documents(ctx).GetAll()
+	if err != nil {
+		return 0, cloudErr(err)
+	}
+	now := time.Now()
+	return store.moveDocuments(ctx, docs, trashCollection, &now)
+}
 
-	for doc, err := iter.Next(); !errors.Is(err, iterator.Done); doc, err = iter.Next() {
-		bulkWriter.Delete(doc.Ref)
-		deleteCount++
+// RestoreItem Take items out of the trash by id.
+func (store *CloudStore) RestoreItem(ctx context.Context, ids ...int) (int, error) {
+	docs, err := store.findDocuments(ctx, trashCollection, ids)
+	if err != nil {
+		return 0, err
 	}
+	return store.moveDocuments(ctx, docs, collection, nil)
+}
 
-	bulkWriter.Flush()
-	return deleteCount
+// EmptyTrash Delete the items moved to the trash before the given time, or
+// all of them, for good.
+func (store *CloudStore) EmptyTrash(ctx context.Context, before time.Time) (int, error) {
+	query := store.client.Collection(trashCollection).Query
+	if !before.IsZero() {
+		query = query.Where("DeletedAt", "<", before)
+	}
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return 0, cloudErr(err)
+	}
+
+	var refs []*firestore.DocumentRef
+	for _, each := range docs {
+		refs = append(refs, each.Ref)
+	}
+	return store.deleteDocuments(ctx, refs)
 }
 
-// DeleteAllItems Delete all items from the store.
-func (store *CloudStore) DeleteAllItems() int {
-	deleteCount := 0
-	bulkWriter := store.client.BulkWriter(context.Background())
-	doc, _ := store.client.Collection(collection).Documents(context.Background()).GetAll()
+// PutItem Store todo as given, ID and timestamps included.
+func (store *CloudStore) PutItem(ctx context.Context, todo internal.Todo) (*internal.Todo, error) {
+	if todo.ID <= 0 {
+		return nil, storage.ErrNoID
+	}
 
-	for _, each := range doc {
-		bulkWriter.Delete(each.Ref)
-		deleteCount++
+	now := time.Now()
+	if todo.CreatedAt.IsZero() {
+		todo.CreatedAt = now
+	}
+	if todo.UpdatedAt.IsZero() {
+		todo.UpdatedAt = now
 	}
 
-	bulkWriter.Flush()
-	return deleteCount
-}
+	// The item goes in the trash or not as its DeletedAt says, wherever
+	// it is now.
+	target, other := collection, trashCollection
+	if todo.DeletedAt != nil {
+		target, other = trashCollection, collection
+	}
 
-// EditItem Update the item with the given id to match todo.
-func (store *CloudStore) EditItem(id int, todo internal.Todo) int {
-	query := store.client.Collection(collection).Query.
-		Where("ID", "==", id).
-		Limit(1)
+	ref := store.client.Collection(target).NewDoc()
+	batch := store.client.Batch()
+	for _, name := range []string{target, other} {
+		doc, err := store.findDocument(ctx, name, todo.ID)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ref = store.client.Collection(target).Doc(doc.Ref.ID)
+		if name == other {
+			batch.Delete(doc.Ref)
+		}
+	}
+	batch.Set(ref, todo)
+	if _, err := batch.Commit(ctx); err != nil {
+		return nil, cloudErr(err)
+	}
 
-	doc, err := query.Documents(context.Background()).Next()
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		return nil, cloudErr(err)
+	}
+	return decode(doc)
+}
 
+// EditItem Update the item with the given id to match todo.
+func (store *CloudStore) EditItem(ctx context.Context, id int, todo internal.Todo) (*internal.Todo, error) {
+	doc, err := store.findDocument(ctx, collection, id)
 	if err != nil {
-		return 0
+		return nil, err
 	}
 
 	now := time.Now()
@@ -211,14 +496,220 @@ func (store *CloudStore) EditItem(id int, todo internal.Todo) int {
 		{Path: "Done", Value: todo.Done},
 		{Path: "Priority", Value: todo.Priority},
 		{Path: "DueDate", Value: todo.DueDate},
+		{Path: "DueTimed", Value: todo.DueTimed},
+		{Path: "DueZone", Value: todo.DueZone},
+		{Path: "StartDate", Value: todo.StartDate},
 		{Path: "Tags", Value: todo.Tags},
+		{Path: "ParentID", Value: todo.ParentID},
+		{Path: "Project", Value: todo.Project},
+		{Path: "Recurrence", Value: todo.Recurrence},
+		{Path: "Extras", Value: todo.Extras},
 		{Path: "UpdatedAt", Value: now},
 	}
 
-	result, err := doc.Ref.Update(context.Background(), updates)
+	if _, err := doc.Ref.Update(ctx, updates); err != nil {
+		return nil, cloudErr(err)
+	}
+
+	return store.GetItem(ctx, id)
+}
+
+// findDocument Find the document of the named collection holding the item
+// with the given id.
+func (store *CloudStore) findDocument(ctx context.Context, name string, id int) (*firestore.DocumentSnapshot, error) {
+	query := store.client.Collection(name).Query.
+		Where("ID", "==", id).
+		Limit(1)
+
+	doc, err := query.Documents(ctx).Next()
+
+	if errors.Is(err, iterator.Done) {
+		return nil, fmt.Errorf("%w: id %d", storage.ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, cloudErr(err)
+	}
+	return doc, nil
+}
+
+// findDocuments Find the documents of the named collection holding the
+// items with the given ids, failing if any is missing.
+func (store *CloudStore) findDocuments(ctx context.Context, name string, ids []int) ([]*firestore.DocumentSnapshot, error) {
+	var docs []*firestore.DocumentSnapshot
+	for _, id := range ids {
+		doc, err := store.findDocument(ctx, name, id)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// moveDocuments Move the items of docs to the document with the same ID in
+// the named collection, setting their DeletedAt. Each batch of items is
+// moved all at once, so no item is ever in both collections or neither.
+func (store *CloudStore) moveDocuments(ctx context.Context, docs []*firestore.DocumentSnapshot, name string, deletedAt *time.Time) (int, error) {
+	moved := 0
+	for len(docs) > moved {
+		end := moved + batchSize
+		if end > len(docs) {
+			end = len(docs)
+		}
+
+		batch := store.client.Batch()
+		for _, doc := range docs[moved:end] {
+			todo, err := decode(doc)
+			if err != nil {
+				return moved, err
+			}
+			todo.DeletedAt = deletedAt
+			batch.Set(store.client.Collection(name).Doc(doc.Ref.ID), todo)
+			batch.Delete(doc.Ref)
+		}
+		if _, err := batch.Commit(ctx); err != nil {
+			return moved, cloudErr(err)
+		}
+		moved = end
+	}
+	return moved, nil
+}
+
+func (store *CloudStore) deleteDocuments(ctx context.Context, refs []*firestore.DocumentRef) (int, error) {
+	bulkWriter := store.client.BulkWriter(ctx)
+
+	var jobs []*firestore.BulkWriterJob
+	for _, ref := range refs {
+		job, err := bulkWriter.Delete(ref)
+		if err != nil {
+			bulkWriter.End()
+			return 0, cloudErr(err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	bulkWriter.End()
+
+	deleteCount := 0
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return deleteCount, cloudErr(err)
+		}
+		deleteCount++
+	}
+	return deleteCount, nil
+}
+
+// RecordChanges Add changes to the history subcollections of their items.
+func (store *CloudStore) RecordChanges(ctx context.Context, changes []storage.FieldChange) error {
+	for start := 0; start < len(changes); start += batchSize {
+		end := start + batchSize
+		if end > len(changes) {
+			end = len(changes)
+		}
+
+		batch := store.client.Batch()
+		for _, change := range changes[start:end] {
+			batch.Create(store.changes(change.ItemID).NewDoc(), change)
+		}
+		if _, err := batch.Commit(ctx); err != nil {
+			return cloudErr(err)
+		}
+	}
+	return nil
+}
+
+// ItemHistory The changes made to the item with id, oldest first.
+func (store *CloudStore) ItemHistory(ctx context.Context, id int) ([]storage.FieldChange, error) {
+	return queryChanges(ctx, store.changes(id).OrderBy("At", firestore.Asc))
+}
+
+// ChangesSince The changes made to any item at or after since, oldest
+// first. This queries every changes subcollection at once, which needs
+// the collection group index on At.
+func (store *CloudStore) ChangesSince(ctx context.Context, since time.Time) ([]storage.FieldChange, error) {
+	return queryChanges(ctx, store.client.CollectionGroup(changesCollection).
+		Where("At", ">=", since).OrderBy("At", firestore.Asc))
+}
+
+// changes The subcollection holding the history of the item with id.
+func (store *CloudStore) changes(id int) *firestore.CollectionRef {
+	return store.client.Collection(historyCollection).Doc(strconv.Itoa(id)).Collection(changesCollection)
+}
+
+func queryChanges(ctx context.Context, query firestore.Query) ([]storage.FieldChange, error) {
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, cloudErr(err)
+	}
+
+	changes := make([]storage.FieldChange, len(docs))
+	for i, doc := range docs {
+		if err := doc.DataTo(&changes[i]); err != nil {
+			return nil, fmt.Errorf("unable to decode change %s: %w", doc.Ref.ID, err)
+		}
+	}
+	return changes, nil
+}
+
+// Projects Every project in the projects collection.
+func (store *CloudStore) Projects(ctx context.Context) ([]internal.Project, error) {
+	docs, err := store.client.Collection(projectsCollection).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, cloudErr(err)
+	}
+
+	projects := make([]internal.Project, len(docs))
+	for i, doc := range docs {
+		if err := doc.DataTo(&projects[i]); err != nil {
+			return nil, fmt.Errorf("unable to decode project %s: %w", doc.Ref.ID, err)
+		}
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].ID < projects[j].ID })
+	return projects, nil
+}
+
+// PutProject Store project in the document named by its ID, taking the
+// next ID in a transaction if it has none.
+func (store *CloudStore) PutProject(ctx context.Context, project internal.Project) (*internal.Project, error) {
+	now := time.Now()
+	if project.CreatedAt.IsZero() {
+		project.CreatedAt = now
+	}
+	if project.UpdatedAt.IsZero() {
+		project.UpdatedAt = now
+	}
+
+	projects := store.client.Collection(projectsCollection)
+	err := store.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if project.ID <= 0 {
+			docs, err := tx.Documents(projects.OrderBy("ID", firestore.Desc).Limit(1)).GetAll()
+			if err != nil {
+				return err
+			}
+			project.ID = 1
+			if len(docs) > 0 {
+				project.ID = int(docs[0].Data()["ID"].(int64)) + 1
+			}
+		}
+		return tx.Set(projects.Doc(strconv.Itoa(project.ID)), project)
+	})
+	if err != nil {
+		return nil, cloudErr(err)
+	}
+	return &project, nil
+}
 
-	if result != nil && err == nil {
-		return 1
+// cloudErr Classify a Firestore error as one of the storage sentinel errors.
+func cloudErr(err error) error {
+	switch status.Code(err) {
+	case codes.NotFound:
+		return fmt.Errorf("%w: %w", storage.ErrNotFound, err)
+	case codes.AlreadyExists, codes.Aborted, codes.FailedPrecondition:
+		return fmt.Errorf("%w: %w", storage.ErrConflict, err)
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Unauthenticated,
+		codes.PermissionDenied, codes.ResourceExhausted, codes.Internal:
+		return fmt.Errorf("%w: %w", storage.ErrBackendUnavailable, err)
 	}
-	return 0
+	return err
 }
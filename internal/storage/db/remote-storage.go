@@ -0,0 +1,545 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/filter"
+	"github.com/tcooper-uk/go-todo/internal/storage"
+)
+
+const (
+	defaultRetries        = 3
+	defaultBackoff        = 200 * time.Millisecond
+	defaultRequestTimeout = 10 * time.Second
+)
+
+// RemoteStore Keeps items on a todo server started with `todo serve`,
+// through its REST API.
+type RemoteStore struct {
+	baseURL *url.URL
+	token   string
+	client  *http.Client
+	retries int
+	backoff time.Duration
+}
+
+type RemoteStoreConfig struct {
+	// URL Where the server is, e.g. http://todo.lan:8080.
+	URL string
+	// Token Sent as a bearer token, if set.
+	Token string
+	// Client Makes the requests. Defaults to one that gives up on a request
+	// after 10 seconds.
+	Client *http.Client
+	// Retries How many times a failed request is retried. Defaults to 3.
+	Retries int
+	// Backoff The wait before the first retry, doubled for each one after.
+	// Defaults to 200ms.
+	Backoff time.Duration
+}
+
+func NewRemoteStore(config *RemoteStoreConfig) (*RemoteStore, error) {
+	base, err := url.Parse(strings.TrimSuffix(config.URL, "/"))
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("invalid todo server URL %q, expected e.g. http://todo.lan:8080", config.URL)
+	}
+
+	store := &RemoteStore{
+		baseURL: base,
+		token:   config.Token,
+		client:  config.Client,
+		retries: config.Retries,
+		backoff: config.Backoff,
+	}
+	if store.client == nil {
+		store.client = &http.Client{Timeout: defaultRequestTimeout}
+	}
+	if store.retries == 0 {
+		store.retries = defaultRetries
+	}
+	if store.backoff == 0 {
+		store.backoff = defaultBackoff
+	}
+	return store, nil
+}
+
+// remoteList The body of GET /todos.
+type remoteList struct {
+	Items      []internal.Todo `json:"items"`
+	NextCursor string          `json:"next_cursor"`
+}
+
+// GetAllItems List the items the server selects for opts.
+func (store *RemoteStore) GetAllItems(ctx context.Context, opts storage.ListOptions) (*internal.TodoCollection, error) {
+	path := "/todos"
+	if opts.Trashed {
+		path = "/trash"
+	}
+	var list remoteList
+	if err := store.do(ctx, http.MethodGet, path, listQuery(opts), nil, nil, &list); err != nil {
+		return nil, err
+	}
+
+	maxLen := 0
+	for _, item := range list.Items {
+		if l := len(item.Name); l > maxLen {
+			maxLen = l
+		}
+	}
+
+	return &internal.TodoCollection{
+		Items:         list.Items,
+		Size:          len(list.Items),
+		MaxLengthItem: maxLen,
+		NextCursor:    list.NextCursor,
+	}, nil
+}
+
+// listQuery The query parameters of GET /todos that select opts.
+func listQuery(opts storage.ListOptions) url.Values {
+	q := url.Values{}
+	if opts.ShowDone {
+		q.Set("all", "true")
+	}
+	if opts.OnlyDone {
+		q.Set("done", "true")
+	}
+	if opts.Overdue {
+		q.Set("overdue", "true")
+	}
+	if opts.IncludeDeferred {
+		q.Set("include_deferred", "true")
+	}
+	if opts.Overdue || opts.Filter != nil {
+		if zone := zoneName(opts.TimeZone()); zone != "" {
+			q.Set("tz", zone)
+		}
+	}
+	if opts.Priority != "" {
+		q.Set("priority", opts.Priority)
+	}
+	for _, tag := range opts.Tags {
+		q.Add("tag", tag)
+	}
+	for _, project := range opts.Projects {
+		q.Add("project", strconv.Itoa(project))
+	}
+	if opts.Filter != nil {
+		q.Set("filter", filter.Format(opts.Filter))
+	}
+	if len(opts.Sort) > 0 {
+		keys := make([]string, len(opts.Sort))
+		for i, key := range opts.Sort {
+			keys[i] = string(key.Field)
+			if key.Desc {
+				keys[i] = "-" + keys[i]
+			}
+		}
+		q.Set("sort", strings.Join(keys, ","))
+	}
+	if opts.Limit > 0 {
+		q.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Offset > 0 {
+		q.Set("offset", strconv.Itoa(opts.Offset))
+	}
+	if opts.Cursor != "" {
+		q.Set("cursor", opts.Cursor)
+	}
+	return q
+}
+
+// GetItem Get a single item by id.
+func (store *RemoteStore) GetItem(ctx context.Context, id int) (*internal.Todo, error) {
+	var item internal.Todo
+	if err := store.do(ctx, http.MethodGet, itemPath(id), nil, nil, nil, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// AddItem Add an item; the server assigns its id and timestamps, bar a
+// CreatedAt that is already set.
+func (store *RemoteStore) AddItem(ctx context.Context, todo internal.Todo) (*internal.Todo, error) {
+	body := itemBody(todo)
+	if !todo.CreatedAt.IsZero() {
+		body["created_at"] = todo.CreatedAt
+	}
+	var item internal.Todo
+	if err := store.do(ctx, http.MethodPost, "/todos", nil, nil, body, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// PutItem Store todo as given, ID and timestamps included.
+func (store *RemoteStore) PutItem(ctx context.Context, todo internal.Todo) (*internal.Todo, error) {
+	if todo.ID <= 0 {
+		return nil, storage.ErrNoID
+	}
+
+	now := time.Now()
+	body := itemBody(todo)
+	for name, t := range map[string]time.Time{"created_at": todo.CreatedAt, "updated_at": todo.UpdatedAt} {
+		if t.IsZero() {
+			t = now
+		}
+		body[name] = t
+	}
+	// Sent as null for a live item, so a trashed one put back is restored.
+	body["deleted_at"] = todo.DeletedAt
+
+	var item internal.Todo
+	if err := store.do(ctx, http.MethodPut, itemPath(todo.ID), nil, nil, body, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// EditItem Replace the item with the given id by todo.
+func (store *RemoteStore) EditItem(ctx context.Context, id int, todo internal.Todo) (*internal.Todo, error) {
+	// If-Match: * stops the server adding the item if it is missing.
+	header := http.Header{"If-Match": {"*"}}
+	var item internal.Todo
+	if err := store.do(ctx, http.MethodPut, itemPath(id), nil, header, itemBody(todo), &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// remoteDeleted The body of DELETE /todos.
+type remoteDeleted struct {
+	Deleted int `json:"deleted"`
+}
+
+// DeleteItem Move items to the server's trash by id, all or none of them.
+func (store *RemoteStore) DeleteItem(ctx context.Context, ids ...int) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	q := url.Values{}
+	for _, id := range ids {
+		q.Add("id", strconv.Itoa(id))
+	}
+
+	// The server refuses to leave subtasks without their parent, as the
+	// CLI does before it gets here.
+	var deleted remoteDeleted
+	if err := store.do(ctx, http.MethodDelete, "/todos", q, nil, nil, &deleted); err != nil {
+		return 0, err
+	}
+	return deleted.Deleted, nil
+}
+
+// DeleteAllItems Move every item on the server to its trash.
+func (store *RemoteStore) DeleteAllItems(ctx context.Context) (int, error) {
+	var deleted remoteDeleted
+	if err := store.do(ctx, http.MethodDelete, "/todos", url.Values{"all": {"true"}}, nil, nil, &deleted); err != nil {
+		return 0, err
+	}
+	return deleted.Deleted, nil
+}
+
+// remoteRestored The body of POST /trash/restore.
+type remoteRestored struct {
+	Restored int `json:"restored"`
+}
+
+// RestoreItem Take items out of the server's trash by id, all or none of
+// them.
+func (store *RemoteStore) RestoreItem(ctx context.Context, ids ...int) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	q := url.Values{}
+	for _, id := range ids {
+		q.Add("id", strconv.Itoa(id))
+	}
+
+	var restored remoteRestored
+	if err := store.do(ctx, http.MethodPost, "/trash/restore", q, nil, nil, &restored); err != nil {
+		return 0, err
+	}
+	return restored.Restored, nil
+}
+
+// EmptyTrash Delete the items moved to the server's trash before the given
+// time, or all of them, for good.
+func (store *RemoteStore) EmptyTrash(ctx context.Context, before time.Time) (int, error) {
+	q := url.Values{}
+	if !before.IsZero() {
+		q.Set("before", before.UTC().Format(time.RFC3339Nano))
+	}
+	var deleted remoteDeleted
+	if err := store.do(ctx, http.MethodDelete, "/trash", q, nil, nil, &deleted); err != nil {
+		return 0, err
+	}
+	return deleted.Deleted, nil
+}
+
+// remoteHistory The body of GET /todos/{id}/history and GET /history.
+type remoteHistory struct {
+	Changes []storage.FieldChange `json:"changes"`
+}
+
+// ItemHistory The changes the server recorded to the item with id, oldest
+// first.
+func (store *RemoteStore) ItemHistory(ctx context.Context, id int) ([]storage.FieldChange, error) {
+	var history remoteHistory
+	if err := store.do(ctx, http.MethodGet, itemPath(id)+"/history", nil, nil, nil, &history); err != nil {
+		return nil, err
+	}
+	return history.Changes, nil
+}
+
+// ChangesSince The changes the server recorded to any item at or after
+// since, oldest first.
+func (store *RemoteStore) ChangesSince(ctx context.Context, since time.Time) ([]storage.FieldChange, error) {
+	q := url.Values{"since": {since.UTC().Format(time.RFC3339Nano)}}
+	var history remoteHistory
+	if err := store.do(ctx, http.MethodGet, "/history", q, nil, nil, &history); err != nil {
+		return nil, err
+	}
+	return history.Changes, nil
+}
+
+// remoteProjects The body of GET /projects.
+type remoteProjects struct {
+	Projects []internal.Project `json:"projects"`
+}
+
+// Projects Every project on the server, archived or not.
+func (store *RemoteStore) Projects(ctx context.Context) ([]internal.Project, error) {
+	var projects remoteProjects
+	if err := store.do(ctx, http.MethodGet, "/projects", nil, nil, nil, &projects); err != nil {
+		return nil, err
+	}
+	return projects.Projects, nil
+}
+
+// PutProject Add project to the server, which assigns its ID if it has
+// none, or replace the one with its ID.
+func (store *RemoteStore) PutProject(ctx context.Context, project internal.Project) (*internal.Project, error) {
+	method, path := http.MethodPost, "/projects"
+	if project.ID != 0 {
+		method, path = http.MethodPut, "/projects/"+strconv.Itoa(project.ID)
+	}
+	body := map[string]any{
+		"name":      project.Name,
+		"parent_id": project.ParentID,
+		"archived":  project.Archived,
+	}
+	for name, t := range map[string]time.Time{"created_at": project.CreatedAt, "updated_at": project.UpdatedAt} {
+		if !t.IsZero() {
+			body[name] = t
+		}
+	}
+
+	var stored internal.Project
+	if err := store.do(ctx, method, path, nil, nil, body, &stored); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// zoneName The IANA name of loc, for the server to reckon days in, or ""
+// if it has none.
+func zoneName(loc *time.Location) string {
+	if loc == time.Local {
+		return internal.LocalZone()
+	}
+	if name := loc.String(); name != "Local" {
+		return name
+	}
+	return ""
+}
+
+func itemPath(id int) string {
+	return "/todos/" + strconv.Itoa(id)
+}
+
+// itemBody The fields of todo a client may set, as the server reads them.
+func itemBody(todo internal.Todo) map[string]any {
+	tags := todo.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	var due any
+	if todo.DueDate != nil {
+		due = todo.DueDate
+		if !todo.DueTimed {
+			due = todo.DueDate.UTC().Format("2006-01-02")
+		}
+	}
+	return map[string]any{
+		"name":       todo.Name,
+		"done":       todo.Done,
+		"priority":   todo.Priority,
+		"due_date":   due,
+		"due_timed":  todo.DueTimed,
+		"due_zone":   todo.DueZone,
+		"start_date": todo.StartDate,
+		"tags":       tags,
+		"parent_id":  todo.ParentID,
+		"project":    todo.Project,
+		"recurrence": todo.Recurrence,
+		"extras":     todo.Extras,
+	}
+}
+
+// remoteError An error reported by the server, wrapping the sentinel
+// matching its status.
+type remoteError struct {
+	msg string
+	err error
+}
+
+func (e *remoteError) Error() string {
+	return e.msg
+}
+
+func (e *remoteError) Unwrap() error {
+	return e.err
+}
+
+// do Send a request with any extra header and body encoded as JSON, and
+// decode the response
+// into out. Requests are retried with exponential backoff while the
+// server cannot be reached or is temporarily unavailable, unless retrying
+// could apply a change twice.
+func (store *RemoteStore) do(ctx context.Context, method, path string, query url.Values, header http.Header, body any, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	u := *store.baseURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	wait := store.backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := store.send(ctx, method, u.String(), header, payload)
+		switch {
+		case err != nil:
+			retry := ctx.Err() == nil && retryableError(err, method)
+			err = fmt.Errorf("%w: cannot reach todo server at %s: %w", storage.ErrBackendUnavailable, store.baseURL.Host, err)
+			if !retry {
+				return err
+			}
+		case retryableStatus(resp.StatusCode, method):
+			err = responseError(resp)
+			resp.Body.Close()
+		default:
+			defer resp.Body.Close()
+			return decodeResponse(resp, out)
+		}
+
+		if attempt == store.retries {
+			return err
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+		wait *= 2
+	}
+}
+
+func (store *RemoteStore) send(ctx context.Context, method, u string, header http.Header, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if store.token != "" {
+		req.Header.Set("Authorization", "Bearer "+store.token)
+	}
+	// The server records the changes made as the CLI's user's.
+	req.Header.Set(storage.ActorHeader, storage.Actor(ctx))
+	return store.client.Do(req)
+}
+
+// retryableError Whether a request that failed with err may be sent again.
+// POST is only resent when it never reached the server, since it would
+// otherwise add the item twice.
+func retryableError(err error, method string) bool {
+	if method != http.MethodPost {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// retryableStatus Whether a response says the server, or a proxy in front
+// of it, could not handle the request for now.
+func retryableStatus(status int, method string) bool {
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return method != http.MethodPost
+	}
+	return false
+}
+
+func decodeResponse(resp *http.Response, out any) error {
+	if resp.StatusCode >= 300 {
+		return responseError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%w: unreadable response from todo server: %w", storage.ErrBackendUnavailable, err)
+	}
+	return nil
+}
+
+// responseError The error reported by a response with a failure status.
+func responseError(resp *http.Response) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(data, &body) != nil || body.Error == "" {
+		body.Error = strings.TrimSpace(string(data))
+	}
+	msg := fmt.Sprintf("todo server: %s", body.Error)
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return &remoteError{msg, storage.ErrNotFound}
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return &remoteError{msg, storage.ErrConflict}
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return &remoteError{msg, storage.ErrBackendUnavailable}
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return errors.New("todo server refused the token (check $TODO_REMOTE_TOKEN): " + body.Error)
+	}
+	if strings.Contains(body.Error, storage.ErrInvalidCursor.Error()) {
+		return &remoteError{msg, storage.ErrInvalidCursor}
+	}
+	return fmt.Errorf("todo server returned %s: %s", resp.Status, body.Error)
+}
@@ -1,15 +1,19 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/filter"
+	"github.com/tcooper-uk/go-todo/internal/search"
 	"github.com/tcooper-uk/go-todo/internal/storage"
 )
 
@@ -22,17 +26,20 @@ const (
 			name TEXT NOT NULL
 		);
 	`
-	fields = `id, created_at, updated_at, name, done, priority, due_date, tags`
+	fields = `id, created_at, updated_at, name, done, priority, due_date, tags, parent_id, recurrence, extras, project_id, due_timed, due_zone, start_date, deleted_at`
 )
 
 type SQLLiteStore struct {
 	db         *sql.DB
 	DbFilePath string
+
+	// searchable Whether the FTS5 search index is available.
+	searchable bool
 }
 
 type rowScan func(dest ...any) error
 
-func NewSQLLiteStorage(dbPath string) (*SQLLiteStore, error) {
+func NewSQLLiteStorage(ctx context.Context, dbPath string) (*SQLLiteStore, error) {
 
 	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
 		_, err := os.Create(dbPath)
@@ -41,42 +48,136 @@ func NewSQLLiteStorage(dbPath string) (*SQLLiteStore, error) {
 		}
 	}
 
-	db, err := sql.Open("sqlite3", dbPath)
+	// SQLite only enforces foreign keys on connections that ask it to.
+	dsn := dbPath + "?_foreign_keys=on"
+	if deadline, ok := ctx.Deadline(); ok {
+		// Waiting on a locked database happens inside SQLite, out of reach
+		// of ctx, so bound it by the caller's deadline as well. The margin
+		// lets ctx expire first, so the failure is reported as a timeout.
+		busyTimeout := time.Until(deadline) + 50*time.Millisecond
+		dsn += fmt.Sprintf("&_busy_timeout=%d", busyTimeout.Milliseconds())
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
 
 	if err != nil {
 		return nil, dbErr(err)
 	}
 
-	db.Exec(setupStatement)
+	if _, err := db.ExecContext(ctx, setupStatement); err != nil {
+		return nil, dbErr(err)
+	}
 
-	if err := migrateSchema(db); err != nil {
-		return nil, fmt.Errorf("schema migration failed: %w", err)
+	if err := migrateSchema(ctx, db); err != nil {
+		return nil, fmt.Errorf("schema migration failed: %w", storeErr(err))
+	}
+
+	searchable, err := migrateSearchIndex(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("search index migration failed: %w", storeErr(err))
 	}
 
 	return &SQLLiteStore{
-		db,
-		dbPath,
+		db:         db,
+		DbFilePath: dbPath,
+		searchable: searchable,
 	}, nil
 }
 
-func migrateSchema(db *sql.DB) error {
-	var version int
-	db.QueryRow("PRAGMA user_version").Scan(&version)
-
-	if version >= 1 {
-		return nil
-	}
-
-	migrations := []string{
+// migrations Schema changes in order; applying migrations[i] moves the
+// schema to version i+1, as recorded in PRAGMA user_version.
+var migrations = [][]string{
+	{
 		`ALTER TABLE todo_item ADD COLUMN done     INTEGER NOT NULL DEFAULT 0`,
 		`ALTER TABLE todo_item ADD COLUMN priority TEXT    NOT NULL DEFAULT ''`,
 		`ALTER TABLE todo_item ADD COLUMN due_date NUMERIC`,
 		`ALTER TABLE todo_item ADD COLUMN tags     TEXT    NOT NULL DEFAULT '[]'`,
-		`PRAGMA user_version = 1`,
+	},
+	{
+		`ALTER TABLE todo_item ADD COLUMN parent_id INTEGER REFERENCES todo_item(id)`,
+		`CREATE INDEX IF NOT EXISTS todo_item_parent_id ON todo_item(parent_id)`,
+	},
+	{
+		`ALTER TABLE todo_item ADD COLUMN recurrence TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		`ALTER TABLE todo_item ADD COLUMN extras TEXT NOT NULL DEFAULT ''`,
+	},
+	{
+		`ALTER TABLE todo_item ADD COLUMN deleted_at NUMERIC`,
+		`CREATE INDEX IF NOT EXISTS todo_item_deleted_at ON todo_item(deleted_at)`,
+	},
+	{
+		// The history outlives the items it is about, so has no foreign key.
+		`CREATE TABLE IF NOT EXISTS todo_history (
+			id        INTEGER PRIMARY KEY NOT NULL,
+			item_id   INTEGER NOT NULL,
+			at        NUMERIC NOT NULL,
+			actor     TEXT    NOT NULL,
+			field     TEXT    NOT NULL,
+			old_value TEXT    NOT NULL,
+			new_value TEXT    NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS todo_history_item_id ON todo_history(item_id)`,
+		`CREATE INDEX IF NOT EXISTS todo_history_at ON todo_history(at)`,
+	},
+	{
+		// Projects are archived rather than deleted, so deleting one that
+		// still has items or subprojects is refused.
+		`CREATE TABLE IF NOT EXISTS project (
+			id         INTEGER PRIMARY KEY NOT NULL,
+			name       TEXT    NOT NULL,
+			parent_id  INTEGER REFERENCES project(id) ON DELETE RESTRICT,
+			archived   INTEGER NOT NULL DEFAULT 0,
+			created_at NUMERIC NOT NULL,
+			updated_at NUMERIC NOT NULL
+		)`,
+		`ALTER TABLE todo_item ADD COLUMN project_id INTEGER REFERENCES project(id) ON DELETE RESTRICT`,
+		`CREATE INDEX IF NOT EXISTS todo_item_project_id ON todo_item(project_id)`,
+		// Foreign keys are enforced from here on, parent_id's too. Subtasks
+		// whose parent was emptied from the trash become top-level items,
+		// as ON DELETE SET NULL would make them; parent_id cannot be
+		// declared again without rebuilding the table.
+		`UPDATE todo_item SET parent_id = NULL WHERE parent_id NOT IN (SELECT id FROM todo_item)`,
+		`CREATE TRIGGER IF NOT EXISTS todo_item_orphan BEFORE DELETE ON todo_item BEGIN
+			UPDATE todo_item SET parent_id = NULL WHERE parent_id = old.id;
+		END`,
+	},
+	{
+		// Due dates until now were all days, kept as midnight UTC.
+		`ALTER TABLE todo_item ADD COLUMN due_timed INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE todo_item ADD COLUMN due_zone  TEXT    NOT NULL DEFAULT ''`,
+	},
+	{
+		`ALTER TABLE todo_item ADD COLUMN start_date NUMERIC`,
+	},
+}
+
+func migrateSchema(ctx context.Context, db *sql.DB) error {
+	var version int
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return err
 	}
 
-	for _, stmt := range migrations {
-		if _, err := db.Exec(stmt); err != nil {
+	for ; version < len(migrations); version++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		for _, stmt := range migrations[version] {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
 			return err
 		}
 	}
@@ -84,28 +185,99 @@ func migrateSchema(db *sql.DB) error {
 	return nil
 }
 
-func (store *SQLLiteStore) GetAllItems(opts storage.ListOptions) *internal.TodoCollection {
-	var items []internal.Todo
+// searchTriggers Keep the FTS5 index in step with todo_item.
+var searchTriggers = map[string]string{
+	"todo_search_insert": `
+		CREATE TRIGGER IF NOT EXISTS todo_search_insert AFTER INSERT ON todo_item BEGIN
+			INSERT INTO todo_search(rowid, name, tags) VALUES (new.id, new.name, new.tags);
+		END`,
+	"todo_search_delete": `
+		CREATE TRIGGER IF NOT EXISTS todo_search_delete AFTER DELETE ON todo_item BEGIN
+			INSERT INTO todo_search(todo_search, rowid, name, tags) VALUES ('delete', old.id, old.name, old.tags);
+		END`,
+	"todo_search_update": `
+		CREATE TRIGGER IF NOT EXISTS todo_search_update AFTER UPDATE OF name, tags ON todo_item BEGIN
+			INSERT INTO todo_search(todo_search, rowid, name, tags) VALUES ('delete', old.id, old.name, old.tags);
+			INSERT INTO todo_search(rowid, name, tags) VALUES (new.id, new.name, new.tags);
+		END`,
+}
 
-	query, args := buildListQuery(opts)
-	rows, err := store.db.Query(query, args...)
+// migrateSearchIndex Create the FTS5 index over item names and tags, and the
+// triggers that maintain it. FTS5 is only compiled in with the sqlite_fts5
+// build tag; without it the triggers are dropped, so writes keep working and
+// search falls back to scanning, and the index is rebuilt when next opened
+// by a build that has it. Returns whether the index can be used.
+func migrateSearchIndex(ctx context.Context, db *sql.DB) (bool, error) {
+	var hasFTS5 bool
+	if err := db.QueryRowContext(ctx, "SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&hasFTS5); err != nil {
+		return false, err
+	}
 
+	var triggers int
+	err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'todo_search_%'").Scan(&triggers)
 	if err != nil {
-		return &internal.TodoCollection{}
+		return false, err
 	}
 
-	defer rows.Close()
-	mapToTodoItems(rows, &items)
-
-	// client-side tag filter
-	if opts.Tag != "" {
-		var filtered []internal.Todo
-		for _, item := range items {
-			if hasTag(item.Tags, opts.Tag) {
-				filtered = append(filtered, item)
+	if !hasFTS5 {
+		for name := range searchTriggers {
+			if _, err := db.ExecContext(ctx, "DROP TRIGGER IF EXISTS "+name); err != nil {
+				return false, err
 			}
 		}
-		items = filtered
+		return false, nil
+	}
+
+	if triggers == len(searchTriggers) {
+		return true, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	stmts := []string{`CREATE VIRTUAL TABLE IF NOT EXISTS todo_search USING fts5(name, tags, content='todo_item', content_rowid='id')`}
+	for _, trigger := range searchTriggers {
+		stmts = append(stmts, trigger)
+	}
+	stmts = append(stmts, `INSERT INTO todo_search(todo_search) VALUES ('rebuild')`)
+
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+func (store *SQLLiteStore) GetAllItems(ctx context.Context, opts storage.ListOptions) (*internal.TodoCollection, error) {
+	var items []internal.Todo
+
+	start, err := storage.PageStart(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args := buildListQuery(opts, start)
+	rows, err := store.db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, storeErr(err)
+	}
+
+	defer rows.Close()
+	if err := mapToTodoItems(rows, &items); err != nil {
+		return nil, storeErr(err)
+	}
+
+	// One row past the limit is fetched to tell whether there are more.
+	more := opts.Limit > 0 && len(items) > opts.Limit
+	if more {
+		items = items[:opts.Limit]
 	}
 
 	maxLen := 0
@@ -119,14 +291,66 @@ func (store *SQLLiteStore) GetAllItems(opts storage.ListOptions) *internal.TodoC
 		Items:         items,
 		Size:          len(items),
 		MaxLengthItem: maxLen,
-	}
+		NextCursor:    storage.NextCursor(opts, start, len(items), more),
+	}, nil
 }
 
-func buildListQuery(opts storage.ListOptions) (string, []any) {
+func buildListQuery(opts storage.ListOptions, start int) (string, []any) {
 	base := "SELECT " + fields + " FROM todo_item"
+	conditions, args := listConditions(opts)
+
+	if len(conditions) > 0 {
+		base += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	base += " ORDER BY " + orderBy(opts.Sort)
+
+	if opts.Limit > 0 {
+		base += " LIMIT ? OFFSET ?"
+		args = append(args, opts.Limit+1, start)
+	} else if start > 0 {
+		base += " LIMIT -1 OFFSET ?"
+		args = append(args, start)
+	}
+
+	return base, args
+}
+
+// sortColumns The expression each sort field orders by. NULL due dates
+// come first, as storage.SortItems puts them.
+var sortColumns = map[storage.SortField]string{
+	storage.SortID:       "id",
+	storage.SortDue:      "due_date",
+	storage.SortPriority: priorityRankSQL,
+	storage.SortCreated:  "created_at",
+	storage.SortUpdated:  "updated_at",
+	storage.SortName:     "name COLLATE NOCASE",
+	storage.SortDone:     "done",
+}
+
+func orderBy(keys []storage.SortKey) string {
+	var terms []string
+	for _, key := range keys {
+		term := sortColumns[key.Field]
+		if key.Desc {
+			term += " DESC"
+		}
+		terms = append(terms, term)
+	}
+	return strings.Join(append(terms, "id"), ", ")
+}
+
+// listConditions The WHERE clauses selecting the items opts asks for.
+func listConditions(opts storage.ListOptions) ([]string, []any) {
 	var conditions []string
 	var args []any
 
+	if opts.Trashed {
+		conditions = append(conditions, "deleted_at IS NOT NULL")
+	} else {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+
 	if !opts.ShowDone && !opts.OnlyDone {
 		conditions = append(conditions, "done = 0")
 	} else if opts.OnlyDone {
@@ -138,159 +362,576 @@ func buildListQuery(opts storage.ListOptions) (string, []any) {
 		args = append(args, opts.Priority)
 	}
 
+	// A timed deadline is overdue once it has passed, an all-day item
+	// once its day is over where the list is asked for.
+	now := time.Now().In(opts.TimeZone())
 	if opts.Overdue {
-		conditions = append(conditions, "due_date IS NOT NULL AND due_date < ?")
-		args = append(args, time.Now().UnixMilli())
+		conditions = append(conditions, "due_date IS NOT NULL AND due_date < (CASE WHEN due_timed THEN ? ELSE ? END)")
+		args = append(args, now.UnixMilli(), internal.Day(now).UnixMilli())
+	}
+
+	if !opts.ShowDeferred() {
+		conditions = append(conditions, "(start_date IS NULL OR start_date <= ?)")
+		args = append(args, now.UnixMilli())
+	}
+
+	for _, tag := range opts.Tags {
+		conditions = append(conditions, hasTagSQL)
+		args = append(args, tag)
 	}
 
+	if len(opts.Projects) > 0 {
+		conditions = append(conditions, "COALESCE(project_id, 0) IN (?"+strings.Repeat(", ?", len(opts.Projects)-1)+")")
+		for _, id := range opts.Projects {
+			args = append(args, id)
+		}
+	}
+
+	if opts.Filter != nil {
+		cond, filterArgs := filterSQL(opts.Filter, now.Location())
+		conditions = append(conditions, cond)
+		args = append(args, filterArgs...)
+	}
+
+	return conditions, args
+}
+
+// hasTagSQL Tests whether the JSON tags array contains the bound tag.
+const hasTagSQL = "EXISTS (SELECT 1 FROM json_each(todo_item.tags) WHERE json_each.value = ?)"
+
+// priorityRankSQL Orders priorities as filter.PriorityRank does.
+const priorityRankSQL = "(CASE priority WHEN 'low' THEN 1 WHEN 'medium' THEN 2 WHEN 'high' THEN 3 ELSE 0 END)"
+
+// filterSQL Translate a filter expression into a WHERE clause and its
+// arguments, with days reckoned in loc. Every clause evaluates to 0 or 1,
+// never NULL, so NOT inverts it as filter.Match would.
+func filterSQL(expr filter.Expr, loc *time.Location) (string, []any) {
+	switch e := expr.(type) {
+	case filter.And:
+		left, leftArgs := filterSQL(e.Left, loc)
+		right, rightArgs := filterSQL(e.Right, loc)
+		return "(" + left + " AND " + right + ")", append(leftArgs, rightArgs...)
+	case filter.Or:
+		left, leftArgs := filterSQL(e.Left, loc)
+		right, rightArgs := filterSQL(e.Right, loc)
+		return "(" + left + " OR " + right + ")", append(leftArgs, rightArgs...)
+	case filter.Not:
+		cond, args := filterSQL(e.Expr, loc)
+		return "NOT " + cond, args
+	case filter.Cond:
+		if e.Op == filter.OpNotEqual {
+			cond, args := filterSQL(filter.Cond{Field: e.Field, Op: filter.OpEqual, Value: e.Value}, loc)
+			return "NOT " + cond, args
+		}
+		return condSQL(e, loc)
+	}
+	return "0", nil
+}
+
+func condSQL(c filter.Cond, loc *time.Location) (string, []any) {
+	switch c.Field {
+	case filter.FieldTag:
+		return hasTagSQL, []any{c.Value}
+	case filter.FieldName:
+		if c.Op == filter.OpIs {
+			return "(instr(lower(name), lower(?)) > 0)", []any{c.Value}
+		}
+		return "(name = ? COLLATE NOCASE)", []any{c.Value}
+	case filter.FieldDone:
+		return "(done = ?)", []any{boolToInt(c.Value.(bool))}
+	case filter.FieldPriority:
+		return "(" + priorityRankSQL + " " + sqlOp(c.Op) + " ?)", []any{filter.PriorityRank(c.Value.(internal.Priority))}
+	case filter.FieldID:
+		return "(id " + sqlOp(c.Op) + " ?)", []any{c.Value}
+	case filter.FieldParent:
+		return "(IFNULL(parent_id, 0) " + sqlOp(c.Op) + " ?)", []any{c.Value}
+	case filter.FieldDue:
+		if c.Value == nil {
+			return "(due_date IS NULL)", nil
+		}
+		// All-day dates are UTC days; timed deadlines fall on the day they
+		// are in loc.
+		from, to := c.Range()
+		day, dayArgs := rangeSQL("due_date", from, to)
+		from, to = c.RangeIn(loc)
+		timed, timedArgs := rangeSQL("due_date", from, to)
+		return "(CASE WHEN due_timed THEN " + timed + " ELSE " + day + " END)", append(timedArgs, dayArgs...)
+	case filter.FieldCreated:
+		from, to := c.RangeIn(loc)
+		return rangeSQL("created_at", from, to)
+	case filter.FieldUpdated:
+		from, to := c.RangeIn(loc)
+		return rangeSQL("updated_at", from, to)
+	}
+	return "0", nil
+}
+
+// rangeSQL Select rows whose millisecond timestamp column falls between
+// from, inclusive, and to, exclusive, either of which may be unbounded.
+func rangeSQL(column string, from, to *time.Time) (string, []any) {
+	conds := []string{column + " IS NOT NULL"}
+	var args []any
+	if from != nil {
+		conds = append(conds, column+" >= ?")
+		args = append(args, from.UnixMilli())
+	}
+	if to != nil {
+		conds = append(conds, column+" < ?")
+		args = append(args, to.UnixMilli())
+	}
+	return "(" + strings.Join(conds, " AND ") + ")", args
+}
+
+func sqlOp(op filter.Op) string {
+	if op == filter.OpIs {
+		return "="
+	}
+	return string(op)
+}
+
+// Search Find items matching expr using the FTS5 index, ranked by BM25.
+// Falls back to scanning every item when the index is unavailable or the
+// query cannot be expressed in FTS5.
+func (store *SQLLiteStore) Search(ctx context.Context, expr search.Expr, opts storage.ListOptions) ([]search.Result, error) {
+	match, ok := search.FTS5(expr)
+	if !store.searchable || !ok {
+		return storage.SearchInMemory(ctx, store, expr, opts)
+	}
+
+	conditions, args := listConditions(opts)
+	query := `
+		SELECT ` + fields + `, score, snip FROM todo_item
+		JOIN (
+			SELECT rowid AS match_id, bm25(todo_search) AS score,
+				snippet(todo_search, 0, ?, ?, ?, 12) AS snip
+			FROM todo_search WHERE todo_search MATCH ?
+		) ON todo_item.id = match_id`
+	args = append([]any{search.MarkStart, search.MarkEnd, search.Ellipsis, match}, args...)
 	if len(conditions) > 0 {
-		base += " WHERE " + strings.Join(conditions, " AND ")
+		query += " WHERE " + strings.Join(conditions, " AND ")
 	}
+	query += " ORDER BY score, id"
 
-	return base, args
+	rows, err := store.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, storeErr(err)
+	}
+	defer rows.Close()
+
+	var results []search.Result
+	for rows.Next() {
+		var score float64
+		var snippet string
+		item, err := mapToTodoItem(func(dest ...any) error {
+			return rows.Scan(append(dest, &score, &snippet)...)
+		})
+		if err != nil {
+			return nil, storeErr(err)
+		}
+
+		// The snippet comes from the name; if only a tag matched, show the
+		// name as highlighted by the in-memory matcher instead.
+		if !strings.Contains(snippet, search.MarkStart) {
+			snippet = search.Highlight(item.Name, expr)
+		}
+
+		// BM25 scores are negative, lower meaning more relevant.
+		results = append(results, search.Result{Item: *item, Score: -score, Snippet: snippet})
+	}
+
+	return results, storeErr(rows.Err())
 }
 
 // GetItem Get a single todo item by its unique id.
-func (store *SQLLiteStore) GetItem(id int) *internal.Todo {
-	row := store.db.QueryRow("SELECT "+fields+" FROM todo_item WHERE id = ?", id)
+func (store *SQLLiteStore) GetItem(ctx context.Context, id int) (*internal.Todo, error) {
+	return store.getItem(ctx, id, "deleted_at IS NULL")
+}
+
+// getItem Get the item with the given id that also meets condition.
+func (store *SQLLiteStore) getItem(ctx context.Context, id int, condition string) (*internal.Todo, error) {
+	row := store.db.QueryRowContext(ctx, "SELECT "+fields+" FROM todo_item WHERE id = ? AND "+condition, id)
 
 	item, err := mapToTodoItem(row.Scan)
 
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: id %d", storage.ErrNotFound, id)
+	}
 	if err != nil {
-		return &internal.Todo{}
+		return nil, storeErr(err)
 	}
 
-	return item
+	return item, nil
 }
 
 // AddItem Add a single item. The store assigns ID and timestamps.
-func (store *SQLLiteStore) AddItem(todo internal.Todo) int {
-	tx, err := store.db.Begin()
+func (store *SQLLiteStore) AddItem(ctx context.Context, todo internal.Todo) (*internal.Todo, error) {
+	tx, err := store.db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0
+		return nil, storeErr(err)
 	}
 
-	stmt, err := tx.Prepare(`
-		INSERT INTO todo_item (created_at, updated_at, name, done, priority, due_date, tags)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO todo_item (created_at, updated_at, name, done, priority, due_date, tags, parent_id, recurrence, extras, project_id, due_timed, due_zone, start_date)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		tx.Rollback()
-		return 0
+		return nil, storeErr(err)
 	}
 	defer stmt.Close()
 
 	now := time.Now().UnixMilli()
-	tagsJSON, _ := json.Marshal(todo.Tags)
-	var dueDateVal any
-	if todo.DueDate != nil {
-		dueDateVal = todo.DueDate.UnixMilli()
+	created := now
+	if !todo.CreatedAt.IsZero() {
+		created = todo.CreatedAt.UnixMilli()
 	}
+	args := append([]any{created, now}, itemValues(todo)...)
+
+	res, err := stmt.ExecContext(ctx, args...)
 
-	_, err = stmt.Exec(now, now, todo.Name, boolToInt(todo.Done), string(todo.Priority), dueDateVal, string(tagsJSON))
+	if err != nil {
+		tx.Rollback()
+		return nil, storeErr(err)
+	}
 
+	id, err := res.LastInsertId()
 	if err != nil {
 		tx.Rollback()
-		return 0
+		return nil, storeErr(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, storeErr(err)
 	}
 
-	tx.Commit()
-	return 1
+	return store.GetItem(ctx, int(id))
 }
 
-// DeleteItem Delete items by id.
-func (store *SQLLiteStore) DeleteItem(ids ...int) int {
+// PutItem Store todo as given, ID and timestamps included.
+func (store *SQLLiteStore) PutItem(ctx context.Context, todo internal.Todo) (*internal.Todo, error) {
+	if todo.ID <= 0 {
+		return nil, storage.ErrNoID
+	}
+
+	now := time.Now()
+	if todo.CreatedAt.IsZero() {
+		todo.CreatedAt = now
+	}
+	if todo.UpdatedAt.IsZero() {
+		todo.UpdatedAt = now
+	}
 
-	tx, err := store.db.Begin()
+	var deletedVal any
+	if todo.DeletedAt != nil {
+		deletedVal = todo.DeletedAt.UnixMilli()
+	}
+
+	args := append([]any{todo.ID, todo.CreatedAt.UnixMilli(), todo.UpdatedAt.UnixMilli()}, itemValues(todo)...)
+	args = append(args, deletedVal)
+	_, err := store.db.ExecContext(ctx, `
+		INSERT INTO todo_item (`+fields+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			created_at = excluded.created_at,
+			updated_at = excluded.updated_at,
+			name = excluded.name,
+			done = excluded.done,
+			priority = excluded.priority,
+			due_date = excluded.due_date,
+			tags = excluded.tags,
+			parent_id = excluded.parent_id,
+			recurrence = excluded.recurrence,
+			extras = excluded.extras,
+			project_id = excluded.project_id,
+			due_timed = excluded.due_timed,
+			due_zone = excluded.due_zone,
+			start_date = excluded.start_date,
+			deleted_at = excluded.deleted_at
+	`, args...)
 	if err != nil {
-		return 0
+		return nil, storeErr(err)
 	}
 
-	stmt, err := tx.Prepare("DELETE FROM todo_item WHERE id = ?")
+	return store.getItem(ctx, todo.ID, "1")
+}
+
+// DeleteItem Move items to the trash by id.
+func (store *SQLLiteStore) DeleteItem(ctx context.Context, ids ...int) (int, error) {
+	return store.updateEach(ctx, "UPDATE todo_item SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL",
+		time.Now().UnixMilli(), "", ids)
+}
+
+// RestoreItem Take items out of the trash by id.
+func (store *SQLLiteStore) RestoreItem(ctx context.Context, ids ...int) (int, error) {
+	return store.updateEach(ctx, "UPDATE todo_item SET deleted_at = ? WHERE id = ? AND deleted_at IS NOT NULL",
+		nil, " is not in the trash", ids)
+}
+
+// updateEach Run query with value and each of ids, in one transaction that
+// is rolled back with ErrNotFound, suffixed by missing, if any id matches
+// no row.
+func (store *SQLLiteStore) updateEach(ctx context.Context, query string, value any, missing string, ids []int) (int, error) {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, storeErr(err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, query)
 	if err != nil {
 		tx.Rollback()
-		return 0
+		return 0, storeErr(err)
 	}
 	defer stmt.Close()
 
 	var successCount int
 
 	for _, i := range ids {
-		_, err := stmt.Exec(i)
-		if err == nil {
-			successCount++
+		res, err := stmt.ExecContext(ctx, value, i)
+		if err != nil {
+			tx.Rollback()
+			return 0, storeErr(err)
 		}
+
+		n, _ := res.RowsAffected()
+		if n == 0 {
+			tx.Rollback()
+			return 0, fmt.Errorf("%w: id %d%s", storage.ErrNotFound, i, missing)
+		}
+		successCount += int(n)
 	}
 
-	if successCount == 0 {
-		tx.Rollback()
-		return 0
+	if err := tx.Commit(); err != nil {
+		return 0, storeErr(err)
 	}
+	return successCount, nil
+}
 
-	tx.Commit()
-	return successCount
+// DeleteAllItems Move all items to the trash.
+func (store *SQLLiteStore) DeleteAllItems(ctx context.Context) (int, error) {
+	return store.execCount(ctx, "UPDATE todo_item SET deleted_at = ? WHERE deleted_at IS NULL", time.Now().UnixMilli())
 }
 
-// DeleteAllItems Delete all items from the store.
-func (store *SQLLiteStore) DeleteAllItems() int {
-	tx, _ := store.db.Begin()
-	res, err := tx.Exec("DELETE FROM todo_item")
+// EmptyTrash Delete the items moved to the trash before the given time, or
+// all of them, for good.
+func (store *SQLLiteStore) EmptyTrash(ctx context.Context, before time.Time) (int, error) {
+	if before.IsZero() {
+		return store.execCount(ctx, "DELETE FROM todo_item WHERE deleted_at IS NOT NULL")
+	}
+	return store.execCount(ctx, "DELETE FROM todo_item WHERE deleted_at < ?", before.UnixMilli())
+}
+
+// execCount Run query in a transaction, returning how many rows it changed.
+func (store *SQLLiteStore) execCount(ctx context.Context, query string, args ...any) (int, error) {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, storeErr(err)
+	}
 
+	res, err := tx.ExecContext(ctx, query, args...)
 	if err != nil {
 		tx.Rollback()
-		return 0
+		return 0, storeErr(err)
 	}
 
-	tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return 0, storeErr(err)
+	}
 
 	i, err := res.RowsAffected()
+	if err != nil {
+		return 0, storeErr(err)
+	}
+
+	return int(i), nil
+}
+
+// RecordChanges Add changes to the todo_history table.
+func (store *SQLLiteStore) RecordChanges(ctx context.Context, changes []storage.FieldChange) error {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storeErr(err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO todo_history (item_id, at, actor, field, old_value, new_value)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		tx.Rollback()
+		return storeErr(err)
+	}
+	defer stmt.Close()
+
+	for _, c := range changes {
+		if _, err := stmt.ExecContext(ctx, c.ItemID, c.At.UnixMilli(), c.Actor, c.Field, c.Old, c.New); err != nil {
+			tx.Rollback()
+			return storeErr(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return storeErr(err)
+	}
+	return nil
+}
+
+// ItemHistory The changes made to the item with id, oldest first.
+func (store *SQLLiteStore) ItemHistory(ctx context.Context, id int) ([]storage.FieldChange, error) {
+	return store.queryHistory(ctx, "item_id = ?", id)
+}
+
+// ChangesSince The changes made to any item at or after since, oldest first.
+func (store *SQLLiteStore) ChangesSince(ctx context.Context, since time.Time) ([]storage.FieldChange, error) {
+	return store.queryHistory(ctx, "at >= ?", since.UnixMilli())
+}
+
+func (store *SQLLiteStore) queryHistory(ctx context.Context, condition string, arg any) ([]storage.FieldChange, error) {
+	rows, err := store.db.QueryContext(ctx, `
+		SELECT item_id, at, actor, field, old_value, new_value
+		FROM todo_history
+		WHERE `+condition+`
+		ORDER BY at, id
+	`, arg)
+	if err != nil {
+		return nil, storeErr(err)
+	}
+	defer rows.Close()
+
+	var changes []storage.FieldChange
+	for rows.Next() {
+		var c storage.FieldChange
+		var at int64
+		if err := rows.Scan(&c.ItemID, &at, &c.Actor, &c.Field, &c.Old, &c.New); err != nil {
+			return nil, storeErr(err)
+		}
+		c.At = time.UnixMilli(at).UTC()
+		changes = append(changes, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storeErr(err)
+	}
+	return changes, nil
+}
 
+// Projects Every project in the project table.
+func (store *SQLLiteStore) Projects(ctx context.Context) ([]internal.Project, error) {
+	rows, err := store.db.QueryContext(ctx, `
+		SELECT id, name, parent_id, archived, created_at, updated_at
+		FROM project
+		ORDER BY id
+	`)
 	if err != nil {
-		return 0
+		return nil, storeErr(err)
 	}
+	defer rows.Close()
 
-	return int(i)
+	var projects []internal.Project
+	for rows.Next() {
+		var p internal.Project
+		var parentID sql.NullInt64
+		var archived int
+		var createdAt, updatedAt int64
+		if err := rows.Scan(&p.ID, &p.Name, &parentID, &archived, &createdAt, &updatedAt); err != nil {
+			return nil, storeErr(err)
+		}
+		p.ParentID = int(parentID.Int64)
+		p.Archived = archived != 0
+		p.CreatedAt = time.UnixMilli(createdAt)
+		p.UpdatedAt = time.UnixMilli(updatedAt)
+		projects = append(projects, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storeErr(err)
+	}
+	return projects, nil
+}
+
+// PutProject Add project to the project table, or replace the row with its
+// id.
+func (store *SQLLiteStore) PutProject(ctx context.Context, project internal.Project) (*internal.Project, error) {
+	now := time.Now()
+	if project.CreatedAt.IsZero() {
+		project.CreatedAt = now
+	}
+	if project.UpdatedAt.IsZero() {
+		project.UpdatedAt = now
+	}
+
+	var idVal, parentVal any
+	if project.ID > 0 {
+		idVal = project.ID
+	}
+	if project.ParentID != 0 {
+		parentVal = project.ParentID
+	}
+
+	res, err := store.db.ExecContext(ctx, `
+		INSERT INTO project (id, name, parent_id, archived, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name,
+			parent_id = excluded.parent_id,
+			archived = excluded.archived,
+			created_at = excluded.created_at,
+			updated_at = excluded.updated_at
+	`, idVal, project.Name, parentVal, boolToInt(project.Archived), project.CreatedAt.UnixMilli(), project.UpdatedAt.UnixMilli())
+	if err != nil {
+		return nil, storeErr(err)
+	}
+	if project.ID <= 0 {
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, storeErr(err)
+		}
+		project.ID = int(id)
+	}
+	project.CreatedAt = time.UnixMilli(project.CreatedAt.UnixMilli())
+	project.UpdatedAt = time.UnixMilli(project.UpdatedAt.UnixMilli())
+	return &project, nil
 }
 
 // EditItem Update the item with the given id.
-func (store *SQLLiteStore) EditItem(id int, todo internal.Todo) int {
-	tx, err := store.db.Begin()
+func (store *SQLLiteStore) EditItem(ctx context.Context, id int, todo internal.Todo) (*internal.Todo, error) {
+	tx, err := store.db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0
+		return nil, storeErr(err)
 	}
 
-	stmt, err := tx.Prepare(`
+	stmt, err := tx.PrepareContext(ctx, `
 		UPDATE todo_item
-		SET name = ?, updated_at = ?, done = ?, priority = ?, due_date = ?, tags = ?
-		WHERE id = ?
+		SET updated_at = ?, name = ?, done = ?, priority = ?, due_date = ?, tags = ?, parent_id = ?, recurrence = ?, extras = ?, project_id = ?, due_timed = ?, due_zone = ?, start_date = ?
+		WHERE id = ? AND deleted_at IS NULL
 	`)
 	if err != nil {
 		tx.Rollback()
-		return 0
+		return nil, storeErr(err)
 	}
 	defer stmt.Close()
 
 	now := time.Now().UnixMilli()
-	tagsJSON, _ := json.Marshal(todo.Tags)
-	var dueDateVal any
-	if todo.DueDate != nil {
-		dueDateVal = todo.DueDate.UnixMilli()
-	}
+	args := append([]any{now}, itemValues(todo)...)
 
-	res, err := stmt.Exec(todo.Name, now, boolToInt(todo.Done), string(todo.Priority), dueDateVal, string(tagsJSON), id)
+	res, err := stmt.ExecContext(ctx, append(args, id)...)
 	if err != nil {
 		tx.Rollback()
-		return 0
+		return nil, storeErr(err)
 	}
 
-	tx.Commit()
 	i, err := res.RowsAffected()
-
 	if err != nil {
-		return 0
+		tx.Rollback()
+		return nil, storeErr(err)
+	}
+	if i == 0 {
+		tx.Rollback()
+		return nil, fmt.Errorf("%w: id %d", storage.ErrNotFound, id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, storeErr(err)
 	}
 
-	return int(i)
+	return store.GetItem(ctx, id)
 }
 
 func findMaxLen(db *sql.DB) int {
@@ -301,17 +942,71 @@ func findMaxLen(db *sql.DB) int {
 }
 
 func dbErr(err error) error {
-	return fmt.Errorf("The path to the DB provided does not exists %e", err)
+	return fmt.Errorf("%w: unable to open the DB: %w", storage.ErrBackendUnavailable, err)
 }
 
-func mapToTodoItems(rows *sql.Rows, items *[]internal.Todo) {
+// storeErr Classify a database error as one of the storage sentinel errors.
+func storeErr(err error) error {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+
+	switch sqliteErr.Code {
+	case sqlite3.ErrConstraint:
+		return fmt.Errorf("%w: %w", storage.ErrConflict, err)
+	case sqlite3.ErrBusy, sqlite3.ErrLocked, sqlite3.ErrReadonly, sqlite3.ErrIoErr,
+		sqlite3.ErrCorrupt, sqlite3.ErrFull, sqlite3.ErrCantOpen, sqlite3.ErrPerm,
+		sqlite3.ErrNotADB:
+		return fmt.Errorf("%w: %w", storage.ErrBackendUnavailable, err)
+	}
+	return err
+}
+
+// itemValues The column values of todo, in the order name, done, priority,
+// due_date, tags, parent_id, recurrence, extras, project_id, due_timed,
+// due_zone, start_date.
+func itemValues(todo internal.Todo) []any {
+	tagsJSON, _ := json.Marshal(todo.Tags)
+
+	var extrasJSON []byte
+	if len(todo.Extras) > 0 {
+		extrasJSON, _ = json.Marshal(todo.Extras)
+	}
+
+	var dueDateVal any
+	if todo.DueDate != nil {
+		dueDateVal = todo.DueDate.UnixMilli()
+	}
+
+	var startDateVal any
+	if todo.StartDate != nil {
+		startDateVal = todo.StartDate.UnixMilli()
+	}
+
+	var parentVal any
+	if todo.ParentID != 0 {
+		parentVal = todo.ParentID
+	}
+
+	var projectVal any
+	if todo.Project != 0 {
+		projectVal = todo.Project
+	}
+
+	return []any{todo.Name, boolToInt(todo.Done), string(todo.Priority), dueDateVal, string(tagsJSON), parentVal, todo.Recurrence, string(extrasJSON), projectVal,
+		boolToInt(todo.DueTimed), todo.DueZone, startDateVal}
+}
+
+func mapToTodoItems(rows *sql.Rows, items *[]internal.Todo) error {
 	for rows.Next() {
 		item, err := mapToTodoItem(rows.Scan)
-
-		if err == nil {
-			*items = append(*items, *item)
+		if err != nil {
+			return err
 		}
+		*items = append(*items, *item)
 	}
+	return rows.Err()
 }
 
 func mapToTodoItem(s rowScan) (*internal.Todo, error) {
@@ -323,8 +1018,16 @@ func mapToTodoItem(s rowScan) (*internal.Todo, error) {
 	var priority string
 	var dueDateMs sql.NullInt64
 	var tagsJSON string
+	var parentID sql.NullInt64
+	var recurrence string
+	var extrasJSON string
+	var projectID sql.NullInt64
+	var dueTimed int
+	var dueZone string
+	var startDateMs sql.NullInt64
+	var deletedAtMs sql.NullInt64
 
-	err := s(&id, &createdAt, &updatedAt, &name, &done, &priority, &dueDateMs, &tagsJSON)
+	err := s(&id, &createdAt, &updatedAt, &name, &done, &priority, &dueDateMs, &tagsJSON, &parentID, &recurrence, &extrasJSON, &projectID, &dueTimed, &dueZone, &startDateMs, &deletedAtMs)
 
 	if err != nil {
 		return nil, err
@@ -335,21 +1038,46 @@ func mapToTodoItem(s rowScan) (*internal.Todo, error) {
 		json.Unmarshal([]byte(tagsJSON), &tags)
 	}
 
+	var extras map[string]any
+	if extrasJSON != "" {
+		json.Unmarshal([]byte(extrasJSON), &extras)
+	}
+
 	var dueDate *time.Time
 	if dueDateMs.Valid {
-		t := time.UnixMilli(dueDateMs.Int64)
+		t := time.UnixMilli(dueDateMs.Int64).UTC()
 		dueDate = &t
 	}
 
+	var startDate *time.Time
+	if startDateMs.Valid {
+		t := time.UnixMilli(startDateMs.Int64)
+		startDate = &t
+	}
+
+	var deletedAt *time.Time
+	if deletedAtMs.Valid {
+		t := time.UnixMilli(deletedAtMs.Int64)
+		deletedAt = &t
+	}
+
 	return &internal.Todo{
-		ID:        id,
-		CreatedAt: time.UnixMilli(createdAt),
-		UpdatedAt: time.UnixMilli(updatedAt),
-		Name:      name,
-		Done:      done != 0,
-		Priority:  internal.Priority(priority),
-		DueDate:   dueDate,
-		Tags:      tags,
+		ID:         id,
+		CreatedAt:  time.UnixMilli(createdAt),
+		UpdatedAt:  time.UnixMilli(updatedAt),
+		Name:       name,
+		Done:       done != 0,
+		Priority:   internal.Priority(priority),
+		DueDate:    dueDate,
+		DueTimed:   dueTimed != 0,
+		DueZone:    dueZone,
+		StartDate:  startDate,
+		Tags:       tags,
+		ParentID:   int(parentID.Int64),
+		Project:    int(projectID.Int64),
+		Recurrence: recurrence,
+		Extras:     extras,
+		DeletedAt:  deletedAt,
 	}, nil
 }
 
@@ -359,12 +1087,3 @@ func boolToInt(b bool) int {
 	}
 	return 0
 }
-
-func hasTag(tags []string, tag string) bool {
-	for _, tg := range tags {
-		if tg == tag {
-			return true
-		}
-	}
-	return false
-}
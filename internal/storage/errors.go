@@ -0,0 +1,21 @@
+package storage
+
+import "errors"
+
+// Sentinel errors returned (usually wrapped) by every TodoStore implementation.
+// Match them with errors.Is.
+var (
+	// ErrNotFound The requested item does not exist in the store.
+	ErrNotFound = errors.New("item not found")
+
+	// ErrConflict The change clashes with the current state of the store,
+	// e.g. a duplicate key or a concurrent modification.
+	ErrConflict = errors.New("conflicting change")
+
+	// ErrBackendUnavailable The underlying file, database or service could
+	// not be read from or written to.
+	ErrBackendUnavailable = errors.New("storage backend unavailable")
+
+	// ErrNoID PutItem was given an item without an ID.
+	ErrNoID = errors.New("item has no id")
+)
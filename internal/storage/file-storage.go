@@ -1,11 +1,17 @@
 package storage
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 	"unicode/utf8"
 
@@ -20,21 +26,28 @@ type LocalFileStore struct {
 	MaxId     int
 }
 
-func NewLocalFileStore(filePath string) *LocalFileStore {
+func NewLocalFileStore(filePath string) (*LocalFileStore, error) {
 
 	items := make(map[int]t.Todo)
 
-	size, maxId, _ := loadItemsFromFile(filePath, items)
+	size, maxId, err := loadItemsFromFile(filePath, items)
+	if err != nil {
+		return nil, err
+	}
 
 	return &LocalFileStore{
 		items:     items,
 		FilePath:  filePath,
 		ItemCount: size,
 		MaxId:     maxId,
-	}
+	}, nil
 }
 
-func (store *LocalFileStore) GetAllItems(opts ListOptions) *t.TodoCollection {
+func (store *LocalFileStore) GetAllItems(ctx context.Context, opts ListOptions) (*t.TodoCollection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	var results []t.Todo
 	var maxLength int
 	now := time.Now()
@@ -42,97 +55,198 @@ func (store *LocalFileStore) GetAllItems(opts ListOptions) *t.TodoCollection {
 	for _, v := range store.items {
 		setUpdatedAtIfRequired(&v)
 
-		if !opts.ShowDone && !opts.OnlyDone && v.Done {
-			continue
-		}
-		if opts.OnlyDone && !v.Done {
-			continue
-		}
-		if opts.Priority != "" && string(v.Priority) != opts.Priority {
-			continue
-		}
-		if opts.Overdue && (v.DueDate == nil || !v.DueDate.Before(now)) {
-			continue
-		}
-		if opts.Tag != "" && !hasTag(v.Tags, opts.Tag) {
+		if !opts.Match(v, now) {
 			continue
 		}
 
+		results = append(results, v)
+	}
+
+	results, next, err := Paginate(results, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range results {
 		nameLen := utf8.RuneCountInString(v.Name)
 		if nameLen > maxLength {
 			maxLength = nameLen
 		}
-
-		results = append(results, v)
 	}
 
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].ID < results[j].ID
-	})
-
 	return &t.TodoCollection{
 		Items:         results,
 		Size:          len(results),
 		MaxLengthItem: maxLength,
-	}
+		NextCursor:    next,
+	}, nil
 }
 
-func (store *LocalFileStore) GetItem(id int) *t.Todo {
+func (store *LocalFileStore) GetItem(ctx context.Context, id int) (*t.Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	item, exists := store.items[id]
-	if !exists {
-		return nil
+	if !exists || item.DeletedAt != nil {
+		return nil, fmt.Errorf("%w: id %d", ErrNotFound, id)
 	}
 	setUpdatedAtIfRequired(&item)
-	return &item
+	return &item, nil
 }
 
-func (store *LocalFileStore) AddItem(todo t.Todo) int {
-	defer saveItems(store.FilePath, store.items)
-	store.MaxId++
+func (store *LocalFileStore) AddItem(ctx context.Context, todo t.Todo) (*t.Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	now := time.Now()
-	todo.ID = store.MaxId
-	todo.CreatedAt = now
+	todo.ID = store.MaxId + 1
+	todo.DeletedAt = nil
+	if todo.CreatedAt.IsZero() {
+		todo.CreatedAt = now
+	}
 	todo.UpdatedAt = now
-	store.items[store.MaxId] = todo
-	return 1
+	items := maps.Clone(store.items)
+	items[todo.ID] = todo
+
+	if err := store.save(items); err != nil {
+		return nil, err
+	}
+	store.MaxId = todo.ID
+	return &todo, nil
 }
 
-func (store *LocalFileStore) DeleteItem(ids ...int) int {
-	defer saveItems(store.FilePath, store.items)
+func (store *LocalFileStore) DeleteItem(ctx context.Context, ids ...int) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		if item, exists := store.items[id]; !exists || item.DeletedAt != nil {
+			return 0, fmt.Errorf("%w: id %d", ErrNotFound, id)
+		}
+	}
 
-	var count int
+	now := time.Now()
+	items := maps.Clone(store.items)
 	for _, id := range ids {
-		count++
-		if _, exists := store.items[id]; !exists {
-			return -1
+		item := items[id]
+		item.DeletedAt = &now
+		items[id] = item
+	}
+
+	if err := store.save(items); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+func (store *LocalFileStore) DeleteAllItems(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	s := 0
+	items := maps.Clone(store.items)
+	for id, item := range items {
+		if item.DeletedAt == nil {
+			item.DeletedAt = &now
+			items[id] = item
+			s++
+		}
+	}
+
+	if err := store.save(items); err != nil {
+		return 0, err
+	}
+	return s, nil
+}
+
+func (store *LocalFileStore) RestoreItem(ctx context.Context, ids ...int) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		if item, exists := store.items[id]; !exists || item.DeletedAt == nil {
+			return 0, fmt.Errorf("%w: id %d is not in the trash", ErrNotFound, id)
 		}
+	}
 
-		delete(store.items, id)
+	items := maps.Clone(store.items)
+	for _, id := range ids {
+		item := items[id]
+		item.DeletedAt = nil
+		items[id] = item
 	}
 
-	return count
+	if err := store.save(items); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
 }
 
-func (store *LocalFileStore) DeleteAllItems() int {
-	defer saveItems(store.FilePath, store.items)
+func (store *LocalFileStore) EmptyTrash(ctx context.Context, before time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s := 0
+	items := maps.Clone(store.items)
+	for id, item := range items {
+		if item.DeletedAt != nil && (before.IsZero() || item.DeletedAt.Before(before)) {
+			delete(items, id)
+			s++
+		}
+	}
 
-	s := len(store.items)
-	store.items = make(map[int]t.Todo)
-	store.ItemCount = 0
-	return s
+	if err := store.save(items); err != nil {
+		return 0, err
+	}
+	return s, nil
 }
 
-func (store *LocalFileStore) EditItem(id int, todo t.Todo) int {
-	defer saveItems(store.FilePath, store.items)
-	if _, exists := store.items[id]; !exists {
-		return 0
+func (store *LocalFileStore) PutItem(ctx context.Context, todo t.Todo) (*t.Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if todo.ID <= 0 {
+		return nil, ErrNoID
+	}
+
+	now := time.Now()
+	if todo.CreatedAt.IsZero() {
+		todo.CreatedAt = now
+	}
+	if todo.UpdatedAt.IsZero() {
+		todo.UpdatedAt = now
+	}
+	items := maps.Clone(store.items)
+	items[todo.ID] = todo
+
+	if err := store.save(items); err != nil {
+		return nil, err
+	}
+	if todo.ID > store.MaxId {
+		store.MaxId = todo.ID
+	}
+	return &todo, nil
+}
+
+func (store *LocalFileStore) EditItem(ctx context.Context, id int, todo t.Todo) (*t.Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if item, exists := store.items[id]; !exists || item.DeletedAt != nil {
+		return nil, fmt.Errorf("%w: id %d", ErrNotFound, id)
 	}
 
 	todo.ID = id
+	todo.DeletedAt = nil
 	todo.UpdatedAt = time.Now()
-	store.items[id] = todo
+	items := maps.Clone(store.items)
+	items[id] = todo
 
-	return 1
+	if err := store.save(items); err != nil {
+		return nil, err
+	}
+	return &todo, nil
 }
 
 func hasTag(tags []string, tag string) bool {
@@ -150,44 +264,78 @@ func setUpdatedAtIfRequired(item *t.Todo) {
 	}
 }
 
-func saveItems(path string, items map[int]t.Todo) {
-
-	file, err := getFile(path)
-
-	if err != nil {
-		fmt.Println(err)
+// save Write items to the file, and only once it is written make them the
+// store's, so that a failed save leaves the store as it was.
+func (store *LocalFileStore) save(items map[int]t.Todo) error {
+	if err := saveItems(store.FilePath, items); err != nil {
+		return err
 	}
+	store.items = items
+	store.ItemCount = len(items)
+	return nil
+}
 
-	file.Truncate(0)
-	file.Seek(0, 0)
+func saveItems(path string, items map[int]t.Todo) error {
 
-	var tmp []t.Todo
+	tmp := make([]t.Todo, 0, len(items))
 	for _, v := range items {
 		tmp = append(tmp, v)
 	}
+	sort.Slice(tmp, func(i, j int) bool {
+		return tmp[i].ID < tmp[j].ID
+	})
 
-	j := json.NewEncoder(file)
-	if j.Encode(&tmp) != nil {
-		fmt.Println("There was an error saving the todo list.", err)
+	if err := saveJSON(path, tmp); err != nil {
+		return fmt.Errorf("%w: unable to save todo list: %w", ErrBackendUnavailable, err)
 	}
+	return nil
 }
 
-func loadItemsFromFile(path string, items map[int]t.Todo) (int, int, error) {
+// saveJSON Write v to path as indented JSON, replacing the file in one step.
+func saveJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	// CreateTemp makes the file private; keep the mode a plain file would get.
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
 
-	loadErr := errors.New("unable to load items from file")
+func loadItemsFromFile(path string, items map[int]t.Todo) (int, int, error) {
 
-	f, err := getFile(path)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	}
 	if err != nil {
-		fmt.Println(loadErr, err)
-		return 0, 0, loadErr
+		return 0, 0, fmt.Errorf("%w: unable to load items from file: %w", ErrBackendUnavailable, err)
 	}
-	defer f.Close()
 
-	var tmp []t.Todo
+	// A freshly created file has no content yet.
+	if len(bytes.TrimSpace(data)) == 0 {
+		return 0, 0, nil
+	}
 
-	decode := json.NewDecoder(f)
-	if decode.Decode(&tmp) != nil {
-		return 0, 0, loadErr
+	var tmp []t.Todo
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return 0, 0, fmt.Errorf("unable to load items from file %s: %w", path, err)
 	}
 
 	var size, maxId int
@@ -206,10 +354,143 @@ func loadItemsFromFile(path string, items map[int]t.Todo) (int, int, error) {
 	return size, maxId, nil
 }
 
-func getFile(path string) (*os.File, error) {
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0755)
+// HistoryPath The sidecar file the history of the store's items is kept
+// in, next to its items: todo.history.jsonl for todo.json. Each line is
+// one FieldChange, so changes are only ever appended.
+func (store *LocalFileStore) HistoryPath() string {
+	return strings.TrimSuffix(store.FilePath, ".json") + ".history.jsonl"
+}
+
+// RecordChanges Append changes to the history file.
+func (store *LocalFileStore) RecordChanges(ctx context.Context, changes []FieldChange) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, change := range changes {
+		if err := enc.Encode(change); err != nil {
+			return fmt.Errorf("unable to encode history: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(store.HistoryPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("%w: unable to save history: %w", ErrBackendUnavailable, err)
+	}
+	defer f.Close()
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("%w: unable to save history: %w", ErrBackendUnavailable, err)
+	}
+	return f.Close()
+}
+
+func (store *LocalFileStore) ItemHistory(ctx context.Context, id int) ([]FieldChange, error) {
+	return store.readHistory(ctx, func(change FieldChange) bool { return change.ItemID == id })
+}
+
+func (store *LocalFileStore) ChangesSince(ctx context.Context, since time.Time) ([]FieldChange, error) {
+	return store.readHistory(ctx, func(change FieldChange) bool { return !change.At.Before(since) })
+}
+
+// readHistory The changes in the history file that keep selects, in the
+// order they were made.
+func (store *LocalFileStore) readHistory(ctx context.Context, keep func(FieldChange) bool) ([]FieldChange, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(store.HistoryPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
 	if err != nil {
+		return nil, fmt.Errorf("%w: unable to load history: %w", ErrBackendUnavailable, err)
+	}
+	defer f.Close()
+
+	var changes []FieldChange
+	dec := json.NewDecoder(f)
+	for {
+		var change FieldChange
+		err := dec.Decode(&change)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to load history from file %s: %w", store.HistoryPath(), err)
+		}
+		if keep(change) {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// ProjectsPath The sidecar file the store's projects are kept in, next to
+// its items: todo.projects.json for todo.json.
+func (store *LocalFileStore) ProjectsPath() string {
+	return strings.TrimSuffix(store.FilePath, ".json") + ".projects.json"
+}
+
+func (store *LocalFileStore) Projects(ctx context.Context) ([]t.Project, error) {
+	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
-	return f, nil
+
+	data, err := os.ReadFile(store.ProjectsPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to load projects: %w", ErrBackendUnavailable, err)
+	}
+
+	var projects []t.Project
+	if err := json.Unmarshal(data, &projects); err != nil {
+		return nil, fmt.Errorf("unable to load projects from file %s: %w", store.ProjectsPath(), err)
+	}
+	return projects, nil
+}
+
+func (store *LocalFileStore) PutProject(ctx context.Context, project t.Project) (*t.Project, error) {
+	projects, err := store.Projects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if project.CreatedAt.IsZero() {
+		project.CreatedAt = now
+	}
+	if project.UpdatedAt.IsZero() {
+		project.UpdatedAt = now
+	}
+
+	replaced := false
+	maxId := 0
+	for i, p := range projects {
+		if p.ID == project.ID {
+			projects[i] = project
+			replaced = true
+		}
+		if p.ID > maxId {
+			maxId = p.ID
+		}
+	}
+	if !replaced {
+		if project.ID <= 0 {
+			project.ID = maxId + 1
+		}
+		projects = append(projects, project)
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		return projects[i].ID < projects[j].ID
+	})
+
+	if err := saveJSON(store.ProjectsPath(), projects); err != nil {
+		return nil, fmt.Errorf("%w: unable to save projects: %w", ErrBackendUnavailable, err)
+	}
+	return &project, nil
 }
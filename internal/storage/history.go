@@ -0,0 +1,359 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/filter"
+	"github.com/tcooper-uk/go-todo/internal/search"
+)
+
+// FieldChange One field of an item changed by one call, as kept in the
+// history of the store it was made in.
+type FieldChange struct {
+	ItemID int       `json:"item_id"`
+	At     time.Time `json:"at"`
+	// Actor Who made the change: the user running the command, or the
+	// client of the server it was made through.
+	Actor string `json:"actor"`
+	// Field The item's JSON key for the field, e.g. "priority".
+	Field string `json:"field"`
+	// Old, New The field's value before and after the change, as text,
+	// empty when unset.
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// HistoryRecorder Implemented by stores that keep a history of the changes
+// made to their items, for WithHistory to add to.
+type HistoryRecorder interface {
+	// RecordChanges Add changes to the history.
+	RecordChanges(ctx context.Context, changes []FieldChange) error
+}
+
+// History Implemented by stores whose history can be read.
+type History interface {
+	// ItemHistory The changes made to the item with id, oldest first.
+	ItemHistory(ctx context.Context, id int) ([]FieldChange, error)
+	// ChangesSince The changes made to any item at or after since, oldest
+	// first.
+	ChangesSince(ctx context.Context, since time.Time) ([]FieldChange, error)
+}
+
+// errNoHistory Returned when history is asked of a store that keeps none.
+var errNoHistory = errors.New("this backend keeps no history of changes")
+
+// ItemHistory The changes made to the item with id in store, oldest first.
+func ItemHistory(ctx context.Context, store TodoStore, id int) ([]FieldChange, error) {
+	h, ok := store.(History)
+	if !ok {
+		return nil, errNoHistory
+	}
+	return h.ItemHistory(ctx, id)
+}
+
+// ChangesSince The changes made to the items of store at or after since,
+// oldest first.
+func ChangesSince(ctx context.Context, store TodoStore, since time.Time) ([]FieldChange, error) {
+	h, ok := store.(History)
+	if !ok {
+		return nil, errNoHistory
+	}
+	return h.ChangesSince(ctx, since)
+}
+
+// ActorHeader The HTTP header a RemoteStore names the actor of its
+// requests in, for the server to record their changes as made by.
+const ActorHeader = "Todo-Actor"
+
+type actorKey struct{}
+
+// WithActor A context whose changes are recorded as made by actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor Who changes made with ctx are recorded as made by, or "unknown".
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return "unknown"
+}
+
+// historyFields The fields whose changes are recorded, in the order they
+// are listed. The timestamps change with every edit, so are left out,
+// bar deleted_at, which records moves to and from the trash.
+var historyFields = []string{"name", "done", "priority", "due_date", "start_date", "tags", "parent_id", "project", "recurrence", "extras", "deleted_at"}
+
+// fieldValues The text of each recorded field of item that is set.
+func fieldValues(item *internal.Todo) map[string]string {
+	values := map[string]string{}
+	if item == nil {
+		return values
+	}
+	set := func(field, value string) {
+		if value != "" {
+			values[field] = value
+		}
+	}
+
+	set("name", item.Name)
+	if item.Done {
+		set("done", "true")
+	}
+	set("priority", string(item.Priority))
+	if item.DueDate != nil && item.DueTimed {
+		set("due_date", item.DueDate.In(item.DueLocation(time.UTC)).Format(time.RFC3339))
+	} else if item.DueDate != nil {
+		set("due_date", item.DueDate.UTC().Format("2006-01-02"))
+	}
+	if item.StartDate != nil {
+		set("start_date", item.StartDate.UTC().Format(time.RFC3339))
+	}
+	set("tags", strings.Join(item.Tags, ","))
+	if item.ParentID != 0 {
+		set("parent_id", strconv.Itoa(item.ParentID))
+	}
+	if item.Project != 0 {
+		set("project", strconv.Itoa(item.Project))
+	}
+	set("recurrence", item.Recurrence)
+	if len(item.Extras) > 0 {
+		// Map keys are encoded in order, so equal extras read the same.
+		data, _ := json.Marshal(item.Extras)
+		set("extras", string(data))
+	}
+	if item.DeletedAt != nil {
+		set("deleted_at", historyTime(*item.DeletedAt))
+	}
+	return values
+}
+
+// historyTime A due day as YYYY-MM-DD, and any other time in RFC 3339.
+func historyTime(t time.Time) string {
+	t = t.UTC()
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format(time.RFC3339)
+}
+
+// fieldChanges The fields that differ between before and after, either of
+// which is nil for an item that did not exist.
+func fieldChanges(ctx context.Context, at time.Time, id int, before, after *internal.Todo) []FieldChange {
+	from, to := fieldValues(before), fieldValues(after)
+	var changes []FieldChange
+	for _, field := range historyFields {
+		if from[field] != to[field] {
+			changes = append(changes, FieldChange{
+				ItemID: id, At: at.UTC(), Actor: Actor(ctx), Field: field, Old: from[field], New: to[field],
+			})
+		}
+	}
+	return changes
+}
+
+// historyStore Records the changes made through the wrapped store in its
+// history.
+type historyStore struct {
+	store    TodoStore
+	recorder HistoryRecorder
+}
+
+// WithHistory Wrap store so that every field each call changes is added to
+// its history, along with who changed it, taken from the context with
+// Actor. Stores that keep no history of their own, like RemoteStore,
+// whose server records it, are returned unchanged.
+func WithHistory(store TodoStore) TodoStore {
+	recorder, ok := store.(HistoryRecorder)
+	if !ok {
+		return store
+	}
+	return &historyStore{store: store, recorder: recorder}
+}
+
+// record Add the changes between the before and after of each item.
+func (s *historyStore) record(ctx context.Context, at time.Time, befores, afters map[int]*internal.Todo, ids []int) error {
+	var changes []FieldChange
+	for _, id := range ids {
+		changes = append(changes, fieldChanges(ctx, at, id, befores[id], afters[id])...)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return s.recorder.RecordChanges(ctx, changes)
+}
+
+// lookup The item with id as it is now, in the trash or not, or nil if
+// there is none.
+func (s *historyStore) lookup(ctx context.Context, id int) (*internal.Todo, error) {
+	item, err := s.store.GetItem(ctx, id)
+	if !errors.Is(err, ErrNotFound) {
+		return item, err
+	}
+
+	trashed, err := s.store.GetAllItems(ctx, ListOptions{
+		Trashed:  true,
+		ShowDone: true,
+		Filter:   filter.Cond{Field: filter.FieldID, Op: filter.OpEqual, Value: id},
+	})
+	if err != nil || len(trashed.Items) == 0 {
+		return nil, err
+	}
+	return &trashed.Items[0], nil
+}
+
+// lookupAll The items with ids as they are now, keyed by id.
+func (s *historyStore) lookupAll(ctx context.Context, ids []int) (map[int]*internal.Todo, error) {
+	items := make(map[int]*internal.Todo, len(ids))
+	for _, id := range ids {
+		item, err := s.lookup(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		items[id] = item
+	}
+	return items, nil
+}
+
+func (s *historyStore) GetAllItems(ctx context.Context, opts ListOptions) (*internal.TodoCollection, error) {
+	return s.store.GetAllItems(ctx, opts)
+}
+
+func (s *historyStore) GetItem(ctx context.Context, id int) (*internal.Todo, error) {
+	return s.store.GetItem(ctx, id)
+}
+
+func (s *historyStore) AddItem(ctx context.Context, todo internal.Todo) (*internal.Todo, error) {
+	item, err := s.store.AddItem(ctx, todo)
+	if err != nil {
+		return nil, err
+	}
+	return item, s.record(ctx, item.UpdatedAt, nil, map[int]*internal.Todo{item.ID: item}, []int{item.ID})
+}
+
+func (s *historyStore) DeleteItem(ctx context.Context, ids ...int) (int, error) {
+	befores, err := s.lookupAll(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := s.store.DeleteItem(ctx, ids...)
+	if err != nil {
+		return count, err
+	}
+	return count, s.recordTrashed(ctx, befores, ids)
+}
+
+func (s *historyStore) DeleteAllItems(ctx context.Context) (int, error) {
+	all, err := s.store.GetAllItems(ctx, ListOptions{ShowDone: true, IncludeDeferred: true})
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := s.store.DeleteAllItems(ctx)
+	if err != nil {
+		return count, err
+	}
+	befores := make(map[int]*internal.Todo, len(all.Items))
+	ids := make([]int, len(all.Items))
+	for i := range all.Items {
+		befores[all.Items[i].ID] = &all.Items[i]
+		ids[i] = all.Items[i].ID
+	}
+	return count, s.recordTrashed(ctx, befores, ids)
+}
+
+// recordTrashed Record the items given by ids as moved to the trash now.
+func (s *historyStore) recordTrashed(ctx context.Context, befores map[int]*internal.Todo, ids []int) error {
+	now := time.Now().UTC()
+	afters := make(map[int]*internal.Todo, len(ids))
+	for _, id := range ids {
+		if before := befores[id]; before != nil {
+			after := *before
+			after.DeletedAt = &now
+			afters[id] = &after
+		}
+	}
+	return s.record(ctx, now, befores, afters, ids)
+}
+
+func (s *historyStore) RestoreItem(ctx context.Context, ids ...int) (int, error) {
+	befores, err := s.lookupAll(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := s.store.RestoreItem(ctx, ids...)
+	if err != nil {
+		return count, err
+	}
+	afters, err := s.lookupAll(ctx, ids)
+	if err != nil {
+		return count, err
+	}
+	return count, s.record(ctx, time.Now().UTC(), befores, afters, ids)
+}
+
+// EmptyTrash Delete items in the trash for good. Their history is kept,
+// with nothing added to it, as it already ends with them being deleted.
+func (s *historyStore) EmptyTrash(ctx context.Context, before time.Time) (int, error) {
+	return s.store.EmptyTrash(ctx, before)
+}
+
+func (s *historyStore) PutItem(ctx context.Context, todo internal.Todo) (*internal.Todo, error) {
+	var before *internal.Todo
+	if todo.ID > 0 {
+		var err error
+		if before, err = s.lookup(ctx, todo.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	item, err := s.store.PutItem(ctx, todo)
+	if err != nil {
+		return nil, err
+	}
+	return item, s.record(ctx, time.Now().UTC(),
+		map[int]*internal.Todo{item.ID: before}, map[int]*internal.Todo{item.ID: item}, []int{item.ID})
+}
+
+func (s *historyStore) EditItem(ctx context.Context, id int, todo internal.Todo) (*internal.Todo, error) {
+	before, err := s.store.GetItem(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := s.store.EditItem(ctx, id, todo)
+	if err != nil {
+		return nil, err
+	}
+	return item, s.record(ctx, item.UpdatedAt,
+		map[int]*internal.Todo{id: before}, map[int]*internal.Todo{id: item}, []int{id})
+}
+
+func (s *historyStore) Search(ctx context.Context, expr search.Expr, opts ListOptions) ([]search.Result, error) {
+	return searchStore(ctx, s.store, expr, opts)
+}
+
+func (s *historyStore) ItemHistory(ctx context.Context, id int) ([]FieldChange, error) {
+	return ItemHistory(ctx, s.store, id)
+}
+
+func (s *historyStore) ChangesSince(ctx context.Context, since time.Time) ([]FieldChange, error) {
+	return ChangesSince(ctx, s.store, since)
+}
+
+func (s *historyStore) Projects(ctx context.Context) ([]internal.Project, error) {
+	return Projects(ctx, s.store)
+}
+
+func (s *historyStore) PutProject(ctx context.Context, project internal.Project) (*internal.Project, error) {
+	return putProject(ctx, s.store, project)
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/tcooper-uk/go-todo/internal"
+)
+
+// Import Add items read from elsewhere to store as new items. Their IDs
+// only serve to link subtasks to their parents among items: each item is
+// given a new ID by the store, and ParentID is changed to match, or cleared
+// if the parent is not among items. Timestamps that are set are kept.
+// Parents are added before their
+// subtasks, and otherwise in the order given; the items added are returned
+// in that order.
+func Import(ctx context.Context, store TodoStore, items []internal.Todo) ([]internal.Todo, error) {
+	byID := make(map[int]internal.Todo, len(items))
+	for _, item := range items {
+		if item.ID != 0 {
+			byID[item.ID] = item
+		}
+	}
+
+	// Keep the order items came in, bar moving subtasks after their parents.
+	ordered := append([]internal.Todo(nil), items...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return depth(ordered[i], byID) < depth(ordered[j], byID)
+	})
+
+	newIDs := make(map[int]int, len(items))
+	added := make([]internal.Todo, 0, len(items))
+	for _, item := range ordered {
+		oldID := item.ID
+		item.ID = 0
+		item.ParentID = newIDs[item.ParentID]
+
+		stored, err := store.AddItem(ctx, item)
+		if err == nil && !item.UpdatedAt.IsZero() {
+			// Keep when the item was last changed, e.g. when it was done.
+			stored.UpdatedAt = item.UpdatedAt
+			stored, err = store.PutItem(ctx, *stored)
+		}
+		if err != nil {
+			return added, fmt.Errorf("importing %q: %w", item.Name, err)
+		}
+		if oldID != 0 {
+			newIDs[oldID] = stored.ID
+		}
+		added = append(added, *stored)
+	}
+	return added, nil
+}
@@ -0,0 +1,427 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/search"
+)
+
+// maxOperations How many operations a journal keeps to undo; older ones
+// are forgotten.
+const maxOperations = 100
+
+// Journal The operations made through a journalStore, kept so that they can
+// be undone and redone later, by the same process or another one.
+type Journal struct {
+	// Done Operations that can be undone, oldest first.
+	Done []*Operation `json:"done"`
+	// Undone Operations that have been undone and can be redone, the most
+	// recently undone last. Any new operation clears them.
+	Undone []*Operation `json:"undone"`
+
+	path string
+}
+
+// Operation The changes one command made to the store.
+type Operation struct {
+	// Command What made the changes, e.g. "rm 3".
+	Command string    `json:"command"`
+	At      time.Time `json:"at"`
+	Changes []Change  `json:"changes"`
+}
+
+// Change One item before and after a call that changed it. Before is nil
+// for an item that was added or restored, and After for one that was
+// deleted, whether to the trash or for good.
+type Change struct {
+	Before *internal.Todo `json:"before"`
+	After  *internal.Todo `json:"after"`
+}
+
+func (c Change) id() int {
+	if c.After != nil {
+		return c.After.ID
+	}
+	return c.Before.ID
+}
+
+// LoadJournal Read the journal saved at path, or an empty one if there is
+// none yet.
+func LoadJournal(path string) (*Journal, error) {
+	journal := &Journal{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return journal, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, journal); err != nil {
+		return nil, fmt.Errorf("invalid journal in %s: %w", path, err)
+	}
+	return journal, nil
+}
+
+// Save Write the journal back to where it was loaded from, replacing it in
+// one step.
+func (j *Journal) Save() error {
+	if err := os.MkdirAll(filepath.Dir(j.path), os.ModePerm); err != nil {
+		return err
+	}
+	return saveJSON(j.path, j)
+}
+
+// begin Start recording a new operation, which can no longer be redone
+// past.
+func (j *Journal) begin(op *Operation) {
+	j.Done = append(j.Done, op)
+	if len(j.Done) > maxOperations {
+		j.Done = j.Done[len(j.Done)-maxOperations:]
+	}
+	j.Undone = nil
+}
+
+// Undo Revert the last n operations, most recent first, putting back every
+// item as it was, ID and timestamps included. store must not be journaled
+// itself. Returns the operations undone; if an item has changed since its
+// operation, that operation and any before it are left alone and
+// ErrConflict is returned.
+func (j *Journal) Undo(ctx context.Context, store TodoStore, n int) ([]*Operation, error) {
+	var undone []*Operation
+	for ; n > 0 && len(j.Done) > 0; n-- {
+		op := j.Done[len(j.Done)-1]
+		if err := apply(ctx, store, op, true); err != nil {
+			return undone, err
+		}
+		j.Done = j.Done[:len(j.Done)-1]
+		j.Undone = append(j.Undone, op)
+		undone = append(undone, op)
+		if err := j.Save(); err != nil {
+			return undone, err
+		}
+	}
+	return undone, nil
+}
+
+// Redo Make the last n undone operations again, the most recently undone
+// first. Returns the operations redone, stopping with ErrConflict as Undo
+// does.
+func (j *Journal) Redo(ctx context.Context, store TodoStore, n int) ([]*Operation, error) {
+	var redone []*Operation
+	for ; n > 0 && len(j.Undone) > 0; n-- {
+		op := j.Undone[len(j.Undone)-1]
+		if err := apply(ctx, store, op, false); err != nil {
+			return redone, err
+		}
+		j.Undone = j.Undone[:len(j.Undone)-1]
+		j.Done = append(j.Done, op)
+		redone = append(redone, op)
+		if err := j.Save(); err != nil {
+			return redone, err
+		}
+	}
+	return redone, nil
+}
+
+// Net The overall change the operation made to each item, in the order the
+// items were first changed. Items added and deleted again are left out.
+func (op *Operation) Net() []Change {
+	index := map[int]int{}
+	var net []Change
+	for _, c := range op.Changes {
+		if i, ok := index[c.id()]; ok {
+			net[i].After = c.After
+			continue
+		}
+		index[c.id()] = len(net)
+		net = append(net, c)
+	}
+
+	kept := net[:0]
+	for _, c := range net {
+		if c.Before != nil || c.After != nil {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// apply Put each item the operation changed back as it was before, to
+// undo it, or as it was after, to redo it. Every item must still be as the
+// operation (or its undoing) left it, or already as it is to be put.
+func apply(ctx context.Context, store TodoStore, op *Operation, undo bool) error {
+	var puts []internal.Todo
+	var deletes []int
+	targets := map[int]internal.Todo{}
+	for _, c := range op.Net() {
+		from, to := c.Before, c.After
+		if undo {
+			from, to = c.After, c.Before
+		}
+
+		current, err := store.GetItem(ctx, c.id())
+		if errors.Is(err, ErrNotFound) {
+			current, err = nil, nil
+		}
+		if err != nil {
+			return err
+		}
+		if sameItem(current, to) {
+			continue
+		}
+		if !sameItem(current, from) {
+			if undo {
+				return fmt.Errorf("%w: item %d was changed after %q; undoing it would lose that change",
+					ErrConflict, c.id(), op.Command)
+			}
+			return fmt.Errorf("%w: item %d was changed after %q was undone; redoing it would lose that change",
+				ErrConflict, c.id(), op.Command)
+		}
+
+		if to == nil {
+			deletes = append(deletes, c.id())
+			continue
+		}
+		puts = append(puts, *to)
+		targets[to.ID] = *to
+	}
+
+	sortParentsFirst(puts, targets)
+	for _, item := range puts {
+		if _, err := store.PutItem(ctx, item); err != nil {
+			return err
+		}
+	}
+	if len(deletes) > 0 {
+		if _, err := store.DeleteItem(ctx, deletes...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameItem Whether x and y are the same item with the same fields, or
+// both nil.
+func sameItem(x, y *internal.Todo) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return len(differentFields(canonical(*x), canonical(*y))) == 0 && x.ID == y.ID
+}
+
+// journalStore Records every change made through the wrapped store in a
+// journal, as one operation.
+type journalStore struct {
+	store   TodoStore
+	path    string
+	command string
+	journal *Journal
+	op      *Operation
+}
+
+// WithJournal Wrap store so the changes made through it are recorded in
+// the journal at path as a single operation, described by command. The
+// journal is only read once something changes, and saved after each
+// change, so that the changes made before a failure can still be undone.
+func WithJournal(store TodoStore, path string, command string) TodoStore {
+	return &journalStore{store: store, path: path, command: command}
+}
+
+// load Read the journal before the first change, so a journal that can't
+// be read stops the change from being made.
+func (s *journalStore) load() error {
+	if s.journal != nil {
+		return nil
+	}
+	journal, err := LoadJournal(s.path)
+	if err != nil {
+		return err
+	}
+	s.journal = journal
+	return nil
+}
+
+// record Add changes to the operation, starting it on the first, and save
+// the journal.
+func (s *journalStore) record(changes ...Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	if s.op == nil {
+		s.op = &Operation{Command: s.command, At: time.Now().UTC()}
+		s.journal.begin(s.op)
+	}
+	s.op.Changes = append(s.op.Changes, changes...)
+	if err := s.journal.Save(); err != nil {
+		return fmt.Errorf("the change was made but could not be saved for undo: %w", err)
+	}
+	return nil
+}
+
+// current The item with id as it is now, or nil if there is none.
+func (s *journalStore) current(ctx context.Context, id int) (*internal.Todo, error) {
+	item, err := s.store.GetItem(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return item, err
+}
+
+func (s *journalStore) GetAllItems(ctx context.Context, opts ListOptions) (*internal.TodoCollection, error) {
+	return s.store.GetAllItems(ctx, opts)
+}
+
+func (s *journalStore) GetItem(ctx context.Context, id int) (*internal.Todo, error) {
+	return s.store.GetItem(ctx, id)
+}
+
+func (s *journalStore) AddItem(ctx context.Context, todo internal.Todo) (*internal.Todo, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	item, err := s.store.AddItem(ctx, todo)
+	if err != nil {
+		return nil, err
+	}
+	return item, s.record(Change{After: item})
+}
+
+func (s *journalStore) DeleteItem(ctx context.Context, ids ...int) (int, error) {
+	if err := s.load(); err != nil {
+		return 0, err
+	}
+	var changes []Change
+	for _, id := range ids {
+		item, err := s.current(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		// A missing item fails the delete, so there is nothing to record.
+		if item != nil {
+			changes = append(changes, Change{Before: item})
+		}
+	}
+
+	count, err := s.store.DeleteItem(ctx, ids...)
+	if err != nil {
+		return count, err
+	}
+	return count, s.record(changes...)
+}
+
+func (s *journalStore) DeleteAllItems(ctx context.Context) (int, error) {
+	if err := s.load(); err != nil {
+		return 0, err
+	}
+	all, err := s.store.GetAllItems(ctx, ListOptions{ShowDone: true, IncludeDeferred: true})
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := s.store.DeleteAllItems(ctx)
+	if err != nil {
+		return count, err
+	}
+	changes := make([]Change, len(all.Items))
+	for i := range all.Items {
+		changes[i] = Change{Before: &all.Items[i]}
+	}
+	return count, s.record(changes...)
+}
+
+func (s *journalStore) PutItem(ctx context.Context, todo internal.Todo) (*internal.Todo, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	var before *internal.Todo
+	if todo.ID > 0 {
+		var err error
+		if before, err = s.current(ctx, todo.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	item, err := s.store.PutItem(ctx, todo)
+	if err != nil {
+		return nil, err
+	}
+	// An item put in the trash is as good as deleted.
+	after := item
+	if item.DeletedAt != nil {
+		after = nil
+	}
+	return item, s.record(Change{Before: before, After: after})
+}
+
+func (s *journalStore) RestoreItem(ctx context.Context, ids ...int) (int, error) {
+	if err := s.load(); err != nil {
+		return 0, err
+	}
+	count, err := s.store.RestoreItem(ctx, ids...)
+	if err != nil {
+		return count, err
+	}
+
+	// Undone, the items go back to the trash.
+	var changes []Change
+	for _, id := range ids {
+		item, err := s.store.GetItem(ctx, id)
+		if err != nil {
+			return count, err
+		}
+		changes = append(changes, Change{After: item})
+	}
+	return count, s.record(changes...)
+}
+
+// EmptyTrash Delete items in the trash for good. That can't be undone, but
+// as the items have already gone, there is nothing to record.
+func (s *journalStore) EmptyTrash(ctx context.Context, before time.Time) (int, error) {
+	return s.store.EmptyTrash(ctx, before)
+}
+
+func (s *journalStore) EditItem(ctx context.Context, id int, todo internal.Todo) (*internal.Todo, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	before, err := s.current(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := s.store.EditItem(ctx, id, todo)
+	if err != nil {
+		return nil, err
+	}
+	return item, s.record(Change{Before: before, After: item})
+}
+
+func (s *journalStore) Search(ctx context.Context, expr search.Expr, opts ListOptions) ([]search.Result, error) {
+	return searchStore(ctx, s.store, expr, opts)
+}
+
+func (s *journalStore) ItemHistory(ctx context.Context, id int) ([]FieldChange, error) {
+	return ItemHistory(ctx, s.store, id)
+}
+
+func (s *journalStore) ChangesSince(ctx context.Context, since time.Time) ([]FieldChange, error) {
+	return ChangesSince(ctx, s.store, since)
+}
+
+// Projects and PutProject are passed straight through: changes to projects
+// are not journaled.
+func (s *journalStore) Projects(ctx context.Context) ([]internal.Project, error) {
+	return Projects(ctx, s.store)
+}
+
+func (s *journalStore) PutProject(ctx context.Context, project internal.Project) (*internal.Project, error) {
+	return putProject(ctx, s.store, project)
+}
@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal"
+)
+
+type MigrateOptions struct {
+	// DryRun Work out what would be copied without copying anything.
+	DryRun bool
+}
+
+// MigrateReport What Migrate copied, or would copy in a dry run.
+type MigrateReport struct {
+	// Added Items missing from the target, in the order they are copied:
+	// parents first, then by id.
+	Added []internal.Todo
+	// Updated Items the target holds under the same id but with different
+	// fields.
+	Updated []MigratedItem
+	// Unchanged The number of items already the same in both stores.
+	Unchanged int
+	// OnlyInTarget The number of items in the target with no counterpart
+	// in the source. They are left as they are.
+	OnlyInTarget int
+	// Projects The projects missing from the target or different there,
+	// copied before the items.
+	Projects []internal.Project
+	// Count, Checksum The number of items copied and a checksum over them,
+	// which the target was found to match once they were. Unset in a dry
+	// run.
+	Count    int
+	Checksum string
+}
+
+// Changes The number of items added or updated.
+func (r *MigrateReport) Changes() int {
+	return len(r.Added) + len(r.Updated)
+}
+
+// MigratedItem An item that differed between the stores.
+type MigratedItem struct {
+	Item internal.Todo
+	// Fields The fields that differed, by their JSON names.
+	Fields []string
+}
+
+// Migrate Copy every item in from to to, keeping its id, timestamps and
+// every other field. Items the target already holds under the same id are
+// replaced if they differ, so running Migrate again copies nothing. Once
+// copied, the items are read back from the target and checked against the
+// source. Projects are copied first, in the same way.
+func Migrate(ctx context.Context, from, to TodoStore, opts MigrateOptions) (*MigrateReport, error) {
+	report := &MigrateReport{}
+	if err := migrateProjects(ctx, from, to, opts, report); err != nil {
+		return report, err
+	}
+
+	source, err := allItems(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	target, err := allItems(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := make([]internal.Todo, 0, len(source))
+	for _, item := range source {
+		ordered = append(ordered, item)
+	}
+	sortParentsFirst(ordered, source)
+
+	var changed []internal.Todo
+	for _, item := range ordered {
+		existing, ok := target[item.ID]
+		if !ok {
+			report.Added = append(report.Added, item)
+			changed = append(changed, item)
+			continue
+		}
+		if fields := differentFields(canonical(item), canonical(existing)); len(fields) > 0 {
+			report.Updated = append(report.Updated, MigratedItem{item, fields})
+			changed = append(changed, item)
+			continue
+		}
+		report.Unchanged++
+	}
+	for id := range target {
+		if _, ok := source[id]; !ok {
+			report.OnlyInTarget++
+		}
+	}
+
+	if opts.DryRun {
+		return report, nil
+	}
+
+	for _, item := range changed {
+		if _, err := to.PutItem(ctx, item); err != nil {
+			return report, fmt.Errorf("copying item %d: %w", item.ID, err)
+		}
+	}
+
+	if err := verifyMigration(ctx, to, source, report); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// migrateProjects Copy the projects of from missing from to, or different
+// there, keeping their ids, and list them on report.
+func migrateProjects(ctx context.Context, from, to TodoStore, opts MigrateOptions, report *MigrateReport) error {
+	source, err := Projects(ctx, from)
+	if errors.Is(err, errNoProjects) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(source) == 0 {
+		return nil
+	}
+	target, err := Projects(ctx, to)
+	if err != nil {
+		return fmt.Errorf("copying projects: %w", err)
+	}
+
+	existing := make(map[int]internal.Project, len(target))
+	for _, p := range target {
+		existing[p.ID] = p
+	}
+	for _, p := range source {
+		if other, ok := existing[p.ID]; ok && other.Name == p.Name && other.ParentID == p.ParentID && other.Archived == p.Archived {
+			continue
+		}
+		report.Projects = append(report.Projects, p)
+	}
+
+	if opts.DryRun {
+		return nil
+	}
+	for _, p := range report.Projects {
+		if _, err := putProject(ctx, to, p); err != nil {
+			return fmt.Errorf("copying project %d: %w", p.ID, err)
+		}
+	}
+	return nil
+}
+
+// verifyMigration Check that the target holds every item in source as it
+// is there, setting the count and checksum on report.
+func verifyMigration(ctx context.Context, to TodoStore, source map[int]internal.Todo, report *MigrateReport) error {
+	target, err := allItems(ctx, to)
+	if err != nil {
+		return fmt.Errorf("reading back migrated items: %w", err)
+	}
+
+	copied := make(map[int]internal.Todo, len(source))
+	for id := range source {
+		if item, ok := target[id]; ok {
+			copied[id] = item
+		}
+	}
+	if len(copied) != len(source) {
+		return fmt.Errorf("migration check failed: %d of %d items found in the target", len(copied), len(source))
+	}
+
+	want, got := checksum(source), checksum(copied)
+	if want != got {
+		return fmt.Errorf("migration check failed: checksum of the target is %s, expected %s", got, want)
+	}
+	report.Count, report.Checksum = len(copied), got
+	return nil
+}
+
+// canonical item as every store can hold it: times to the millisecond in
+// UTC, and no tags or extras as nil.
+func canonical(item internal.Todo) internal.Todo {
+	ms := func(t time.Time) time.Time {
+		return t.Truncate(time.Millisecond).UTC()
+	}
+	item.CreatedAt = ms(item.CreatedAt)
+	item.UpdatedAt = ms(item.UpdatedAt)
+	if item.DueDate != nil {
+		due := ms(*item.DueDate)
+		item.DueDate = &due
+	}
+	if item.StartDate != nil {
+		start := ms(*item.StartDate)
+		item.StartDate = &start
+	}
+	if item.DeletedAt != nil {
+		deleted := ms(*item.DeletedAt)
+		item.DeletedAt = &deleted
+	}
+	if len(item.Tags) == 0 {
+		item.Tags = nil
+	}
+	if len(item.Extras) == 0 {
+		item.Extras = nil
+	}
+	return item
+}
+
+// differentFields The fields of two canonical items that differ, by their
+// JSON names.
+func differentFields(x, y internal.Todo) []string {
+	var fields []string
+	for _, f := range syncFields {
+		if !f.equal(x, y) {
+			fields = append(fields, f.name)
+		}
+	}
+	if !x.CreatedAt.Equal(y.CreatedAt) {
+		fields = append(fields, "created_at")
+	}
+	if !x.UpdatedAt.Equal(y.UpdatedAt) {
+		fields = append(fields, "updated_at")
+	}
+	return fields
+}
+
+// checksum A SHA-256 over the canonical form of items, in id order.
+func checksum(items map[int]internal.Todo) string {
+	list := make([]internal.Todo, 0, len(items))
+	for _, item := range items {
+		list = append(list, canonical(item))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, item := range list {
+		enc.Encode(item)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tcooper-uk/go-todo/internal"
+)
+
+// ProjectStore Implemented by stores that keep projects for their items to
+// be put in.
+type ProjectStore interface {
+	// Projects Every project, archived or not, by id.
+	Projects(ctx context.Context) ([]internal.Project, error)
+
+	// PutProject Store project, replacing the one with its ID, or adding it
+	// under the next free ID if it has none. Zero timestamps are set to
+	// now. Returns the project as stored.
+	PutProject(ctx context.Context, project internal.Project) (*internal.Project, error)
+}
+
+// errNoProjects Returned when projects are asked of a store that keeps
+// none.
+var errNoProjects = errors.New("this backend keeps no projects")
+
+// Projects Every project in store, archived or not.
+func Projects(ctx context.Context, store TodoStore) ([]internal.Project, error) {
+	p, ok := store.(ProjectStore)
+	if !ok {
+		return nil, errNoProjects
+	}
+	return p.Projects(ctx)
+}
+
+// PutProject Add project to store, or change the one with its ID, once it
+// is checked: it must have a name without a "/", one no other project in
+// the same parent has, and a parent that exists and is not inside it.
+func PutProject(ctx context.Context, store TodoStore, project internal.Project) (*internal.Project, error) {
+	p, ok := store.(ProjectStore)
+	if !ok {
+		return nil, errNoProjects
+	}
+
+	project.Name = strings.TrimSpace(project.Name)
+	if project.Name == "" || strings.Contains(project.Name, "/") {
+		return nil, fmt.Errorf("invalid project name %q: it must not be empty or contain /", project.Name)
+	}
+
+	projects, err := p.Projects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if project.ParentID != 0 {
+		if !containsProject(projects, project.ParentID) {
+			return nil, fmt.Errorf("parent project %d: %w", project.ParentID, ErrNotFound)
+		}
+		if project.ID != 0 {
+			for _, id := range internal.SubProjects(projects, project.ID) {
+				if id == project.ParentID {
+					return nil, fmt.Errorf("%w: project %d cannot be inside itself", ErrConflict, project.ID)
+				}
+			}
+		}
+	}
+	for _, other := range projects {
+		if other.ID != project.ID && other.ParentID == project.ParentID && strings.EqualFold(other.Name, project.Name) {
+			return nil, fmt.Errorf("%w: there is already a project called %s",
+				ErrConflict, internal.ProjectPath(projects, other.ID))
+		}
+	}
+
+	return p.PutProject(ctx, project)
+}
+
+// putProject Pass project to store unchecked, for wrappers of stores,
+// whose callers have checked it already.
+func putProject(ctx context.Context, store TodoStore, project internal.Project) (*internal.Project, error) {
+	p, ok := store.(ProjectStore)
+	if !ok {
+		return nil, errNoProjects
+	}
+	return p.PutProject(ctx, project)
+}
+
+func containsProject(projects []internal.Project, id int) bool {
+	for _, p := range projects {
+		if p.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// FindProject The project name refers to, by its path, e.g.
+// "work/clients", or by its own name if no other project has it. Matching
+// ignores case.
+func FindProject(projects []internal.Project, name string) (*internal.Project, error) {
+	name = strings.Trim(strings.TrimSpace(name), "/")
+
+	var matches []internal.Project
+	for _, p := range projects {
+		if strings.EqualFold(internal.ProjectPath(projects, p.ID), name) {
+			return &p, nil
+		}
+		if strings.EqualFold(p.Name, name) {
+			matches = append(matches, p)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%w: no project called %s", ErrNotFound, name)
+	case 1:
+		return &matches[0], nil
+	}
+	paths := make([]string, len(matches))
+	for i, p := range matches {
+		paths[i] = internal.ProjectPath(projects, p.ID)
+	}
+	return nil, fmt.Errorf("%w: %s could be any of %s; give its path", ErrConflict, name, strings.Join(paths, ", "))
+}
+
+// CheckProject Verify that the project with id exists and can take items.
+// Use id 0 for no project.
+func CheckProject(ctx context.Context, store TodoStore, id int) error {
+	if id == 0 {
+		return nil
+	}
+	projects, err := Projects(ctx, store)
+	if err != nil {
+		return err
+	}
+	for _, p := range projects {
+		if p.ID != id {
+			continue
+		}
+		if p.Archived {
+			return fmt.Errorf("%w: project %s is archived", ErrConflict, internal.ProjectPath(projects, id))
+		}
+		return nil
+	}
+	return fmt.Errorf("project %d: %w", id, ErrNotFound)
+}
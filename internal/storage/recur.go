@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/recur"
+)
+
+// AddNextOccurrence Add the next occurrence of a repeating item that was
+// completed at completedAt. Returns the added item, or nil when item does
+// not repeat or its series has ended.
+func AddNextOccurrence(ctx context.Context, store TodoStore, item internal.Todo, completedAt time.Time) (*internal.Todo, error) {
+	next, err := recur.NextOccurrence(item, completedAt)
+	if err != nil || next == nil {
+		return nil, err
+	}
+	return store.AddItem(ctx, *next)
+}
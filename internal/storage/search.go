@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/tcooper-uk/go-todo/internal/search"
+)
+
+// Searcher Implemented by stores with a search index of their own.
+type Searcher interface {
+	// Search Find items matching expr and opts, best match first.
+	Search(ctx context.Context, expr search.Expr, opts ListOptions) ([]search.Result, error)
+}
+
+// Search Find items matching query and opts, ranked by relevance. Stores
+// without a search index are searched in memory.
+func Search(ctx context.Context, store TodoStore, query string, opts ListOptions) ([]search.Result, error) {
+	expr, err := search.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return searchStore(ctx, store, expr, opts)
+}
+
+func searchStore(ctx context.Context, store TodoStore, expr search.Expr, opts ListOptions) ([]search.Result, error) {
+	if s, ok := store.(Searcher); ok {
+		return s.Search(ctx, expr, opts)
+	}
+	return SearchInMemory(ctx, store, expr, opts)
+}
+
+// SearchInMemory Search every item listed by opts without an index.
+func SearchInMemory(ctx context.Context, store TodoStore, expr search.Expr, opts ListOptions) ([]search.Result, error) {
+	// Results are ranked by relevance, so list them all, in any order.
+	opts.Sort, opts.Limit, opts.Offset, opts.Cursor = nil, 0, 0, ""
+	items, err := store.GetAllItems(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return search.Filter(items.Items, expr), nil
+}
@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/filter"
+)
+
+// SortField An item attribute a listing can be ordered by.
+type SortField string
+
+const (
+	SortID       SortField = "id"
+	SortDue      SortField = "due"
+	SortPriority SortField = "priority"
+	SortCreated  SortField = "created"
+	SortUpdated  SortField = "updated"
+	SortName     SortField = "name"
+	SortDone     SortField = "done"
+)
+
+var sortFields = []SortField{SortID, SortDue, SortPriority, SortCreated, SortUpdated, SortName, SortDone}
+
+// SortKey One level of ordering. Items without a due date sort before
+// those with one, as in both SQLite and Firestore; priorities run from
+// none to high.
+type SortKey struct {
+	Field SortField
+	Desc  bool
+}
+
+// ErrInvalidCursor Returned for a cursor that did not come from NextCursor
+// of the same listing.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ParseSort Read a comma-separated list of fields, each optionally
+// prefixed with '-' for descending order, e.g. "due,-priority".
+func ParseSort(spec string) ([]SortKey, error) {
+	var keys []SortKey
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		key := SortKey{}
+		if strings.HasPrefix(part, "-") {
+			key.Desc = true
+			part = part[1:]
+		}
+		key.Field = SortField(strings.ToLower(part))
+
+		known := false
+		for _, f := range sortFields {
+			known = known || f == key.Field
+		}
+		if !known {
+			names := make([]string, len(sortFields))
+			for i, f := range sortFields {
+				names[i] = string(f)
+			}
+			return nil, fmt.Errorf("unknown sort field %q (expected one of %s)", part, strings.Join(names, ", "))
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// SortItems Order items by keys, then by id.
+func SortItems(items []internal.Todo, keys []SortKey) {
+	sort.SliceStable(items, func(i, j int) bool {
+		for _, key := range keys {
+			c := compareField(items[i], items[j], key.Field)
+			if key.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return items[i].ID < items[j].ID
+	})
+}
+
+func compareField(a, b internal.Todo, field SortField) int {
+	switch field {
+	case SortID:
+		return compareInts(a.ID, b.ID)
+	case SortDue:
+		switch {
+		case a.DueDate == nil && b.DueDate == nil:
+			return 0
+		case a.DueDate == nil:
+			return -1
+		case b.DueDate == nil:
+			return 1
+		}
+		return a.DueDate.Compare(*b.DueDate)
+	case SortPriority:
+		return compareInts(filter.PriorityRank(a.Priority), filter.PriorityRank(b.Priority))
+	case SortCreated:
+		return a.CreatedAt.Compare(b.CreatedAt)
+	case SortUpdated:
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	case SortName:
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	case SortDone:
+		return compareInts(boolInt(a.Done), boolInt(b.Done))
+	}
+	return 0
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// PageStart Where a listing resumes: the position encoded in cursor plus
+// offset. Used by stores that page by position.
+func PageStart(opts ListOptions) (int, error) {
+	start := 0
+	if opts.Cursor != "" {
+		n, err := strconv.Atoi(opts.Cursor)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidCursor, opts.Cursor)
+		}
+		start = n
+	}
+	return start + opts.Offset, nil
+}
+
+// NextCursor The cursor for the page after one that began at start and
+// returned count items, or "" if that was the last page.
+func NextCursor(opts ListOptions, start, count int, more bool) string {
+	if opts.Limit <= 0 || !more {
+		return ""
+	}
+	return strconv.Itoa(start + count)
+}
+
+// Paginate Sort items by opts.Sort and cut out the page opts asks for,
+// for stores that list in memory. Returns the page and the cursor of the
+// next one.
+func Paginate(items []internal.Todo, opts ListOptions) ([]internal.Todo, string, error) {
+	SortItems(items, opts.Sort)
+
+	start, err := PageStart(opts)
+	if err != nil {
+		return nil, "", err
+	}
+	if start > len(items) {
+		start = len(items)
+	}
+	end := len(items)
+	if opts.Limit > 0 && start+opts.Limit < end {
+		end = start + opts.Limit
+	}
+
+	return items[start:end], NextCursor(opts, start, end-start, end < len(items)), nil
+}
@@ -1,10 +1,14 @@
 package storage
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/filter"
 	"os"
+	"slices"
+	"time"
 )
 
 type Mode uint8
@@ -13,6 +17,9 @@ const (
 	FileMode  Mode = 0
 	DbMode    Mode = 1
 	CloudMode Mode = 2
+	// RemoteMode Items are kept by a `todo serve` instance, so there is no
+	// local file to set up.
+	RemoteMode Mode = 3
 )
 
 const (
@@ -26,37 +33,133 @@ type ListOptions struct {
 	ShowDone bool
 	OnlyDone bool
 	Priority string
-	Tag      string
-	Overdue  bool
+	// Tags Items must have every one of these tags.
+	Tags    []string
+	Overdue bool
+	// Projects Items must be in one of these projects, if any are given.
+	Projects []int
+	// Filter An expression items must also match, if set.
+	Filter filter.Expr
+	// Trashed List the items in the trash instead of the others.
+	Trashed bool
+	// IncludeDeferred Also list items whose start date is still to come,
+	// which are otherwise hidden until then.
+	IncludeDeferred bool
+	// Location The time zone days are reckoned in, for Overdue and date
+	// filters, e.g. when today ends; nil for the local one.
+	Location *time.Location
+
+	// Sort Order of the items; they are always ordered by id last.
+	Sort []SortKey
+	// Limit The most items to return, or 0 for all of them.
+	Limit int
+	// Offset Items to skip before the first returned.
+	Offset int
+	// Cursor Resume from the NextCursor of a previous listing with the
+	// same options. Its format is private to each store.
+	Cursor string
 }
 
-// TodoStore Represents store of todo items
+// TimeZone The time zone opts reckons days in.
+func (opts ListOptions) TimeZone() *time.Location {
+	if opts.Location == nil {
+		return time.Local
+	}
+	return opts.Location
+}
+
+// ShowDeferred Whether opts lists deferred items: when asked to, and
+// always in the trash.
+func (opts ListOptions) ShowDeferred() bool {
+	return opts.IncludeDeferred || opts.Trashed
+}
+
+// Match Whether item is selected by opts, for stores that filter in memory.
+func (opts ListOptions) Match(item internal.Todo, now time.Time) bool {
+	now = now.In(opts.TimeZone())
+	if opts.Trashed != (item.DeletedAt != nil) {
+		return false
+	}
+	if !opts.ShowDone && !opts.OnlyDone && item.Done {
+		return false
+	}
+	if opts.OnlyDone && !item.Done {
+		return false
+	}
+	if opts.Priority != "" && string(item.Priority) != opts.Priority {
+		return false
+	}
+	if opts.Overdue && !item.Overdue(now) {
+		return false
+	}
+	if !opts.ShowDeferred() && item.Deferred(now) {
+		return false
+	}
+	for _, tag := range opts.Tags {
+		if !hasTag(item.Tags, tag) {
+			return false
+		}
+	}
+	if len(opts.Projects) > 0 && !slices.Contains(opts.Projects, item.Project) {
+		return false
+	}
+	return opts.Filter == nil || opts.Filter.Match(item, now.Location())
+}
+
+// TodoStore Represents store of todo items.
+// Every method reports failure through its error, wrapping one of
+// ErrNotFound, ErrConflict or ErrBackendUnavailable where the cause is known,
+// and gives up once ctx is cancelled or its deadline passes.
 type TodoStore interface {
-	// GetAllItems List all items, filtered by opts.
-	GetAllItems(opts ListOptions) *internal.TodoCollection
+	// GetAllItems List all items, filtered by opts. Items in the trash are
+	// only listed, on their own, with opts.Trashed.
+	GetAllItems(ctx context.Context, opts ListOptions) (*internal.TodoCollection, error)
 
 	// GetItem Get a single todo item by its unique id.
-	GetItem(id int) *internal.Todo
+	// Returns ErrNotFound if no item has that id, or it is in the trash.
+	GetItem(ctx context.Context, id int) (*internal.Todo, error)
 
-	// AddItem Add a single item. The store assigns ID and timestamps.
-	// Returns 1 on success, 0 on error.
-	AddItem(todo internal.Todo) int
+	// AddItem Add a single item. The store assigns ID and UpdatedAt, and
+	// CreatedAt unless it is already set, as when copying between stores.
+	// Returns the item as stored.
+	AddItem(ctx context.Context, todo internal.Todo) (*internal.Todo, error)
 
-	// DeleteItem Delete items by id.
-	// Returns count deleted, or 0 on error.
-	DeleteItem(ids ...int) int
+	// DeleteItem Move items to the trash by id, setting their DeletedAt.
+	// Returns count deleted. If any id does not exist, nothing is deleted
+	// and ErrNotFound is returned.
+	DeleteItem(ctx context.Context, ids ...int) (int, error)
 
-	// DeleteAllItems Delete all items from the store.
+	// DeleteAllItems Move all items to the trash.
 	// Returns count deleted.
-	DeleteAllItems() int
+	DeleteAllItems(ctx context.Context) (int, error)
+
+	// RestoreItem Take items out of the trash by id, as they were before.
+	// Returns count restored. If any id is not in the trash, nothing is
+	// restored and ErrNotFound is returned.
+	RestoreItem(ctx context.Context, ids ...int) (int, error)
+
+	// EmptyTrash Delete the items moved to the trash before the given time
+	// for good, or every item in the trash if it is zero.
+	// Returns count deleted.
+	EmptyTrash(ctx context.Context, before time.Time) (int, error)
+
+	// PutItem Store todo exactly as given, ID, timestamps and DeletedAt
+	// included, adding it or replacing the item with that ID, in the trash
+	// or not. Zero timestamps are set to now. Used to copy items between
+	// stores.
+	// Returns the item as stored.
+	PutItem(ctx context.Context, todo internal.Todo) (*internal.Todo, error)
 
 	// EditItem Update the item with the given id to match todo.
 	// Caller should read the item first, mutate fields, then pass it back.
-	// Returns count updated.
-	EditItem(id int, todo internal.Todo) int
+	// Returns the item as stored, or ErrNotFound.
+	EditItem(ctx context.Context, id int, todo internal.Todo) (*internal.Todo, error)
 }
 
 func Setup(mode Mode) (string, error) {
+	if mode == RemoteMode {
+		return "", nil
+	}
 
 	homeDir := os.Getenv("HOME")
 
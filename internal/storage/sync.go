@@ -0,0 +1,713 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal"
+)
+
+// SyncStrategy How Sync settles an item edited in both stores since they
+// were last synced.
+type SyncStrategy string
+
+const (
+	// SyncMerge Combine the edits field by field. A field edited in both
+	// stores takes the value that was saved last.
+	SyncMerge SyncStrategy = "merge"
+	// SyncLastWriterWins Keep whichever version of the item was saved last.
+	SyncLastWriterWins SyncStrategy = "lww"
+)
+
+// SyncSide One of the two stores being synced.
+type SyncSide string
+
+const (
+	SideA SyncSide = "a"
+	SideB SyncSide = "b"
+)
+
+// SyncState What Sync knows of two stores from when it last ran. Keep one
+// per pair of stores and pass the stores in the same order every time.
+type SyncState struct {
+	// A, B Describe the stores the state belongs to, so it is not used for
+	// others by mistake.
+	A     string        `json:"a"`
+	B     string        `json:"b"`
+	Items []*SyncedItem `json:"items"`
+}
+
+// SyncedItem An item kept in both stores.
+type SyncedItem struct {
+	// A, B The item's id in each store.
+	A int `json:"a"`
+	B int `json:"b"`
+	// AUpdated, BUpdated The item's UpdatedAt in each store when last
+	// synced. Items still carrying it have not been edited since.
+	AUpdated time.Time `json:"a_updated"`
+	BUpdated time.Time `json:"b_updated"`
+	// Base The fields both stores last agreed on, with ParentID as an id
+	// in A. Edits are found by comparing against it.
+	Base internal.Todo `json:"base"`
+
+	// gone Set once the item has been deleted from both stores.
+	gone bool
+}
+
+// LoadSyncState Read the state saved at path, or an empty one if there is
+// none yet.
+func LoadSyncState(path string) (*SyncState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &SyncState{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state SyncState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid sync state in %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+// Save Write the state to path, replacing it in one step so an
+// interrupted save leaves the previous state intact.
+func (state *SyncState) Save(path string) error {
+	return saveJSON(path, state)
+}
+
+type SyncOptions struct {
+	Strategy SyncStrategy
+	// DryRun Work out what would change without changing anything.
+	DryRun bool
+}
+
+// SyncReport What Sync changed, or would change in a dry run.
+type SyncReport struct {
+	AddedToA, AddedToB         int
+	UpdatedInA, UpdatedInB     int
+	DeletedFromA, DeletedFromB int
+	// ProjectsAddedToA, ProjectsAddedToB Projects the items copied across
+	// needed, added with the same path as in the other store.
+	ProjectsAddedToA, ProjectsAddedToB int
+	Conflicts                          []SyncConflict
+}
+
+// Changes The number of items added, updated or deleted in either store,
+// and of projects added.
+func (r *SyncReport) Changes() int {
+	return r.AddedToA + r.AddedToB + r.UpdatedInA + r.UpdatedInB + r.DeletedFromA + r.DeletedFromB +
+		r.ProjectsAddedToA + r.ProjectsAddedToB
+}
+
+// SyncConflict An item changed in both stores in ways that could not both
+// be kept.
+type SyncConflict struct {
+	// A, B The item's id in each store.
+	A, B int
+	Name string
+	// Fields The fields edited differently in each store, by their JSON
+	// names, or nil when the item was edited in one store and deleted from
+	// the other.
+	Fields []string
+	// Kept The store whose version won. An edited item always wins over
+	// its deletion.
+	Kept SyncSide
+}
+
+// syncField A field Sync compares and copies, named as in JSON.
+type syncField struct {
+	name  string
+	equal func(x, y internal.Todo) bool
+	copy  func(dst *internal.Todo, src internal.Todo)
+}
+
+var syncFields = []syncField{
+	{"name",
+		func(x, y internal.Todo) bool { return x.Name == y.Name },
+		func(dst *internal.Todo, src internal.Todo) { dst.Name = src.Name }},
+	{"done",
+		func(x, y internal.Todo) bool { return x.Done == y.Done },
+		func(dst *internal.Todo, src internal.Todo) { dst.Done = src.Done }},
+	{"priority",
+		func(x, y internal.Todo) bool { return x.Priority == y.Priority },
+		func(dst *internal.Todo, src internal.Todo) { dst.Priority = src.Priority }},
+	{"due_date",
+		func(x, y internal.Todo) bool {
+			if x.DueDate == nil || y.DueDate == nil {
+				return x.DueDate == y.DueDate
+			}
+			// SQLite keeps a deadline to the millisecond.
+			return x.DueDate.Truncate(time.Millisecond).Equal(y.DueDate.Truncate(time.Millisecond)) && x.DueTimed == y.DueTimed && x.DueZone == y.DueZone
+		},
+		func(dst *internal.Todo, src internal.Todo) {
+			dst.DueDate, dst.DueTimed, dst.DueZone = src.DueDate, src.DueTimed, src.DueZone
+		}},
+	{"start_date",
+		func(x, y internal.Todo) bool {
+			if x.StartDate == nil || y.StartDate == nil {
+				return x.StartDate == y.StartDate
+			}
+			// SQLite keeps it to the millisecond.
+			return x.StartDate.Truncate(time.Millisecond).Equal(y.StartDate.Truncate(time.Millisecond))
+		},
+		func(dst *internal.Todo, src internal.Todo) { dst.StartDate = src.StartDate }},
+	{"tags",
+		func(x, y internal.Todo) bool { return slices.Equal(x.Tags, y.Tags) },
+		func(dst *internal.Todo, src internal.Todo) { dst.Tags = slices.Clone(src.Tags) }},
+	{"parent_id",
+		func(x, y internal.Todo) bool { return x.ParentID == y.ParentID },
+		func(dst *internal.Todo, src internal.Todo) { dst.ParentID = src.ParentID }},
+	{"project",
+		func(x, y internal.Todo) bool { return x.Project == y.Project },
+		func(dst *internal.Todo, src internal.Todo) { dst.Project = src.Project }},
+	{"recurrence",
+		func(x, y internal.Todo) bool { return x.Recurrence == y.Recurrence },
+		func(dst *internal.Todo, src internal.Todo) { dst.Recurrence = src.Recurrence }},
+	{"extras",
+		func(x, y internal.Todo) bool { return extrasKey(x) == extrasKey(y) },
+		func(dst *internal.Todo, src internal.Todo) { dst.Extras = maps.Clone(src.Extras) }},
+}
+
+// extrasKey The item's extras as JSON, for comparing them; none at all,
+// nil or empty, is "".
+func extrasKey(item internal.Todo) string {
+	if len(item.Extras) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(item.Extras)
+	return string(data)
+}
+
+func sameContent(x, y internal.Todo) bool {
+	for _, f := range syncFields {
+		if !f.equal(x, y) {
+			return false
+		}
+	}
+	return true
+}
+
+func copyContent(dst *internal.Todo, src internal.Todo) {
+	for _, f := range syncFields {
+		f.copy(dst, src)
+	}
+}
+
+// Sync Reconcile two stores, using and updating state. Items added to one
+// store are added to the other; edits and deletions since the last sync
+// are copied across. An item edited in both is settled by opts.Strategy
+// and reported as a conflict if either edit is lost. Items in both stores
+// with the same content that Sync has not seen before are matched up
+// rather than copied, so stores that were copied by other means can be
+// synced without duplicating everything.
+//
+// Projects are matched by their path, e.g. "work/clients", as their ids
+// differ between stores; those missing from either store are added to it.
+//
+// The state reflects whatever was done even if Sync fails part way, and
+// should be saved regardless.
+func Sync(ctx context.Context, a, b TodoStore, state *SyncState, opts SyncOptions) (*SyncReport, error) {
+	s := &syncer{ctx: ctx, a: a, b: b, opts: opts, report: &SyncReport{}}
+
+	var err error
+	if s.itemsA, err = allItems(ctx, a); err != nil {
+		return nil, err
+	}
+	if s.itemsB, err = allItems(ctx, b); err != nil {
+		return nil, err
+	}
+	if err := s.syncProjects(); err != nil {
+		return nil, err
+	}
+
+	s.aToB = make(map[int]int)
+	s.bToA = make(map[int]int)
+	for _, e := range state.Items {
+		entry := *e
+		s.entries = append(s.entries, &entry)
+		s.aToB[e.A] = e.B
+		s.bToA[e.B] = e.A
+	}
+	known := len(s.entries)
+
+	if !opts.DryRun {
+		defer func() {
+			state.Items = state.Items[:0]
+			for _, e := range s.entries {
+				if !e.gone {
+					state.Items = append(state.Items, e)
+				}
+			}
+		}()
+	}
+
+	if err := s.addNew(); err != nil {
+		return s.report, err
+	}
+	for _, e := range s.entries[:known] {
+		if err := s.reconcile(e); err != nil {
+			return s.report, err
+		}
+	}
+	return s.report, s.deleteQueued()
+}
+
+type syncer struct {
+	ctx    context.Context
+	a, b   TodoStore
+	opts   SyncOptions
+	report *SyncReport
+
+	itemsA, itemsB map[int]internal.Todo
+	aToB, bToA     map[int]int
+	entries        []*SyncedItem
+	// projectAToB, projectBToA Map the ids of projects with the same path.
+	projectAToB, projectBToA map[int]int
+
+	// deleteA, deleteB Entries whose item is to be deleted from A or B.
+	deleteA, deleteB []*SyncedItem
+	// dryRunID Stands in for the ids stores would assign in a dry run.
+	dryRunID int
+}
+
+func allItems(ctx context.Context, store TodoStore) (map[int]internal.Todo, error) {
+	list, err := store.GetAllItems(ctx, ListOptions{ShowDone: true, IncludeDeferred: true})
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[int]internal.Todo, len(list.Items))
+	for _, item := range list.Items {
+		items[item.ID] = item
+	}
+	return items, nil
+}
+
+// content The fields of item Sync compares, with ParentID and Project as
+// ids in A.
+func (s *syncer) content(item internal.Todo, side SyncSide) internal.Todo {
+	var c internal.Todo
+	copyContent(&c, item)
+	if side == SideB && c.ParentID != 0 {
+		c.ParentID = s.bToA[c.ParentID]
+	}
+	if side == SideB && c.Project != 0 {
+		c.Project = s.projectBToA[c.Project]
+	}
+	return c
+}
+
+// syncProjects Match up the projects of both stores by path, adding those
+// missing from either, parents first. Stores that keep no projects are
+// left out: their items are in none.
+func (s *syncer) syncProjects() error {
+	s.projectAToB = make(map[int]int)
+	s.projectBToA = make(map[int]int)
+
+	projectsA, errA := Projects(s.ctx, s.a)
+	projectsB, errB := Projects(s.ctx, s.b)
+	for _, err := range []error{errA, errB} {
+		if err != nil && !errors.Is(err, errNoProjects) {
+			return err
+		}
+	}
+	if errA != nil || errB != nil {
+		return nil
+	}
+
+	byPathB := make(map[string]int, len(projectsB))
+	for _, p := range projectsB {
+		byPathB[strings.ToLower(internal.ProjectPath(projectsB, p.ID))] = p.ID
+	}
+	for _, p := range projectsA {
+		if id, ok := byPathB[strings.ToLower(internal.ProjectPath(projectsA, p.ID))]; ok {
+			s.projectAToB[p.ID] = id
+			s.projectBToA[id] = p.ID
+		}
+	}
+
+	for _, side := range []SyncSide{SideA, SideB} {
+		from, mapping, reverse := projectsA, s.projectAToB, s.projectBToA
+		if side == SideB {
+			from, mapping, reverse = projectsB, s.projectBToA, s.projectAToB
+		}
+		for _, p := range internal.NestProjects(from) {
+			if _, ok := mapping[p.Project.ID]; ok {
+				continue
+			}
+			project := p.Project
+			project.ID = 0
+			if project.ParentID != 0 {
+				project.ParentID = mapping[project.ParentID]
+			}
+			added, err := s.addProject(side, project)
+			if err != nil {
+				return err
+			}
+			mapping[p.Project.ID] = added.ID
+			reverse[added.ID] = p.Project.ID
+		}
+	}
+	return nil
+}
+
+// addProject Add project, from the store on side, to the other store.
+func (s *syncer) addProject(from SyncSide, project internal.Project) (*internal.Project, error) {
+	to := SideB
+	if from == SideB {
+		to = SideA
+	}
+	if to == SideA {
+		s.report.ProjectsAddedToA++
+	} else {
+		s.report.ProjectsAddedToB++
+	}
+	if s.opts.DryRun {
+		s.dryRunID--
+		project.ID = s.dryRunID
+		return &project, nil
+	}
+	added, err := putProject(s.ctx, s.store(to), project)
+	if err != nil {
+		return nil, fmt.Errorf("adding project %q to store %s: %w", project.Name, to, err)
+	}
+	return added, nil
+}
+
+// addNew Copy items Sync has not seen before into the other store,
+// parents before their subtasks.
+func (s *syncer) addNew() error {
+	newA := s.unsynced(s.itemsA, s.aToB)
+	newB := s.unsynced(s.itemsB, s.bToA)
+	newA, newB = s.matchIdentical(newA, newB)
+
+	for _, item := range newA {
+		if err := s.copyNew(item, SideA); err != nil {
+			return err
+		}
+	}
+	for _, item := range newB {
+		if err := s.copyNew(item, SideB); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unsynced The items not yet in the other store, ordered so that parents
+// come before their subtasks.
+func (s *syncer) unsynced(items map[int]internal.Todo, synced map[int]int) []internal.Todo {
+	var unsynced []internal.Todo
+	for id, item := range items {
+		if _, ok := synced[id]; !ok {
+			unsynced = append(unsynced, item)
+		}
+	}
+	sortParentsFirst(unsynced, items)
+	return unsynced
+}
+
+// matchIdentical Pair up new items with the same content in each store,
+// returning those left over.
+func (s *syncer) matchIdentical(newA, newB []internal.Todo) ([]internal.Todo, []internal.Todo) {
+	key := func(item internal.Todo, project int) string {
+		due := ""
+		if item.DueDate != nil {
+			due = fmt.Sprintf("%s %t %s", item.DueDate.Truncate(time.Millisecond).UTC().Format(time.RFC3339Nano), item.DueTimed, item.DueZone)
+		}
+		start := ""
+		if item.StartDate != nil {
+			// SQLite keeps it to the millisecond.
+			start = item.StartDate.Truncate(time.Millisecond).UTC().Format(time.RFC3339Nano)
+		}
+		return fmt.Sprintf("%q %t %q %s %s %q %q %q %d", item.Name, item.Done, item.Priority, due, start, item.Tags, item.Recurrence, extrasKey(item), project)
+	}
+
+	byKey := make(map[string][]internal.Todo)
+	for _, item := range newB {
+		k := key(item, s.projectBToA[item.Project])
+		byKey[k] = append(byKey[k], item)
+	}
+
+	var restA []internal.Todo
+	matchedB := make(map[int]bool)
+	for _, a := range newA {
+		k := key(a, a.Project)
+		candidates := byKey[k]
+		if len(candidates) == 0 {
+			restA = append(restA, a)
+			continue
+		}
+		b := candidates[0]
+		byKey[k] = candidates[1:]
+		matchedB[b.ID] = true
+		s.link(a, b)
+	}
+
+	var restB []internal.Todo
+	for _, b := range newB {
+		if !matchedB[b.ID] {
+			restB = append(restB, b)
+		}
+	}
+	return restA, restB
+}
+
+// link Record that a and b are the same item.
+func (s *syncer) link(a, b internal.Todo) *SyncedItem {
+	s.aToB[a.ID] = b.ID
+	s.bToA[b.ID] = a.ID
+	e := &SyncedItem{A: a.ID, B: b.ID, AUpdated: a.UpdatedAt, BUpdated: b.UpdatedAt, Base: s.content(a, SideA)}
+	s.entries = append(s.entries, e)
+	return e
+}
+
+// copyNew Add item, from the store on side from, to the other store,
+// keeping its creation time.
+func (s *syncer) copyNew(item internal.Todo, from SyncSide) error {
+	todo := internal.Todo{CreatedAt: item.CreatedAt}
+	copyContent(&todo, item)
+	if item.ParentID != 0 {
+		if from == SideA {
+			todo.ParentID = s.aToB[item.ParentID]
+		} else {
+			todo.ParentID = s.bToA[item.ParentID]
+		}
+	}
+	if item.Project != 0 {
+		if from == SideA {
+			todo.Project = s.projectAToB[item.Project]
+		} else {
+			todo.Project = s.projectBToA[item.Project]
+		}
+	}
+
+	if from == SideA {
+		added, err := s.add(SideB, todo)
+		if err != nil {
+			return err
+		}
+		s.link(item, *added)
+		s.report.AddedToB++
+	} else {
+		added, err := s.add(SideA, todo)
+		if err != nil {
+			return err
+		}
+		s.link(*added, item)
+		s.report.AddedToA++
+	}
+	return nil
+}
+
+func (s *syncer) store(side SyncSide) TodoStore {
+	if side == SideA {
+		return s.a
+	}
+	return s.b
+}
+
+func (s *syncer) add(side SyncSide, todo internal.Todo) (*internal.Todo, error) {
+	if s.opts.DryRun {
+		s.dryRunID--
+		todo.ID = s.dryRunID
+		return &todo, nil
+	}
+	added, err := s.store(side).AddItem(s.ctx, todo)
+	if err != nil {
+		return nil, fmt.Errorf("adding %q to store %s: %w", todo.Name, side, err)
+	}
+	return added, nil
+}
+
+// edit Give the item current, in the store on side, the content of merged.
+func (s *syncer) edit(side SyncSide, current internal.Todo, merged internal.Todo) (internal.Todo, error) {
+	todo := current
+	copyContent(&todo, merged)
+	if side == SideB && merged.ParentID != 0 {
+		todo.ParentID = s.aToB[merged.ParentID]
+	}
+	if side == SideB && merged.Project != 0 {
+		todo.Project = s.projectAToB[merged.Project]
+	}
+
+	if side == SideA {
+		s.report.UpdatedInA++
+	} else {
+		s.report.UpdatedInB++
+	}
+	if s.opts.DryRun {
+		return todo, nil
+	}
+
+	edited, err := s.store(side).EditItem(s.ctx, current.ID, todo)
+	if err != nil {
+		return current, fmt.Errorf("updating item %d in store %s: %w", current.ID, side, err)
+	}
+	return *edited, nil
+}
+
+// edited Whether item, as found on side, has changed since the last sync.
+func (s *syncer) edited(e *SyncedItem, item internal.Todo, side SyncSide) bool {
+	synced := e.AUpdated
+	if side == SideB {
+		synced = e.BUpdated
+	}
+	return !item.UpdatedAt.Equal(synced) && !sameContent(e.Base, s.content(item, side))
+}
+
+// reconcile Bring an item synced before up to date in both stores.
+func (s *syncer) reconcile(e *SyncedItem) error {
+	a, inA := s.itemsA[e.A]
+	b, inB := s.itemsB[e.B]
+
+	switch {
+	case !inA && !inB:
+		e.gone = true
+		return nil
+	case !inA:
+		return s.deletedFrom(e, SideA, b)
+	case !inB:
+		return s.deletedFrom(e, SideB, a)
+	}
+
+	ca, cb := s.content(a, SideA), s.content(b, SideB)
+	editedA, editedB := s.edited(e, a, SideA), s.edited(e, b, SideB)
+
+	merged := e.Base
+	switch {
+	case editedA && editedB && !sameContent(ca, cb):
+		merged = s.resolve(e, a, ca, b, cb)
+	case editedA:
+		merged = ca
+	case editedB:
+		merged = cb
+	}
+
+	var err error
+	if !sameContent(merged, ca) {
+		if a, err = s.edit(SideA, a, merged); err != nil {
+			return err
+		}
+	}
+	if !sameContent(merged, cb) {
+		if b, err = s.edit(SideB, b, merged); err != nil {
+			return err
+		}
+	}
+
+	e.AUpdated, e.BUpdated, e.Base = a.UpdatedAt, b.UpdatedAt, merged
+	return nil
+}
+
+// resolve Settle an item edited differently in both stores.
+func (s *syncer) resolve(e *SyncedItem, a, ca, b, cb internal.Todo) internal.Todo {
+	conflict := SyncConflict{A: a.ID, B: b.ID, Name: ca.Name, Kept: SideA}
+	newer := ca
+	if b.UpdatedAt.After(a.UpdatedAt) {
+		conflict.Kept, newer = SideB, cb
+	}
+
+	merged := e.Base
+	for _, f := range syncFields {
+		editedA, editedB := !f.equal(e.Base, ca), !f.equal(e.Base, cb)
+		clash := !f.equal(ca, cb) && (editedA && editedB || s.opts.Strategy == SyncLastWriterWins)
+
+		switch {
+		case clash || s.opts.Strategy == SyncLastWriterWins:
+			f.copy(&merged, newer)
+		case editedB:
+			f.copy(&merged, cb)
+		default:
+			f.copy(&merged, ca)
+		}
+		if clash {
+			conflict.Fields = append(conflict.Fields, f.name)
+		}
+	}
+
+	if len(conflict.Fields) > 0 {
+		conflict.Name = merged.Name
+		s.report.Conflicts = append(s.report.Conflicts, conflict)
+	}
+	return merged
+}
+
+// deletedFrom Handle an item deleted from the store on side since the last
+// sync: delete it from the other store too, unless it was edited there, in
+// which case it is added back.
+func (s *syncer) deletedFrom(e *SyncedItem, side SyncSide, other internal.Todo) error {
+	otherSide := SideB
+	if side == SideB {
+		otherSide = SideA
+	}
+
+	if !s.edited(e, other, otherSide) {
+		if side == SideA {
+			s.deleteB = append(s.deleteB, e)
+			s.report.DeletedFromB++
+		} else {
+			s.deleteA = append(s.deleteA, e)
+			s.report.DeletedFromA++
+		}
+		return nil
+	}
+
+	// The edit wins: the item goes back where it was deleted from.
+	e.gone = true
+	conflict := SyncConflict{A: e.A, B: e.B, Name: other.Name, Kept: otherSide}
+	if err := s.copyNew(other, otherSide); err != nil {
+		return err
+	}
+	if side == SideA {
+		conflict.A = s.bToA[other.ID]
+	} else {
+		conflict.B = s.aToB[other.ID]
+	}
+	s.report.Conflicts = append(s.report.Conflicts, conflict)
+	return nil
+}
+
+// deleteQueued Delete the items found deleted from the other store.
+func (s *syncer) deleteQueued() error {
+	for _, q := range []struct {
+		side    SyncSide
+		entries []*SyncedItem
+		id      func(e *SyncedItem) int
+	}{
+		{SideA, s.deleteA, func(e *SyncedItem) int { return e.A }},
+		{SideB, s.deleteB, func(e *SyncedItem) int { return e.B }},
+	} {
+		if len(q.entries) == 0 || s.opts.DryRun {
+			continue
+		}
+		ids := make([]int, len(q.entries))
+		for i, e := range q.entries {
+			ids[i] = q.id(e)
+		}
+		if _, err := s.store(q.side).DeleteItem(s.ctx, ids...); err != nil {
+			return fmt.Errorf("deleting %s from store %s: %w", joinIDs(ids), q.side, err)
+		}
+		for _, e := range q.entries {
+			e.gone = true
+		}
+	}
+	return nil
+}
+
+func joinIDs(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprint(id)
+	}
+	return strings.Join(parts, ", ")
+}
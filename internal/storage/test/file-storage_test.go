@@ -1,6 +1,7 @@
 package storage_test
 
 import (
+	"context"
 	"os"
 	"testing"
 	"time"
@@ -11,15 +12,24 @@ import (
 	"github.com/tcooper-uk/go-todo/internal/storage"
 )
 
+var ctx = context.Background()
+
 func newTodo(name string) internal.Todo {
 	return internal.Todo{Name: name}
 }
 
+func newFileStore(t *testing.T, filename string) *storage.LocalFileStore {
+	s, err := storage.NewLocalFileStore(filename)
+	assert.Nil(t, err)
+	return s
+}
+
 func TestCanGetAllItems(t *testing.T) {
-	s := storage.NewLocalFileStore("testtodo.json")
+	s := newFileStore(t, "testtodo.json")
 
-	items := s.GetAllItems(storage.ListOptions{ShowDone: true})
+	items, err := s.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
 
+	assert.Nil(t, err)
 	assert.Equal(t, 2, items.Size)
 	assert.Equal(t, items.Items[0].ID, 1)
 	assert.Equal(t, items.Items[0].Name, "first")
@@ -31,12 +41,12 @@ func TestGetAllItemsHidesDoneByDefault(t *testing.T) {
 	const filename = "filterdone.json"
 	defer cleanUp(filename)
 
-	s := storage.NewLocalFileStore(filename)
-	s.AddItem(newTodo("active"))
+	s := newFileStore(t, filename)
+	s.AddItem(ctx, newTodo("active"))
 	doneTodo := internal.Todo{Name: "done item", Done: true}
-	s.AddItem(doneTodo)
+	s.AddItem(ctx, doneTodo)
 
-	items := s.GetAllItems(storage.ListOptions{})
+	items, _ := s.GetAllItems(ctx, storage.ListOptions{})
 	assert.Equal(t, 1, items.Size)
 	assert.Equal(t, "active", items.Items[0].Name)
 }
@@ -45,38 +55,50 @@ func TestGetAllItemsOnlyDone(t *testing.T) {
 	const filename = "onlydone.json"
 	defer cleanUp(filename)
 
-	s := storage.NewLocalFileStore(filename)
-	s.AddItem(newTodo("active"))
-	s.AddItem(internal.Todo{Name: "done item", Done: true})
+	s := newFileStore(t, filename)
+	s.AddItem(ctx, newTodo("active"))
+	s.AddItem(ctx, internal.Todo{Name: "done item", Done: true})
 
-	items := s.GetAllItems(storage.ListOptions{OnlyDone: true})
+	items, _ := s.GetAllItems(ctx, storage.ListOptions{OnlyDone: true})
 	assert.Equal(t, 1, items.Size)
 	assert.Equal(t, "done item", items.Items[0].Name)
 }
 
 func TestCanGetSingleItem(t *testing.T) {
-	s := storage.NewLocalFileStore("testtodo.json")
+	s := newFileStore(t, "testtodo.json")
 
-	item := s.GetItem(2)
+	item, err := s.GetItem(ctx, 2)
 
+	assert.Nil(t, err)
 	assert.Equal(t, item.ID, 2)
 	assert.Equal(t, item.Name, "second")
 }
 
+func TestGetMissingItemIsNotFound(t *testing.T) {
+	s := newFileStore(t, "testtodo.json")
+
+	item, err := s.GetItem(ctx, 99)
+
+	assert.Nil(t, item)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
 func TestCanCreateNewItem(t *testing.T) {
 	const filename = "newfile.json"
 	defer cleanUp(filename)
 
-	s := storage.NewLocalFileStore(filename)
+	s := newFileStore(t, filename)
 
-	initalItems := s.GetAllItems(storage.ListOptions{ShowDone: true})
+	initalItems, _ := s.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
 
-	s.AddItem(newTodo("new item"))
+	added, err := s.AddItem(ctx, newTodo("new item"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, added.ID)
 
-	items := s.GetAllItems(storage.ListOptions{ShowDone: true})
+	items, _ := s.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
 
-	assert.Empty(t, initalItems)
-	assert.NotEmpty(t, items)
+	assert.Empty(t, initalItems.Items)
+	assert.NotEmpty(t, items.Items)
 	assert.Equal(t, items.Items[0].ID, 1)
 	assert.Equal(t, items.Items[0].Name, "new item")
 }
@@ -93,10 +115,10 @@ func TestCanCreateItemWithFields(t *testing.T) {
 		Tags:     []string{"work", "urgent"},
 	}
 
-	s := storage.NewLocalFileStore(filename)
-	s.AddItem(todo)
+	s := newFileStore(t, filename)
+	s.AddItem(ctx, todo)
 
-	items := s.GetAllItems(storage.ListOptions{ShowDone: true})
+	items, _ := s.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
 	assert.Equal(t, 1, items.Size)
 	got := items.Items[0]
 	assert.Equal(t, "rich item", got.Name)
@@ -106,40 +128,83 @@ func TestCanCreateItemWithFields(t *testing.T) {
 	assert.Equal(t, due.UTC(), got.DueDate.UTC())
 }
 
+func TestCanCreateSubtask(t *testing.T) {
+	const filename = "subtask.json"
+	defer cleanUp(filename)
+
+	s := newFileStore(t, filename)
+	parent, _ := s.AddItem(ctx, newTodo("parent"))
+	s.AddItem(ctx, internal.Todo{Name: "child", ParentID: parent.ID})
+
+	// Reload to check the relationship is persisted.
+	s = newFileStore(t, filename)
+	child, err := s.GetItem(ctx, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, parent.ID, child.ParentID)
+}
+
 func TestCanEditItem(t *testing.T) {
 	const filename = "newfile.json"
 	defer cleanUp(filename)
 
-	s := storage.NewLocalFileStore(filename)
+	s := newFileStore(t, filename)
 
-	s.AddItem(newTodo("value1"))
+	s.AddItem(ctx, newTodo("value1"))
 
-	item := s.GetItem(1)
+	item, _ := s.GetItem(ctx, 1)
 	item.Name = "value2"
-	s.EditItem(1, *item)
+	_, err := s.EditItem(ctx, 1, *item)
+	assert.Nil(t, err)
 
-	items := s.GetAllItems(storage.ListOptions{ShowDone: true})
+	items, _ := s.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
 
-	assert.NotEmpty(t, items)
+	assert.NotEmpty(t, items.Items)
 	assert.Equal(t, items.Items[0].ID, 1)
 	assert.Equal(t, items.Items[0].Name, "value2")
 }
 
+func TestEditMissingItemIsNotFound(t *testing.T) {
+	const filename = "editmissing.json"
+	defer cleanUp(filename)
+
+	s := newFileStore(t, filename)
+
+	_, err := s.EditItem(ctx, 1, newTodo("nothing here"))
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
 func TestCanDeleteItem(t *testing.T) {
 	const filename = "deleteFile.json"
 	defer cleanUp(filename)
 
 	os.WriteFile(filename, []byte("[{\"id\": 1,\"name\": \"first\",\"created_at\": \"2022-04-20T14:32:28.901094+01:00\"}]"), 0755)
 
-	s := storage.NewLocalFileStore(filename)
+	s := newFileStore(t, filename)
 
-	items := s.GetAllItems(storage.ListOptions{ShowDone: true})
-	assert.NotEmpty(t, items)
+	items, _ := s.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
+	assert.NotEmpty(t, items.Items)
 
-	s.DeleteItem(1)
+	deleted, err := s.DeleteItem(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, deleted)
 
-	items = s.GetAllItems(storage.ListOptions{ShowDone: true})
-	assert.Empty(t, items)
+	items, _ = s.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
+	assert.Empty(t, items.Items)
+}
+
+func TestDeleteWithMissingIdDeletesNothing(t *testing.T) {
+	const filename = "deletePartial.json"
+	defer cleanUp(filename)
+
+	s := newFileStore(t, filename)
+	s.AddItem(ctx, newTodo("keep me"))
+
+	deleted, err := s.DeleteItem(ctx, 1, 42)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.Equal(t, 0, deleted)
+
+	items, _ := s.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
+	assert.Equal(t, 1, items.Size)
 }
 
 func TestCanDeleteAllItems(t *testing.T) {
@@ -148,15 +213,63 @@ func TestCanDeleteAllItems(t *testing.T) {
 
 	os.WriteFile(filename, []byte("[{\"id\": 1,\"name\": \"first\",\"created_at\": \"2022-04-20T14:32:28.901094+01:00\"}]"), 0755)
 
-	s := storage.NewLocalFileStore(filename)
+	s := newFileStore(t, filename)
+
+	items, _ := s.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
+	assert.NotEmpty(t, items.Items)
 
-	items := s.GetAllItems(storage.ListOptions{ShowDone: true})
-	assert.NotEmpty(t, items)
+	s.DeleteAllItems(ctx)
 
-	s.DeleteAllItems()
+	items, _ = s.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
+	assert.Empty(t, items.Items)
+}
 
-	items = s.GetAllItems(storage.ListOptions{ShowDone: true})
-	assert.Empty(t, items)
+func TestCorruptFileIsAnError(t *testing.T) {
+	const filename = "corrupt.json"
+	defer cleanUp(filename)
+
+	os.WriteFile(filename, []byte("{not json"), 0755)
+
+	s, err := storage.NewLocalFileStore(filename)
+	assert.Nil(t, s)
+	assert.Error(t, err)
+}
+
+func TestUnwritableFileIsUnavailable(t *testing.T) {
+	// The parent directory does not exist, so every save fails.
+	s := newFileStore(t, t.TempDir()+"/missing/todo.json")
+
+	_, err := s.AddItem(ctx, newTodo("cannot save"))
+	assert.ErrorIs(t, err, storage.ErrBackendUnavailable)
+}
+
+func TestFailedSaveLeavesItemsAsTheyWere(t *testing.T) {
+	dir := t.TempDir() + "/todo"
+	assert.Nil(t, os.Mkdir(dir, 0755))
+	s := newFileStore(t, dir+"/todo.json")
+
+	item, err := s.AddItem(ctx, newTodo("saved"))
+	assert.Nil(t, err)
+	info, err := os.Stat(dir + "/todo.json")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	// Nothing can be saved once the directory has gone.
+	assert.Nil(t, os.RemoveAll(dir))
+
+	_, err = s.AddItem(ctx, newTodo("unsaved"))
+	assert.ErrorIs(t, err, storage.ErrBackendUnavailable)
+	_, err = s.EditItem(ctx, item.ID, newTodo("renamed"))
+	assert.ErrorIs(t, err, storage.ErrBackendUnavailable)
+	_, err = s.DeleteItem(ctx, item.ID)
+	assert.ErrorIs(t, err, storage.ErrBackendUnavailable)
+
+	items, err := s.GetAllItems(ctx, storage.ListOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, items.Size)
+	assert.Equal(t, "saved", items.Items[0].Name)
+	assert.Equal(t, 1, s.ItemCount)
+	assert.Equal(t, 1, s.MaxId)
 }
 
 func cleanUp(file string) {
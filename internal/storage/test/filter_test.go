@@ -0,0 +1,177 @@
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/filter"
+	"github.com/tcooper-uk/go-todo/internal/storage"
+)
+
+// filterCases Filters and the names of the items from addFilterItems they
+// select, in id order.
+var filterCases = map[string][]string{
+	"tag:work and (priority:high or due<2026-11-01) and not tag:blocked": {"report", "email"},
+	"tag:work tag:blocked":            {"blocked"},
+	"not tag:work":                    {"garden"},
+	"priority>=medium":                {"report", "blocked"},
+	"priority:none":                   {"garden"},
+	"due:none":                        {"blocked"},
+	"not due:none":                    {"report", "garden", "email"},
+	"due!=2026-10-30":                 {"blocked", "garden", "email"},
+	"due>=2026-10-31 due<=2026-12-01": {"garden", "email"},
+	"name:EMAIL or name=garden":       {"garden", "email"},
+	"parent:none":                     {"report", "blocked", "garden"},
+	"not parent:1":                    {"report", "blocked", "garden"},
+	"done:false":                      {"report", "blocked", "garden", "email"},
+}
+
+func addFilterItems(t *testing.T, store storage.TodoStore) {
+	due := func(s string) *time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return &d
+	}
+
+	report, err := store.AddItem(ctx, internal.Todo{Name: "report", Priority: internal.PriorityHigh, DueDate: due("2026-10-30"), Tags: []string{"work"}})
+	assert.Nil(t, err)
+	store.AddItem(ctx, internal.Todo{Name: "blocked", Priority: internal.PriorityHigh, Tags: []string{"work", "blocked"}})
+	store.AddItem(ctx, internal.Todo{Name: "garden", DueDate: due("2026-12-01"), Tags: []string{"home"}})
+	store.AddItem(ctx, internal.Todo{Name: "email", Priority: internal.PriorityLow, DueDate: due("2026-10-31"), Tags: []string{"work"}, ParentID: report.ID})
+}
+
+func assertFilters(t *testing.T, store storage.TodoStore) {
+	for query, want := range filterCases {
+		expr, err := filter.Parse(query)
+		assert.Nil(t, err, query)
+
+		items, err := store.GetAllItems(ctx, storage.ListOptions{Filter: expr})
+		assert.Nil(t, err, query)
+
+		var names []string
+		for _, item := range items.Items {
+			names = append(names, item.Name)
+		}
+		assert.Equal(t, want, names, query)
+	}
+}
+
+func TestFilterFileStore(t *testing.T) {
+	const filename = "filter.json"
+	defer cleanUp(filename)
+
+	s := newFileStore(t, filename)
+	addFilterItems(t, s)
+	assertFilters(t, s)
+}
+
+func TestFilterDb(t *testing.T) {
+	filePath, store := getStore(t)
+	defer tearDown(filePath)
+
+	clearStore(store)
+	addFilterItems(t, store)
+	assertFilters(t, store)
+}
+
+func TestListAllTags(t *testing.T) {
+	filePath, store := getStore(t)
+	defer tearDown(filePath)
+
+	clearStore(store)
+	addFilterItems(t, store)
+
+	items, err := store.GetAllItems(ctx, storage.ListOptions{Tags: []string{"work", "blocked"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, items.Size)
+	assert.Equal(t, "blocked", items.Items[0].Name)
+}
+
+// assertOverdueInLocation Deadlines are overdue once their time has passed
+// and all-day items once their day is over where the listing says it is:
+// Kiritimati is always a day ahead of Honolulu.
+func assertOverdueInLocation(t *testing.T, store storage.TodoStore) {
+	honolulu, err := time.LoadLocation("Pacific/Honolulu")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	kiritimati, _ := time.LoadLocation("Pacific/Kiritimati")
+
+	now := time.Now()
+	today := internal.Day(now.In(honolulu))
+	past, later := now.Add(-time.Hour).UTC().Truncate(time.Millisecond), now.Add(time.Hour).UTC().Truncate(time.Millisecond)
+	store.AddItem(ctx, internal.Todo{Name: "day", DueDate: &today})
+	added, err := store.AddItem(ctx, internal.Todo{Name: "past", DueDate: &past, DueTimed: true, DueZone: "Europe/London"})
+	assert.Nil(t, err)
+	store.AddItem(ctx, internal.Todo{Name: "later", DueDate: &later, DueTimed: true})
+
+	got, err := store.GetItem(ctx, added.ID)
+	assert.Nil(t, err)
+	assert.True(t, past.Equal(*got.DueDate))
+	assert.True(t, got.DueTimed)
+	assert.Equal(t, "Europe/London", got.DueZone)
+
+	for loc, want := range map[*time.Location][]string{honolulu: {"past"}, kiritimati: {"day", "past"}} {
+		items, err := store.GetAllItems(ctx, storage.ListOptions{Overdue: true, Location: loc})
+		assert.Nil(t, err)
+		assert.Equal(t, want, names(items.Items), loc.String())
+	}
+}
+
+func TestOverdueInLocationFileStore(t *testing.T) {
+	const filename = "overdue.json"
+	defer cleanUp(filename)
+
+	assertOverdueInLocation(t, newFileStore(t, filename))
+}
+
+func TestOverdueInLocationDb(t *testing.T) {
+	filePath, store := getStore(t)
+	defer tearDown(filePath)
+
+	clearStore(store)
+	assertOverdueInLocation(t, store)
+}
+
+// assertDeferred Items are hidden until their start date unless asked
+// for, but always listed in the trash.
+func assertDeferred(t *testing.T, store storage.TodoStore) {
+	began, later := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	store.AddItem(ctx, internal.Todo{Name: "any time"})
+	store.AddItem(ctx, internal.Todo{Name: "began", StartDate: &began})
+	deferred, err := store.AddItem(ctx, internal.Todo{Name: "later", StartDate: &later})
+	assert.Nil(t, err)
+
+	items, err := store.GetAllItems(ctx, storage.ListOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"any time", "began"}, names(items.Items))
+
+	items, err = store.GetAllItems(ctx, storage.ListOptions{IncludeDeferred: true, Limit: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"any time", "began"}, names(items.Items))
+	items, err = store.GetAllItems(ctx, storage.ListOptions{IncludeDeferred: true, Limit: 2, Cursor: items.NextCursor})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"later"}, names(items.Items))
+
+	_, err = store.DeleteItem(ctx, deferred.ID)
+	assert.Nil(t, err)
+	items, err = store.GetAllItems(ctx, storage.ListOptions{Trashed: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"later"}, names(items.Items))
+}
+
+func TestDeferredFileStore(t *testing.T) {
+	const filename = "deferred.json"
+	defer cleanUp(filename)
+
+	assertDeferred(t, newFileStore(t, filename))
+}
+
+func TestDeferredDb(t *testing.T) {
+	filePath, store := getStore(t)
+	defer tearDown(filePath)
+
+	clearStore(store)
+	assertDeferred(t, store)
+}
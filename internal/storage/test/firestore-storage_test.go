@@ -0,0 +1,354 @@
+package storage_test
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/option"
+	pb "google.golang.org/genproto/googleapis/firestore/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/storage"
+	"github.com/tcooper-uk/go-todo/internal/storage/db"
+)
+
+const fakeRoot = "projects/test/databases/(default)/documents/"
+
+// fakeFirestore An in-memory Firestore answering just the queries and
+// writes CloudStore makes, which records the queries it runs.
+type fakeFirestore struct {
+	pb.UnimplementedFirestoreServer
+
+	mu      sync.Mutex
+	docs    map[string]*pb.Document
+	queries []*pb.StructuredQuery
+}
+
+// newCloudStore A CloudStore talking to a fresh fakeFirestore.
+func newCloudStore(t *testing.T) (*db.CloudStore, *fakeFirestore) {
+	fake := &fakeFirestore{docs: make(map[string]*pb.Document)}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	srv := grpc.NewServer()
+	pb.RegisterFirestoreServer(srv, fake)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	client, err := firestore.NewClient(ctx, "test", option.WithGRPCConn(conn))
+	assert.Nil(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	store, err := db.NewCloudStore(ctx, &db.CloudStoreConfig{ProjectId: "test", Client: client})
+	assert.Nil(t, err)
+	return store, fake
+}
+
+// listings The queries run over every items collection, as GetAllItems
+// runs them, since the last call.
+func (f *fakeFirestore) listings() []*pb.StructuredQuery {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var listings []*pb.StructuredQuery
+	for _, q := range f.queries {
+		if q.From[0].AllDescendants {
+			listings = append(listings, q)
+		}
+	}
+	f.queries = nil
+	return listings
+}
+
+func (f *fakeFirestore) BatchGetDocuments(req *pb.BatchGetDocumentsRequest, stream pb.Firestore_BatchGetDocumentsServer) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, name := range req.Documents {
+		resp := &pb.BatchGetDocumentsResponse{ReadTime: timestamppb.Now()}
+		if doc, ok := f.docs[name]; ok {
+			resp.Result = &pb.BatchGetDocumentsResponse_Found{Found: doc}
+		} else {
+			resp.Result = &pb.BatchGetDocumentsResponse_Missing{Missing: name}
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeFirestore) Commit(_ context.Context, req *pb.CommitRequest) (*pb.CommitResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := timestamppb.Now()
+	resp := &pb.CommitResponse{CommitTime: now}
+	for _, w := range req.Writes {
+		switch op := w.Operation.(type) {
+		case *pb.Write_Delete:
+			delete(f.docs, op.Delete)
+		case *pb.Write_Update:
+			doc, ok := f.docs[op.Update.Name]
+			if !ok || w.UpdateMask == nil {
+				doc = &pb.Document{Name: op.Update.Name, Fields: map[string]*pb.Value{}, CreateTime: now}
+			}
+			if w.UpdateMask == nil {
+				doc.Fields = op.Update.Fields
+			} else {
+				for _, path := range w.UpdateMask.FieldPaths {
+					doc.Fields[path] = op.Update.Fields[path]
+				}
+			}
+			doc.UpdateTime = now
+			f.docs[doc.Name] = doc
+		default:
+			return nil, status.Errorf(codes.Unimplemented, "write %T", op)
+		}
+		resp.WriteResults = append(resp.WriteResults, &pb.WriteResult{UpdateTime: now})
+	}
+	return resp, nil
+}
+
+func (f *fakeFirestore) RunQuery(req *pb.RunQueryRequest, stream pb.Firestore_RunQueryServer) error {
+	f.mu.Lock()
+	q := req.GetStructuredQuery()
+	f.queries = append(f.queries, q)
+
+	var docs []*pb.Document
+	for name, doc := range f.docs {
+		path := strings.Split(strings.TrimPrefix(name, fakeRoot), "/")
+		if path[len(path)-2] == q.From[0].CollectionId && matchFilter(doc, q.Where) {
+			docs = append(docs, doc)
+		}
+	}
+	f.mu.Unlock()
+
+	sort.Slice(docs, func(i, j int) bool { return compareOrder(docs[i], docs[j], q.OrderBy) < 0 })
+	if c := q.StartAt; c != nil {
+		for len(docs) > 0 {
+			cmp := compareCursor(docs[0], c.Values, q.OrderBy)
+			if cmp > 0 || (cmp == 0 && c.Before) {
+				break
+			}
+			docs = docs[1:]
+		}
+	}
+	if int(q.Offset) < len(docs) {
+		docs = docs[q.Offset:]
+	} else {
+		docs = nil
+	}
+	if q.Limit != nil && int(q.Limit.Value) < len(docs) {
+		docs = docs[:q.Limit.Value]
+	}
+
+	for _, doc := range docs {
+		if err := stream.Send(&pb.RunQueryResponse{Document: doc, ReadTime: timestamppb.Now()}); err != nil {
+			return err
+		}
+	}
+	return stream.Send(&pb.RunQueryResponse{ReadTime: timestamppb.Now()})
+}
+
+func matchFilter(doc *pb.Document, f *pb.StructuredQuery_Filter) bool {
+	switch {
+	case f == nil:
+		return true
+	case f.GetCompositeFilter() != nil:
+		for _, each := range f.GetCompositeFilter().Filters {
+			if !matchFilter(doc, each) {
+				return false
+			}
+		}
+		return true
+	case f.GetUnaryFilter() != nil:
+		u := f.GetUnaryFilter()
+		v, ok := doc.Fields[u.GetField().FieldPath]
+		return u.Op == pb.StructuredQuery_UnaryFilter_IS_NULL && ok && isNull(v)
+	}
+	ff := f.GetFieldFilter()
+	v, ok := doc.Fields[ff.Field.FieldPath]
+	if !ok || isNull(v) {
+		return false
+	}
+	c := compareValues(v, ff.Value)
+	switch ff.Op {
+	case pb.StructuredQuery_FieldFilter_EQUAL:
+		return c == 0
+	case pb.StructuredQuery_FieldFilter_LESS_THAN_OR_EQUAL:
+		return c <= 0
+	case pb.StructuredQuery_FieldFilter_LESS_THAN:
+		return c < 0
+	case pb.StructuredQuery_FieldFilter_ARRAY_CONTAINS:
+		for _, e := range v.GetArrayValue().GetValues() {
+			if compareValues(e, ff.Value) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isNull(v *pb.Value) bool {
+	_, ok := v.ValueType.(*pb.Value_NullValue)
+	return ok
+}
+
+func orderValue(doc *pb.Document, field string) *pb.Value {
+	if field == "__name__" {
+		return &pb.Value{ValueType: &pb.Value_ReferenceValue{ReferenceValue: doc.Name}}
+	}
+	return doc.Fields[field]
+}
+
+func compareOrder(a, b *pb.Document, orders []*pb.StructuredQuery_Order) int {
+	for _, o := range orders {
+		c := compareValues(orderValue(a, o.Field.FieldPath), orderValue(b, o.Field.FieldPath))
+		if o.Direction == pb.StructuredQuery_DESCENDING {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareCursor(doc *pb.Document, values []*pb.Value, orders []*pb.StructuredQuery_Order) int {
+	for i, v := range values {
+		c := compareValues(orderValue(doc, orders[i].Field.FieldPath), v)
+		if orders[i].Direction == pb.StructuredQuery_DESCENDING {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareValues Order values as Firestore does, for the types items use.
+func compareValues(a, b *pb.Value) int {
+	rank := func(v *pb.Value) int {
+		switch v.GetValueType().(type) {
+		case *pb.Value_NullValue, nil:
+			return 0
+		case *pb.Value_BooleanValue:
+			return 1
+		case *pb.Value_IntegerValue:
+			return 2
+		case *pb.Value_TimestampValue:
+			return 3
+		case *pb.Value_StringValue:
+			return 4
+		case *pb.Value_ReferenceValue:
+			return 5
+		}
+		return 6
+	}
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return ra - rb
+	}
+	switch a.GetValueType().(type) {
+	case *pb.Value_BooleanValue:
+		if a.GetBooleanValue() == b.GetBooleanValue() {
+			return 0
+		}
+		if b.GetBooleanValue() {
+			return -1
+		}
+		return 1
+	case *pb.Value_IntegerValue:
+		x, y := a.GetIntegerValue(), b.GetIntegerValue()
+		if x < y {
+			return -1
+		}
+		if x > y {
+			return 1
+		}
+	case *pb.Value_TimestampValue:
+		return a.GetTimestampValue().AsTime().Compare(b.GetTimestampValue().AsTime())
+	case *pb.Value_StringValue:
+		return strings.Compare(a.GetStringValue(), b.GetStringValue())
+	case *pb.Value_ReferenceValue:
+		return strings.Compare(a.GetReferenceValue(), b.GetReferenceValue())
+	}
+	return 0
+}
+
+// TestCloudStorePagesDeferredHidingListsNatively A limited listing that
+// hides deferred items is still cut by Firestore, a page at a time, with
+// the items stored before start dates existed among them.
+func TestCloudStorePagesDeferredHidingListsNatively(t *testing.T) {
+	store, fake := newCloudStore(t)
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(72 * time.Hour)
+	for _, todo := range []internal.Todo{
+		{Name: "a"},
+		{Name: "b", StartDate: &past},
+		{Name: "deferred", StartDate: &future},
+		{Name: "c"},
+		{Name: "d", StartDate: &past},
+	} {
+		_, err := store.AddItem(ctx, todo)
+		assert.Nil(t, err)
+	}
+	// An item written before start dates, without the field.
+	fake.mu.Lock()
+	fake.docs[fakeRoot+"todos/legacy"] = &pb.Document{
+		Name: fakeRoot + "todos/legacy",
+		Fields: map[string]*pb.Value{
+			"ID":   {ValueType: &pb.Value_IntegerValue{IntegerValue: 6}},
+			"Name": {ValueType: &pb.Value_StringValue{StringValue: "e"}},
+			"Done": {ValueType: &pb.Value_BooleanValue{BooleanValue: false}},
+		},
+		CreateTime: timestamppb.Now(),
+		UpdateTime: timestamppb.Now(),
+	}
+	fake.mu.Unlock()
+
+	var pages [][]string
+	opts := storage.ListOptions{Limit: 2}
+	for {
+		page, err := store.GetAllItems(ctx, opts)
+		assert.Nil(t, err)
+		pages = append(pages, names(page.Items))
+
+		// Each query fetches no more than a page and one more item.
+		for _, q := range fake.listings() {
+			if assert.NotNil(t, q.Limit) {
+				assert.Equal(t, int32(3), q.Limit.Value)
+			}
+		}
+		if page.NextCursor == "" || len(pages) > 3 {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	assert.Equal(t, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}, pages)
+	fake.mu.Lock()
+	assert.Contains(t, fake.docs[fakeRoot+"todos/legacy"].Fields, "StartDate")
+	fake.mu.Unlock()
+
+	// Offsets skip within the merged listing.
+	page, err := store.GetAllItems(ctx, storage.ListOptions{Limit: 2, Offset: 1, Sort: []storage.SortKey{{Field: storage.SortID, Desc: true}}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"d", "c"}, names(page.Items))
+	assert.NotEmpty(t, page.NextCursor)
+
+	items, err := store.GetAllItems(ctx, storage.ListOptions{Limit: 10, IncludeDeferred: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b", "deferred", "c", "d", "e"}, names(items.Items))
+}
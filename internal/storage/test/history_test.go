@@ -0,0 +1,121 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/storage"
+)
+
+// change The parts of a FieldChange that don't depend on when it was made.
+type change struct {
+	id              int
+	actor, field    string
+	oldValue, value string
+}
+
+func changesOf(changes []storage.FieldChange) []change {
+	var got []change
+	for _, c := range changes {
+		value := c.New
+		if c.Field == "deleted_at" && value != "" {
+			value = "set"
+		}
+		oldValue := c.Old
+		if c.Field == "deleted_at" && oldValue != "" {
+			oldValue = "set"
+		}
+		got = append(got, change{c.ItemID, c.Actor, c.Field, oldValue, value})
+	}
+	return got
+}
+
+func assertHistory(t *testing.T, store storage.TodoStore) {
+	start := time.Now().Add(-time.Second)
+	alice := storage.WithActor(ctx, "alice")
+	bob := storage.WithActor(ctx, "bob")
+
+	due, _ := time.Parse("2006-01-02", "2026-11-01")
+	item, err := store.AddItem(alice, internal.Todo{Name: "plan the party", Priority: internal.PriorityLow, DueDate: &due})
+	assert.Nil(t, err)
+	other, _ := store.AddItem(alice, newTodo("other"))
+
+	edited := *item
+	edited.Priority = internal.PriorityHigh
+	later := due.AddDate(0, 0, 4)
+	edited.DueDate = &later
+	edited.Tags = []string{"fun", "home"}
+	_, err = store.EditItem(bob, item.ID, edited)
+	assert.Nil(t, err)
+
+	// An edit that changes nothing records nothing.
+	_, err = store.EditItem(bob, item.ID, edited)
+	assert.Nil(t, err)
+
+	_, err = store.DeleteItem(alice, item.ID)
+	assert.Nil(t, err)
+	_, err = store.RestoreItem(bob, item.ID)
+	assert.Nil(t, err)
+
+	history, err := storage.ItemHistory(ctx, store, item.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, []change{
+		{item.ID, "alice", "name", "", "plan the party"},
+		{item.ID, "alice", "priority", "", "low"},
+		{item.ID, "alice", "due_date", "", "2026-11-01"},
+		{item.ID, "bob", "priority", "low", "high"},
+		{item.ID, "bob", "due_date", "2026-11-01", "2026-11-05"},
+		{item.ID, "bob", "tags", "", "fun,home"},
+		{item.ID, "alice", "deleted_at", "", "set"},
+		{item.ID, "bob", "deleted_at", "set", ""},
+	}, changesOf(history))
+
+	// The history outlives the item.
+	store.DeleteItem(ctx, item.ID)
+	store.EmptyTrash(ctx, time.Time{})
+	history, err = storage.ItemHistory(ctx, store, item.ID)
+	assert.Nil(t, err)
+	assert.Len(t, history, 9)
+	assert.Equal(t, "unknown", history[8].Actor)
+
+	all, err := storage.ChangesSince(ctx, store, start)
+	assert.Nil(t, err)
+	assert.Len(t, all, 10)
+	assert.Equal(t, change{other.ID, "alice", "name", "", "other"}, changesOf(all)[3])
+	for i := 1; i < len(all); i++ {
+		assert.False(t, all[i].At.Before(all[i-1].At))
+	}
+
+	none, err := storage.ChangesSince(ctx, store, time.Now().Add(time.Minute))
+	assert.Nil(t, err)
+	assert.Empty(t, none)
+}
+
+func TestHistoryFileStore(t *testing.T) {
+	store := newFileStore(t, filepath.Join(t.TempDir(), "todo.json"))
+	assertHistory(t, storage.WithHistory(store))
+	assert.FileExists(t, store.HistoryPath())
+}
+
+func TestHistoryDb(t *testing.T) {
+	assertHistory(t, storage.WithHistory(newSQLiteStore(t)))
+}
+
+func TestHistoryRemote(t *testing.T) {
+	// The server records the history, as made by the client's actor.
+	remote := newRemoteStore(t, nil)
+	assert.Same(t, storage.TodoStore(remote), storage.WithHistory(remote))
+	assertHistory(t, remote)
+}
+
+func TestNoHistoryWithoutWrapping(t *testing.T) {
+	store := newSQLiteStore(t)
+	store.AddItem(ctx, newTodo("unrecorded"))
+	history, err := storage.ItemHistory(ctx, store, 1)
+	assert.Nil(t, err)
+	assert.Empty(t, history)
+}
@@ -0,0 +1,35 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/storage"
+)
+
+func TestImportLinksSubtasksToNewIds(t *testing.T) {
+	store := newFileStore(t, filepath.Join(t.TempDir(), "todo.json"))
+	store.AddItem(ctx, newTodo("already there"))
+
+	done := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	added, err := storage.Import(ctx, store, []internal.Todo{
+		{Name: "first"},
+		{ID: 5, Name: "child", ParentID: 9, Done: true, UpdatedAt: done},
+		{ID: 9, Name: "parent"},
+		{Name: "orphan", ParentID: 42},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"first", "parent", "orphan", "child"}, names(added))
+
+	parent := itemNamed(t, store, "parent")
+	child := itemNamed(t, store, "child")
+	assert.NotEqual(t, 9, parent.ID)
+	assert.Equal(t, parent.ID, child.ParentID)
+	assert.True(t, done.Equal(child.UpdatedAt))
+	assert.Equal(t, 0, itemNamed(t, store, "orphan").ParentID)
+	assert.Equal(t, 5, count(t, store))
+}
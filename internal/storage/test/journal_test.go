@@ -0,0 +1,141 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/storage"
+)
+
+// journaled A store recording into the journal at path, as a fresh
+// command would.
+func journaled(store storage.TodoStore, path, command string) storage.TodoStore {
+	return storage.WithJournal(store, path, command)
+}
+
+func loadJournal(t *testing.T, path string) *storage.Journal {
+	journal, err := storage.LoadJournal(path)
+	assert.Nil(t, err)
+	return journal
+}
+
+func assertUndoRedo(t *testing.T, store storage.TodoStore) {
+	path := filepath.Join(t.TempDir(), "journal.json")
+
+	// Two items added by one command are one operation.
+	add := journaled(store, path, "import")
+	parent, err := add.AddItem(ctx, internal.Todo{Name: "plan the party", Tags: []string{"fun"}})
+	assert.Nil(t, err)
+	child, err := add.AddItem(ctx, internal.Todo{Name: "book the venue", ParentID: parent.ID})
+	assert.Nil(t, err)
+
+	edit := journaled(store, path, "edit")
+	edited := *parent
+	edited.Priority = internal.PriorityHigh
+	_, err = edit.EditItem(ctx, parent.ID, edited)
+	assert.Nil(t, err)
+	beforeDelete, _ := store.GetItem(ctx, parent.ID)
+
+	_, err = journaled(store, path, "rm").DeleteItem(ctx, parent.ID, child.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count(t, store))
+
+	// Undone, the items are back as they were, IDs and times included.
+	undone, err := loadJournal(t, path).Undo(ctx, store, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "rm", undone[0].Command)
+	got, err := store.GetItem(ctx, parent.ID)
+	assert.Nil(t, err)
+	assertSameItem(t, *beforeDelete, *got)
+	got, err = store.GetItem(ctx, child.ID)
+	assert.Nil(t, err)
+	assertSameItem(t, *child, *got)
+
+	undone, err = loadJournal(t, path).Undo(ctx, store, 1)
+	assert.Nil(t, err)
+	assert.Len(t, undone, 1)
+	got, _ = store.GetItem(ctx, parent.ID)
+	assertSameItem(t, *parent, *got)
+
+	// Redone, both are deleted again.
+	redone, err := loadJournal(t, path).Redo(ctx, store, 5)
+	assert.Nil(t, err)
+	assert.Len(t, redone, 2)
+	assert.Equal(t, 0, count(t, store))
+
+	// Undoing everything leaves nothing added.
+	undone, err = loadJournal(t, path).Undo(ctx, store, 5)
+	assert.Nil(t, err)
+	assert.Len(t, undone, 3)
+	assert.Equal(t, 0, count(t, store))
+	assert.Empty(t, loadJournal(t, path).Done)
+}
+
+func TestUndoRedoFileStore(t *testing.T) {
+	assertUndoRedo(t, newFileStore(t, filepath.Join(t.TempDir(), "todo.json")))
+}
+
+func TestUndoRedoDb(t *testing.T) {
+	assertUndoRedo(t, newSQLiteStore(t))
+}
+
+func TestUndoRedoRemote(t *testing.T) {
+	assertUndoRedo(t, newRemoteStore(t, nil))
+}
+
+func TestUndoDeleteAll(t *testing.T) {
+	store := newSQLiteStore(t)
+	path := filepath.Join(t.TempDir(), "journal.json")
+	items := addMigrateItems(t, store)
+
+	_, err := journaled(store, path, "clearall").DeleteAllItems(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count(t, store))
+
+	_, err = loadJournal(t, path).Undo(ctx, store, 1)
+	assert.Nil(t, err)
+	for _, item := range items {
+		got, err := store.GetItem(ctx, item.ID)
+		assert.Nil(t, err)
+		assertSameItem(t, item, *got)
+	}
+}
+
+func TestUndoRefusesToLoseLaterChanges(t *testing.T) {
+	store := newFileStore(t, filepath.Join(t.TempDir(), "todo.json"))
+	path := filepath.Join(t.TempDir(), "journal.json")
+
+	item, _ := journaled(store, path, "add").AddItem(ctx, newTodo("water the plants"))
+	item.Name = "water the garden"
+	_, err := store.EditItem(ctx, item.ID, *item)
+	assert.Nil(t, err)
+
+	journal := loadJournal(t, path)
+	undone, err := journal.Undo(ctx, store, 1)
+	assert.ErrorIs(t, err, storage.ErrConflict)
+	assert.Empty(t, undone)
+	assert.Len(t, journal.Done, 1)
+	assert.Equal(t, 1, count(t, store))
+}
+
+func TestNewOperationClearsRedo(t *testing.T) {
+	store := newFileStore(t, filepath.Join(t.TempDir(), "todo.json"))
+	path := filepath.Join(t.TempDir(), "journal.json")
+
+	journaled(store, path, "add one").AddItem(ctx, newTodo("one"))
+	_, err := loadJournal(t, path).Undo(ctx, store, 1)
+	assert.Nil(t, err)
+	assert.Len(t, loadJournal(t, path).Undone, 1)
+
+	journaled(store, path, "add two").AddItem(ctx, newTodo("two"))
+	journal := loadJournal(t, path)
+	assert.Empty(t, journal.Undone)
+	assert.Len(t, journal.Done, 1)
+
+	// Reading changes nothing, so is not an operation.
+	journaled(store, path, "list").GetAllItems(ctx, storage.ListOptions{})
+	assert.Len(t, loadJournal(t, path).Done, 1)
+}
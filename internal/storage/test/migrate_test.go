@@ -0,0 +1,254 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/storage"
+	"github.com/tcooper-uk/go-todo/internal/storage/db"
+)
+
+func newSQLiteStore(t *testing.T) *db.SQLLiteStore {
+	store, err := db.NewSQLLiteStorage(ctx, filepath.Join(t.TempDir(), "todo.db"))
+	assert.Nil(t, err)
+	return store
+}
+
+// addMigrateItems Fill store with items using every field, returning them
+// as stored.
+func addMigrateItems(t *testing.T, store storage.TodoStore) []internal.Todo {
+	due := time.Date(2026, 11, 1, 9, 30, 0, 0, time.UTC)
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	start := time.Date(2099, 3, 1, 8, 0, 0, 0, time.UTC)
+	todos := []internal.Todo{
+		{Name: "plan the party", Priority: internal.PriorityHigh, Tags: []string{"home", "fun"}, CreatedAt: created},
+		{Name: "book the venue", Done: true, DueDate: &due, DueTimed: true, DueZone: "Europe/London"},
+		{Name: "water the plants", Recurrence: "FREQ=WEEKLY", StartDate: &start},
+	}
+
+	var added []internal.Todo
+	for i, todo := range todos {
+		if i == 1 {
+			todo.ParentID = added[0].ID
+		}
+		item, err := store.AddItem(ctx, todo)
+		assert.Nil(t, err)
+		added = append(added, *item)
+	}
+	return added
+}
+
+func assertSameItem(t *testing.T, want, got internal.Todo) {
+	t.Helper()
+	assert.Equal(t, want.ID, got.ID)
+	assert.Equal(t, want.Name, got.Name)
+	assert.Equal(t, want.Done, got.Done)
+	assert.Equal(t, want.Priority, got.Priority)
+	assert.Equal(t, want.Tags, got.Tags)
+	assert.Equal(t, want.ParentID, got.ParentID)
+	assert.Equal(t, want.Recurrence, got.Recurrence)
+	assert.Equal(t, want.DueDate == nil, got.DueDate == nil)
+	if want.DueDate != nil && got.DueDate != nil {
+		assert.True(t, want.DueDate.Equal(*got.DueDate))
+	}
+	assert.Equal(t, want.DueTimed, got.DueTimed)
+	assert.Equal(t, want.DueZone, got.DueZone)
+	assert.Equal(t, want.StartDate == nil, got.StartDate == nil)
+	if want.StartDate != nil && got.StartDate != nil {
+		assert.True(t, want.StartDate.Equal(*got.StartDate))
+	}
+	assert.Equal(t, want.CreatedAt.UnixMilli(), got.CreatedAt.UnixMilli())
+	assert.Equal(t, want.UpdatedAt.UnixMilli(), got.UpdatedAt.UnixMilli())
+}
+
+func TestPutItemKeepsIdAndTimes(t *testing.T) {
+	stores := map[string]storage.TodoStore{
+		"file":   newFileStore(t, filepath.Join(t.TempDir(), "todo.json")),
+		"sqlite": newSQLiteStore(t),
+		"remote": newRemoteStore(t, nil),
+	}
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	updated := time.Date(2025, 6, 7, 8, 9, 10, 0, time.UTC)
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			todo := internal.Todo{ID: 7, Name: "copied", Tags: []string{"x"}, CreatedAt: created, UpdatedAt: updated}
+			put, err := store.PutItem(ctx, todo)
+			assert.Nil(t, err)
+			assertSameItem(t, todo, *put)
+
+			// Putting it again replaces it.
+			todo.Name, todo.Done = "replaced", true
+			_, err = store.PutItem(ctx, todo)
+			assert.Nil(t, err)
+			got, err := store.GetItem(ctx, 7)
+			assert.Nil(t, err)
+			assertSameItem(t, todo, *got)
+
+			// New items are numbered after it.
+			added, err := store.AddItem(ctx, newTodo("next"))
+			assert.Nil(t, err)
+			assert.Greater(t, added.ID, 7)
+
+			// A trashed item stays in the trash, and comes out when put back live.
+			deleted := time.Date(2025, 7, 8, 9, 10, 11, 0, time.UTC)
+			todo.DeletedAt = &deleted
+			_, err = store.PutItem(ctx, todo)
+			assert.Nil(t, err)
+			_, err = store.GetItem(ctx, 7)
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+			trash, err := store.GetAllItems(ctx, storage.ListOptions{Trashed: true, ShowDone: true})
+			assert.Nil(t, err)
+			if assert.Equal(t, 1, trash.Size) {
+				assert.True(t, deleted.Equal(*trash.Items[0].DeletedAt))
+			}
+			todo.DeletedAt = nil
+			_, err = store.PutItem(ctx, todo)
+			assert.Nil(t, err)
+			_, err = store.GetItem(ctx, 7)
+			assert.Nil(t, err)
+
+			_, err = store.PutItem(ctx, internal.Todo{Name: "no id"})
+			assert.ErrorIs(t, err, storage.ErrNoID)
+		})
+	}
+}
+
+func TestMigrateFileToSQLite(t *testing.T) {
+	file := newFileStore(t, filepath.Join(t.TempDir(), "todo.json"))
+	sqlite := newSQLiteStore(t)
+	items := addMigrateItems(t, file)
+
+	report, err := storage.Migrate(ctx, file, sqlite, storage.MigrateOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, report.Added, 3)
+	assert.Equal(t, 3, report.Count)
+	assert.NotEmpty(t, report.Checksum)
+
+	for _, item := range items {
+		got, err := sqlite.GetItem(ctx, item.ID)
+		assert.Nil(t, err)
+		assertSameItem(t, item, *got)
+	}
+
+	// Running it again copies nothing, and the way back finds the same.
+	report, err = storage.Migrate(ctx, file, sqlite, storage.MigrateOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, report.Changes())
+	assert.Equal(t, 3, report.Unchanged)
+	back, err := storage.Migrate(ctx, sqlite, file, storage.MigrateOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, back.Changes())
+	assert.Equal(t, report.Checksum, back.Checksum)
+}
+
+// TestMigrateDeferredItemFileToSQLite A start date the file store keeps
+// to the nanosecond checks out once SQLite has it to the millisecond.
+func TestMigrateDeferredItemFileToSQLite(t *testing.T) {
+	file := newFileStore(t, filepath.Join(t.TempDir(), "todo.json"))
+	sqlite := newSQLiteStore(t)
+	start := time.Now().Add(72 * time.Hour)
+	_, err := file.AddItem(ctx, internal.Todo{Name: "deferred", StartDate: &start})
+	assert.Nil(t, err)
+
+	report, err := storage.Migrate(ctx, file, sqlite, storage.MigrateOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, report.Added, 1)
+
+	report, err = storage.Migrate(ctx, file, sqlite, storage.MigrateOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, report.Changes())
+}
+
+func TestMigrateUpdatesChangedItemsAndKeepsOthers(t *testing.T) {
+	dir := t.TempDir()
+	from := newFileStore(t, filepath.Join(dir, "from.json"))
+	to := newFileStore(t, filepath.Join(dir, "to.json"))
+	items := addMigrateItems(t, from)
+	storage.Migrate(ctx, from, to, storage.MigrateOptions{})
+
+	edited := items[0]
+	edited.Tags = []string{"home"}
+	edited.Done = true
+	from.PutItem(ctx, edited)
+	to.PutItem(ctx, internal.Todo{ID: 50, Name: "only in target"})
+
+	report, err := storage.Migrate(ctx, from, to, storage.MigrateOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, report.Added)
+	assert.Len(t, report.Updated, 1)
+	assert.Equal(t, items[0].ID, report.Updated[0].Item.ID)
+	assert.Equal(t, []string{"done", "tags"}, report.Updated[0].Fields)
+	assert.Equal(t, 2, report.Unchanged)
+	assert.Equal(t, 1, report.OnlyInTarget)
+
+	got, _ := to.GetItem(ctx, items[0].ID)
+	assert.Equal(t, []string{"home"}, got.Tags)
+	_, err = to.GetItem(ctx, 50)
+	assert.Nil(t, err)
+}
+
+func TestMigrateDryRunChangesNothing(t *testing.T) {
+	file := newFileStore(t, filepath.Join(t.TempDir(), "todo.json"))
+	sqlite := newSQLiteStore(t)
+	addMigrateItems(t, file)
+
+	report, err := storage.Migrate(ctx, file, sqlite, storage.MigrateOptions{DryRun: true})
+	assert.Nil(t, err)
+	assert.Len(t, report.Added, 3)
+	assert.Empty(t, report.Checksum)
+	assert.Equal(t, 0, count(t, sqlite))
+
+	// Parents are listed before their subtasks.
+	assert.Equal(t, "plan the party", report.Added[0].Name)
+}
+
+func TestMigrateThroughRemote(t *testing.T) {
+	sqlite := newSQLiteStore(t)
+	remote := newRemoteStore(t, nil)
+	file := newFileStore(t, filepath.Join(t.TempDir(), "todo.json"))
+	items := addMigrateItems(t, sqlite)
+
+	_, err := storage.Migrate(ctx, sqlite, remote, storage.MigrateOptions{})
+	assert.Nil(t, err)
+	_, err = storage.Migrate(ctx, remote, file, storage.MigrateOptions{})
+	assert.Nil(t, err)
+
+	for _, item := range items {
+		got, err := file.GetItem(ctx, item.ID)
+		assert.Nil(t, err)
+		assertSameItem(t, item, *got)
+	}
+}
+
+func TestMigrateCopiesProjects(t *testing.T) {
+	file := newFileStore(t, filepath.Join(t.TempDir(), "todo.json"))
+	sqlite := newSQLiteStore(t)
+	work := putProject(t, file, "work", 0)
+	clients := putProject(t, file, "clients", work.ID)
+	clients.Archived = true
+	_, err := storage.PutProject(ctx, file, *clients)
+	assert.Nil(t, err)
+	item, _ := file.AddItem(ctx, internal.Todo{Name: "call acme", Project: clients.ID})
+
+	report, err := storage.Migrate(ctx, file, sqlite, storage.MigrateOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, report.Projects, 2)
+
+	projects, err := storage.Projects(ctx, sqlite)
+	assert.Nil(t, err)
+	assert.Len(t, projects, 2)
+	assert.Equal(t, "work/clients", internal.ProjectPath(projects, clients.ID))
+	assert.True(t, projects[1].Archived)
+	got, err := sqlite.GetItem(ctx, item.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, clients.ID, got.Project)
+
+	report, err = storage.Migrate(ctx, file, sqlite, storage.MigrateOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, report.Projects)
+}
@@ -0,0 +1,140 @@
+package storage_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/storage"
+)
+
+func putProject(t *testing.T, store storage.TodoStore, name string, parent int) *internal.Project {
+	t.Helper()
+	p, err := storage.PutProject(ctx, store, internal.Project{Name: name, ParentID: parent})
+	assert.Nil(t, err)
+	return p
+}
+
+func assertProjects(t *testing.T, store storage.TodoStore) {
+	work := putProject(t, store, "work", 0)
+	clients := putProject(t, store, " clients ", work.ID)
+	home := putProject(t, store, "home", 0)
+	assert.Equal(t, "clients", clients.Name)
+	assert.False(t, clients.CreatedAt.IsZero())
+
+	projects, err := storage.Projects(ctx, store)
+	assert.Nil(t, err)
+	assert.Len(t, projects, 3)
+	assert.Equal(t, "work/clients", internal.ProjectPath(projects, clients.ID))
+
+	// Names are unique within a parent, ignoring case, and parents must
+	// exist and not be inside the project.
+	_, err = storage.PutProject(ctx, store, internal.Project{Name: "Work"})
+	assert.ErrorIs(t, err, storage.ErrConflict)
+	_, err = storage.PutProject(ctx, store, internal.Project{Name: "clients", ParentID: home.ID})
+	assert.Nil(t, err)
+	_, err = storage.PutProject(ctx, store, internal.Project{Name: "x", ParentID: 99})
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	work.ParentID = clients.ID
+	_, err = storage.PutProject(ctx, store, *work)
+	assert.ErrorIs(t, err, storage.ErrConflict)
+	_, err = storage.PutProject(ctx, store, internal.Project{Name: "a/b"})
+	assert.Error(t, err)
+
+	item, err := store.AddItem(ctx, internal.Todo{Name: "call acme", Project: clients.ID})
+	assert.Nil(t, err)
+	assert.Equal(t, clients.ID, item.Project)
+	_, err = store.AddItem(ctx, internal.Todo{Name: "plan q4", Project: work.ID})
+	assert.Nil(t, err)
+	_, err = store.AddItem(ctx, newTodo("loose end"))
+	assert.Nil(t, err)
+
+	got, err := store.GetItem(ctx, item.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, clients.ID, got.Project)
+
+	list, err := store.GetAllItems(ctx, storage.ListOptions{Projects: internal.SubProjects(projects, work.ID)})
+	assert.Nil(t, err)
+	assert.Len(t, list.Items, 2)
+	list, err = store.GetAllItems(ctx, storage.ListOptions{Projects: []int{0}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"loose end"}, names(list.Items))
+
+	got.Project = home.ID
+	_, err = store.EditItem(ctx, got.ID, *got)
+	assert.Nil(t, err)
+	list, err = store.GetAllItems(ctx, storage.ListOptions{Projects: []int{home.ID}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"call acme"}, names(list.Items))
+
+	// Archived projects take no new items.
+	home.Archived = true
+	_, err = storage.PutProject(ctx, store, *home)
+	assert.Nil(t, err)
+	assert.ErrorIs(t, storage.CheckProject(ctx, store, home.ID), storage.ErrConflict)
+	assert.ErrorIs(t, storage.CheckProject(ctx, store, 99), storage.ErrNotFound)
+	assert.Nil(t, storage.CheckProject(ctx, store, work.ID))
+	assert.Nil(t, storage.CheckProject(ctx, store, 0))
+}
+
+func TestProjectsFileStore(t *testing.T) {
+	store := newFileStore(t, filepath.Join(t.TempDir(), "todo.json"))
+	assertProjects(t, store)
+	assert.FileExists(t, store.ProjectsPath())
+}
+
+func TestProjectsDb(t *testing.T) {
+	assertProjects(t, newSQLiteStore(t))
+}
+
+func TestProjectsRemote(t *testing.T) {
+	assertProjects(t, newRemoteStore(t, nil))
+}
+
+func TestProjectsThroughWrappers(t *testing.T) {
+	store := storage.WithTimeout(storage.WithHistory(newSQLiteStore(t)), 0)
+	assertProjects(t, journaled(store, filepath.Join(t.TempDir(), "journal.json"), "todo project"))
+}
+
+func TestFindProject(t *testing.T) {
+	projects := []internal.Project{
+		{ID: 1, Name: "work"},
+		{ID: 2, Name: "clients", ParentID: 1},
+		{ID: 3, Name: "home"},
+		{ID: 4, Name: "Clients", ParentID: 3},
+		{ID: 5, Name: "garden", ParentID: 3},
+	}
+
+	p, err := storage.FindProject(projects, "Work/Clients")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, p.ID)
+	p, err = storage.FindProject(projects, "garden")
+	assert.Nil(t, err)
+	assert.Equal(t, 5, p.ID)
+
+	_, err = storage.FindProject(projects, "clients")
+	assert.ErrorIs(t, err, storage.ErrConflict)
+	assert.ErrorContains(t, err, "work/clients, home/Clients")
+	_, err = storage.FindProject(projects, "shed")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestNestProjects(t *testing.T) {
+	projects := []internal.Project{
+		{ID: 1, Name: "work"},
+		{ID: 2, Name: "clients", ParentID: 1},
+		{ID: 3, Name: "Home"},
+		{ID: 4, Name: "acme", ParentID: 2},
+	}
+
+	var got []string
+	for _, n := range internal.NestProjects(projects) {
+		got = append(got, fmt.Sprintf("%d %s", n.Depth, n.Project.Name))
+	}
+	assert.Equal(t, []string{"0 Home", "0 work", "1 clients", "2 acme"}, got)
+	assert.Equal(t, []int{1, 2, 4}, internal.SubProjects(projects, 1))
+	assert.Equal(t, "work/clients/acme", internal.ProjectPath(projects, 4))
+}
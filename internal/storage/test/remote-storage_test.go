@@ -0,0 +1,231 @@
+package storage_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/filter"
+	"github.com/tcooper-uk/go-todo/internal/server"
+	"github.com/tcooper-uk/go-todo/internal/storage"
+	"github.com/tcooper-uk/go-todo/internal/storage/db"
+)
+
+// newRemoteStore A RemoteStore talking to a server over a fresh file store,
+// which keeps a history as todo serve's does, with the handler optionally
+// wrapped to misbehave.
+func newRemoteStore(t *testing.T, wrap func(http.Handler) http.Handler) *db.RemoteStore {
+	files, err := storage.NewLocalFileStore(filepath.Join(t.TempDir(), "todo.json"))
+	assert.Nil(t, err)
+
+	var handler http.Handler = server.New(storage.WithHistory(files), "token")
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	s, err := db.NewRemoteStore(&db.RemoteStoreConfig{URL: srv.URL, Token: "token", Backoff: time.Millisecond})
+	assert.Nil(t, err)
+	return s
+}
+
+func TestRemoteStoreRoundTrip(t *testing.T) {
+	s := newRemoteStore(t, nil)
+
+	due := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	added, err := s.AddItem(ctx, internal.Todo{Name: "parent", Priority: internal.PriorityHigh, DueDate: &due, Tags: []string{"work"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, added.ID)
+	assert.False(t, added.CreatedAt.IsZero())
+
+	child, err := s.AddItem(ctx, internal.Todo{Name: "child", ParentID: added.ID, Recurrence: "weekly"})
+	assert.Nil(t, err)
+
+	got, err := s.GetItem(ctx, added.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "parent", got.Name)
+	assert.True(t, due.Equal(*got.DueDate))
+	assert.Equal(t, []string{"work"}, got.Tags)
+
+	got.Name = "renamed"
+	got.DueDate = nil
+	got.Done = true
+	edited, err := s.EditItem(ctx, got.ID, *got)
+	assert.Nil(t, err)
+	assert.Equal(t, "renamed", edited.Name)
+	assert.Nil(t, edited.DueDate)
+	assert.True(t, edited.Done)
+
+	// Editing is a plain write, so completing an item adds nothing.
+	all, err := s.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, all.Size)
+	assert.Equal(t, child.ID, all.Items[1].ID)
+	assert.Equal(t, "FREQ=WEEKLY", all.Items[1].Recurrence)
+}
+
+func TestRemoteStoreListOptions(t *testing.T) {
+	s := newRemoteStore(t, nil)
+	for _, todo := range []internal.Todo{
+		{Name: "a", Priority: internal.PriorityLow, Tags: []string{"work"}},
+		{Name: "b", Priority: internal.PriorityHigh, Tags: []string{"work", "urgent"}},
+		{Name: "c", Done: true, Tags: []string{"work"}},
+		{Name: "d", Priority: internal.PriorityMedium},
+	} {
+		_, err := s.AddItem(ctx, todo)
+		assert.Nil(t, err)
+	}
+
+	names := func(items *internal.TodoCollection) []string {
+		var names []string
+		for _, item := range items.Items {
+			names = append(names, item.Name)
+		}
+		return names
+	}
+
+	expr, err := filter.Parse("tag:work and (priority>=medium or done:true)")
+	assert.Nil(t, err)
+	items, err := s.GetAllItems(ctx, storage.ListOptions{ShowDone: true, Filter: expr})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b", "c"}, names(items))
+
+	items, err = s.GetAllItems(ctx, storage.ListOptions{Tags: []string{"work", "urgent"}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b"}, names(items))
+
+	items, err = s.GetAllItems(ctx, storage.ListOptions{OnlyDone: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"c"}, names(items))
+
+	opts := storage.ListOptions{Sort: []storage.SortKey{{Field: storage.SortPriority, Desc: true}}, Limit: 2}
+	items, err = s.GetAllItems(ctx, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b", "d"}, names(items))
+	assert.NotEmpty(t, items.NextCursor)
+
+	opts.Cursor = items.NextCursor
+	items, err = s.GetAllItems(ctx, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a"}, names(items))
+	assert.Empty(t, items.NextCursor)
+
+	opts.Cursor = "bogus"
+	_, err = s.GetAllItems(ctx, opts)
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+}
+
+func TestRemoteStoreDelete(t *testing.T) {
+	s := newRemoteStore(t, nil)
+	for _, name := range []string{"one", "two", "three"} {
+		_, err := s.AddItem(ctx, newTodo(name))
+		assert.Nil(t, err)
+	}
+
+	_, err := s.DeleteItem(ctx, 1, 99)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = s.GetItem(ctx, 1)
+	assert.Nil(t, err, "nothing is deleted if any id is missing")
+
+	count, err := s.DeleteItem(ctx, 1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+	_, err = s.GetItem(ctx, 1)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	count, err = s.DeleteAllItems(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestRemoteStoreRejectsWrongToken(t *testing.T) {
+	s := newRemoteStore(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Header.Set("Authorization", "Bearer wrong")
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	_, err := s.GetItem(ctx, 1)
+	assert.ErrorContains(t, err, "TODO_REMOTE_TOKEN")
+	assert.NotErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestRemoteStoreRetriesWhileUnavailable(t *testing.T) {
+	var requests atomic.Int32
+	s := newRemoteStore(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1) <= 2 {
+				http.Error(w, `{"error":"busy"}`, http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	items, err := s.GetAllItems(ctx, storage.ListOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, items.Size)
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestRemoteStoreDoesNotRetryAdds(t *testing.T) {
+	var requests atomic.Int32
+	s := newRemoteStore(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			http.Error(w, `{"error":"busy"}`, http.StatusServiceUnavailable)
+		})
+	})
+
+	_, err := s.AddItem(ctx, newTodo("once"))
+	assert.ErrorIs(t, err, storage.ErrBackendUnavailable)
+	assert.Equal(t, int32(1), requests.Load(), "the item may have been added, so it is not sent again")
+}
+
+func TestRemoteStoreUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	s, err := db.NewRemoteStore(&db.RemoteStoreConfig{URL: srv.URL, Backoff: time.Millisecond})
+	assert.Nil(t, err)
+
+	_, err = s.GetItem(ctx, 1)
+	assert.ErrorIs(t, err, storage.ErrBackendUnavailable)
+	assert.ErrorContains(t, err, "cannot reach todo server")
+
+	_, err = s.AddItem(ctx, newTodo("x"))
+	assert.ErrorIs(t, err, storage.ErrBackendUnavailable)
+}
+
+func TestRemoteStoreInvalidURL(t *testing.T) {
+	for _, u := range []string{"", "todo.lan:8080", "ftp://todo.lan"} {
+		_, err := db.NewRemoteStore(&db.RemoteStoreConfig{URL: u})
+		assert.NotNil(t, err, u)
+	}
+}
+
+func TestRemoteStoreKeepsCreationTime(t *testing.T) {
+	s := newRemoteStore(t, nil)
+
+	created := time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)
+	added, err := s.AddItem(ctx, internal.Todo{Name: "copied", CreatedAt: created})
+	assert.Nil(t, err)
+	assert.True(t, created.Equal(added.CreatedAt))
+	assert.True(t, added.UpdatedAt.After(created))
+}
+
+func TestRemoteStoreOverdueInLocation(t *testing.T) {
+	assertOverdueInLocation(t, newRemoteStore(t, nil))
+}
+
+func TestRemoteStoreDeferred(t *testing.T) {
+	assertDeferred(t, newRemoteStore(t, nil))
+}
@@ -0,0 +1,110 @@
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/storage"
+)
+
+func TestParseSort(t *testing.T) {
+	keys, err := storage.ParseSort("due, -Priority,created")
+	assert.Nil(t, err)
+	assert.Equal(t, []storage.SortKey{
+		{Field: storage.SortDue},
+		{Field: storage.SortPriority, Desc: true},
+		{Field: storage.SortCreated},
+	}, keys)
+
+	_, err = storage.ParseSort("due,urgency")
+	assert.NotNil(t, err)
+	_, err = storage.ParseSort("")
+	assert.NotNil(t, err)
+}
+
+func addSortItems(t *testing.T, store storage.TodoStore) {
+	due := func(s string) *time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return &d
+	}
+
+	for _, item := range []internal.Todo{
+		{Name: "b", Priority: internal.PriorityLow, DueDate: due("2026-11-03")},
+		{Name: "C", Priority: internal.PriorityHigh, DueDate: due("2026-11-02")},
+		{Name: "a", Priority: internal.PriorityHigh},
+		{Name: "d", Priority: internal.PriorityMedium, DueDate: due("2026-11-02")},
+		{Name: "e", DueDate: due("2026-11-01")},
+	} {
+		_, err := store.AddItem(ctx, item)
+		assert.Nil(t, err)
+	}
+}
+
+func names(items []internal.Todo) []string {
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Name)
+	}
+	return names
+}
+
+func assertSortAndPages(t *testing.T, store storage.TodoStore) {
+	cases := map[string][]string{
+		"due":            {"a", "e", "C", "d", "b"},
+		"-due":           {"b", "C", "d", "e", "a"},
+		"due,-priority":  {"a", "e", "C", "d", "b"},
+		"-priority,name": {"a", "C", "d", "b", "e"},
+		"name":           {"a", "b", "C", "d", "e"},
+		"-id":            {"e", "d", "a", "C", "b"},
+	}
+	for spec, want := range cases {
+		keys, _ := storage.ParseSort(spec)
+		items, err := store.GetAllItems(ctx, storage.ListOptions{Sort: keys})
+		assert.Nil(t, err, spec)
+		assert.Equal(t, want, names(items.Items), spec)
+		assert.Empty(t, items.NextCursor, spec)
+	}
+
+	// Walk the pages of a sorted listing by cursor.
+	keys, _ := storage.ParseSort("-priority,name")
+	opts := storage.ListOptions{Sort: keys, Limit: 2}
+	var pages [][]string
+	for {
+		items, err := store.GetAllItems(ctx, opts)
+		assert.Nil(t, err)
+		pages = append(pages, names(items.Items))
+		if items.NextCursor == "" {
+			break
+		}
+		opts.Cursor = items.NextCursor
+	}
+	assert.Equal(t, [][]string{{"a", "C"}, {"d", "b"}, {"e"}}, pages)
+
+	items, err := store.GetAllItems(ctx, storage.ListOptions{Sort: keys, Limit: 2, Offset: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b", "e"}, names(items.Items))
+	assert.Empty(t, items.NextCursor)
+
+	_, err = store.GetAllItems(ctx, storage.ListOptions{Limit: 2, Cursor: "nonsense"})
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+}
+
+func TestSortAndPageFileStore(t *testing.T) {
+	const filename = "sort.json"
+	defer cleanUp(filename)
+
+	s := newFileStore(t, filename)
+	addSortItems(t, s)
+	assertSortAndPages(t, s)
+}
+
+func TestSortAndPageDb(t *testing.T) {
+	filePath, store := getStore(t)
+	defer tearDown(filePath)
+
+	clearStore(store)
+	addSortItems(t, store)
+	assertSortAndPages(t, store)
+}
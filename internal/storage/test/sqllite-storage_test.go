@@ -1,6 +1,7 @@
 package storage_test
 
 import (
+	"database/sql"
 	"io"
 	"os"
 	"testing"
@@ -16,8 +17,9 @@ func TestCanGetItemsFromSqlLite(t *testing.T) {
 	filePath, store := getStore(t)
 	defer tearDown(filePath)
 
-	collection := store.GetAllItems(storage.ListOptions{ShowDone: true})
+	collection, err := store.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
 
+	assert.Nil(t, err)
 	assert.Equal(t, 2, collection.Size)
 	assert.Equal(t, 11, collection.MaxLengthItem)
 	assert.NotEmpty(t, collection.Items)
@@ -33,11 +35,11 @@ func TestDefaultListHidesDoneItems(t *testing.T) {
 	defer tearDown(filePath)
 
 	// Mark item 1 as done.
-	item := store.GetItem(1)
+	item, _ := store.GetItem(ctx, 1)
 	item.Done = true
-	store.EditItem(1, *item)
+	store.EditItem(ctx, 1, *item)
 
-	collection := store.GetAllItems(storage.ListOptions{})
+	collection, _ := store.GetAllItems(ctx, storage.ListOptions{})
 	assert.Equal(t, 1, collection.Size)
 	assert.Equal(t, "more", collection.Items[0].Name)
 }
@@ -47,8 +49,9 @@ func TestCanGetSingleItemFromDb(t *testing.T) {
 	defer tearDown(filePath)
 	unixMilliTime := int64(1257894000000)
 
-	item := store.GetItem(1)
+	item, err := store.GetItem(ctx, 1)
 
+	assert.Nil(t, err)
 	assert.NotNil(t, item)
 	assert.Equal(t, "Just a test", item.Name)
 	assert.Equal(t, unixMilliTime, item.CreatedAt.UnixMilli())
@@ -59,14 +62,17 @@ func TestCanAddItemFromDb(t *testing.T) {
 	filePath, store := getStore(t)
 	defer tearDown(filePath)
 
-	collection := store.GetAllItems(storage.ListOptions{ShowDone: true})
+	collection, _ := store.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
 	assert.Equal(t, 2, collection.Size)
 
-	added := store.AddItem(internal.Todo{Name: "New Item"})
+	added, err := store.AddItem(ctx, internal.Todo{Name: "New Item"})
 
-	assert.Equal(t, 1, added)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, added.ID)
+	assert.Equal(t, "New Item", added.Name)
+	assert.False(t, added.CreatedAt.IsZero())
 
-	collection = store.GetAllItems(storage.ListOptions{ShowDone: true})
+	collection, _ = store.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
 	assert.Equal(t, 3, collection.Size)
 }
 
@@ -81,9 +87,9 @@ func TestCanAddItemWithFieldsFromDb(t *testing.T) {
 		DueDate:  &due,
 		Tags:     []string{"work", "later"},
 	}
-	store.AddItem(todo)
+	store.AddItem(ctx, todo)
 
-	collection := store.GetAllItems(storage.ListOptions{ShowDone: true})
+	collection, _ := store.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
 	var got *internal.Todo
 	for i := range collection.Items {
 		if collection.Items[i].Name == "tagged" {
@@ -96,32 +102,66 @@ func TestCanAddItemWithFieldsFromDb(t *testing.T) {
 	assert.NotNil(t, got.DueDate)
 }
 
+func TestExtrasAreKeptInDb(t *testing.T) {
+	filePath, store := getStore(t)
+	defer tearDown(filePath)
+
+	extras := map[string]any{"taskwarrior": map[string]any{"project": "home", "annotations": []any{"a", "b"}}}
+	added, err := store.AddItem(ctx, internal.Todo{Name: "with extras", Extras: extras})
+	assert.Nil(t, err)
+	assert.Equal(t, extras, added.Extras)
+
+	added.Extras = nil
+	edited, err := store.EditItem(ctx, added.ID, *added)
+	assert.Nil(t, err)
+	assert.Nil(t, edited.Extras)
+
+	added.Extras = extras
+	put, err := store.PutItem(ctx, *added)
+	assert.Nil(t, err)
+	assert.Equal(t, extras, put.Extras)
+}
+
 func TestCanDeleteItemFromDb(t *testing.T) {
 
 	filePath, store := getStore(t)
 	defer tearDown(filePath)
 
-	collection := store.GetAllItems(storage.ListOptions{ShowDone: true})
+	collection, _ := store.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
 	assert.Equal(t, 2, collection.Size)
 
-	deleted := store.DeleteItem(1, 2)
+	deleted, err := store.DeleteItem(ctx, 1, 2)
+	assert.Nil(t, err)
 	assert.Equal(t, 2, deleted)
 
-	collection = store.GetAllItems(storage.ListOptions{ShowDone: true})
+	collection, _ = store.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
 	assert.Equal(t, 0, collection.Size)
 }
 
+func TestDeleteWithMissingIdDeletesNothingFromDb(t *testing.T) {
+	filePath, store := getStore(t)
+	defer tearDown(filePath)
+
+	deleted, err := store.DeleteItem(ctx, 1, 42)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.Equal(t, 0, deleted)
+
+	collection, _ := store.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
+	assert.Equal(t, 2, collection.Size)
+}
+
 func TestCanDeleteAllItemsFromDb(t *testing.T) {
 	filePath, store := getStore(t)
 	defer tearDown(filePath)
 
-	collection := store.GetAllItems(storage.ListOptions{ShowDone: true})
+	collection, _ := store.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
 	assert.Equal(t, 2, collection.Size)
 
-	deleted := store.DeleteAllItems()
+	deleted, err := store.DeleteAllItems(ctx)
+	assert.Nil(t, err)
 	assert.Equal(t, 2, deleted)
 
-	collection = store.GetAllItems(storage.ListOptions{ShowDone: true})
+	collection, _ = store.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
 	assert.Equal(t, 0, collection.Size)
 }
 
@@ -129,19 +169,133 @@ func TestCanEditItemInDb(t *testing.T) {
 	filePath, store := getStore(t)
 	defer tearDown(filePath)
 
-	item := store.GetItem(2)
+	item, _ := store.GetItem(ctx, 2)
 	updatedAt := item.UpdatedAt
 	assert.Equal(t, "more", item.Name)
 
 	item.Name = "new"
-	updated := store.EditItem(2, *item)
-	assert.Equal(t, 1, updated)
+	updated, err := store.EditItem(ctx, 2, *item)
+	assert.Nil(t, err)
+	assert.Equal(t, "new", updated.Name)
 
-	item = store.GetItem(2)
+	item, _ = store.GetItem(ctx, 2)
 	assert.Equal(t, "new", item.Name)
 	assert.Greater(t, item.UpdatedAt, updatedAt)
 }
 
+func TestMissingItemIsNotFoundInDb(t *testing.T) {
+	filePath, store := getStore(t)
+	defer tearDown(filePath)
+
+	item, err := store.GetItem(ctx, 42)
+	assert.Nil(t, item)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	_, err = store.EditItem(ctx, 42, internal.Todo{Name: "nothing here"})
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestCanAddSubtaskInDb(t *testing.T) {
+	filePath, store := getStore(t)
+	defer tearDown(filePath)
+
+	child, err := store.AddItem(ctx, internal.Todo{Name: "child", ParentID: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, child.ParentID)
+
+	subtasks, err := storage.Subtasks(ctx, store, 1)
+	assert.Nil(t, err)
+	assert.Len(t, subtasks, 1)
+	assert.Equal(t, "child", subtasks[0].Name)
+
+	child.ParentID = 0
+	child, err = store.EditItem(ctx, child.ID, *child)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, child.ParentID)
+}
+
+func TestCheckParentRejectsCycles(t *testing.T) {
+	filePath, store := getStore(t)
+	defer tearDown(filePath)
+
+	child, _ := store.AddItem(ctx, internal.Todo{Name: "child", ParentID: 1})
+
+	assert.Nil(t, storage.CheckParent(ctx, store, 0, 1))
+	assert.ErrorIs(t, storage.CheckParent(ctx, store, 1, 1), storage.ErrConflict)
+	assert.ErrorIs(t, storage.CheckParent(ctx, store, 1, child.ID), storage.ErrConflict)
+	assert.ErrorIs(t, storage.CheckParent(ctx, store, 0, 42), storage.ErrNotFound)
+}
+
+// TestDbEnforcesProjects Items must be in a project that exists, and a
+// project with items in it cannot be deleted.
+func TestDbEnforcesProjects(t *testing.T) {
+	store := newSQLiteStore(t)
+
+	_, err := store.AddItem(ctx, internal.Todo{Name: "lost", Project: 9})
+	assert.ErrorIs(t, err, storage.ErrConflict)
+
+	project, err := store.PutProject(ctx, internal.Project{Name: "home"})
+	assert.Nil(t, err)
+	_, err = store.AddItem(ctx, internal.Todo{Name: "paint", Project: project.ID})
+	assert.Nil(t, err)
+
+	raw, err := sql.Open("sqlite3", store.DbFilePath+"?_foreign_keys=on")
+	assert.Nil(t, err)
+	defer raw.Close()
+	_, err = raw.ExecContext(ctx, "DELETE FROM project WHERE id = ?", project.ID)
+	assert.ErrorContains(t, err, "FOREIGN KEY constraint failed")
+}
+
+// TestDbEmptyTrashDetachesSubtasks A subtask restored without its parent
+// becomes a top-level item once the parent is emptied from the trash.
+func TestDbEmptyTrashDetachesSubtasks(t *testing.T) {
+	store := newSQLiteStore(t)
+	parent, _ := store.AddItem(ctx, internal.Todo{Name: "parent"})
+	child, _ := store.AddItem(ctx, internal.Todo{Name: "child", ParentID: parent.ID})
+	_, err := store.DeleteItem(ctx, child.ID, parent.ID)
+	assert.Nil(t, err)
+	_, err = store.RestoreItem(ctx, child.ID)
+	assert.Nil(t, err)
+
+	count, err := store.EmptyTrash(ctx, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+
+	got, err := store.GetItem(ctx, child.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, got.ParentID)
+}
+
+func TestCanSearchDb(t *testing.T) {
+	filePath, store := getStore(t)
+	defer tearDown(filePath)
+
+	store.AddItem(ctx, internal.Todo{Name: "Buy milk"})
+	bread, _ := store.AddItem(ctx, internal.Todo{Name: "Buy bread", Tags: []string{"groceries"}})
+	store.AddItem(ctx, internal.Todo{Name: "Buy milk and bread", Done: true})
+
+	results, err := storage.Search(ctx, store, "bread OR groceries", storage.ListOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, bread.ID, results[0].Item.ID)
+	assert.Equal(t, "Buy \x02bread\x03", results[0].Snippet)
+
+	results, err = storage.Search(ctx, store, "buy -bread", storage.ListOptions{ShowDone: true})
+	assert.Nil(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "Buy milk", results[0].Item.Name)
+
+	// The index follows edits and deletes.
+	bread.Name = "Buy rolls"
+	store.EditItem(ctx, bread.ID, *bread)
+	results, _ = storage.Search(ctx, store, "rolls", storage.ListOptions{})
+	assert.Len(t, results, 1)
+
+	store.DeleteItem(ctx, bread.ID)
+	results, _ = storage.Search(ctx, store, "rolls", storage.ListOptions{})
+	assert.Empty(t, results)
+}
+
 func getStore(t *testing.T) (string, *db.SQLLiteStore) {
 
 	f, _ := os.CreateTemp(".", "*.db")
@@ -152,11 +306,18 @@ func getStore(t *testing.T) (string, *db.SQLLiteStore) {
 
 	io.Copy(f, dbFile)
 
-	store, err := db.NewSQLLiteStorage(f.Name())
+	store, err := db.NewSQLLiteStorage(ctx, f.Name())
 	assert.Nil(t, err)
 	return f.Name(), store
 }
 
+// clearStore Delete every item for good, emptying the trash too, so the
+// IDs of items added next start from 1 again.
+func clearStore(store storage.TodoStore) {
+	store.DeleteAllItems(ctx)
+	store.EmptyTrash(ctx, time.Time{})
+}
+
 func tearDown(filePath string) {
 	os.Remove(filePath)
 }
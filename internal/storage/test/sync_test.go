@@ -0,0 +1,325 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/storage"
+	"github.com/tcooper-uk/go-todo/internal/storage/db"
+)
+
+func newSyncStores(t *testing.T) (*storage.LocalFileStore, *storage.LocalFileStore) {
+	dir := t.TempDir()
+	return newFileStore(t, filepath.Join(dir, "a.json")), newFileStore(t, filepath.Join(dir, "b.json"))
+}
+
+func runSync(t *testing.T, a, b storage.TodoStore, state *storage.SyncState, strategy storage.SyncStrategy) *storage.SyncReport {
+	report, err := storage.Sync(ctx, a, b, state, storage.SyncOptions{Strategy: strategy})
+	assert.Nil(t, err)
+	return report
+}
+
+func itemNamed(t *testing.T, store storage.TodoStore, name string) internal.Todo {
+	items, err := store.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
+	assert.Nil(t, err)
+	for _, item := range items.Items {
+		if item.Name == name {
+			return item
+		}
+	}
+	t.Fatalf("no item named %q", name)
+	return internal.Todo{}
+}
+
+func count(t *testing.T, store storage.TodoStore) int {
+	items, err := store.GetAllItems(ctx, storage.ListOptions{ShowDone: true})
+	assert.Nil(t, err)
+	return items.Size
+}
+
+func TestSyncCopiesNewItemsBothWays(t *testing.T) {
+	a, b := newSyncStores(t)
+	parent, _ := a.AddItem(ctx, internal.Todo{Name: "parent", Tags: []string{"x"}})
+	a.AddItem(ctx, internal.Todo{Name: "child", ParentID: parent.ID})
+	b.AddItem(ctx, newTodo("from b"))
+
+	state := &storage.SyncState{}
+	report := runSync(t, a, b, state, storage.SyncMerge)
+	assert.Equal(t, 2, report.AddedToB)
+	assert.Equal(t, 1, report.AddedToA)
+	assert.Len(t, state.Items, 3)
+
+	// Creation times survive, and subtasks point at the copy of their parent.
+	copied := itemNamed(t, b, "parent")
+	assert.True(t, parent.CreatedAt.Equal(copied.CreatedAt))
+	assert.Equal(t, []string{"x"}, copied.Tags)
+	assert.Equal(t, copied.ID, itemNamed(t, b, "child").ParentID)
+	itemNamed(t, a, "from b")
+
+	report = runSync(t, a, b, state, storage.SyncMerge)
+	assert.Equal(t, 0, report.Changes())
+}
+
+func TestSyncCopiesEditsAndDeletions(t *testing.T) {
+	a, b := newSyncStores(t)
+	a.AddItem(ctx, newTodo("edit me"))
+	a.AddItem(ctx, newTodo("delete me"))
+	state := &storage.SyncState{}
+	runSync(t, a, b, state, storage.SyncMerge)
+
+	item := itemNamed(t, b, "edit me")
+	item.Name = "edited"
+	item.Done = true
+	b.EditItem(ctx, item.ID, item)
+	a.DeleteItem(ctx, itemNamed(t, a, "delete me").ID)
+
+	report := runSync(t, a, b, state, storage.SyncMerge)
+	assert.Equal(t, 1, report.UpdatedInA)
+	assert.Equal(t, 1, report.DeletedFromB)
+	assert.Empty(t, report.Conflicts)
+
+	assert.True(t, itemNamed(t, a, "edited").Done)
+	assert.Equal(t, 1, count(t, b))
+	assert.Len(t, state.Items, 1)
+}
+
+func TestSyncMergesEditsToDifferentFields(t *testing.T) {
+	a, b := newSyncStores(t)
+	a.AddItem(ctx, newTodo("task"))
+	state := &storage.SyncState{}
+	runSync(t, a, b, state, storage.SyncMerge)
+
+	inA := itemNamed(t, a, "task")
+	inA.Priority = internal.PriorityHigh
+	a.EditItem(ctx, inA.ID, inA)
+	inB := itemNamed(t, b, "task")
+	inB.Tags = []string{"home"}
+	b.EditItem(ctx, inB.ID, inB)
+
+	report := runSync(t, a, b, state, storage.SyncMerge)
+	assert.Empty(t, report.Conflicts)
+
+	for _, store := range []storage.TodoStore{a, b} {
+		item := itemNamed(t, store, "task")
+		assert.Equal(t, internal.PriorityHigh, item.Priority)
+		assert.Equal(t, []string{"home"}, item.Tags)
+	}
+}
+
+func TestSyncConflictingEdits(t *testing.T) {
+	for _, strategy := range []storage.SyncStrategy{storage.SyncMerge, storage.SyncLastWriterWins} {
+		a, b := newSyncStores(t)
+		a.AddItem(ctx, newTodo("task"))
+		state := &storage.SyncState{}
+		runSync(t, a, b, state, strategy)
+
+		inA := itemNamed(t, a, "task")
+		inA.Name = "renamed in a"
+		inA.Priority = internal.PriorityLow
+		a.EditItem(ctx, inA.ID, inA)
+		inB := itemNamed(t, b, "task")
+		inB.Name = "renamed in b"
+		b.EditItem(ctx, inB.ID, inB)
+
+		report := runSync(t, a, b, state, strategy)
+		assert.Len(t, report.Conflicts, 1, strategy)
+		conflict := report.Conflicts[0]
+		assert.Equal(t, storage.SideB, conflict.Kept, strategy)
+
+		// b was saved last, so its name wins. Merging keeps a's priority;
+		// last writer wins drops it.
+		item := itemNamed(t, a, "renamed in b")
+		if strategy == storage.SyncMerge {
+			assert.Equal(t, []string{"name"}, conflict.Fields)
+			assert.Equal(t, internal.PriorityLow, item.Priority)
+		} else {
+			assert.Equal(t, []string{"name", "priority"}, conflict.Fields)
+			assert.Equal(t, internal.PriorityNone, item.Priority)
+		}
+		assert.Equal(t, item.Priority, itemNamed(t, b, "renamed in b").Priority)
+	}
+}
+
+func TestSyncKeepsItemEditedWhileDeletedElsewhere(t *testing.T) {
+	a, b := newSyncStores(t)
+	a.AddItem(ctx, newTodo("task"))
+	state := &storage.SyncState{}
+	runSync(t, a, b, state, storage.SyncMerge)
+
+	a.DeleteItem(ctx, itemNamed(t, a, "task").ID)
+	inB := itemNamed(t, b, "task")
+	inB.Done = true
+	b.EditItem(ctx, inB.ID, inB)
+
+	report := runSync(t, a, b, state, storage.SyncMerge)
+	assert.Len(t, report.Conflicts, 1)
+	assert.Nil(t, report.Conflicts[0].Fields)
+	assert.Equal(t, storage.SideB, report.Conflicts[0].Kept)
+	assert.True(t, itemNamed(t, a, "task").Done)
+
+	report = runSync(t, a, b, state, storage.SyncMerge)
+	assert.Equal(t, 0, report.Changes())
+}
+
+func TestSyncMatchesIdenticalItemsInsteadOfCopying(t *testing.T) {
+	a, b := newSyncStores(t)
+	due := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	for _, store := range []storage.TodoStore{a, b} {
+		store.AddItem(ctx, internal.Todo{Name: "same", DueDate: &due})
+	}
+	b.AddItem(ctx, newTodo("only in b"))
+
+	report := runSync(t, a, b, &storage.SyncState{}, storage.SyncMerge)
+	assert.Equal(t, 1, report.AddedToA)
+	assert.Equal(t, 0, report.AddedToB)
+	assert.Equal(t, 2, count(t, a))
+}
+
+func TestSyncDryRunChangesNothing(t *testing.T) {
+	a, b := newSyncStores(t)
+	a.AddItem(ctx, newTodo("new"))
+
+	state := &storage.SyncState{}
+	report, err := storage.Sync(ctx, a, b, state, storage.SyncOptions{Strategy: storage.SyncMerge, DryRun: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.AddedToB)
+	assert.Equal(t, 0, count(t, b))
+	assert.Empty(t, state.Items)
+}
+
+func TestSyncBetweenSQLiteAndFile(t *testing.T) {
+	dir := t.TempDir()
+	sqlite, err := db.NewSQLLiteStorage(ctx, filepath.Join(dir, "todo.db"))
+	assert.Nil(t, err)
+	file := newFileStore(t, filepath.Join(dir, "todo.json"))
+
+	due := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	sqlite.AddItem(ctx, internal.Todo{Name: "in sqlite", DueDate: &due, Recurrence: "FREQ=WEEKLY"})
+	file.AddItem(ctx, internal.Todo{Name: "in file", Tags: []string{"a", "b"}})
+
+	path := filepath.Join(dir, "sync.json")
+	state, err := storage.LoadSyncState(path)
+	assert.Nil(t, err)
+	runSync(t, sqlite, file, state, storage.SyncMerge)
+	assert.Nil(t, state.Save(path))
+
+	// A fresh run from the saved state finds nothing to do, despite each
+	// store keeping times to a different precision.
+	state, err = storage.LoadSyncState(path)
+	assert.Nil(t, err)
+	assert.Len(t, state.Items, 2)
+	report := runSync(t, sqlite, file, state, storage.SyncMerge)
+	assert.Equal(t, 0, report.Changes())
+
+	assert.True(t, due.Equal(*itemNamed(t, file, "in sqlite").DueDate))
+	assert.Equal(t, []string{"a", "b"}, itemNamed(t, sqlite, "in file").Tags)
+}
+
+// TestSyncDeferredItemBetweenFileAndSQLite A start date the file store
+// keeps to the nanosecond is not taken as an edit once SQLite has it to
+// the millisecond.
+func TestSyncDeferredItemBetweenFileAndSQLite(t *testing.T) {
+	dir := t.TempDir()
+	sqlite, err := db.NewSQLLiteStorage(ctx, filepath.Join(dir, "todo.db"))
+	assert.Nil(t, err)
+	file := newFileStore(t, filepath.Join(dir, "todo.json"))
+
+	start := time.Now().Add(72 * time.Hour)
+	file.AddItem(ctx, internal.Todo{Name: "deferred", StartDate: &start})
+
+	state, err := storage.LoadSyncState(filepath.Join(dir, "sync.json"))
+	assert.Nil(t, err)
+	report := runSync(t, file, sqlite, state, storage.SyncMerge)
+	assert.Equal(t, 1, report.AddedToB)
+
+	for i := 0; i < 2; i++ {
+		report = runSync(t, file, sqlite, state, storage.SyncMerge)
+		assert.Equal(t, 0, report.Changes())
+	}
+}
+
+// TestSyncPairsDeadlineAfterMigrate A deadline copied by migrate is the same
+// item to sync, and stays unchanged, though SQLite has it to the
+// millisecond.
+func TestSyncPairsDeadlineAfterMigrate(t *testing.T) {
+	dir := t.TempDir()
+	sqlite, err := db.NewSQLLiteStorage(ctx, filepath.Join(dir, "todo.db"))
+	assert.Nil(t, err)
+	file := newFileStore(t, filepath.Join(dir, "todo.json"))
+
+	due := time.Now().Add(3 * time.Hour).UTC()
+	file.AddItem(ctx, internal.Todo{Name: "timesheet", DueDate: &due, DueTimed: true, DueZone: "Europe/London"})
+	_, err = storage.Migrate(ctx, file, sqlite, storage.MigrateOptions{})
+	assert.Nil(t, err)
+
+	state, err := storage.LoadSyncState(filepath.Join(dir, "sync.json"))
+	assert.Nil(t, err)
+	for i := 0; i < 2; i++ {
+		report := runSync(t, file, sqlite, state, storage.SyncMerge)
+		assert.Equal(t, 0, report.Changes())
+	}
+	items, err := sqlite.GetAllItems(ctx, storage.ListOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"timesheet"}, names(items.Items))
+}
+
+// TestSyncPairsDeferredItemAfterMigrate A deferred item copied by migrate
+// is the same item to sync, though SQLite has its start date to the
+// millisecond.
+func TestSyncPairsDeferredItemAfterMigrate(t *testing.T) {
+	dir := t.TempDir()
+	sqlite, err := db.NewSQLLiteStorage(ctx, filepath.Join(dir, "todo.db"))
+	assert.Nil(t, err)
+	file := newFileStore(t, filepath.Join(dir, "todo.json"))
+
+	start := time.Now().Add(72 * time.Hour)
+	file.AddItem(ctx, internal.Todo{Name: "deferred", StartDate: &start})
+	_, err = storage.Migrate(ctx, file, sqlite, storage.MigrateOptions{})
+	assert.Nil(t, err)
+
+	state, err := storage.LoadSyncState(filepath.Join(dir, "sync.json"))
+	assert.Nil(t, err)
+	report := runSync(t, file, sqlite, state, storage.SyncMerge)
+	assert.Equal(t, 0, report.Changes())
+	items, err := sqlite.GetAllItems(ctx, storage.ListOptions{IncludeDeferred: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"deferred"}, names(items.Items))
+}
+
+func TestSyncMatchesProjectsByPath(t *testing.T) {
+	a, b := newSyncStores(t)
+	// The same project has different ids in each store.
+	putProject(t, b, "home", 0)
+	workB := putProject(t, b, "Work", 0)
+	work := putProject(t, a, "work", 0)
+	clients := putProject(t, a, "clients", work.ID)
+	a.AddItem(ctx, internal.Todo{Name: "call acme", Project: clients.ID})
+	b.AddItem(ctx, internal.Todo{Name: "plan q4", Project: workB.ID})
+
+	state := &storage.SyncState{}
+	report := runSync(t, a, b, state, storage.SyncMerge)
+	assert.Equal(t, 1, report.ProjectsAddedToA)
+	assert.Equal(t, 1, report.ProjectsAddedToB)
+
+	projectsA, _ := storage.Projects(ctx, a)
+	projectsB, _ := storage.Projects(ctx, b)
+	assert.Len(t, projectsA, 3)
+	assert.Len(t, projectsB, 3)
+	assert.Equal(t, "Work/clients", internal.ProjectPath(projectsB, itemNamed(t, b, "call acme").Project))
+	assert.Equal(t, work.ID, itemNamed(t, a, "plan q4").Project)
+
+	// Moving an item between projects is an edit like any other.
+	item := itemNamed(t, a, "plan q4")
+	item.Project = clients.ID
+	a.EditItem(ctx, item.ID, item)
+	report = runSync(t, a, b, state, storage.SyncMerge)
+	assert.Equal(t, 1, report.UpdatedInB)
+	assert.Equal(t, "Work/clients", internal.ProjectPath(projectsB, itemNamed(t, b, "plan q4").Project))
+
+	report = runSync(t, a, b, state, storage.SyncMerge)
+	assert.Equal(t, 0, report.Changes())
+}
@@ -0,0 +1,91 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcooper-uk/go-todo/internal/storage"
+)
+
+func trashed(t *testing.T, store storage.TodoStore) []string {
+	items, err := store.GetAllItems(ctx, storage.ListOptions{Trashed: true, ShowDone: true})
+	assert.Nil(t, err)
+	var names []string
+	for _, item := range items.Items {
+		assert.NotNil(t, item.DeletedAt, item.Name)
+		names = append(names, item.Name)
+	}
+	return names
+}
+
+func assertTrash(t *testing.T, store storage.TodoStore) {
+	one, _ := store.AddItem(ctx, newTodo("one"))
+	two, _ := store.AddItem(ctx, newTodo("two"))
+	store.AddItem(ctx, newTodo("three"))
+
+	// Deleted items are hidden, but kept in the trash.
+	n, err := store.DeleteItem(ctx, one.ID, two.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	_, err = store.GetItem(ctx, one.ID)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.Equal(t, 1, count(t, store))
+	assert.Equal(t, []string{"one", "two"}, trashed(t, store))
+
+	// An item can't be deleted twice, or edited, while in the trash.
+	_, err = store.DeleteItem(ctx, one.ID)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = store.EditItem(ctx, one.ID, *one)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// Restored, it is back as it was, keeping its ID.
+	n, err = store.RestoreItem(ctx, one.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	got, err := store.GetItem(ctx, one.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "one", got.Name)
+	assert.Nil(t, got.DeletedAt)
+	_, err = store.RestoreItem(ctx, one.ID)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// New items never take the ID of one in the trash.
+	four, _ := store.AddItem(ctx, newTodo("four"))
+	assert.NotEqual(t, two.ID, four.ID)
+
+	// Emptying it deletes only what was trashed before the time given.
+	n, err = store.EmptyTrash(ctx, time.Now().Add(-time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	n, err = store.EmptyTrash(ctx, time.Now().Add(time.Second))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Empty(t, trashed(t, store))
+	_, err = store.RestoreItem(ctx, two.ID)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// Deleting everything moves it all to the trash.
+	n, err = store.DeleteAllItems(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, 0, count(t, store))
+	assert.Len(t, trashed(t, store), 3)
+	n, err = store.EmptyTrash(ctx, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+}
+
+func TestTrashFileStore(t *testing.T) {
+	assertTrash(t, newFileStore(t, filepath.Join(t.TempDir(), "todo.json")))
+}
+
+func TestTrashDb(t *testing.T) {
+	assertTrash(t, newSQLiteStore(t))
+}
+
+func TestTrashRemote(t *testing.T) {
+	assertTrash(t, newRemoteStore(t, nil))
+}
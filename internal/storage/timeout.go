@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/search"
+)
+
+// timeoutStore Applies a deadline to every call made on the wrapped store.
+type timeoutStore struct {
+	store   TodoStore
+	timeout time.Duration
+}
+
+// WithTimeout Wrap store so each call fails with ErrBackendUnavailable once
+// timeout has elapsed, instead of waiting on a hung backend.
+// A zero or negative timeout returns store unchanged.
+func WithTimeout(store TodoStore, timeout time.Duration) TodoStore {
+	if timeout <= 0 {
+		return store
+	}
+	return &timeoutStore{store: store, timeout: timeout}
+}
+
+// TimeoutErr Annotate err as a timeout if ctx expired while it was produced.
+func TimeoutErr(ctx context.Context, timeout time.Duration, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	if errors.Is(err, ErrBackendUnavailable) {
+		return fmt.Errorf("timed out after %s: %w", timeout, err)
+	}
+	return fmt.Errorf("%w: timed out after %s: %w", ErrBackendUnavailable, timeout, err)
+}
+
+func (s *timeoutStore) GetAllItems(ctx context.Context, opts ListOptions) (*internal.TodoCollection, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	items, err := s.store.GetAllItems(ctx, opts)
+	return items, TimeoutErr(ctx, s.timeout, err)
+}
+
+func (s *timeoutStore) GetItem(ctx context.Context, id int) (*internal.Todo, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	item, err := s.store.GetItem(ctx, id)
+	return item, TimeoutErr(ctx, s.timeout, err)
+}
+
+func (s *timeoutStore) AddItem(ctx context.Context, todo internal.Todo) (*internal.Todo, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	item, err := s.store.AddItem(ctx, todo)
+	return item, TimeoutErr(ctx, s.timeout, err)
+}
+
+func (s *timeoutStore) DeleteItem(ctx context.Context, ids ...int) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	count, err := s.store.DeleteItem(ctx, ids...)
+	return count, TimeoutErr(ctx, s.timeout, err)
+}
+
+func (s *timeoutStore) DeleteAllItems(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	count, err := s.store.DeleteAllItems(ctx)
+	return count, TimeoutErr(ctx, s.timeout, err)
+}
+
+func (s *timeoutStore) RestoreItem(ctx context.Context, ids ...int) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	count, err := s.store.RestoreItem(ctx, ids...)
+	return count, TimeoutErr(ctx, s.timeout, err)
+}
+
+func (s *timeoutStore) EmptyTrash(ctx context.Context, before time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	count, err := s.store.EmptyTrash(ctx, before)
+	return count, TimeoutErr(ctx, s.timeout, err)
+}
+
+func (s *timeoutStore) PutItem(ctx context.Context, todo internal.Todo) (*internal.Todo, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	item, err := s.store.PutItem(ctx, todo)
+	return item, TimeoutErr(ctx, s.timeout, err)
+}
+
+func (s *timeoutStore) EditItem(ctx context.Context, id int, todo internal.Todo) (*internal.Todo, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	item, err := s.store.EditItem(ctx, id, todo)
+	return item, TimeoutErr(ctx, s.timeout, err)
+}
+
+func (s *timeoutStore) Search(ctx context.Context, expr search.Expr, opts ListOptions) ([]search.Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	results, err := searchStore(ctx, s.store, expr, opts)
+	return results, TimeoutErr(ctx, s.timeout, err)
+}
+
+func (s *timeoutStore) ItemHistory(ctx context.Context, id int) ([]FieldChange, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	changes, err := ItemHistory(ctx, s.store, id)
+	return changes, TimeoutErr(ctx, s.timeout, err)
+}
+
+func (s *timeoutStore) ChangesSince(ctx context.Context, since time.Time) ([]FieldChange, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	changes, err := ChangesSince(ctx, s.store, since)
+	return changes, TimeoutErr(ctx, s.timeout, err)
+}
+
+func (s *timeoutStore) Projects(ctx context.Context) ([]internal.Project, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	projects, err := Projects(ctx, s.store)
+	return projects, TimeoutErr(ctx, s.timeout, err)
+}
+
+func (s *timeoutStore) PutProject(ctx context.Context, project internal.Project) (*internal.Project, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	stored, err := putProject(ctx, s.store, project)
+	return stored, TimeoutErr(ctx, s.timeout, err)
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/tcooper-uk/go-todo/internal"
+)
+
+// Subtasks Find every descendant of the item with the given id, done or not.
+func Subtasks(ctx context.Context, store TodoStore, id int) ([]internal.Todo, error) {
+	all, err := store.GetAllItems(ctx, ListOptions{ShowDone: true, IncludeDeferred: true})
+	if err != nil {
+		return nil, err
+	}
+	return internal.Descendants(all.Items, id), nil
+}
+
+// CheckParent Verify that parentID exists and can become the parent of the
+// item with the given id without creating a cycle. Use id 0 for a new item.
+func CheckParent(ctx context.Context, store TodoStore, id int, parentID int) error {
+	if parentID == 0 {
+		return nil
+	}
+
+	if _, err := store.GetItem(ctx, parentID); err != nil {
+		return fmt.Errorf("parent %d: %w", parentID, err)
+	}
+
+	if id == 0 {
+		return nil
+	}
+	if parentID == id {
+		return fmt.Errorf("%w: item %d cannot be its own parent", ErrConflict, id)
+	}
+
+	descendants, err := Subtasks(ctx, store, id)
+	if err != nil {
+		return err
+	}
+	for _, d := range descendants {
+		if d.ID == parentID {
+			return fmt.Errorf("%w: item %d is a subtask of %d", ErrConflict, parentID, id)
+		}
+	}
+
+	return nil
+}
+
+// sortParentsFirst Order items so that parents come before their subtasks,
+// and otherwise by id. all holds every item by id, to look up parents that
+// are not among items.
+func sortParentsFirst(items []internal.Todo, all map[int]internal.Todo) {
+	sort.Slice(items, func(i, j int) bool {
+		di, dj := depth(items[i], all), depth(items[j], all)
+		if di != dj {
+			return di < dj
+		}
+		return items[i].ID < items[j].ID
+	})
+}
+
+// depth How many ancestors item has in all, giving up once a cycle is
+// certain.
+func depth(item internal.Todo, all map[int]internal.Todo) int {
+	d := 0
+	for d <= len(all) {
+		parent, ok := all[item.ParentID]
+		if !ok || item.ParentID == 0 {
+			break
+		}
+		item = parent
+		d++
+	}
+	return d
+}
@@ -0,0 +1,390 @@
+// Package taskwarrior converts todo items to and from the JSON tasks that
+// Taskwarrior's `task export` writes and `task import` reads. Attributes
+// with no field of their own, like project, annotations or the task's
+// uuid, are kept in the item's extras, so that they are written back.
+package taskwarrior
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"strconv"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal"
+)
+
+// ExtrasKey The key of internal.Todo.Extras that holds the attributes of
+// a task with no field of their own.
+const ExtrasKey = "taskwarrior"
+
+// timeLayout How Taskwarrior writes dates: UTC, in ISO 8601 basic format.
+const timeLayout = "20060102T150405Z"
+
+// Attributes that map onto item fields.
+const (
+	attrUUID        = "uuid"
+	attrDescription = "description"
+	attrStatus      = "status"
+	attrPriority    = "priority"
+	attrDue         = "due"
+	attrWait        = "wait"
+	attrEntry       = "entry"
+	attrModified    = "modified"
+	attrEnd         = "end"
+	attrTags        = "tags"
+	// attrParent A user-defined attribute naming the uuid of the item a
+	// subtask belongs to, which Taskwarrior keeps without knowing it.
+	attrParent = "todoparent"
+)
+
+// Statuses of a task. Tasks that are deleted are done, and those waiting
+// or recurring are not. A recurring task keeps its status in the extras;
+// a waiting one is waiting while its start date is to come.
+const (
+	statusPending   = "pending"
+	statusCompleted = "completed"
+	statusDeleted   = "deleted"
+	statusWaiting   = "waiting"
+	statusRecurring = "recurring"
+)
+
+// computed Attributes Taskwarrior works out again on import, so they are
+// neither kept nor written: the task's number in the working set and its
+// urgency.
+var computed = map[string]bool{"id": true, "urgency": true}
+
+var priorities = map[internal.Priority]string{
+	internal.PriorityHigh:   "H",
+	internal.PriorityMedium: "M",
+	internal.PriorityLow:    "L",
+}
+
+// uuidNamespace The namespace of the uuids made for items that were never
+// tasks, so that each item gets the same one every time.
+var uuidNamespace = []byte("go-todo")
+
+// Read Parse the tasks of r, either a JSON array as `task export` writes or
+// one object per line. Items get IDs in the order they appear, and
+// subtasks the ParentID of the item their todoparent names, as todotxt
+// links them.
+func Read(r io.Reader) ([]internal.Todo, error) {
+	tasks, err := decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]int, len(tasks))
+	for i, task := range tasks {
+		if uuid, ok := task[attrUUID].(string); ok && uuid != "" {
+			ids[uuid] = i + 1
+		}
+	}
+
+	items := make([]internal.Todo, len(tasks))
+	for i, task := range tasks {
+		item, err := FromTask(task)
+		if err != nil {
+			return nil, fmt.Errorf("task %d: %w", i+1, err)
+		}
+		item.ID = i + 1
+		if parent, ok := task[attrParent].(string); ok {
+			item.ParentID = ids[parent]
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+func decode(r io.Reader) ([]map[string]any, error) {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b == ' ' || b == '\t' || b == '\r' || b == '\n' {
+			continue
+		}
+		br.UnreadByte()
+		break
+	}
+
+	dec := json.NewDecoder(br)
+	first, _ := br.Peek(1)
+	if first[0] == '[' {
+		var tasks []map[string]any
+		if err := dec.Decode(&tasks); err != nil {
+			return nil, fmt.Errorf("not Taskwarrior JSON: %w", err)
+		}
+		return tasks, nil
+	}
+
+	var tasks []map[string]any
+	for {
+		var task map[string]any
+		err := dec.Decode(&task)
+		if err == io.EOF {
+			return tasks, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("not Taskwarrior JSON: %w", err)
+		}
+		tasks = append(tasks, task)
+	}
+}
+
+// FromTask The item for a task. The attributes it has no field for are
+// kept in its extras, under ExtrasKey; its status too, when it is neither
+// pending nor completed. A priority other than H, M or L is kept there
+// rather than dropped.
+func FromTask(task map[string]any) (internal.Todo, error) {
+	var item internal.Todo
+	extras := map[string]any{}
+	for attr, value := range task {
+		if !computed[attr] {
+			extras[attr] = value
+		}
+	}
+
+	var err error
+	str := func(attr string) string {
+		s, _ := task[attr].(string)
+		return s
+	}
+	date := func(attr string) (time.Time, bool) {
+		value := str(attr)
+		if value == "" || err != nil {
+			return time.Time{}, false
+		}
+		var t time.Time
+		if t, err = parseTime(value); err != nil {
+			err = fmt.Errorf("%s: %w", attr, err)
+			return time.Time{}, false
+		}
+		return t, true
+	}
+
+	item.Name = str(attrDescription)
+	if item.Name == "" {
+		item.Name = "Untitled"
+	}
+	delete(extras, attrDescription)
+
+	status := str(attrStatus)
+	item.Done = status == statusCompleted || status == statusDeleted
+	if status == statusPending || status == statusCompleted || status == "" {
+		delete(extras, attrStatus)
+	}
+
+	for priority, letter := range priorities {
+		if str(attrPriority) == letter {
+			item.Priority = priority
+			delete(extras, attrPriority)
+		}
+	}
+
+	// Taskwarrior writes a due day as midnight local time; any other time
+	// is a deadline.
+	if due, ok := date(attrDue); ok {
+		local := due.Local()
+		if local.Hour() == 0 && local.Minute() == 0 && local.Second() == 0 {
+			day := internal.Day(local)
+			item.DueDate = &day
+		} else {
+			utc := due.UTC()
+			item.DueDate, item.DueTimed, item.DueZone = &utc, true, internal.LocalZone()
+		}
+		delete(extras, attrDue)
+	}
+	// wait hides a task until then, as a start date does.
+	if wait, ok := date(attrWait); ok {
+		utc := wait.UTC()
+		item.StartDate = &utc
+		delete(extras, attrWait)
+		if status == statusWaiting {
+			delete(extras, attrStatus)
+		}
+	}
+	if entry, ok := date(attrEntry); ok {
+		item.CreatedAt = entry
+		delete(extras, attrEntry)
+	}
+	if modified, ok := date(attrModified); ok {
+		item.UpdatedAt = modified
+		delete(extras, attrModified)
+	}
+	if err != nil {
+		return internal.Todo{}, err
+	}
+
+	if tags, ok := task[attrTags].([]any); ok {
+		for _, tag := range tags {
+			if s, ok := tag.(string); ok && s != "" {
+				item.Tags = append(item.Tags, s)
+			}
+		}
+		delete(extras, attrTags)
+	}
+	delete(extras, attrParent)
+
+	if len(extras) > 0 {
+		item.Extras = map[string]any{ExtrasKey: extras}
+	}
+	return item, nil
+}
+
+// ToTask The task for item: its kept attributes, with those its fields map
+// onto set from them. Subtasks name their parent's uuid in todoparent,
+// given the uuids of the other items.
+func ToTask(item internal.Todo, uuids map[int]string) map[string]any {
+	task := map[string]any{}
+	if kept, ok := item.Extras[ExtrasKey].(map[string]any); ok {
+		maps.Copy(task, kept)
+	}
+
+	task[attrUUID] = uuidOf(item)
+	task[attrDescription] = item.Name
+	task[attrEntry] = formatTime(item.CreatedAt)
+	task[attrModified] = formatTime(item.UpdatedAt)
+
+	// A kept status only stands while it agrees with Done.
+	status, _ := task[attrStatus].(string)
+	switch {
+	case item.Done && status == statusDeleted:
+	case !item.Done && status == statusRecurring:
+	case item.Done:
+		task[attrStatus] = statusCompleted
+	case item.Deferred(time.Now()):
+		task[attrStatus] = statusWaiting
+	default:
+		task[attrStatus] = statusPending
+	}
+	if item.StartDate != nil {
+		task[attrWait] = formatTime(*item.StartDate)
+	} else {
+		delete(task, attrWait)
+	}
+	if item.Done {
+		if _, ok := task[attrEnd]; !ok {
+			task[attrEnd] = formatTime(item.UpdatedAt)
+		}
+	} else {
+		delete(task, attrEnd)
+	}
+
+	// Otherwise a kept priority that isn't H, M or L stays.
+	if letter, ok := priorities[item.Priority]; ok {
+		task[attrPriority] = letter
+	}
+
+	if item.DueDate != nil && item.DueTimed {
+		task[attrDue] = formatTime(*item.DueDate)
+	} else if item.DueDate != nil {
+		day := item.DueDate.UTC()
+		due := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+		// A due time kept by an earlier import, on the same day, is written
+		// back as it was.
+		if kept, ok := task[attrDue].(string); ok {
+			if t, err := parseTime(kept); err == nil && sameDay(t.Local(), due) {
+				due = t
+			}
+		}
+		task[attrDue] = formatTime(due)
+	} else {
+		delete(task, attrDue)
+	}
+
+	if len(item.Tags) > 0 {
+		task[attrTags] = item.Tags
+	} else {
+		delete(task, attrTags)
+	}
+
+	if parent, ok := uuids[item.ParentID]; ok && item.ParentID != 0 {
+		task[attrParent] = parent
+	} else {
+		delete(task, attrParent)
+	}
+	return task
+}
+
+// Write Write items to w as a JSON array that `task import` reads, one
+// task per line.
+func Write(w io.Writer, items []internal.Todo) error {
+	uuids := make(map[int]string, len(items))
+	for _, item := range items {
+		uuids[item.ID] = uuidOf(item)
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("[\n")
+	for i, item := range items {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(ToTask(item, uuids)); err != nil {
+			return err
+		}
+		bw.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+		if i < len(items)-1 {
+			bw.WriteString(",")
+		}
+		bw.WriteString("\n")
+	}
+	bw.WriteString("]\n")
+	return bw.Flush()
+}
+
+// Unlink Drop the uuid kept in extras, which names one task, so that an
+// item copied from another is exported as a task of its own.
+func Unlink(extras map[string]any) {
+	if kept, ok := extras[ExtrasKey].(map[string]any); ok {
+		delete(kept, attrUUID)
+	}
+}
+
+// uuidOf The uuid the item was imported with, or one made from its ID and
+// creation time, the same every time it is exported from the same store.
+func uuidOf(item internal.Todo) string {
+	if kept, ok := item.Extras[ExtrasKey].(map[string]any); ok {
+		if uuid, ok := kept[attrUUID].(string); ok && uuid != "" {
+			return uuid
+		}
+	}
+
+	// A version 5, name-based uuid.
+	h := sha1.New()
+	h.Write(uuidNamespace)
+	h.Write([]byte(strconv.Itoa(item.ID) + "-" + strconv.FormatInt(item.CreatedAt.Unix(), 10)))
+	b := h.Sum(nil)[:16]
+	b[6] = b[6]&0x0f | 0x50
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(timeLayout, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.New("invalid date " + strconv.Quote(value))
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.Month() == b.Month() && a.Day() == b.Day()
+}
@@ -0,0 +1,188 @@
+package taskwarrior_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/taskwarrior"
+)
+
+// useZone Run the test with local time in a zone an hour ahead of UTC, as
+// Taskwarrior's due dates are midnight local time.
+func useZone(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+1", 3600)
+	t.Cleanup(func() { time.Local = local })
+}
+
+const export = `[
+{"id":1,"description":"Plan the party","entry":"20261001T090000Z","modified":"20261018T173000Z","due":"20261101T230000Z","priority":"H","project":"home","status":"pending","tags":["party"],"uuid":"8a9b7c6d-1111-4222-8333-444455556666","urgency":9.1,"annotations":[{"entry":"20261002T100000Z","description":"ask Sam"}]},
+{"id":0,"description":"Book the venue","end":"20261005T120000Z","entry":"20261001T090000Z","modified":"20261005T120000Z","status":"completed","uuid":"0f0e0d0c-2222-4333-8444-555566667777","todoparent":"8a9b7c6d-1111-4222-8333-444455556666"},
+{"id":0,"description":"Old idea","entry":"20261001T090000Z","modified":"20261003T080000Z","end":"20261003T080000Z","status":"deleted","priority":"X","due":"20261110T143000Z","uuid":"aaaabbbb-3333-4444-8555-666677778888"}
+]
+`
+
+func TestRead(t *testing.T) {
+	useZone(t)
+	items, err := taskwarrior.Read(strings.NewReader(export))
+	assert.Nil(t, err)
+	assert.Len(t, items, 3)
+
+	party := items[0]
+	assert.Equal(t, 1, party.ID)
+	assert.Equal(t, "Plan the party", party.Name)
+	assert.False(t, party.Done)
+	assert.Equal(t, internal.PriorityHigh, party.Priority)
+	// Midnight local time is the day itself.
+	assert.Equal(t, time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC), *party.DueDate)
+	assert.Equal(t, []string{"party"}, party.Tags)
+	assert.Equal(t, time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC), party.CreatedAt)
+	assert.Equal(t, time.Date(2026, 10, 18, 17, 30, 0, 0, time.UTC), party.UpdatedAt)
+	kept := party.Extras[taskwarrior.ExtrasKey].(map[string]any)
+	assert.Equal(t, "home", kept["project"])
+	assert.Equal(t, "8a9b7c6d-1111-4222-8333-444455556666", kept["uuid"])
+	assert.NotNil(t, kept["annotations"])
+	// Worked out again by Taskwarrior, so not kept.
+	assert.NotContains(t, kept, "id")
+	assert.NotContains(t, kept, "urgency")
+	assert.NotContains(t, kept, "due")
+
+	venue := items[1]
+	assert.True(t, venue.Done)
+	assert.Equal(t, 1, venue.ParentID)
+
+	idea := items[2]
+	assert.True(t, idea.Done)
+	assert.Equal(t, internal.PriorityNone, idea.Priority)
+	kept = idea.Extras[taskwarrior.ExtrasKey].(map[string]any)
+	assert.Equal(t, "deleted", kept["status"])
+	assert.Equal(t, "X", kept["priority"])
+	// A due time other than midnight is a deadline.
+	assert.Equal(t, time.Date(2026, 11, 10, 14, 30, 0, 0, time.UTC), *idea.DueDate)
+	assert.True(t, idea.DueTimed)
+	assert.NotContains(t, kept, "due")
+}
+
+func TestReadLinePerTask(t *testing.T) {
+	items, err := taskwarrior.Read(strings.NewReader(`{"description":"One","status":"pending"}
+{"description":"Two","status":"completed"}
+`))
+	assert.Nil(t, err)
+	assert.Len(t, items, 2)
+	assert.Equal(t, "Two", items[1].Name)
+	assert.True(t, items[1].Done)
+	assert.Nil(t, items[0].Extras)
+
+	_, err = taskwarrior.Read(strings.NewReader(`{"description":"Bad","entry":"yesterday"}`))
+	assert.NotNil(t, err)
+	_, err = taskwarrior.Read(strings.NewReader(`not json`))
+	assert.NotNil(t, err)
+}
+
+// tasks Decode written tasks, keyed by description.
+func tasks(t *testing.T, data []byte) map[string]map[string]any {
+	var list []map[string]any
+	assert.Nil(t, json.Unmarshal(data, &list))
+	byName := map[string]map[string]any{}
+	for _, task := range list {
+		byName[task["description"].(string)] = task
+	}
+	return byName
+}
+
+func TestRoundTrip(t *testing.T) {
+	useZone(t)
+	items, err := taskwarrior.Read(strings.NewReader(export))
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	assert.Nil(t, taskwarrior.Write(&buf, items))
+	assert.True(t, strings.HasPrefix(buf.String(), "[\n{"))
+
+	var original []map[string]any
+	assert.Nil(t, json.Unmarshal([]byte(export), &original))
+	written := tasks(t, buf.Bytes())
+	for _, task := range original {
+		delete(task, "id")
+		delete(task, "urgency")
+		// Nothing else is lost, or changed.
+		assert.Equal(t, task, written[task["description"].(string)])
+	}
+}
+
+func TestWrite(t *testing.T) {
+	useZone(t)
+	created := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	updated := time.Date(2026, 10, 18, 17, 30, 0, 0, time.UTC)
+	due := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+	deadline := time.Date(2026, 10, 5, 12, 0, 0, 0, time.UTC)
+	items := []internal.Todo{
+		{ID: 1, Name: "Plan the party", Priority: internal.PriorityMedium, DueDate: &due,
+			Tags: []string{"party"}, CreatedAt: created, UpdatedAt: created},
+		{ID: 2, Name: "Book the venue", Done: true, ParentID: 1, DueDate: &deadline, DueTimed: true,
+			CreatedAt: created, UpdatedAt: updated},
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, taskwarrior.Write(&buf, items))
+	written := tasks(t, buf.Bytes())
+
+	party := written["Plan the party"]
+	assert.Equal(t, "pending", party["status"])
+	assert.Equal(t, "M", party["priority"])
+	assert.Equal(t, "20261101T230000Z", party["due"])
+	assert.Equal(t, "20261001T090000Z", party["entry"])
+	assert.Equal(t, []any{"party"}, party["tags"])
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, party["uuid"])
+
+	venue := written["Book the venue"]
+	assert.Equal(t, "completed", venue["status"])
+	assert.Equal(t, "20261018T173000Z", venue["end"])
+	assert.Equal(t, "20261005T120000Z", venue["due"])
+	assert.Equal(t, party["uuid"], venue["todoparent"])
+
+	// The same items always get the same uuids.
+	var again bytes.Buffer
+	taskwarrior.Write(&again, items)
+	assert.Equal(t, buf.String(), again.String())
+}
+
+func TestWriteKeptStatus(t *testing.T) {
+	item := internal.Todo{ID: 1, Name: "Old idea", Done: true,
+		Extras: map[string]any{taskwarrior.ExtrasKey: map[string]any{"status": "deleted", "end": "20261003T080000Z"}}}
+
+	task := taskwarrior.ToTask(item, nil)
+	assert.Equal(t, "deleted", task["status"])
+	assert.Equal(t, "20261003T080000Z", task["end"])
+
+	// Reopened, it is pending again, with no end.
+	item.Done = false
+	task = taskwarrior.ToTask(item, nil)
+	assert.Equal(t, "pending", task["status"])
+	assert.NotContains(t, task, "end")
+}
+
+// TestWait A waiting task is deferred until its wait date, and written
+// back as waiting only while that is to come.
+func TestWait(t *testing.T) {
+	wait := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+	item, err := taskwarrior.FromTask(map[string]any{"description": "Renew passport", "status": "waiting", "wait": wait.Format("20060102T150405Z")})
+	assert.Nil(t, err)
+	assert.Equal(t, wait, *item.StartDate)
+	assert.Nil(t, item.Extras, "status and wait come from the start date")
+
+	task := taskwarrior.ToTask(item, nil)
+	assert.Equal(t, "waiting", task["status"])
+	assert.Equal(t, wait.Format("20060102T150405Z"), task["wait"])
+
+	item.StartDate = nil
+	task = taskwarrior.ToTask(item, nil)
+	assert.Equal(t, "pending", task["status"])
+	assert.NotContains(t, task, "wait")
+}
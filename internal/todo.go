@@ -12,20 +12,43 @@ const (
 )
 
 type Todo struct {
-	ID        int        `json:"id"`
-	Name      string     `json:"name"`
-	Done      bool       `json:"done"`
-	Priority  Priority   `json:"priority,omitempty"`
-	DueDate   *time.Time `json:"due_date,omitempty"`
+	ID       int      `json:"id"`
+	Name     string   `json:"name"`
+	Done     bool     `json:"done"`
+	Priority Priority `json:"priority,omitempty"`
+	// DueDate When the item is due: the day, as midnight UTC, for an
+	// all-day item, or the instant of its deadline if DueTimed is set.
+	DueDate *time.Time `json:"due_date,omitempty"`
+	// DueTimed Whether DueDate is a deadline at a time of day rather than a
+	// whole day.
+	DueTimed bool `json:"due_timed,omitempty"`
+	// DueZone The IANA time zone a timed deadline was set in, e.g.
+	// "Europe/London", or "" if it is not known.
+	DueZone string `json:"due_zone,omitempty"`
+	// StartDate When the item becomes actionable; until then it is
+	// deferred, and hidden from listings. nil if it always is.
+	StartDate *time.Time `json:"start_date,omitempty"`
 	Tags      []string   `json:"tags,omitempty"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
+	ParentID  int        `json:"parent_id,omitempty"`
+	// Project The ID of the project the item is in, or 0 for none.
+	Project    int    `json:"project,omitempty"`
+	Recurrence string `json:"recurrence,omitempty"`
+	// Extras Attributes of other tools that no field holds, by tool, kept
+	// so that they are written back when items go back to that tool.
+	Extras    map[string]any `json:"extras,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	// DeletedAt When the item was moved to the trash, or nil if it is not
+	// in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 type TodoCollection struct {
 	Items         []Todo
 	Size          int
 	MaxLengthItem int
+	// NextCursor Set when a limited listing has more items to come.
+	NextCursor string
 }
 
 func NewTodo(id int, name string, createdAt time.Time) *Todo {
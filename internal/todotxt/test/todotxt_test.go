@@ -0,0 +1,105 @@
+package todotxt_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/todotxt"
+)
+
+func date(s string) time.Time {
+	t, _ := time.ParseInLocation("2006-01-02", s, time.Local)
+	return t
+}
+
+func TestParse(t *testing.T) {
+	todo := todotxt.Parse("(A) 2026-10-01 Call mum +family @phone due:2026-11-02 t:2026-10-28 see:notes http://example.com/a")
+	assert.Equal(t, "Call mum see:notes http://example.com/a", todo.Name)
+	assert.Equal(t, internal.PriorityHigh, todo.Priority)
+	assert.False(t, todo.Done)
+	assert.Equal(t, date("2026-10-01"), todo.CreatedAt)
+	assert.Equal(t, []string{"family", "@phone"}, todo.Tags)
+	assert.Equal(t, "2026-11-02", todo.DueDate.Format("2006-01-02"))
+	assert.Equal(t, date("2026-10-28"), *todo.StartDate)
+
+	todo = todotxt.Parse("x 2026-10-18 2026-10-01 Pay rent pri:B rec:+1m")
+	assert.True(t, todo.Done)
+	assert.Equal(t, date("2026-10-18"), todo.UpdatedAt)
+	assert.Equal(t, date("2026-10-01"), todo.CreatedAt)
+	assert.Equal(t, internal.PriorityMedium, todo.Priority)
+	assert.Equal(t, "FREQ=MONTHLY", todo.Recurrence)
+	assert.Equal(t, "Pay rent", todo.Name)
+}
+
+func TestParseEdgeCases(t *testing.T) {
+	for _, tt := range []struct {
+		line     string
+		name     string
+		priority internal.Priority
+	}{
+		{"(E) low anyway", "low anyway", internal.PriorityLow},
+		{"(a) not a priority", "(a) not a priority", internal.PriorityNone},
+		{"xylophone lessons", "xylophone lessons", internal.PriorityNone},
+		{"due:someday stays in the name", "due:someday stays in the name", internal.PriorityNone},
+		{"rec:often stays too", "rec:often stays too", internal.PriorityNone},
+		{"a lone + and @ are words", "a lone + and @ are words", internal.PriorityNone},
+	} {
+		todo := todotxt.Parse(tt.line)
+		assert.Equal(t, tt.name, todo.Name, tt.line)
+		assert.Equal(t, tt.priority, todo.Priority, tt.line)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	due := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+	start := date("2026-10-28")
+	todo := internal.Todo{
+		ID: 4, Name: "Call mum", Priority: internal.PriorityHigh, DueDate: &due,
+		Tags: []string{"family", "@phone", "two words"}, Recurrence: "FREQ=WEEKLY;INTERVAL=2",
+		StartDate: &start, CreatedAt: date("2026-10-01"),
+	}
+	assert.Equal(t, "(A) 2026-10-01 Call mum +family @phone +two_words due:2026-11-02 t:2026-10-28 rec:+2w id:4",
+		todotxt.Format(todo, true))
+
+	todo.Done, todo.UpdatedAt, todo.DueDate, todo.StartDate, todo.Tags = true, date("2026-10-18"), nil, nil, nil
+	todo.Recurrence, todo.ParentID = "FREQ=MONTHLY;BYDAY=-1FR", 2
+	assert.Equal(t, "x 2026-10-18 2026-10-01 Call mum pri:A rec:FREQ=MONTHLY;BYDAY=-1FR parent:2",
+		todotxt.Format(todo, false))
+}
+
+func TestRoundTrip(t *testing.T) {
+	due := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+	items := []internal.Todo{
+		{ID: 1, Name: "Plan party ref:42", Priority: internal.PriorityMedium, Tags: []string{"home"},
+			CreatedAt: date("2026-10-01"), UpdatedAt: date("2026-10-02")},
+		{ID: 2, Name: "Book venue", Done: true, Priority: internal.PriorityLow, DueDate: &due, ParentID: 1,
+			Recurrence: "FREQ=YEARLY", CreatedAt: date("2026-10-01"), UpdatedAt: date("2026-10-18")},
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, todotxt.Write(&buf, items))
+	assert.Equal(t, 2, strings.Count(buf.String(), "\n"))
+
+	read, err := todotxt.Read(&buf)
+	assert.Nil(t, err)
+	assert.Len(t, read, 2)
+
+	assert.Equal(t, 1, read[0].ID)
+	assert.Equal(t, "Plan party ref:42", read[0].Name)
+	assert.Equal(t, internal.PriorityMedium, read[0].Priority)
+	assert.Equal(t, []string{"home"}, read[0].Tags)
+	assert.Equal(t, items[0].CreatedAt, read[0].CreatedAt)
+
+	assert.Equal(t, "Book venue", read[1].Name)
+	assert.True(t, read[1].Done)
+	assert.Equal(t, internal.PriorityLow, read[1].Priority)
+	assert.True(t, due.Equal(*read[1].DueDate))
+	assert.Equal(t, 1, read[1].ParentID)
+	assert.Equal(t, "FREQ=YEARLY", read[1].Recurrence)
+	assert.Equal(t, items[1].UpdatedAt, read[1].UpdatedAt)
+}
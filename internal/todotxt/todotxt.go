@@ -0,0 +1,273 @@
+// Package todotxt reads and writes todo lists in the todo.txt format
+// (https://github.com/todotxt/todo.txt), one item per line:
+//
+//	x 2026-10-18 2026-10-01 Book the venue +party @phone due:2026-11-02
+//	(A) 2026-10-01 Plan the party +party id:1
+package todotxt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/recur"
+)
+
+const dateLayout = "2006-01-02"
+
+// Keys of the key:value extensions that map onto item fields. Any other
+// extension is left in the item's name, so it is written back as it was.
+const (
+	keyDue      = "due"
+	keyPriority = "pri"
+	// keyStart The threshold date of todo.txt tools: hidden until then.
+	keyStart  = "t"
+	keyRepeat = "rec"
+	keyID     = "id"
+	keyParent = "parent"
+)
+
+var priorities = map[internal.Priority]string{
+	internal.PriorityHigh:   "A",
+	internal.PriorityMedium: "B",
+	internal.PriorityLow:    "C",
+}
+
+// shortRepeat The rec: values of todo.txt tools, e.g. 1w or +2m: every so
+// many days, weeks, months or years, optionally from the due date rather
+// than from completion.
+var shortRepeat = regexp.MustCompile(`^\+?(\d+)([dwmy])$`)
+
+var shortFreqs = map[string]recur.Frequency{
+	"d": recur.Daily, "w": recur.Weekly, "m": recur.Monthly, "y": recur.Yearly,
+}
+
+// Parse Read one line of todo.txt into an item. Projects (+project) and
+// contexts (@context) become tags, the latter keeping their @. A priority
+// of A, B or C is high, medium or low, and D to Z are low too. Items only
+// get an ID or ParentID from id: and parent:, for linking subtasks to
+// their parents within a list.
+func Parse(line string) internal.Todo {
+	var todo internal.Todo
+	tokens := strings.Fields(line)
+
+	if len(tokens) > 0 && tokens[0] == "x" {
+		todo.Done = true
+		tokens = tokens[1:]
+		if t, ok := parseDate(tokens); ok {
+			todo.UpdatedAt = t
+			tokens = tokens[1:]
+		}
+	} else if len(tokens) > 0 && isPriority(tokens[0]) {
+		todo.Priority = priorityOf(tokens[0][1:2])
+		tokens = tokens[1:]
+	}
+	if t, ok := parseDate(tokens); ok {
+		todo.CreatedAt = t
+		tokens = tokens[1:]
+	}
+
+	var name []string
+	for _, token := range tokens {
+		switch {
+		case len(token) > 1 && token[0] == '+':
+			todo.Tags = append(todo.Tags, token[1:])
+		case len(token) > 1 && token[0] == '@':
+			todo.Tags = append(todo.Tags, token)
+		case !parseExtension(&todo, token):
+			name = append(name, token)
+		}
+	}
+	todo.Name = strings.Join(name, " ")
+	return todo
+}
+
+func parseDate(tokens []string) (time.Time, bool) {
+	if len(tokens) == 0 {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(dateLayout, tokens[0], time.Local)
+	return t, err == nil
+}
+
+func isPriority(token string) bool {
+	return len(token) == 3 && token[0] == '(' && token[1] >= 'A' && token[1] <= 'Z' && token[2] == ')'
+}
+
+func priorityOf(letter string) internal.Priority {
+	for priority, l := range priorities {
+		if l == letter {
+			return priority
+		}
+	}
+	return internal.PriorityLow
+}
+
+// parseExtension Set the field a key:value token maps onto, reporting
+// whether it did. Unknown keys and values that don't fit are left alone.
+func parseExtension(todo *internal.Todo, token string) bool {
+	key, value, ok := strings.Cut(token, ":")
+	if !ok || key == "" || value == "" || strings.Contains(value, ":") {
+		return false
+	}
+
+	switch key {
+	case keyDue:
+		t, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return false
+		}
+		todo.DueDate = &t
+	case keyStart:
+		t, err := time.ParseInLocation(dateLayout, value, time.Local)
+		if err != nil {
+			return false
+		}
+		todo.StartDate = &t
+	case keyPriority:
+		if len(value) != 1 || value[0] < 'A' || value[0] > 'Z' {
+			return false
+		}
+		todo.Priority = priorityOf(value)
+	case keyRepeat:
+		rule, ok := parseRepeat(value)
+		if !ok {
+			return false
+		}
+		todo.Recurrence = rule
+	case keyID, keyParent:
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return false
+		}
+		if key == keyID {
+			todo.ID = n
+		} else {
+			todo.ParentID = n
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// parseRepeat Read a rec: value, either the short form of todo.txt tools or
+// a rule recur understands, into the canonical rule.
+func parseRepeat(value string) (string, bool) {
+	if m := shortRepeat.FindStringSubmatch(value); m != nil {
+		interval, _ := strconv.Atoi(m[1])
+		if interval < 1 {
+			return "", false
+		}
+		return recur.Rule{Freq: shortFreqs[m[2]], Interval: interval}.String(), true
+	}
+	rule, err := recur.Parse(value)
+	if err != nil {
+		return "", false
+	}
+	return rule.String(), true
+}
+
+// Format Write todo as one line of todo.txt. A done item gets its
+// priority as pri:, as the format asks, and UpdatedAt as its completion
+// date. withID adds id:, for subtasks to refer to the item by.
+func Format(todo internal.Todo, withID bool) string {
+	var parts []string
+	if todo.Done {
+		parts = append(parts, "x")
+		if !todo.UpdatedAt.IsZero() {
+			parts = append(parts, todo.UpdatedAt.Local().Format(dateLayout))
+		}
+	} else if letter, ok := priorities[todo.Priority]; ok {
+		parts = append(parts, "("+letter+")")
+	}
+	if !todo.CreatedAt.IsZero() {
+		parts = append(parts, todo.CreatedAt.Local().Format(dateLayout))
+	}
+	if todo.Name != "" {
+		parts = append(parts, todo.Name)
+	}
+
+	for _, tag := range todo.Tags {
+		tag = strings.Join(strings.Fields(tag), "_")
+		if !strings.HasPrefix(tag, "@") {
+			tag = "+" + tag
+		}
+		parts = append(parts, tag)
+	}
+	// todo.txt has no times, so a deadline is written as its day.
+	if due := todo.DueDay(todo.DueLocation(time.Local)); due != nil {
+		parts = append(parts, keyDue+":"+due.Format(dateLayout))
+	}
+	if todo.StartDate != nil {
+		parts = append(parts, keyStart+":"+todo.StartDate.Local().Format(dateLayout))
+	}
+	if letter, ok := priorities[todo.Priority]; ok && todo.Done {
+		parts = append(parts, keyPriority+":"+letter)
+	}
+	if todo.Recurrence != "" {
+		parts = append(parts, keyRepeat+":"+formatRepeat(todo))
+	}
+	if withID {
+		parts = append(parts, keyID+":"+strconv.Itoa(todo.ID))
+	}
+	if todo.ParentID != 0 {
+		parts = append(parts, keyParent+":"+strconv.Itoa(todo.ParentID))
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatRepeat The rec: value for todo: the short form where the rule has
+// one, marked with + when the next occurrence follows the due date.
+func formatRepeat(todo internal.Todo) string {
+	rule, err := recur.Parse(todo.Recurrence)
+	if err != nil || len(rule.ByDay) > 0 || rule.Until != nil || rule.Count > 0 {
+		return todo.Recurrence
+	}
+	for unit, freq := range shortFreqs {
+		if freq == rule.Freq {
+			value := strconv.Itoa(rule.Interval) + unit
+			if todo.DueDate != nil {
+				value = "+" + value
+			}
+			return value
+		}
+	}
+	return todo.Recurrence
+}
+
+// Read Parse every non-blank line of r.
+func Read(r io.Reader) ([]internal.Todo, error) {
+	var items []internal.Todo
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			items = append(items, Parse(line))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading todo.txt: %w", err)
+	}
+	return items, nil
+}
+
+// Write Format each item as a line of w, giving id: to those that have
+// subtasks among items.
+func Write(w io.Writer, items []internal.Todo) error {
+	parents := map[int]bool{}
+	for _, item := range items {
+		parents[item.ParentID] = true
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, item := range items {
+		fmt.Fprintln(bw, Format(item, parents[item.ID]))
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,81 @@
+package internal
+
+// Nested An item together with its depth in the subtask tree.
+type Nested struct {
+	Item  Todo
+	Depth int
+}
+
+// Nest Order items depth-first so every subtask follows its parent,
+// keeping the existing order among siblings. Items whose parent is not in
+// items are treated as top-level.
+func Nest(items []Todo) []Nested {
+	present := make(map[int]bool, len(items))
+	for _, item := range items {
+		present[item.ID] = true
+	}
+
+	var roots []Todo
+	children := make(map[int][]Todo)
+	for _, item := range items {
+		if item.ParentID != 0 && item.ParentID != item.ID && present[item.ParentID] {
+			children[item.ParentID] = append(children[item.ParentID], item)
+		} else {
+			roots = append(roots, item)
+		}
+	}
+
+	nested := make([]Nested, 0, len(items))
+	visited := make(map[int]bool, len(items))
+
+	var walk func(item Todo, depth int)
+	walk = func(item Todo, depth int) {
+		if visited[item.ID] {
+			return
+		}
+		visited[item.ID] = true
+		nested = append(nested, Nested{Item: item, Depth: depth})
+		for _, child := range children[item.ID] {
+			walk(child, depth+1)
+		}
+	}
+
+	for _, root := range roots {
+		walk(root, 0)
+	}
+
+	// Anything left over is part of a parent cycle; show it rather than lose it.
+	for _, item := range items {
+		walk(item, 0)
+	}
+
+	return nested
+}
+
+// Descendants Every item below id in the subtask tree, depth-first.
+func Descendants(items []Todo, id int) []Todo {
+	children := make(map[int][]Todo)
+	for _, item := range items {
+		if item.ParentID != 0 {
+			children[item.ParentID] = append(children[item.ParentID], item)
+		}
+	}
+
+	var result []Todo
+	visited := map[int]bool{id: true}
+
+	var walk func(parent int)
+	walk = func(parent int) {
+		for _, child := range children[parent] {
+			if visited[child.ID] {
+				continue
+			}
+			visited[child.ID] = true
+			result = append(result, child)
+			walk(child.ID)
+		}
+	}
+	walk(id)
+
+	return result
+}
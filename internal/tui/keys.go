@@ -0,0 +1,87 @@
+package tui
+
+import "unicode/utf8"
+
+// KeyCode A key with no character of its own.
+type KeyCode int
+
+const (
+	KeyRune KeyCode = iota
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyPageUp
+	KeyPageDown
+	KeyHome
+	KeyEnd
+	KeyEnter
+	KeyEsc
+	KeyBackspace
+	KeyTab
+	KeyCtrlC
+	// KeyUnknown An escape sequence for a key the TUI doesn't use.
+	KeyUnknown
+)
+
+// Key One key press: a character typed, when Code is KeyRune, or another
+// key.
+type Key struct {
+	Code KeyCode
+	Rune rune
+}
+
+// escapes The sequences terminals send for the keys that have one, in
+// both the normal and the application cursor key modes.
+var escapes = map[string]KeyCode{
+	"[A": KeyUp, "[B": KeyDown, "[C": KeyRight, "[D": KeyLeft,
+	"OA": KeyUp, "OB": KeyDown, "OC": KeyRight, "OD": KeyLeft,
+	"[5~": KeyPageUp, "[6~": KeyPageDown,
+	"[H": KeyHome, "[F": KeyEnd, "OH": KeyHome, "OF": KeyEnd,
+	"[1~": KeyHome, "[4~": KeyEnd, "[7~": KeyHome, "[8~": KeyEnd,
+}
+
+// ParseKeys The keys pressed, as read from a terminal in raw mode. An escape
+// on its own, at the end of what was read, is the Esc key.
+func ParseKeys(b []byte) []Key {
+	var keys []Key
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == 0x1b && len(b) > 1 && (b[1] == '[' || b[1] == 'O'):
+			// The sequence runs to its first letter or ~.
+			end := 2
+			for end < len(b) && !(b[end] >= 0x40 && b[end] <= 0x7e) {
+				end++
+			}
+			if end == len(b) {
+				end--
+			}
+			code, ok := escapes[string(b[1:end+1])]
+			if !ok {
+				code = KeyUnknown
+			}
+			keys = append(keys, Key{Code: code})
+			b = b[end+1:]
+			continue
+		case c == 0x1b:
+			keys = append(keys, Key{Code: KeyEsc})
+		case c == '\r' || c == '\n':
+			keys = append(keys, Key{Code: KeyEnter})
+		case c == '\t':
+			keys = append(keys, Key{Code: KeyTab})
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, Key{Code: KeyBackspace})
+		case c == 0x03:
+			keys = append(keys, Key{Code: KeyCtrlC})
+		case c < 0x20:
+			keys = append(keys, Key{Code: KeyUnknown})
+		default:
+			r, size := utf8.DecodeRune(b)
+			keys = append(keys, Key{Code: KeyRune, Rune: r})
+			b = b[size:]
+			continue
+		}
+		b = b[1:]
+	}
+	return keys
+}
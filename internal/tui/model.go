@@ -0,0 +1,426 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/dateparse"
+	"github.com/tcooper-uk/go-todo/internal/search"
+	"github.com/tcooper-uk/go-todo/internal/storage"
+)
+
+// mode What keys typed go to: the list, or the prompt being answered.
+type mode int
+
+const (
+	modeList mode = iota
+	modeSearch
+	modeDue
+	modeTag
+)
+
+// priorities The order p cycles an item's priority through.
+var priorities = []internal.Priority{
+	internal.PriorityNone, internal.PriorityLow, internal.PriorityMedium, internal.PriorityHigh,
+}
+
+// Model The state of the TUI: the items of a store, which of them are
+// shown, and the one the cursor is on. Keys are given to Update and the
+// screen is drawn from View, so it can be driven without a terminal.
+type Model struct {
+	ctx   context.Context
+	store storage.TodoStore
+
+	// items Every item in the store, done or not; rows are those shown.
+	items []internal.Todo
+	rows  []internal.Nested
+
+	cursor int
+	// offset The row shown at the top of the list, and listHeight how many
+	// rows fit, as of the last View.
+	offset     int
+	listHeight int
+
+	// showDone Also show done and deferred items.
+	showDone bool
+	tag      string
+	query    string
+	expr     search.Expr
+
+	mode mode
+	// input What has been typed at the prompt, and before what was there
+	// when it was opened, restored by Esc.
+	input  []rune
+	before string
+
+	status string
+	quit   bool
+}
+
+// New A Model of the items in store.
+func New(ctx context.Context, store storage.TodoStore) (*Model, error) {
+	m := &Model{ctx: ctx, store: store}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// load Read the items from the store again, keeping the cursor on the
+// item it was on.
+func (m *Model) load() error {
+	all, err := m.store.GetAllItems(m.ctx, storage.ListOptions{ShowDone: true, IncludeDeferred: true})
+	if err != nil {
+		return err
+	}
+	m.items = all.Items
+	m.refilter()
+	return nil
+}
+
+// refilter Work out the rows shown from the items and the filters set.
+func (m *Model) refilter() {
+	selected := 0
+	if item := m.Selected(); item != nil {
+		selected = item.ID
+	}
+
+	now := time.Now()
+	var shown []internal.Todo
+	for _, item := range m.items {
+		if (item.Done || item.Deferred(now)) && !m.showDone {
+			continue
+		}
+		if m.tag != "" && !hasTag(item, m.tag) {
+			continue
+		}
+		shown = append(shown, item)
+	}
+	if m.expr != nil {
+		results := search.Filter(shown, m.expr)
+		shown = make([]internal.Todo, len(results))
+		for i, r := range results {
+			shown[i] = r.Item
+		}
+	}
+	m.rows = internal.Nest(shown)
+
+	// An item no longer shown, like one just marked done, leaves the
+	// cursor on the row that took its place.
+	for i, row := range m.rows {
+		if row.Item.ID == selected {
+			m.cursor = i
+			break
+		}
+	}
+	m.move(0)
+}
+
+func hasTag(item internal.Todo, tag string) bool {
+	for _, t := range item.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// tags Every tag used by an item, sorted.
+func (m *Model) tags() []string {
+	seen := map[string]bool{}
+	var tags []string
+	for _, item := range m.items {
+		for _, t := range item.Tags {
+			if !seen[t] {
+				seen[t] = true
+				tags = append(tags, t)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// Selected The item the cursor is on, or nil if no items are shown.
+func (m *Model) Selected() *internal.Todo {
+	if m.cursor < 0 || m.cursor >= len(m.rows) {
+		return nil
+	}
+	return &m.rows[m.cursor].Item
+}
+
+// Status The message shown at the bottom of the screen, if any.
+func (m *Model) Status() string {
+	return m.status
+}
+
+// Quit Whether the user has asked to leave the TUI.
+func (m *Model) Quit() bool {
+	return m.quit
+}
+
+// Update Act on a key pressed.
+func (m *Model) Update(k Key) {
+	if k.Code == KeyCtrlC {
+		m.quit = true
+		return
+	}
+	if m.mode != modeList {
+		m.updatePrompt(k)
+		return
+	}
+
+	m.status = ""
+	switch {
+	case k.Code == KeyDown || k.Rune == 'j':
+		m.move(1)
+	case k.Code == KeyUp || k.Rune == 'k':
+		m.move(-1)
+	case k.Code == KeyPageDown:
+		m.move(max(m.listHeight-1, 1))
+	case k.Code == KeyPageUp:
+		m.move(-max(m.listHeight-1, 1))
+	case k.Code == KeyHome || k.Rune == 'g':
+		m.move(-len(m.rows))
+	case k.Code == KeyEnd || k.Rune == 'G':
+		m.move(len(m.rows))
+	case k.Code == KeyEsc:
+		m.query, m.expr = "", nil
+		m.refilter()
+	case k.Code == KeyTab:
+		m.cycleTag()
+	case k.Code != KeyRune:
+	case k.Rune == 'q':
+		m.quit = true
+	case k.Rune == ' ' || k.Rune == 'x':
+		m.toggleDone()
+	case k.Rune == 'p':
+		m.cyclePriority()
+	case k.Rune == 'd':
+		if item := m.Selected(); item != nil {
+			m.prompt(modeDue, item.FormatDue("2006-01-02", time.Local))
+		}
+	case k.Rune == 't':
+		m.prompt(modeTag, m.tag)
+	case k.Rune == '/':
+		m.prompt(modeSearch, m.query)
+	case k.Rune == 'a':
+		m.showDone = !m.showDone
+		m.refilter()
+	case k.Rune == 'r':
+		m.report(m.load())
+	}
+}
+
+func (m *Model) move(by int) {
+	m.cursor += by
+	if m.cursor >= len(m.rows) {
+		m.cursor = len(m.rows) - 1
+	}
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// prompt Start asking for a value, starting from value.
+func (m *Model) prompt(mode mode, value string) {
+	m.mode = mode
+	m.input = []rune(value)
+	m.before = value
+	m.status = ""
+}
+
+func (m *Model) updatePrompt(k Key) {
+	switch k.Code {
+	case KeyEsc:
+		m.input = []rune(m.before)
+		if m.mode == modeSearch {
+			m.search()
+		}
+		m.mode = modeList
+		return
+	case KeyEnter:
+		m.answer()
+		return
+	case KeyBackspace:
+		if len(m.input) > 0 {
+			m.input = m.input[:len(m.input)-1]
+		}
+	case KeyTab:
+		if m.mode == modeTag {
+			m.input = []rune(nextTag(m.tags(), string(m.input)))
+		}
+	case KeyRune:
+		m.input = append(m.input, k.Rune)
+	default:
+		return
+	}
+	if m.mode == modeSearch {
+		m.search()
+	}
+}
+
+// search Show the items matching what has been typed so far. A query that
+// can't be read yet, like one with an open quote, keeps the last results.
+func (m *Model) search() {
+	m.query = strings.TrimSpace(string(m.input))
+	m.status = ""
+	if m.query == "" {
+		m.expr = nil
+	} else if expr, err := search.Parse(m.query); err != nil {
+		m.status = err.Error()
+		return
+	} else {
+		m.expr = expr
+	}
+	m.refilter()
+}
+
+// answer Act on the value given at the prompt.
+func (m *Model) answer() {
+	value := strings.TrimSpace(string(m.input))
+	switch m.mode {
+	case modeSearch:
+		m.search()
+	case modeTag:
+		m.tag = strings.TrimPrefix(value, "#")
+		m.refilter()
+	case modeDue:
+		var due dateparse.Date
+		if value != "" {
+			var err error
+			if due, err = dateparse.Parse(value, time.Now()); err != nil {
+				m.status = fmt.Sprintf("Invalid due date %q — use e.g. %s", value, dateparse.Examples)
+				return
+			}
+		}
+		m.edit(func(item *internal.Todo) {
+			item.DueDate, item.DueTimed, item.DueZone = nil, false, ""
+			if value != "" {
+				due.SetDue(item)
+			}
+		})
+	}
+	m.mode = modeList
+}
+
+// nextTag The tag after current in tags, wrapping round, or the first tag
+// current is the start of.
+func nextTag(tags []string, current string) string {
+	if len(tags) == 0 {
+		return current
+	}
+	for i, t := range tags {
+		if t == current {
+			return tags[(i+1)%len(tags)]
+		}
+	}
+	for _, t := range tags {
+		if strings.HasPrefix(strings.ToLower(t), strings.ToLower(current)) {
+			return t
+		}
+	}
+	return tags[0]
+}
+
+// cycleTag Filter by the next tag in use, then by none after the last.
+func (m *Model) cycleTag() {
+	tags := m.tags()
+	if len(tags) == 0 {
+		return
+	}
+	if m.tag == "" {
+		m.tag = tags[0]
+	} else if next := nextTag(tags, m.tag); next == tags[0] {
+		m.tag = ""
+	} else {
+		m.tag = next
+	}
+	m.refilter()
+}
+
+// toggleDone Mark the selected item done, adding its next occurrence if it
+// repeats, as todo done does, or open it again if it is done.
+func (m *Model) toggleDone() {
+	item := m.Selected()
+	if item == nil {
+		return
+	}
+	done := !item.Done
+	edited, ok := m.edit(func(item *internal.Todo) { item.Done = done })
+	if !ok || !done {
+		return
+	}
+
+	added, err := storage.AddNextOccurrence(m.ctx, m.store, *edited, time.Now())
+	if err != nil || added == nil {
+		m.report(err)
+		return
+	}
+	m.report(m.load())
+	if m.status == "" {
+		m.status = fmt.Sprintf("Next occurrence: [%d] due %s", added.ID, added.FormatDue("Mon 02 Jan 06", time.Local))
+	}
+}
+
+// cyclePriority Move the selected item on to the next priority.
+func (m *Model) cyclePriority() {
+	item := m.Selected()
+	if item == nil {
+		return
+	}
+	next := priorities[0]
+	for i, p := range priorities {
+		if p == item.Priority {
+			next = priorities[(i+1)%len(priorities)]
+		}
+	}
+	m.edit(func(item *internal.Todo) { item.Priority = next })
+}
+
+// edit Change the selected item with change and save it, returning it as
+// saved.
+func (m *Model) edit(change func(item *internal.Todo)) (*internal.Todo, bool) {
+	item := m.Selected()
+	if item == nil {
+		return nil, false
+	}
+	todo := *item
+	change(&todo)
+	edited, err := m.store.EditItem(m.ctx, todo.ID, todo)
+	if err != nil {
+		m.report(err)
+		return nil, false
+	}
+	for i := range m.items {
+		if m.items[i].ID == edited.ID {
+			m.items[i] = *edited
+		}
+	}
+	m.refilter()
+	return edited, true
+}
+
+// report Show err, if any, in the status line.
+func (m *Model) report(err error) {
+	if err == nil {
+		return
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		m.status = "That item no longer exists — press r to reload"
+		return
+	}
+	m.status = err.Error()
+}
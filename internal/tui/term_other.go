@@ -0,0 +1,20 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package tui
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoTerminal = errors.New("todo tui needs a Unix terminal")
+
+func makeRaw(fd int) (func(), error) {
+	return nil, errNoTerminal
+}
+
+func size(fd int) (int, int, error) {
+	return 0, 0, errNoTerminal
+}
+
+func notifyResize(c chan<- os.Signal) {}
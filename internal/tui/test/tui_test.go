@@ -0,0 +1,251 @@
+package tui_test
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/storage"
+	"github.com/tcooper-uk/go-todo/internal/tui"
+)
+
+var ansi = regexp.MustCompile("\x1b\\[[0-9;?]*[a-zA-Z]")
+
+func newStore(t *testing.T, items ...internal.Todo) storage.TodoStore {
+	t.Helper()
+	store, err := storage.NewLocalFileStore(filepath.Join(t.TempDir(), "todo.json"))
+	assert.NoError(t, err)
+	for _, item := range items {
+		_, err := store.AddItem(context.Background(), item)
+		assert.NoError(t, err)
+	}
+	return store
+}
+
+func newModel(t *testing.T, store storage.TodoStore) *tui.Model {
+	t.Helper()
+	m, err := tui.New(context.Background(), store)
+	assert.NoError(t, err)
+	return m
+}
+
+// press Give m the keys typed as keys.
+func press(m *tui.Model, keys string) {
+	for _, k := range tui.ParseKeys([]byte(keys)) {
+		m.Update(k)
+	}
+}
+
+// screen The text of the screen m shows, without its colours.
+func screen(m *tui.Model, width, height int) string {
+	return ansi.ReplaceAllString(strings.Join(m.View(width, height), "\n"), "")
+}
+
+func get(t *testing.T, store storage.TodoStore, id int) *internal.Todo {
+	t.Helper()
+	item, err := store.GetItem(context.Background(), id)
+	assert.NoError(t, err)
+	return item
+}
+
+func TestParseKeys(t *testing.T) {
+	keys := tui.ParseKeys([]byte("j\x1b[A\x1b[B\x1bOA\x1b[5~\x1b[6~\r\t\x7f\x03é\x1b"))
+	assert.Equal(t, []tui.Key{
+		{Code: tui.KeyRune, Rune: 'j'},
+		{Code: tui.KeyUp}, {Code: tui.KeyDown}, {Code: tui.KeyUp},
+		{Code: tui.KeyPageUp}, {Code: tui.KeyPageDown},
+		{Code: tui.KeyEnter}, {Code: tui.KeyTab}, {Code: tui.KeyBackspace}, {Code: tui.KeyCtrlC},
+		{Code: tui.KeyRune, Rune: 'é'},
+		{Code: tui.KeyEsc},
+	}, keys)
+
+	assert.Equal(t, []tui.Key{{Code: tui.KeyUnknown}, {Code: tui.KeyRune, Rune: 'q'}}, tui.ParseKeys([]byte("\x1b[15~q")))
+}
+
+func TestNavigation(t *testing.T) {
+	store := newStore(t, internal.Todo{Name: "one"}, internal.Todo{Name: "two"}, internal.Todo{Name: "three"})
+	m := newModel(t, store)
+	assert.Equal(t, 1, m.Selected().ID)
+
+	press(m, "jj")
+	assert.Equal(t, 3, m.Selected().ID)
+	press(m, "j")
+	assert.Equal(t, 3, m.Selected().ID, "the cursor stops at the last item")
+	press(m, "\x1b[A")
+	assert.Equal(t, 2, m.Selected().ID)
+	press(m, "g")
+	assert.Equal(t, 1, m.Selected().ID)
+	press(m, "G")
+	assert.Equal(t, 3, m.Selected().ID)
+
+	assert.False(t, m.Quit())
+	press(m, "q")
+	assert.True(t, m.Quit())
+}
+
+func TestToggleDone(t *testing.T) {
+	due := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	store := newStore(t,
+		internal.Todo{Name: "water the plants", DueDate: &due, Recurrence: "FREQ=WEEKLY"},
+		internal.Todo{Name: "post the letter"},
+	)
+	m := newModel(t, store)
+
+	press(m, " ")
+	assert.True(t, get(t, store, 1).Done)
+	assert.Contains(t, m.Status(), "Next occurrence: [3] due Mon 26 Oct 26")
+	assert.Equal(t, 2, m.Selected().ID, "the cursor moves on as the done item is hidden")
+	assert.NotContains(t, screen(m, 80, 24), "[1]")
+
+	press(m, "a")
+	assert.Contains(t, screen(m, 80, 24), "[1]  [x]")
+	press(m, "gx")
+	assert.False(t, get(t, store, 1).Done)
+	items, err := store.GetAllItems(context.Background(), storage.ListOptions{ShowDone: true})
+	assert.NoError(t, err)
+	assert.Len(t, items.Items, 3, "reopening adds no occurrence")
+}
+
+func TestCyclePriority(t *testing.T) {
+	store := newStore(t, internal.Todo{Name: "one"})
+	m := newModel(t, store)
+
+	for _, want := range []internal.Priority{
+		internal.PriorityLow, internal.PriorityMedium, internal.PriorityHigh, internal.PriorityNone,
+	} {
+		press(m, "p")
+		assert.Equal(t, want, get(t, store, 1).Priority)
+	}
+}
+
+func TestEditDueDate(t *testing.T) {
+	store := newStore(t, internal.Todo{Name: "one"})
+	m := newModel(t, store)
+
+	press(m, "d2026-11-05\r")
+	assert.Equal(t, time.Date(2026, 11, 5, 0, 0, 0, 0, time.UTC), *get(t, store, 1).DueDate)
+
+	press(m, "d")
+	assert.Contains(t, screen(m, 80, 24), "Due (e.g. 2026-11-05, fri or +3d; empty for none): 2026-11-05")
+	press(m, "\x7f\x7fxx\r")
+	assert.Contains(t, m.Status(), `Invalid due date "2026-11-xx"`)
+	press(m, "\x1b")
+	assert.Equal(t, time.Date(2026, 11, 5, 0, 0, 0, 0, time.UTC), *get(t, store, 1).DueDate)
+
+	press(m, "d")
+	for i := 0; i < 10; i++ {
+		press(m, "\x7f")
+	}
+	press(m, "tomorrow\r")
+	y, mo, d := time.Now().AddDate(0, 0, 1).Date()
+	assert.Equal(t, time.Date(y, mo, d, 0, 0, 0, 0, time.UTC), *get(t, store, 1).DueDate)
+
+	press(m, "d")
+	for i := 0; i < 10; i++ {
+		press(m, "\x7f")
+	}
+	press(m, "\r")
+	assert.Nil(t, get(t, store, 1).DueDate)
+}
+
+func TestTagFilter(t *testing.T) {
+	store := newStore(t,
+		internal.Todo{Name: "one", Tags: []string{"work"}},
+		internal.Todo{Name: "two", Tags: []string{"home"}},
+		internal.Todo{Name: "three", Tags: []string{"work", "urgent"}},
+	)
+	m := newModel(t, store)
+
+	press(m, "twork\r")
+	view := screen(m, 80, 24)
+	assert.Contains(t, view, "#work")
+	assert.Contains(t, view, "one")
+	assert.Contains(t, view, "three")
+	assert.NotContains(t, view, "two")
+
+	// Tab steps through the tags in use, then back to all of them.
+	press(m, "\t")
+	assert.Contains(t, screen(m, 80, 24), "todo — 3 item(s)")
+	press(m, "\t")
+	assert.Contains(t, screen(m, 80, 24), "todo — 1 item(s)  #home")
+	press(m, "\t\t")
+	assert.Contains(t, screen(m, 80, 24), "todo — 2 item(s)  #work")
+
+	press(m, "t\x7f\x7f\x7f\x7fur\t\r")
+	assert.Contains(t, screen(m, 80, 24), "todo — 1 item(s)  #urgent")
+	press(m, "t\x7f\x7f\x7f\x7f\x7f\x7f\r")
+	assert.Contains(t, screen(m, 80, 24), "todo — 3 item(s)")
+}
+
+func TestLiveSearch(t *testing.T) {
+	store := newStore(t,
+		internal.Todo{Name: "buy milk"},
+		internal.Todo{Name: "buy bread"},
+		internal.Todo{Name: "walk the dog"},
+	)
+	m := newModel(t, store)
+
+	press(m, "/buy")
+	assert.Contains(t, screen(m, 80, 24), "todo — 2 item(s)  /buy")
+	press(m, " mi")
+	assert.Contains(t, screen(m, 80, 24), "todo — 0 item(s)", "results follow each key")
+	press(m, "lk")
+	assert.Equal(t, 1, m.Selected().ID)
+
+	// A query that can't be read yet keeps the last results.
+	press(m, ` "`)
+	assert.Contains(t, screen(m, 80, 24), "todo — 1 item(s)")
+	assert.NotEmpty(t, m.Status())
+
+	press(m, "\x7f\x7f\r")
+	assert.Contains(t, screen(m, 80, 24), "/buy milk")
+	press(m, "\x1b")
+	assert.Contains(t, screen(m, 80, 24), "todo — 3 item(s)")
+}
+
+func TestDetailPane(t *testing.T) {
+	due := time.Date(2026, 11, 5, 0, 0, 0, 0, time.UTC)
+	store := newStore(t, internal.Todo{
+		Name:       "Plan the party\nbook the hall\nsend the invitations",
+		Priority:   internal.PriorityHigh,
+		DueDate:    &due,
+		Tags:       []string{"party"},
+		Recurrence: "FREQ=YEARLY",
+	})
+	m := newModel(t, store)
+
+	view := screen(m, 80, 24)
+	assert.Contains(t, view, "Plan the party …", "the list shows the first line")
+	assert.Contains(t, view, "\nbook the hall")
+	assert.Contains(t, view, "\nsend the invitations")
+	assert.Contains(t, view, "\nPriority: high  Due: Thu 05 Nov 26  Repeats: every year  Tags: #party")
+	assert.Contains(t, view, "Created: ")
+
+	assert.NotContains(t, screen(m, 80, 8), "book the hall", "small terminals have no pane")
+}
+
+func TestViewFitsTheTerminal(t *testing.T) {
+	var items []internal.Todo
+	for i := 0; i < 40; i++ {
+		items = append(items, internal.Todo{Name: strings.Repeat("a long name ", 10), Tags: []string{"tag"}})
+	}
+	m := newModel(t, newStore(t, items...))
+
+	for _, size := range [][2]int{{80, 24}, {20, 5}, {120, 50}, {1, 1}, {40, 12}} {
+		press(m, "G")
+		lines := m.View(size[0], size[1])
+		assert.Len(t, lines, size[1])
+		for _, line := range lines {
+			assert.LessOrEqual(t, utf8.RuneCountInString(ansi.ReplaceAllString(line, "")), size[0])
+		}
+		if size[1] > 2 {
+			assert.Contains(t, screen(m, size[0], size[1]), "[40]", "the cursor row is shown")
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Package tui A full-screen terminal interface to the items of any
+// TodoStore.
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tcooper-uk/go-todo/internal/storage"
+)
+
+const (
+	enterScreen = "\x1b[?1049h\x1b[?25l"
+	leaveScreen = "\x1b[?25h\x1b[?1049l"
+)
+
+// Run Show the items of store full screen on the terminal until the user
+// quits or ctx is done.
+func Run(ctx context.Context, store storage.TodoStore) error {
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if _, _, err := size(out); err != nil {
+		return fmt.Errorf("todo tui needs a terminal: %w", err)
+	}
+
+	m, err := New(ctx, store)
+	if err != nil {
+		return err
+	}
+
+	restore, err := makeRaw(in)
+	if err != nil {
+		return fmt.Errorf("todo tui needs a terminal: %w", err)
+	}
+	defer restore()
+	os.Stdout.WriteString(enterScreen)
+	defer os.Stdout.WriteString(leaveScreen)
+
+	keys := make(chan []Key)
+	errs := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				errs <- err
+				return
+			}
+			keys <- ParseKeys(buf[:n])
+		}
+	}()
+
+	resized := make(chan os.Signal, 1)
+	notifyResize(resized)
+
+	for {
+		if err := draw(m, out); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errs:
+			return err
+		case <-resized:
+		case ks := <-keys:
+			for _, k := range ks {
+				m.Update(k)
+			}
+			if m.Quit() {
+				return nil
+			}
+		}
+	}
+}
+
+// draw Write the screen for the terminal's size as it is now.
+func draw(m *Model, fd int) error {
+	width, height, err := size(fd)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	for i, line := range m.View(width, height) {
+		fmt.Fprintf(&b, "\x1b[%d;1H%s\x1b[K", i+1, line)
+	}
+	_, err = os.Stdout.WriteString(b.String())
+	if errors.Is(err, os.ErrClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,307 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tcooper-uk/go-todo/internal"
+	"github.com/tcooper-uk/go-todo/internal/recur"
+)
+
+const (
+	ansiRed     = "\033[31m"
+	ansiReverse = "\033[7m"
+	ansiReset   = "\033[0m"
+)
+
+// minDetailHeight The smallest terminal the detail pane is shown in; below
+// it, every line goes to the list.
+const minDetailHeight = 12
+
+// help The keys, shown in the status line when there is nothing else to
+// show.
+const help = "j/k move  space done  p priority  d due  t tag  tab next tag  / search  a all  r reload  q quit"
+
+// View The screen as height lines, none wider than width, not counting
+// the escape codes that colour them.
+func (m *Model) View(width, height int) []string {
+	if width < 1 || height < 1 {
+		return nil
+	}
+
+	lines := []string{fit(m.header(), width)}
+
+	detailHeight := 0
+	if height >= minDetailHeight {
+		detailHeight = height / 3
+	}
+	m.listHeight = height - 2
+	if detailHeight > 0 {
+		m.listHeight -= detailHeight + 1
+	}
+	if m.listHeight < 0 {
+		m.listHeight = 0
+	}
+	lines = append(lines, m.list(width)...)
+
+	if detailHeight > 0 {
+		lines = append(lines, strings.Repeat("─", width))
+		lines = append(lines, m.detail(width, detailHeight)...)
+	}
+
+	lines = append(lines, m.statusLine(width))
+	return lines[:height]
+}
+
+// header What is shown, and how it is filtered.
+func (m *Model) header() string {
+	header := fmt.Sprintf("todo — %d item(s)", len(m.rows))
+	if m.showDone {
+		header += ", done and deferred shown"
+	}
+	if m.tag != "" {
+		header += "  #" + m.tag
+	}
+	if m.query != "" {
+		header += "  /" + m.query
+	}
+	return header
+}
+
+// list The rows that fit, scrolled so the cursor is on one of them.
+func (m *Model) list(width int) []string {
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+m.listHeight {
+		m.offset = m.cursor - m.listHeight + 1
+	}
+	if m.offset > len(m.rows)-m.listHeight {
+		m.offset = max(len(m.rows)-m.listHeight, 0)
+	}
+
+	idWidth := 1
+	for _, row := range m.rows {
+		if w := len(strconv.Itoa(row.Item.ID)); w > idWidth {
+			idWidth = w
+		}
+	}
+
+	now := time.Now()
+	lines := make([]string, m.listHeight)
+	for i := range lines {
+		n := m.offset + i
+		if n >= len(m.rows) {
+			if i == 0 && len(m.rows) == 0 {
+				lines[i] = fit("No items.", width)
+			} else {
+				lines[i] = strings.Repeat(" ", width)
+			}
+			continue
+		}
+
+		line, due := row(m.rows[n], idWidth)
+		line = fit(line, width)
+		if n == m.cursor {
+			lines[i] = ansiReverse + line + ansiReset
+			continue
+		}
+		// The due date is red when it has passed, if it fits.
+		if m.rows[n].Item.Overdue(now) && due != "" {
+			if at := strings.LastIndex(line, due); at != -1 {
+				line = line[:at] + ansiRed + due + ansiReset + line[at+len(due):]
+			}
+		}
+		lines[i] = line
+	}
+	return lines
+}
+
+// row One item as a line, as todo ls shows it, and its due date as
+// written in the line.
+func row(n internal.Nested, idWidth int) (string, string) {
+	item := n.Item
+	done := "[ ]"
+	if item.Done {
+		done = "[x]"
+	}
+	priority := "[ ]"
+	switch item.Priority {
+	case internal.PriorityHigh:
+		priority = "[H]"
+	case internal.PriorityMedium:
+		priority = "[M]"
+	case internal.PriorityLow:
+		priority = "[L]"
+	}
+
+	name := item.Name
+	if i := strings.IndexByte(name, '\n'); i != -1 {
+		name = name[:i] + " …"
+	}
+	if n.Depth > 0 {
+		name = strings.Repeat("  ", n.Depth-1) + "└ " + name
+	}
+
+	line := fmt.Sprintf("%*s  %s %s %s", idWidth+2, "["+strconv.Itoa(item.ID)+"]", done, priority, name)
+	for _, tag := range item.Tags {
+		line += " #" + tag
+	}
+	due := item.FormatDue("Mon 02 Jan 06", time.Local)
+	if due != "" {
+		line += "  " + due
+	}
+	if item.Recurrence != "" {
+		line += " ↻"
+	}
+	if item.Deferred(time.Now()) {
+		line += " ⏸"
+	}
+	return line, due
+}
+
+// detail The selected item in full, in height lines.
+func (m *Model) detail(width, height int) []string {
+	var lines []string
+	if item := m.Selected(); item != nil {
+		for _, line := range strings.Split(item.Name, "\n") {
+			lines = append(lines, wrap(line, width)...)
+		}
+		lines = append(lines, "")
+		lines = append(lines, pack(fields(*item), width)...)
+	}
+
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+	if len(lines) > height {
+		lines = lines[:height]
+		lines[height-1] = "…"
+	}
+	for i := range lines {
+		lines[i] = fit(lines[i], width)
+	}
+	return lines
+}
+
+// fields The item's fields other than its name that are set.
+func fields(item internal.Todo) []string {
+	var lines []string
+	add := func(label, value string) {
+		if value != "" {
+			lines = append(lines, label+": "+value)
+		}
+	}
+
+	add("Priority", string(item.Priority))
+	if item.DueDate != nil {
+		add("Due", item.FormatDue("Mon 02 Jan 06", time.Local))
+	}
+	if item.StartDate != nil {
+		add("Starts", item.StartDate.In(time.Local).Format("Mon 02 Jan 06 15:04"))
+	}
+	if item.Recurrence != "" {
+		repeats := item.Recurrence
+		if rule, err := recur.Parse(item.Recurrence); err == nil {
+			repeats = rule.Describe()
+		}
+		add("Repeats", repeats)
+	}
+	if len(item.Tags) > 0 {
+		add("Tags", "#"+strings.Join(item.Tags, " #"))
+	}
+	if item.ParentID != 0 {
+		add("Parent", "["+strconv.Itoa(item.ParentID)+"]")
+	}
+	add("Created", item.CreatedAt.Local().Format("Mon 02 Jan 06 15:04"))
+	add("Updated", item.UpdatedAt.Local().Format("Mon 02 Jan 06 15:04"))
+	return lines
+}
+
+// statusLine The prompt being answered, or else the last message, or else
+// the keys.
+func (m *Model) statusLine(width int) string {
+	var label string
+	switch m.mode {
+	case modeSearch:
+		label = "Search: "
+	case modeDue:
+		label = "Due (e.g. 2026-11-05, fri or +3d; empty for none): "
+	case modeTag:
+		label = "Tag (tab to complete, empty for all): "
+	default:
+		if m.status != "" {
+			return fit(m.status, width)
+		}
+		return fit(help, width)
+	}
+
+	// Show the end of a long answer, with a caret after it.
+	prompt := label + string(m.input)
+	if m.status != "" {
+		prompt = m.status + "  " + prompt
+	}
+	runes := []rune(prompt)
+	if len(runes) > width-1 {
+		runes = runes[len(runes)-(width-1):]
+	}
+	return string(runes) + ansiReverse + " " + ansiReset + strings.Repeat(" ", width-1-len(runes))
+}
+
+// pack As many of fields on each line as fit in width, two spaces apart.
+func pack(fields []string, width int) []string {
+	var lines []string
+	line := ""
+	for _, field := range fields {
+		if line != "" && utf8.RuneCountInString(line)+2+utf8.RuneCountInString(field) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += "  "
+		}
+		line += field
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// fit s cut or padded to exactly width runes.
+func fit(s string, width int) string {
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		runes := []rune(s)
+		if width < 2 {
+			return string(runes[:width])
+		}
+		return string(runes[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+// wrap s split into lines of at most width runes, breaking between words
+// where it can.
+func wrap(s string, width int) []string {
+	runes := []rune(s)
+	var lines []string
+	for len(runes) > width {
+		cut := width
+		for i := width; i > 0; i-- {
+			if runes[i] == ' ' {
+				cut = i
+				break
+			}
+		}
+		lines = append(lines, string(runes[:cut]))
+		runes = runes[cut:]
+		for len(runes) > 0 && runes[0] == ' ' {
+			runes = runes[1:]
+		}
+	}
+	return append(lines, string(runes))
+}
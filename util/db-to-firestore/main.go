@@ -1,24 +0,0 @@
-package main
-
-import (
-	"github.com/tcooper-uk/go-todo/internal/storage"
-	"github.com/tcooper-uk/go-todo/internal/storage/db"
-)
-
-func main() {
-	dbPath, _ := storage.Setup(storage.DbMode)
-	firestorePath, _ := storage.Setup(storage.CloudMode)
-	firestore, _ := db.NewCloudStore(&db.CloudStoreConfig{
-		ProjectId: db.ProjectId,
-		KeyFile:   firestorePath,
-	})
-
-	liteStorage, _ := db.NewSQLLiteStorage(dbPath)
-	items := liteStorage.GetAllItems(storage.ListOptions{ShowDone: true})
-
-	firestore.DeleteAllItems()
-
-	for _, item := range items.Items {
-		firestore.AddItem(item)
-	}
-}